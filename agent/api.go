@@ -0,0 +1,340 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/inventory"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/localstore"
+	"github.com/zertifikat-waechter/agent/notify"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scheduler"
+)
+
+// redacted ersetzt Geheimnisse in API-Antworten
+const redacted = "[redacted]"
+
+// statusAPI stellt lokal lesbar bereit, was dieser Agent sieht (nur GET, Bearer-Token)
+type statusAPI struct {
+	token []byte
+	agent *agent
+	store *config.Store
+	db    *localstore.Store // nur im Standalone-Modus
+}
+
+// newStatusAPI liefert den Handler für /api/v1/; nil, wenn kein Token konfiguriert ist.
+// Mit lokaler Datenbank (Standalone) kommen die Abfragen unter /api/v1/local/ hinzu.
+func newStatusAPI(token string, a *agent, store *config.Store, db *localstore.Store) http.Handler {
+	if token == "" {
+		return nil
+	}
+	api := &statusAPI{token: []byte(token), agent: a, store: store, db: db}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/config", api.config)
+	mux.HandleFunc("GET /api/v1/targets", api.targets)
+	mux.HandleFunc("GET /api/v1/schedule", api.schedule)
+	mux.HandleFunc("GET /api/v1/discovery", api.discovery)
+	mux.HandleFunc("GET /api/v1/certificates", api.certificates)
+	mux.HandleFunc("GET /api/v1/progress", api.progress)
+	mux.HandleFunc("GET /api/v1/runs", api.runs)
+	mux.HandleFunc("GET /api/v1/sinks", api.sinks)
+	mux.HandleFunc("GET /api/v1/deliveries", api.deliveries)
+	mux.HandleFunc("GET /api/v1/notifications", api.notifications)
+	mux.HandleFunc("GET /api/v1/incidents", api.incidents)
+	mux.HandleFunc("GET /api/v1/tickets", api.tickets)
+	if db != nil {
+		mux.HandleFunc("GET /api/v1/local/assets", api.localAssets)
+		mux.HandleFunc("GET /api/v1/local/certificates", api.localCertificates)
+		mux.HandleFunc("GET /api/v1/local/expiring", api.localExpiring)
+		mux.HandleFunc("GET /api/v1/local/runs", api.localRuns)
+		mux.HandleFunc("GET /api/v1/local/history", api.localHistory)
+		mux.HandleFunc("GET /api/v1/local/changes", api.localChanges)
+		mux.HandleFunc("GET /api/v1/local/export", api.localExport)
+	}
+	return api.authorize(mux)
+}
+
+// authorize prüft das Bearer-Token (Vergleich in konstanter Zeit)
+func (api *statusAPI) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), api.token) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="agent"`)
+			writeAPIError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// config liefert die wirksame Konfiguration ohne Geheimnisse
+func (api *statusAPI) config(w http.ResponseWriter, r *http.Request) {
+	cfg := api.agent.cfg
+	snap := api.store.Current()
+	cfgView := map[string]interface{}{
+		"connector": map[string]interface{}{
+			"id":        cfg.ConnectorID,
+			"tenant_id": cfg.TenantID,
+			"name":      cfg.ConnectorName,
+		},
+		"backend": map[string]interface{}{
+			"url":             cfg.SupabaseURL,
+			"api_key":         redacted,
+			"connector_token": redacted,
+		},
+		"version":    snap.Version,
+		"source":     snap.Source,
+		"revision":   snap.Revision,
+		"applied_at": snap.AppliedAt.UTC(),
+		"settings":   snap.Settings,
+		"local": map[string]interface{}{
+			"health_check_port":        cfg.HealthCheckPort,
+			"shutdown_timeout":         cfg.ShutdownTimeout.String(),
+			"readiness_scan_intervals": cfg.ReadinessScanIntervals,
+			"override_file":            cfg.Override != nil,
+			"status_api_token":         redacted,
+		},
+		"standalone": cfg.Standalone,
+		"sinks":      api.agent.sinks.Configs(),
+		"build":      currentBuildInfo(),
+	}
+	if api.agent.notifier != nil {
+		cfgView["notifications"] = api.agent.notifier.Summary()
+	}
+	writeAPI(w, cfgView)
+}
+
+// targetStatus ist ein Endpoint mit Lebenszyklus, letztem Ergebnis und nächstem Scan
+type targetStatus struct {
+	Host                string                    `json:"host"`
+	Port                int                       `json:"port"`
+	Source              string                    `json:"source"` // "target" oder "discovery"
+	State               lifecycle.State           `json:"state,omitempty"`
+	ConsecutiveFailures int                       `json:"consecutive_failures"`
+	LastResult          *inventory.EndpointResult `json:"last_result,omitempty"`
+	Schedule            string                    `json:"schedule,omitempty"`
+	NextRun             *time.Time                `json:"next_run,omitempty"`
+}
+
+// targets liefert die konfigurierten Endpoints und alle weiteren gescannten (Discovery)
+func (api *statusAPI) targets(w http.ResponseWriter, r *http.Request) {
+	a := api.agent
+	snap := api.store.Current()
+
+	schedules := make(map[string]scheduler.Entry)
+	for _, e := range a.schedule.Entries() {
+		schedules[e.Target] = e
+	}
+
+	list := []targetStatus{}
+	seen := make(map[string]bool)
+	add := func(host string, port int, source string) {
+		k := host + ":" + strconv.Itoa(port)
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+
+		item := targetStatus{Host: host, Port: port, Source: source}
+		if rec, ok := a.assets.Get(host, port); ok {
+			item.State = rec.State
+			item.ConsecutiveFailures = rec.ConsecutiveFailures
+		}
+		if res, ok := a.inventory.Endpoint(host, port); ok {
+			item.LastResult = &res
+		}
+		if e, ok := schedules[host]; ok && !e.NextRun.IsZero() {
+			next := e.NextRun.UTC()
+			item.Schedule = e.Schedule
+			item.NextRun = &next
+		}
+		list = append(list, item)
+	}
+
+	for _, target := range snap.ScanTargets {
+		for _, port := range snap.ScanPorts {
+			add(target, port, "target")
+		}
+	}
+	for _, res := range a.inventory.Endpoints() {
+		add(res.Host, res.Port, "discovery")
+	}
+	writeAPI(w, list)
+}
+
+func (api *statusAPI) discovery(w http.ResponseWriter, r *http.Request) {
+	d := api.agent.inventory.Discovery()
+	if d == nil {
+		d = &inventory.Discovery{Hosts: []scanner.DiscoveryResult{}}
+	}
+	writeAPI(w, d)
+}
+
+// certificates liefert das Zertifikatsinventar, bald ablaufende zuerst
+func (api *statusAPI) certificates(w http.ResponseWriter, r *http.Request) {
+	type certificateStatus struct {
+		inventory.Certificate
+		DaysLeft int `json:"days_left"`
+	}
+	certs := api.agent.inventory.Certificates()
+	list := make([]certificateStatus, 0, len(certs))
+	for _, c := range certs {
+		list = append(list, certificateStatus{Certificate: c, DaysLeft: daysLeft(c.NotAfter)})
+	}
+	writeAPI(w, list)
+}
+
+func (api *statusAPI) progress(w http.ResponseWriter, r *http.Request) {
+	writeAPI(w, api.agent.progress.State())
+}
+
+// runs liefert die letzten Läufe (?limit=n, neuester zuerst)
+func (api *statusAPI) runs(w http.ResponseWriter, r *http.Request) {
+	runs := api.agent.inventory.Runs()
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 && limit < len(runs) {
+		runs = runs[:limit]
+	}
+	writeAPI(w, runs)
+}
+
+// sinks liefert die Zähler der Ergebnis-Sinks
+func (api *statusAPI) sinks(w http.ResponseWriter, r *http.Request) {
+	writeAPI(w, api.agent.sinks.Stats())
+}
+
+// schedule liefert den nächsten geplanten Scan pro Target
+func (api *statusAPI) schedule(w http.ResponseWriter, r *http.Request) {
+	writeAPI(w, api.agent.schedule.Entries())
+}
+
+// deliveries liefert die letzten Zustellversuche der Sinks (?sink=, ?limit=)
+func (api *statusAPI) deliveries(w http.ResponseWriter, r *http.Request) {
+	writeAPI(w, api.agent.sinks.Deliveries(r.URL.Query().Get("sink"), queryLimit(r, 50)))
+}
+
+// notifications liefert die Zähler der Benachrichtigungskanäle (leer ohne NOTIFY_FILE)
+func (api *statusAPI) notifications(w http.ResponseWriter, r *http.Request) {
+	if api.agent.notifier == nil {
+		writeAPI(w, []notify.ChannelStats{})
+		return
+	}
+	writeAPI(w, api.agent.notifier.Stats())
+}
+
+// incidents liefert die offenen Incidents in PagerDuty, Opsgenie bzw. Alertmanager
+func (api *statusAPI) incidents(w http.ResponseWriter, r *http.Request) {
+	if api.agent.notifier == nil {
+		writeAPI(w, []notify.IncidentStatus{})
+		return
+	}
+	writeAPI(w, api.agent.notifier.Incidents())
+}
+
+// tickets liefert die offenen Erneuerungs-Tickets in Jira bzw. ServiceNow
+func (api *statusAPI) tickets(w http.ResponseWriter, r *http.Request) {
+	if api.agent.notifier == nil {
+		writeAPI(w, []notify.TicketStatus{})
+		return
+	}
+	writeAPI(w, api.agent.notifier.Tickets())
+}
+
+// localAssets liefert alle gespeicherten Assets mit Lebenszyklus
+func (api *statusAPI) localAssets(w http.ResponseWriter, r *http.Request) {
+	assets, err := api.db.Assets()
+	writeLocal(w, assets, err)
+}
+
+// localCertificates liefert das gespeicherte Zertifikatsinventar mit Fundorten
+func (api *statusAPI) localCertificates(w http.ResponseWriter, r *http.Request) {
+	items, err := api.db.Inventory()
+	writeLocal(w, items, err)
+}
+
+// localExpiring liefert ausgelieferte Zertifikate, die in ?days=n Tagen ablaufen (Default 30)
+func (api *statusAPI) localExpiring(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "invalid days")
+			return
+		}
+		days = n
+	}
+	items, err := api.db.Expiring(time.Now().AddDate(0, 0, days))
+	writeLocal(w, items, err)
+}
+
+// localRuns liefert die gespeicherten Läufe (?limit=n, Default 50)
+func (api *statusAPI) localRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := api.db.Runs(queryLimit(r, 50))
+	writeLocal(w, runs, err)
+}
+
+// localHistory liefert die Scan-Ergebnisse eines Endpoints (?host=&port=, Default-Port 443)
+func (api *statusAPI) localHistory(w http.ResponseWriter, r *http.Request) {
+	ep, err := scanner.ParseEndpoint(r.URL.Query().Get("host"), 443)
+	if err == nil && r.URL.Query().Get("port") != "" {
+		ep.Port, err = strconv.Atoi(r.URL.Query().Get("port"))
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid host or port")
+		return
+	}
+	history, err := api.db.History(ep.Host, ep.Port, queryLimit(r, 100))
+	writeLocal(w, history, err)
+}
+
+// localChanges liefert die erkannten Zertifikatswechsel (?limit=n, Default 50)
+func (api *statusAPI) localChanges(w http.ResponseWriter, r *http.Request) {
+	changes, err := api.db.Changes(queryLimit(r, 50))
+	writeLocal(w, changes, err)
+}
+
+// localExport liefert den gesamten Datenbestand zum Übertragen ins Backend
+func (api *statusAPI) localExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="agent-export.json"`)
+	if err := api.db.Export(w); err != nil {
+		log.WithError(err).Warn("Export of local database failed")
+	}
+}
+
+// queryLimit liest ?limit=n (0 = alle)
+func queryLimit(r *http.Request, def int) int {
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit >= 0 {
+		return limit
+	}
+	return def
+}
+
+func writeLocal(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		log.WithError(err).Warn("Query of local database failed")
+		writeAPIError(w, http.StatusInternalServerError, "local database query failed")
+		return
+	}
+	writeAPI(w, v)
+}
+
+func writeAPI(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/localstore"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/supabase"
+)
+
+// backend nimmt die Ergebnisse der Scans auf: Supabase (supabase.Client) oder im
+// Standalone-Modus die lokale Datenbank (localstore.Store)
+type backend interface {
+	supabase.StateWriter
+
+	UpsertAsset(ctx context.Context, rec lifecycle.Record) (string, error)
+	UpdateAssetStatus(ctx context.Context, rec lifecycle.Record) (string, error)
+	SetAssetStatus(ctx context.Context, assetID, status string) error
+	ListAssets(ctx context.Context) ([]supabase.AssetData, error)
+	InsertAssetTransition(ctx context.Context, runID string, tr lifecycle.Transition) error
+
+	UpsertCertificate(ctx context.Context, cert *scanner.CertificateData) (string, error)
+	UpsertCertificateObservation(ctx context.Context, obs supabase.CertificateObservation) error
+	ListEndpointCertificates(ctx context.Context) ([]supabase.EndpointCertificate, error)
+	InsertCertificateChange(ctx context.Context, runID, assetID string, event rotation.Event) error
+	InsertCheck(ctx context.Context, check *supabase.CheckData) error
+
+	CreateScanRun(ctx context.Context, run scanrun.Summary) error
+	FinishScanRun(ctx context.Context, run scanrun.Summary) error
+	InsertScanOutcomes(ctx context.Context, runID string, outcomes []scanrun.Outcome) error
+	UpsertDiscoveryResult(ctx context.Context, result *scanner.DiscoveryResult) error
+
+	SendLog(ctx context.Context, connectorName, level, message string, metadata map[string]interface{}) error
+	MarkConnectorOffline(ctx context.Context, reason string) error
+
+	Health() supabase.BackendHealth
+	RequestStats() []supabase.RequestStats
+}
+
+var (
+	_ backend = (*supabase.Client)(nil)
+	_ backend = (*localstore.Store)(nil)
+)
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/notify"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// Exit-Codes der Subcommands
+const (
+	exitOK      = 0
+	exitFailure = 1 // Scan fehlgeschlagen, Policy verletzt bzw. Config ungültig
+	exitUsage   = 2
+)
+
+const usage = `Zertifikat-Wächter Agent
+
+Usage:
+  agent [run]                            Start the agent daemon (default)
+  agent scan [flags] host[:port] ...     One-shot certificate scan, no backend
+  agent discover --cidr CIDR [flags]     Discover hosts and TLS endpoints in a network
+  agent inspect [flags] host[:port]      Show chain, protocols and findings of an endpoint
+  agent check [flags] host[:port] ...    Check endpoints against a policy (CI gate)
+  agent config validate [flags] [file]   Validate local configuration or a remote config file
+  agent local <query> [flags]            Query the local database of a standalone agent
+  agent notify test [flags]              Send a sample notification to the configured channels
+  agent version [--json]                 Show version and build information
+
+Run 'agent <command> -h' for the flags of a command.
+`
+
+// runCLI führt ein Subcommand aus und liefert den Exit-Code.
+// "run" (oder kein Subcommand) startet den Daemon und kehrt nicht zurück.
+func runCLI(args []string) int {
+	cmd := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	} else if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		cmd = "help"
+	}
+
+	switch cmd {
+	case "run":
+		runAgent()
+		return exitOK
+	case "scan":
+		return cmdScan(args, os.Stdout)
+	case "discover":
+		return cmdDiscover(args, os.Stdout)
+	case "inspect":
+		return cmdInspect(args, os.Stdout)
+	case "check":
+		return cmdCheck(args, os.Stdout)
+	case "config":
+		return cmdConfig(args, os.Stdout)
+	case "local":
+		return cmdLocal(args, os.Stdout)
+	case "notify":
+		return cmdNotify(args, os.Stdout)
+	case "version":
+		return cmdVersion(args, os.Stdout)
+	case "help":
+		fmt.Print(usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		return exitUsage
+	}
+}
+
+// cliContext liefert einen Context, der bei SIGINT/SIGTERM abbricht
+func cliContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// cliLogger loggt für Subcommands lesbar auf stderr, damit stdout nur das Ergebnis enthält
+func cliLogger(verbose bool) *logrus.Logger {
+	l := logrus.New()
+	l.SetOutput(os.Stderr)
+	l.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	l.SetLevel(logrus.WarnLevel)
+	if verbose {
+		l.SetLevel(logrus.DebugLevel)
+	}
+	return l
+}
+
+// newFlagSet erstellt ein FlagSet mit einheitlicher Hilfe
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: agent %s\n\nFlags:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags wertet die Flags aus; -h liefert exitOK, andere Fehler exitUsage
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// parseEndpoints zerlegt die Endpoint-Argumente
+func parseEndpoints(args []string, defaultPort int) ([]scanner.Endpoint, error) {
+	endpoints := make([]scanner.Endpoint, 0, len(args))
+	for _, arg := range args {
+		for _, value := range strings.Split(arg, ",") {
+			if value = strings.TrimSpace(value); value == "" {
+				continue
+			}
+			ep, err := scanner.ParseEndpoint(value, defaultPort)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, ep)
+		}
+	}
+	return endpoints, nil
+}
+
+// scanResult ist das Ergebnis eines Endpoints bei "agent scan"
+type scanResult struct {
+	Host        string                   `json:"host"`
+	Port        int                      `json:"port"`
+	Certificate *scanner.CertificateData `json:"certificate,omitempty"`
+	DaysLeft    *int                     `json:"days_left,omitempty"`
+	ErrorClass  string                   `json:"error_class,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+}
+
+// newScanResult übernimmt Zertifikat oder klassifizierten Fehler
+func newScanResult(ep scanner.Endpoint, cert *scanner.CertificateData, err error) scanResult {
+	res := scanResult{Host: ep.Host, Port: ep.Port, Certificate: cert}
+	if err != nil {
+		res.ErrorClass = string(scanner.ClassUnknown)
+		res.Error = err.Error()
+		var scanErr *scanner.ScanError
+		if errors.As(err, &scanErr) {
+			res.ErrorClass = string(scanErr.Class)
+			res.Error = scanErr.Detail()
+		}
+		return res
+	}
+	days := daysLeft(cert.NotAfter)
+	res.DaysLeft = &days
+	return res
+}
+
+func daysLeft(notAfter time.Time) int {
+	return int(time.Until(notAfter).Hours() / 24)
+}
+
+func cmdScan(args []string, out io.Writer) int {
+	fs := newFlagSet("scan", "scan [flags] host[:port] ...")
+	port := fs.Int("port", 443, "port for endpoints given without port")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout per connection")
+	concurrency := fs.Int("concurrency", 10, "endpoints scanned in parallel")
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	verbose := fs.Bool("v", false, "verbose logging on stderr")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	endpoints, err := parseEndpoints(fs.Args(), *port)
+	if err != nil || len(endpoints) == 0 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fs.Usage()
+		return exitUsage
+	}
+
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	certScanner := scanner.NewScanner(*timeout, cliLogger(*verbose))
+	limits := scanner.PoolLimits{Global: *concurrency, PerHost: 2}
+	results, started := scanner.RunPool(ctx, endpoints, limits, func(ctx context.Context, ep scanner.Endpoint) scanResult {
+		cert, err := certScanner.ScanHost(ctx, ep.Host, ep.Port)
+		return newScanResult(ep, cert, err)
+	})
+
+	failed := 0
+	list := make([]scanResult, 0, len(results))
+	for i, res := range results {
+		if !started[i] {
+			continue
+		}
+		if res.Error != "" {
+			failed++
+		}
+		list = append(list, res)
+	}
+
+	if *jsonOut {
+		writeJSON(out, list)
+	} else {
+		printScanResults(out, list)
+	}
+
+	if failed > 0 || ctx.Err() != nil {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// printScanResults gibt die Scan-Ergebnisse als Tabelle aus
+func printScanResults(out io.Writer, results []scanResult) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tSTATUS\tSUBJECT\tISSUER\tEXPIRES\tDAYS")
+	for _, res := range results {
+		endpoint := fmt.Sprintf("%s:%d", res.Host, res.Port)
+		if res.Certificate == nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t\t\t\n", endpoint, res.ErrorClass, res.Error)
+			continue
+		}
+		cert := res.Certificate
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", endpoint, expiryStatus(*res.DaysLeft), cert.SubjectCN, cert.Issuer, cert.NotAfter.Format("2006-01-02"), *res.DaysLeft)
+	}
+	tw.Flush()
+}
+
+// expiryStatus fasst die Restlaufzeit zusammen (gleiche Schwellen wie die checks-Tabelle)
+func expiryStatus(days int) string {
+	switch {
+	case days < 0:
+		return "expired"
+	case days < 30:
+		return "warning"
+	}
+	return "ok"
+}
+
+func cmdDiscover(args []string, out io.Writer) int {
+	fs := newFlagSet("discover", "discover --cidr CIDR[,CIDR...] [flags]")
+	cidrs := fs.String("cidr", "", "networks to scan, comma separated (e.g. 10.0.1.0/24); empty = local networks")
+	timeout := fs.Duration("timeout", 2*time.Second, "timeout per port")
+	concurrency := fs.Int("concurrency", 100, "hosts checked in parallel")
+	certs := fs.Bool("certs", true, "read certificates on TLS ports of discovered hosts")
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	verbose := fs.Bool("v", false, "verbose logging on stderr")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	logger := cliLogger(*verbose)
+	networkScanner := scanner.NewNetworkScanner(*timeout, logger)
+	networkScanner.SetLimits(*concurrency, 10, 0)
+
+	var hosts []scanner.DiscoveryResult
+	var err error
+	if *cidrs == "" {
+		hosts, err = networkScanner.DiscoverLocalNetwork(ctx, nil)
+	} else {
+		hosts, err = networkScanner.DiscoverCIDR(ctx, strings.Split(*cidrs, ","), nil)
+	}
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintln(os.Stderr, "discovery failed:", err)
+		return exitFailure
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].IPAddress < hosts[j].IPAddress })
+
+	// Zertifikate auf den TLS-Ports der gefundenen Hosts
+	type discovered struct {
+		scanner.DiscoveryResult
+		Certificates []scanResult `json:"certificates,omitempty"`
+	}
+	list := make([]discovered, 0, len(hosts))
+	certScanner := scanner.NewScanner(*timeout, logger)
+	for _, host := range hosts {
+		item := discovered{DiscoveryResult: host}
+		if *certs {
+			for _, port := range tlsPorts(host.OpenPorts) {
+				ep := scanner.Endpoint{Host: host.IPAddress, Port: port}
+				cert, err := certScanner.ScanHost(ctx, ep.Host, ep.Port)
+				item.Certificates = append(item.Certificates, newScanResult(ep, cert, err))
+			}
+		}
+		list = append(list, item)
+	}
+
+	if *jsonOut {
+		writeJSON(out, list)
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tOPEN PORTS\tSERVICES\tCERTIFICATES")
+	for _, item := range list {
+		var certInfo []string
+		for _, res := range item.Certificates {
+			if res.Certificate != nil {
+				certInfo = append(certInfo, fmt.Sprintf("%d: %s (%dd)", res.Port, res.Certificate.SubjectCN, *res.DaysLeft))
+			} else {
+				certInfo = append(certInfo, fmt.Sprintf("%d: %s", res.Port, res.ErrorClass))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.IPAddress, joinInts(item.OpenPorts), strings.Join(item.Services, ", "), strings.Join(certInfo, "; "))
+	}
+	tw.Flush()
+	fmt.Fprintf(out, "\n%d hosts found\n", len(list))
+	return exitOK
+}
+
+func cmdInspect(args []string, out io.Writer) int {
+	fs := newFlagSet("inspect", "inspect [flags] host[:port]")
+	port := fs.Int("port", 443, "port if not given in the endpoint")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout per connection")
+	jsonOut := fs.Bool("json", false, "print the inspection as JSON")
+	verbose := fs.Bool("v", false, "verbose logging on stderr")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	endpoints, err := parseEndpoints(fs.Args(), *port)
+	if err != nil || len(endpoints) != 1 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		fs.Usage()
+		return exitUsage
+	}
+	ep := endpoints[0]
+
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	insp, err := scanner.NewScanner(*timeout, cliLogger(*verbose)).Inspect(ctx, ep.Host, ep.Port)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:%d: %v\n", ep.Host, ep.Port, err)
+		return exitFailure
+	}
+
+	if *jsonOut {
+		writeJSON(out, insp)
+		return exitOK
+	}
+	printInspection(out, insp)
+	return exitOK
+}
+
+// printInspection gibt eine Inspektion lesbar aus
+func printInspection(out io.Writer, insp *scanner.Inspection) {
+	cert := insp.Certificate
+	fmt.Fprintf(out, "Endpoint     %s:%d\n", insp.Host, insp.Port)
+	fmt.Fprintf(out, "Negotiated   %s, %s\n", insp.NegotiatedVersion, insp.CipherSuite)
+	fmt.Fprintf(out, "Subject      %s\n", cert.SubjectCN)
+	if len(cert.SAN) > 0 {
+		fmt.Fprintf(out, "SAN          %s\n", strings.Join(cert.SAN, ", "))
+	}
+	fmt.Fprintf(out, "Issuer       %s\n", cert.Issuer)
+	fmt.Fprintf(out, "Valid        %s → %s (%d days left)\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"), daysLeft(cert.NotAfter))
+	fmt.Fprintf(out, "Key          %s %d bit, %s\n", cert.KeyAlgorithm, cert.KeySize, cert.SignatureAlg)
+	fmt.Fprintf(out, "Trusted      %t (self-signed: %t)\n", cert.IsTrusted, cert.IsSelfSigned)
+	fmt.Fprintf(out, "Fingerprint  %s\n", cert.Fingerprint)
+
+	fmt.Fprintln(out, "\nChain:")
+	for i, c := range insp.Chain {
+		fmt.Fprintf(out, "  %d  %s\n", i, c.Subject)
+		fmt.Fprintf(out, "     issuer  %s\n", c.Issuer)
+		fmt.Fprintf(out, "     valid   %s → %s, %s %d bit, CA: %t\n", c.NotBefore.Format("2006-01-02"), c.NotAfter.Format("2006-01-02"), c.KeyAlgorithm, c.KeySize, c.IsCA)
+	}
+
+	fmt.Fprintln(out, "\nProtocols:")
+	for _, p := range insp.Protocols {
+		status := "no"
+		if p.Supported {
+			status = "yes  " + p.CipherSuite
+		}
+		fmt.Fprintf(out, "  %-8s %s\n", p.Version, status)
+	}
+
+	fmt.Fprintln(out, "\nFindings:")
+	if len(insp.Findings) == 0 {
+		fmt.Fprintln(out, "  none")
+	}
+	for _, f := range insp.Findings {
+		fmt.Fprintf(out, "  [%-8s] %-18s %s\n", f.Severity, f.Code, f.Message)
+	}
+}
+
+func cmdConfig(args []string, out io.Writer) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: agent config validate [flags] [file]")
+		return exitUsage
+	}
+
+	fs := newFlagSet("config validate", "config validate [flags] [file]\n\nValidates the environment (incl. CONFIG_OVERRIDE_FILE, SINKS_FILE and NOTIFY_FILE) or, if given,\na remote config file (connectors.config) against the schema")
+	jsonOut := fs.Bool("json", false, "print the resolved settings as JSON")
+	if code, ok := parseFlags(fs, args[1:]); !ok {
+		return code
+	}
+
+	cfg, err := config.LoadLocal()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid environment:", err)
+		return exitFailure
+	}
+
+	// Optional: Remote-Config aus Datei wie vom Backend anwenden
+	var remote *config.RemoteConfig
+	if fs.NArg() > 0 {
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: invalid JSON: %v\n", fs.Arg(0), err)
+			return exitFailure
+		}
+		var problems []string
+		if remote, problems = config.ParseRemote(raw); len(problems) > 0 {
+			fmt.Fprintf(os.Stderr, "%s: schema validation failed:\n", fs.Arg(0))
+			for _, p := range problems {
+				fmt.Fprintln(os.Stderr, "  -", p)
+			}
+			return exitFailure
+		}
+	}
+
+	settings := config.Resolve(cfg.BaseSettings(), remote, cfg.Override)
+	if err := settings.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		return exitFailure
+	}
+
+	var sinks []sink.Config
+	if cfg.SinksFile != "" {
+		if sinks, err = sink.LoadConfig(cfg.SinksFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+	var notifications *notify.Config
+	if cfg.NotifyFile != "" {
+		if notifications, err = notify.Load(cfg.NotifyFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+
+	if *jsonOut {
+		writeJSON(out, settings)
+		return exitOK
+	}
+	fmt.Fprintln(out, "configuration valid")
+	fmt.Fprintf(out, "  targets      %s\n", strings.Join(settings.ScanTargets, ", "))
+	fmt.Fprintf(out, "  ports        %s\n", joinInts(settings.ScanPorts))
+	fmt.Fprintf(out, "  interval     %s (timeout %s)\n", settings.ScanInterval, settings.ScanTimeout)
+	fmt.Fprintf(out, "  discovery    %s\n", settings.DiscoveryMode)
+	fmt.Fprintf(out, "  schedules    %d groups, splay %s, jitter %s, catch-up %s\n", len(settings.Schedules), settings.ScheduleSplay, settings.ScheduleJitter, settings.ScheduleCatchUp)
+	for _, c := range sinks {
+		fmt.Fprintf(out, "  sink         %s (%s)\n", c.Name, c.Type)
+	}
+	if notifications != nil {
+		for _, c := range notifications.Channels {
+			fmt.Fprintf(out, "  channel      %s (%s, %s)\n", c.Name, c.Type, c.Language)
+		}
+		for _, r := range notifications.Rules {
+			fmt.Fprintf(out, "  rule         %s: %s -> %s\n", r.Name, strings.Join(r.Events, ", "), strings.Join(r.Channels, ", "))
+		}
+	}
+	return exitOK
+}
+
+func cmdVersion(args []string, out io.Writer) int {
+	fs := newFlagSet("version", "version [--json]")
+	jsonOut := fs.Bool("json", false, "print build information as JSON")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	info := currentBuildInfo()
+	if *jsonOut {
+		writeJSON(out, info)
+		return exitOK
+	}
+	fmt.Fprintf(out, "agent %s\n", info.Version)
+	if info.Commit != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(out, "  commit      %s%s\n", info.Commit, modified)
+	}
+	if info.BuildDate != "" {
+		fmt.Fprintf(out, "  built       %s\n", info.BuildDate)
+	}
+	fmt.Fprintf(out, "  go          %s\n", info.GoVersion)
+	fmt.Fprintf(out, "  platform    %s\n", info.Platform)
+	return exitOK
+}
+
+func writeJSON(out io.Writer, v interface{}) {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -9,20 +9,103 @@ import (
 )
 
 type Config struct {
-	SupabaseURL      string
-	SupabaseAPIKey   string
-	ConnectorToken   string // Token für Connector-Registration
-	ConnectorName    string
-	TenantID         string // Wird nach Registration gesetzt
-	ConnectorID      string // Wird nach Registration gesetzt
-	ScanTargets      []string
-	ScanPorts        []int
-	ScanInterval     time.Duration
-	ScanTimeout      time.Duration
-	HealthCheckPort  string
+	SupabaseURL     string
+	SupabaseAPIKey  string
+	ConnectorToken  string // Token für Connector-Registration
+	ConnectorName   string
+	TenantID        string // Wird nach Registration gesetzt
+	ConnectorID     string // Wird nach Registration gesetzt
+	ScanTargets     []string
+	ScanPorts       []int
+	ScanInterval    time.Duration
+	ScanTimeout     time.Duration
+	HealthCheckPort string
+	ShutdownTimeout time.Duration // Frist für ausstehende Schreibzugriffe beim Beenden
+
+	// Readiness: nicht ready, wenn ein Target seit so vielen Intervallen nicht gescannt wurde
+	ReadinessScanIntervals int
+	// Bearer-Token für die lokale Status-API (leer = API deaktiviert)
+	StatusAPIToken string
+
+	// Standalone: Ergebnisse in eine lokale Datenbank statt an Supabase
+	Standalone          bool
+	StandaloneDB        string
+	StandaloneRetention time.Duration // Verlauf (Checks, Läufe, Logs) wird danach gelöscht
+
+	// JSON-Datei mit zusätzlichen Ergebnis-Sinks (leer = keine)
+	SinksFile string
+
+	// JSON-Datei mit Benachrichtigungskanälen und Regeln (leer = keine Benachrichtigungen)
+	NotifyFile string
+
+	// Zertifikate gegen die Sperrlisten (CRL) ihres Ausstellers prüfen
+	RevocationCheck bool
+
+	DiscoveryMode        string
+	DiscoveryConcurrency int
+	PortConcurrency      int
+	ConnectionsPerSecond int
+	ScanConcurrency      int
+	ScanHostConcurrency  int
+	ScanSequential       bool
+	ScheduleSplay        time.Duration
+	ScheduleJitter       time.Duration
+	ScheduleCatchUp      string
+	AdaptiveSchedule     bool
+	AdaptiveMinInterval  time.Duration
+	AdaptiveMaxInterval  time.Duration
+	LogLevel             string
+
+	AssetDegradedAfter    int
+	AssetUnreachableAfter int
+	AssetRetireAfter      time.Duration
+
+	// Override aus CONFIG_OVERRIDE_FILE - hat Vorrang vor der Backend-Config
+	Override *RemoteConfig
+}
+
+// BaseSettings liefert die Settings aus Umgebungsvariablen bzw. Defaults
+// (unterste Ebene der Config-Auflösung)
+func (c *Config) BaseSettings() Settings {
+	return Settings{
+		ScanTargets:          c.ScanTargets,
+		ScanPorts:            c.ScanPorts,
+		ScanInterval:         c.ScanInterval,
+		ScanTimeout:          c.ScanTimeout,
+		DiscoveryMode:        c.DiscoveryMode,
+		DiscoveryConcurrency: c.DiscoveryConcurrency,
+		PortConcurrency:      c.PortConcurrency,
+		ConnectionsPerSecond: c.ConnectionsPerSecond,
+		ScanConcurrency:      c.ScanConcurrency,
+		ScanHostConcurrency:  c.ScanHostConcurrency,
+		ScanSequential:       c.ScanSequential,
+		ScheduleSplay:        c.ScheduleSplay,
+		ScheduleJitter:       c.ScheduleJitter,
+		ScheduleCatchUp:      c.ScheduleCatchUp,
+		AdaptiveSchedule:     c.AdaptiveSchedule,
+		AdaptiveMinInterval:  c.AdaptiveMinInterval,
+		AdaptiveMaxInterval:  c.AdaptiveMaxInterval,
+		LogLevel:             c.LogLevel,
+
+		AssetDegradedAfter:    c.AssetDegradedAfter,
+		AssetUnreachableAfter: c.AssetUnreachableAfter,
+		AssetRetireAfter:      c.AssetRetireAfter,
+	}.clone()
 }
 
+// DefaultStandaloneDB ist der Pfad der lokalen Datenbank, wenn STANDALONE_DB fehlt
+const DefaultStandaloneDB = "data/agent.db"
+
 func Load() (*Config, error) {
+	cfg, err := LoadLocal()
+	if err != nil {
+		return nil, err
+	}
+	// Standalone braucht kein Backend
+	if cfg.Standalone {
+		return cfg, nil
+	}
+
 	supabaseURL := os.Getenv("SUPABASE_URL")
 	if supabaseURL == "" {
 		return nil, fmt.Errorf("SUPABASE_URL is required")
@@ -42,6 +125,15 @@ func Load() (*Config, error) {
 		}
 	}
 
+	cfg.SupabaseURL = supabaseURL
+	cfg.SupabaseAPIKey = supabaseAPIKey
+	cfg.ConnectorToken = connectorToken
+	return cfg, nil
+}
+
+// LoadLocal liest alle Einstellungen außer den Backend-Zugangsdaten
+// (für CLI-Befehle, die ohne Supabase laufen)
+func LoadLocal() (*Config, error) {
 	connectorName := os.Getenv("CONNECTOR_NAME")
 	if connectorName == "" {
 		connectorName = "agent-" + time.Now().Format("20060102-150405")
@@ -98,17 +190,199 @@ func Load() (*Config, error) {
 		healthCheckPort = "8080"
 	}
 
+	shutdownTimeoutSec, err := intEnv("SHUTDOWN_TIMEOUT", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	readinessIntervals, err := intEnv("READINESS_SCAN_INTERVALS", 3)
+	if err != nil {
+		return nil, err
+	}
+	if readinessIntervals < 2 {
+		return nil, fmt.Errorf("invalid READINESS_SCAN_INTERVALS: %d (minimum 2)", readinessIntervals)
+	}
+
+	statusAPIToken := os.Getenv("STATUS_API_TOKEN")
+	if statusAPIToken != "" && len(statusAPIToken) < 16 {
+		return nil, fmt.Errorf("STATUS_API_TOKEN too short (minimum 16 characters)")
+	}
+
+	standalone, err := boolEnv("STANDALONE", false)
+	if err != nil {
+		return nil, err
+	}
+	standaloneDB := os.Getenv("STANDALONE_DB")
+	if standaloneDB == "" {
+		standaloneDB = DefaultStandaloneDB
+	}
+	retentionDays, err := intEnv("STANDALONE_RETENTION_DAYS", 90)
+	if err != nil {
+		return nil, err
+	}
+	if retentionDays < 1 {
+		return nil, fmt.Errorf("invalid STANDALONE_RETENTION_DAYS: %d (minimum 1)", retentionDays)
+	}
+
+	sinksFile := os.Getenv("SINKS_FILE")
+	notifyFile := os.Getenv("NOTIFY_FILE")
+	revocationCheck, err := boolEnv("REVOCATION_CHECK", false)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryMode := strings.ToLower(os.Getenv("DISCOVERY_MODE"))
+	if discoveryMode == "" {
+		discoveryMode = "auto"
+	}
+
+	discoveryConcurrency, err := intEnv("DISCOVERY_CONCURRENCY", 100)
+	if err != nil {
+		return nil, err
+	}
+	portConcurrency, err := intEnv("PORT_CONCURRENCY", 10)
+	if err != nil {
+		return nil, err
+	}
+	connectionsPerSecond, err := intEnv("CONNECTIONS_PER_SECOND", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Worker-Pool für feste Targets
+	scanConcurrency, err := intEnv("SCAN_CONCURRENCY", 20)
+	if err != nil {
+		return nil, err
+	}
+	scanHostConcurrency, err := intEnv("SCAN_HOST_CONCURRENCY", 2)
+	if err != nil {
+		return nil, err
+	}
+	scanSequential, err := boolEnv("SCAN_SEQUENTIAL", false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scheduler: Versatz pro Agent, Zufalls-Jitter, Verhalten nach Downtime
+	splaySec, err := intEnv("SCAN_SPLAY", 60)
+	if err != nil {
+		return nil, err
+	}
+	jitterSec, err := intEnv("SCAN_JITTER", 0)
+	if err != nil {
+		return nil, err
+	}
+	catchUp := strings.ToLower(os.Getenv("SCAN_CATCH_UP"))
+	if catchUp == "" {
+		catchUp = "once"
+	}
+
+	// Adaptives Intervall nach Ablaufdatum: Unter- und Obergrenze in Sekunden
+	adaptive, err := boolEnv("ADAPTIVE_SCHEDULE", true)
+	if err != nil {
+		return nil, err
+	}
+	adaptiveMinSec, err := intEnv("ADAPTIVE_MIN_INTERVAL", 900)
+	if err != nil {
+		return nil, err
+	}
+	adaptiveMaxSec, err := intEnv("ADAPTIVE_MAX_INTERVAL", 86400)
+	if err != nil {
+		return nil, err
+	}
+
+	// Asset-Lebenszyklus: active → degraded → unreachable → retired
+	degradedAfter, err := intEnv("ASSET_DEGRADED_AFTER", 1)
+	if err != nil {
+		return nil, err
+	}
+	unreachableAfter, err := intEnv("ASSET_UNREACHABLE_AFTER", 3)
+	if err != nil {
+		return nil, err
+	}
+	retireAfterDays, err := intEnv("ASSET_RETIRE_AFTER_DAYS", 30)
+	if err != nil {
+		return nil, err
+	}
+
+	logLevel := strings.ToLower(os.Getenv("LOG_LEVEL"))
+	if logLevel == "" {
+		logLevel = "info"
+	}
+
+	var override *RemoteConfig
+	if path := os.Getenv("CONFIG_OVERRIDE_FILE"); path != "" {
+		override, err = LoadOverride(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Config{
-		SupabaseURL:     supabaseURL,
-		SupabaseAPIKey:  supabaseAPIKey,
-		ConnectorToken:  connectorToken,
 		ConnectorName:   connectorName,
 		ScanTargets:     scanTargets,
 		ScanPorts:       scanPorts,
 		ScanInterval:    time.Duration(intervalSec) * time.Second,
 		ScanTimeout:     time.Duration(timeoutSec) * time.Second,
 		HealthCheckPort: healthCheckPort,
+		ShutdownTimeout: time.Duration(shutdownTimeoutSec) * time.Second,
+
+		ReadinessScanIntervals: readinessIntervals,
+		StatusAPIToken:         statusAPIToken,
+
+		Standalone:          standalone,
+		StandaloneDB:        standaloneDB,
+		StandaloneRetention: time.Duration(retentionDays) * 24 * time.Hour,
+
+		SinksFile:  sinksFile,
+		NotifyFile: notifyFile,
+
+		RevocationCheck: revocationCheck,
+
+		DiscoveryMode:        discoveryMode,
+		DiscoveryConcurrency: discoveryConcurrency,
+		PortConcurrency:      portConcurrency,
+		ConnectionsPerSecond: connectionsPerSecond,
+		ScanConcurrency:      scanConcurrency,
+		ScanHostConcurrency:  scanHostConcurrency,
+		ScanSequential:       scanSequential,
+		ScheduleSplay:        time.Duration(splaySec) * time.Second,
+		ScheduleJitter:       time.Duration(jitterSec) * time.Second,
+		ScheduleCatchUp:      catchUp,
+		AdaptiveSchedule:     adaptive,
+		AdaptiveMinInterval:  time.Duration(adaptiveMinSec) * time.Second,
+		AdaptiveMaxInterval:  time.Duration(adaptiveMaxSec) * time.Second,
+		LogLevel:             logLevel,
+		Override:             override,
+
+		AssetDegradedAfter:    degradedAfter,
+		AssetUnreachableAfter: unreachableAfter,
+		AssetRetireAfter:      time.Duration(retireAfterDays) * 24 * time.Hour,
 	}, nil
 }
 
+// intEnv liest eine Ganzzahl aus der Umgebung mit Default-Wert
+func intEnv(name string, def int) (int, error) {
+	str := os.Getenv(name)
+	if str == "" {
+		return def, nil
+	}
+	val, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, str)
+	}
+	return val, nil
+}
 
+// boolEnv liest einen Wahrheitswert aus der Umgebung mit Default-Wert
+func boolEnv(name string, def bool) (bool, error) {
+	str := os.Getenv(name)
+	if str == "" {
+		return def, nil
+	}
+	val, err := strconv.ParseBool(strings.TrimSpace(str))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", name, str)
+	}
+	return val, nil
+}
@@ -0,0 +1,302 @@
+package config
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// SchemaVersion ist die höchste vom Agent verstandene Version von connectors.config
+const SchemaVersion = 1
+
+//go:embed schema.json
+var schemaJSON string
+
+var remoteSchema = jsonschema.MustCompileString("connector-config.json", schemaJSON)
+
+// RemoteConfig ist das typisierte Schema von connectors.config.
+// Nicht gesetzte Felder (nil) übernehmen den Wert der nächstniedrigeren Ebene.
+type RemoteConfig struct {
+	SchemaVersion int        `json:"schema_version,omitempty"`
+	ScanTargets   []string   `json:"scan_targets,omitempty"`
+	ScanPorts     []int      `json:"scan_ports,omitempty"`
+	ScanInterval  *int       `json:"scan_interval,omitempty"` // Sekunden
+	ScanTimeout   *int       `json:"scan_timeout,omitempty"`  // Sekunden
+	DiscoveryMode *string    `json:"discovery_mode,omitempty"`
+	RateLimit     *RateLimit `json:"rate_limit,omitempty"`
+	LogLevel      *string    `json:"log_level,omitempty"`
+
+	AssetLifecycle *AssetLifecycle `json:"asset_lifecycle,omitempty"`
+	Schedule       *Schedule       `json:"schedule,omitempty"`
+}
+
+// RateLimit begrenzt die Last, die der Agent im Netzwerk erzeugt
+type RateLimit struct {
+	DiscoveryConcurrency *int `json:"discovery_concurrency,omitempty"`
+	PortConcurrency      *int `json:"port_concurrency,omitempty"`
+	ConnectionsPerSecond *int `json:"connections_per_second,omitempty"`
+
+	ScanConcurrency     *int  `json:"scan_concurrency,omitempty"`
+	ScanHostConcurrency *int  `json:"scan_host_concurrency,omitempty"`
+	ScanSequential      *bool `json:"scan_sequential,omitempty"`
+}
+
+// AssetLifecycle steuert, wann fehlschlagende Endpoints herabgestuft werden
+type AssetLifecycle struct {
+	DegradedAfter    *int `json:"degraded_after,omitempty"`
+	UnreachableAfter *int `json:"unreachable_after,omitempty"`
+	RetireAfterDays  *int `json:"retire_after_days,omitempty"`
+}
+
+// Schedule steuert, wann welche Targets gescannt werden
+type Schedule struct {
+	Splay   *int            `json:"splay,omitempty"`  // Sekunden
+	Jitter  *int            `json:"jitter,omitempty"` // Sekunden
+	CatchUp *string         `json:"catch_up,omitempty"`
+	Groups  []ScheduleEntry `json:"groups,omitempty"`
+
+	Adaptive *AdaptiveSchedule `json:"adaptive,omitempty"`
+}
+
+// AdaptiveSchedule richtet das Scan-Intervall nach dem Ablaufdatum des Zertifikats
+type AdaptiveSchedule struct {
+	Enabled     *bool `json:"enabled,omitempty"`
+	MinInterval *int  `json:"min_interval,omitempty"` // Sekunden
+	MaxInterval *int  `json:"max_interval,omitempty"` // Sekunden
+}
+
+// ScheduleEntry ist ein Zeitplan für einzelne Targets oder eine Gruppe
+type ScheduleEntry struct {
+	Name     string   `json:"name"`
+	Targets  []string `json:"targets"`
+	Interval *int     `json:"interval,omitempty"` // Sekunden
+	Cron     string   `json:"cron,omitempty"`
+	Jitter   *int     `json:"jitter,omitempty"` // Sekunden
+}
+
+// volatileKeys werden von der UI bzw. älteren Agents geschrieben und sind keine Einstellungen
+var volatileKeys = map[string]bool{
+	"trigger_scan":  true,
+	"scanning":      true,
+	"scan_progress": true,
+	"last_scan":     true,
+}
+
+// RemoteRevision berechnet einen stabilen Hash über die Einstellungen der Backend-Config
+func RemoteRevision(raw map[string]interface{}) string {
+	filtered := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		if !volatileKeys[k] {
+			filtered[k] = v
+		}
+	}
+
+	// json.Marshal sortiert Map-Keys → deterministisch
+	data, _ := json.Marshal(filtered)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8])
+}
+
+// UnsupportedSchemaError meldet eine Config mit neuerer schema_version, als der Agent kennt
+type UnsupportedSchemaError struct {
+	Version int
+}
+
+func (e *UnsupportedSchemaError) Error() string {
+	return fmt.Sprintf("unsupported schema_version %d (agent supports up to %d, update the agent)", e.Version, SchemaVersion)
+}
+
+// CheckSchemaVersion lehnt Configs mit einer neueren schema_version ab; fehlende oder
+// ungültige Werte prüft das JSON-Schema
+func CheckSchemaVersion(raw map[string]interface{}) error {
+	v, ok := raw["schema_version"].(float64)
+	if ok && v == float64(int(v)) && int(v) > SchemaVersion {
+		return &UnsupportedSchemaError{Version: int(v)}
+	}
+	return nil
+}
+
+// ParseRemote validiert raw gegen das JSON-Schema und liefert die typisierte Config.
+// Bei ungültiger Config werden alle gefundenen Fehler zurückgegeben. Felder mit null
+// gelten als nicht gesetzt (create_connector_with_token schreibt z.B. "scan_targets": null).
+func ParseRemote(raw map[string]interface{}) (*RemoteConfig, []string) {
+	if raw == nil {
+		return &RemoteConfig{}, nil
+	}
+	if err := CheckSchemaVersion(raw); err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	set := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		if v != nil {
+			set[k] = v
+		}
+	}
+	raw = set
+
+	if err := remoteSchema.Validate(raw); err != nil {
+		var ve *jsonschema.ValidationError
+		if errors.As(err, &ve) {
+			return nil, validationMessages(ve)
+		}
+		return nil, []string{err.Error()}
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var rc RemoteConfig
+	if err := json.Unmarshal(data, &rc); err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	return &rc, nil
+}
+
+// validationMessages sammelt die eigentlichen Ursachen (Blätter) eines Validierungsfehlers
+func validationMessages(ve *jsonschema.ValidationError) []string {
+	if len(ve.Causes) == 0 {
+		location := ve.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		return []string{fmt.Sprintf("%s: %s", location, ve.Message)}
+	}
+
+	messages := []string{}
+	for _, cause := range ve.Causes {
+		messages = append(messages, validationMessages(cause)...)
+	}
+	return messages
+}
+
+// LoadOverride liest eine lokale Override-Datei im selben Schema wie connectors.config
+func LoadOverride(path string) (*RemoteConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read override file: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse override file: %w", err)
+	}
+
+	rc, errs := ParseRemote(raw)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid override file: %s", strings.Join(errs, "; "))
+	}
+	return rc, nil
+}
+
+// Resolve berechnet die effektiven Settings nach der Regel
+// lokaler Override > Remote-Config > Umgebung/Defaults
+func Resolve(base Settings, remote, override *RemoteConfig) Settings {
+	s := base.clone()
+	remote.applyTo(&s)
+	override.applyTo(&s)
+	return s
+}
+
+// applyTo überträgt alle gesetzten Felder auf die Settings
+func (rc *RemoteConfig) applyTo(s *Settings) {
+	if rc == nil {
+		return
+	}
+
+	if len(rc.ScanTargets) > 0 {
+		s.ScanTargets = append([]string(nil), rc.ScanTargets...)
+	}
+	if len(rc.ScanPorts) > 0 {
+		s.ScanPorts = append([]int(nil), rc.ScanPorts...)
+	}
+	if rc.ScanInterval != nil {
+		s.ScanInterval = time.Duration(*rc.ScanInterval) * time.Second
+	}
+	if rc.ScanTimeout != nil {
+		s.ScanTimeout = time.Duration(*rc.ScanTimeout) * time.Second
+	}
+	if rc.DiscoveryMode != nil {
+		s.DiscoveryMode = *rc.DiscoveryMode
+	}
+	if rc.LogLevel != nil {
+		s.LogLevel = *rc.LogLevel
+	}
+	if rl := rc.RateLimit; rl != nil {
+		if rl.DiscoveryConcurrency != nil {
+			s.DiscoveryConcurrency = *rl.DiscoveryConcurrency
+		}
+		if rl.PortConcurrency != nil {
+			s.PortConcurrency = *rl.PortConcurrency
+		}
+		if rl.ConnectionsPerSecond != nil {
+			s.ConnectionsPerSecond = *rl.ConnectionsPerSecond
+		}
+		if rl.ScanConcurrency != nil {
+			s.ScanConcurrency = *rl.ScanConcurrency
+		}
+		if rl.ScanHostConcurrency != nil {
+			s.ScanHostConcurrency = *rl.ScanHostConcurrency
+		}
+		if rl.ScanSequential != nil {
+			s.ScanSequential = *rl.ScanSequential
+		}
+	}
+	if sc := rc.Schedule; sc != nil {
+		if sc.Splay != nil {
+			s.ScheduleSplay = time.Duration(*sc.Splay) * time.Second
+		}
+		if sc.Jitter != nil {
+			s.ScheduleJitter = time.Duration(*sc.Jitter) * time.Second
+		}
+		if sc.CatchUp != nil {
+			s.ScheduleCatchUp = *sc.CatchUp
+		}
+		if ad := sc.Adaptive; ad != nil {
+			if ad.Enabled != nil {
+				s.AdaptiveSchedule = *ad.Enabled
+			}
+			if ad.MinInterval != nil {
+				s.AdaptiveMinInterval = time.Duration(*ad.MinInterval) * time.Second
+			}
+			if ad.MaxInterval != nil {
+				s.AdaptiveMaxInterval = time.Duration(*ad.MaxInterval) * time.Second
+			}
+		}
+		if sc.Groups != nil {
+			s.Schedules = make([]ScheduleGroup, 0, len(sc.Groups))
+			for _, e := range sc.Groups {
+				g := ScheduleGroup{Name: e.Name, Targets: append([]string(nil), e.Targets...), Cron: e.Cron}
+				if e.Interval != nil {
+					g.Interval = time.Duration(*e.Interval) * time.Second
+				}
+				if e.Jitter != nil {
+					g.Jitter = time.Duration(*e.Jitter) * time.Second
+				}
+				s.Schedules = append(s.Schedules, g)
+			}
+		}
+	}
+	if al := rc.AssetLifecycle; al != nil {
+		if al.DegradedAfter != nil {
+			s.AssetDegradedAfter = *al.DegradedAfter
+		}
+		if al.UnreachableAfter != nil {
+			s.AssetUnreachableAfter = *al.UnreachableAfter
+		}
+		if al.RetireAfterDays != nil {
+			s.AssetRetireAfter = time.Duration(*al.RetireAfterDays) * 24 * time.Hour
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func parseJSON(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		t.Fatalf("invalid test json: %v", err)
+	}
+	return raw
+}
+
+func TestParseRemoteValid(t *testing.T) {
+	rc, errs := ParseRemote(parseJSON(t, `{"schema_version": 1, "scan_targets": ["a.internal"], "scan_ports": [443, 636], "scan_interval": 600}`))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(rc.ScanTargets) != 1 || rc.ScanTargets[0] != "a.internal" || *rc.ScanInterval != 600 {
+		t.Errorf("unexpected config %+v", rc)
+	}
+}
+
+// Frisch angelegte Connectors haben "scan_targets": null (create_connector_with_token)
+func TestParseRemoteNullFields(t *testing.T) {
+	raw := parseJSON(t, `{"scan_targets": null, "scan_ports": [443], "log_level": null}`)
+	rc, errs := ParseRemote(raw)
+	if len(errs) > 0 {
+		t.Fatalf("null fields rejected: %v", errs)
+	}
+	if rc.ScanTargets != nil || rc.LogLevel != nil {
+		t.Errorf("null fields should stay unset: %+v", rc)
+	}
+	if _, ok := raw["scan_targets"]; !ok {
+		t.Error("ParseRemote must not modify the caller's map")
+	}
+
+	base := Settings{ScanTargets: []string{"env.internal"}}
+	if got := Resolve(base, rc, nil).ScanTargets; len(got) != 1 || got[0] != "env.internal" {
+		t.Errorf("null scan_targets overrode the environment: %v", got)
+	}
+}
+
+func TestParseRemoteUnsupportedSchema(t *testing.T) {
+	raw := parseJSON(t, `{"schema_version": 2, "scan_ports": [443], "new_field": true}`)
+
+	var unsupported *UnsupportedSchemaError
+	if err := CheckSchemaVersion(raw); !errors.As(err, &unsupported) || unsupported.Version != 2 {
+		t.Fatalf("CheckSchemaVersion = %v, want UnsupportedSchemaError{2}", err)
+	}
+	_, errs := ParseRemote(raw)
+	if len(errs) != 1 || !strings.Contains(errs[0], "unsupported schema_version 2") {
+		t.Errorf("errors = %v, want only the unsupported schema_version message", errs)
+	}
+
+	for _, text := range []string{`{}`, `{"schema_version": 1}`, `{"schema_version": "2"}`, `{"schema_version": 1.5}`} {
+		if err := CheckSchemaVersion(parseJSON(t, text)); err != nil {
+			t.Errorf("%s: unexpected %v", text, err)
+		}
+	}
+}
+
+func TestParseRemoteInvalid(t *testing.T) {
+	_, errs := ParseRemote(parseJSON(t, `{"scan_ports": [0], "discovery_mode": "sometimes", "unknown": 1}`))
+	if len(errs) < 2 {
+		t.Errorf("want one error per problem, got %v", errs)
+	}
+	if _, errs := ParseRemote(parseJSON(t, `{"schema_version": 0}`)); len(errs) == 0 {
+		t.Error("schema_version 0 accepted")
+	}
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scheduler"
+)
+
+// Settings enthält alle zur Laufzeit änderbaren Einstellungen des Agents
+type Settings struct {
+	ScanTargets  []string
+	ScanPorts    []int
+	ScanInterval time.Duration
+	ScanTimeout  time.Duration
+
+	DiscoveryMode        string // "auto", "always" oder "off"
+	DiscoveryConcurrency int    // Parallel geprüfte Hosts bei der Discovery
+	PortConcurrency      int    // Parallel geprüfte Ports pro Host
+	ConnectionsPerSecond int    // Max. neue Verbindungen pro Sekunde (0 = unbegrenzt)
+
+	ScanConcurrency     int  // Parallel gescannte Endpoints bei festen Targets
+	ScanHostConcurrency int  // Parallel gescannte Ports pro Target-Host
+	ScanSequential      bool // Targets strikt nacheinander scannen (altes Verhalten)
+
+	Schedules       []ScheduleGroup // Eigene Zeitpläne für einzelne Targets oder Gruppen
+	ScheduleSplay   time.Duration   // Versatz pro Agent und Target für erste und nachgeholte Läufe
+	ScheduleJitter  time.Duration   // Zufällige Verzögerung pro Lauf
+	ScheduleCatchUp string          // "once" oder "skip" - Verhalten nach einer Downtime
+
+	AdaptiveSchedule    bool          // Intervall nach Ablaufdatum des Zertifikats (nur Targets ohne eigene Gruppe)
+	AdaptiveMinInterval time.Duration // Untergrenze: ablaufende oder gerade gewechselte Zertifikate
+	AdaptiveMaxInterval time.Duration // Obergrenze: stabile Zertifikate
+
+	AssetDegradedAfter    int           // Fehlschläge in Folge bis ein Asset als degraded gilt
+	AssetUnreachableAfter int           // Fehlschläge in Folge bis ein Asset als unreachable gilt
+	AssetRetireAfter      time.Duration // Zeit ohne Erfolg bis ein Asset als retired gilt (0 = nie)
+
+	LogLevel string
+}
+
+// ScheduleGroup ist ein eigener Zeitplan für einzelne Targets oder eine Gruppe von Targets
+type ScheduleGroup struct {
+	Name     string
+	Targets  []string      // Hostnamen oder Glob-Muster (*.internal), "@discovery" für die Discovery
+	Interval time.Duration // entweder Intervall ...
+	Cron     string        // ... oder Cron-Ausdruck
+	Jitter   time.Duration // 0 = globaler ScheduleJitter
+}
+
+// Snapshot ist eine unveränderliche Momentaufnahme der Laufzeit-Konfiguration.
+// Snapshots werden nie verändert, sondern bei jeder Änderung komplett ersetzt.
+type Snapshot struct {
+	Settings
+	Version   int64  // Lokale, monoton steigende Versionsnummer
+	Revision  string // Hash der Backend-Config, aus der der Snapshot stammt
+	Source    string // "env" oder "remote"
+	AppliedAt time.Time
+}
+
+// HasTargets gibt an ob feste Scan-Targets konfiguriert sind
+func (s *Snapshot) HasTargets() bool {
+	return !(len(s.ScanTargets) == 0 || (len(s.ScanTargets) == 1 && s.ScanTargets[0] == "localhost"))
+}
+
+// UsesDiscovery gibt an ob die Netzwerk-Discovery laufen soll
+func (s *Snapshot) UsesDiscovery() bool {
+	switch s.DiscoveryMode {
+	case "always":
+		return true
+	case "off":
+		return false
+	default:
+		return !s.HasTargets()
+	}
+}
+
+// clone erstellt eine tiefe Kopie der Settings (Slices werden kopiert)
+func (s Settings) clone() Settings {
+	c := s
+	c.ScanTargets = append([]string(nil), s.ScanTargets...)
+	c.ScanPorts = append([]int(nil), s.ScanPorts...)
+	c.Schedules = nil
+	for _, g := range s.Schedules {
+		g.Targets = append([]string(nil), g.Targets...)
+		c.Schedules = append(c.Schedules, g)
+	}
+	return c
+}
+
+// Validate prüft die Settings auf gültige Werte
+func (s Settings) Validate() error {
+	for _, target := range s.ScanTargets {
+		if target == "" {
+			return fmt.Errorf("scan_targets: empty target not allowed")
+		}
+	}
+	if len(s.ScanPorts) == 0 {
+		return fmt.Errorf("scan_ports: at least one port required")
+	}
+	for _, port := range s.ScanPorts {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("scan_ports: invalid port %d", port)
+		}
+	}
+	if s.ScanInterval < 10*time.Second {
+		return fmt.Errorf("scan_interval: must be at least 10s (got %s)", s.ScanInterval)
+	}
+	if s.ScanTimeout <= 0 || s.ScanTimeout > 5*time.Minute {
+		return fmt.Errorf("scan_timeout: must be between 1s and 5m (got %s)", s.ScanTimeout)
+	}
+	switch s.DiscoveryMode {
+	case "auto", "always", "off":
+	default:
+		return fmt.Errorf("discovery_mode: must be auto, always or off (got %q)", s.DiscoveryMode)
+	}
+	if s.DiscoveryConcurrency < 1 || s.PortConcurrency < 1 {
+		return fmt.Errorf("rate_limit: concurrency must be at least 1")
+	}
+	if s.ScanConcurrency < 1 || s.ScanHostConcurrency < 1 {
+		return fmt.Errorf("rate_limit: scan concurrency must be at least 1")
+	}
+	if s.ConnectionsPerSecond < 0 {
+		return fmt.Errorf("rate_limit: connections_per_second must not be negative")
+	}
+	if s.AssetDegradedAfter < 1 || s.AssetUnreachableAfter < s.AssetDegradedAfter {
+		return fmt.Errorf("asset_lifecycle: need 1 <= degraded_after <= unreachable_after (got %d, %d)", s.AssetDegradedAfter, s.AssetUnreachableAfter)
+	}
+	if s.AssetRetireAfter < 0 {
+		return fmt.Errorf("asset_lifecycle: retire_after_days must not be negative")
+	}
+	if err := s.validateSchedules(); err != nil {
+		return err
+	}
+	switch s.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("log_level: must be debug, info, warn or error (got %q)", s.LogLevel)
+	}
+	return nil
+}
+
+// validateSchedules prüft Zeitpläne, Splay, Jitter, Catch-up und adaptive Grenzen
+func (s Settings) validateSchedules() error {
+	if s.ScheduleSplay < 0 || s.ScheduleJitter < 0 {
+		return fmt.Errorf("schedule: splay and jitter must not be negative")
+	}
+	switch s.ScheduleCatchUp {
+	case "once", "skip":
+	default:
+		return fmt.Errorf("schedule: catch_up must be once or skip (got %q)", s.ScheduleCatchUp)
+	}
+	if s.AdaptiveMinInterval < 10*time.Second || s.AdaptiveMaxInterval < s.AdaptiveMinInterval {
+		return fmt.Errorf("schedule: need 10s <= adaptive min_interval <= max_interval (got %s, %s)", s.AdaptiveMinInterval, s.AdaptiveMaxInterval)
+	}
+	names := make(map[string]bool, len(s.Schedules))
+	for _, g := range s.Schedules {
+		if g.Name == "" {
+			return fmt.Errorf("schedule: group without name")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("schedule %q: duplicate name", g.Name)
+		}
+		names[g.Name] = true
+		if len(g.Targets) == 0 {
+			return fmt.Errorf("schedule %q: at least one target required", g.Name)
+		}
+		if (g.Interval > 0) == (g.Cron != "") {
+			return fmt.Errorf("schedule %q: set either interval or cron", g.Name)
+		}
+		if g.Cron != "" {
+			cron, err := scheduler.ParseCron(g.Cron)
+			if err != nil {
+				return fmt.Errorf("schedule %q: %w", g.Name, err)
+			}
+			if cron.Next(time.Now()).IsZero() {
+				return fmt.Errorf("schedule %q: cron %q never fires", g.Name, g.Cron)
+			}
+		} else if g.Interval < 10*time.Second {
+			return fmt.Errorf("schedule %q: interval must be at least 10s (got %s)", g.Name, g.Interval)
+		}
+		if g.Jitter < 0 {
+			return fmt.Errorf("schedule %q: jitter must not be negative", g.Name)
+		}
+	}
+	return nil
+}
+
+// Store hält die aktuelle Konfiguration und tauscht Snapshots atomar aus.
+// Lesen ist lock-frei, Updates werden serialisiert.
+type Store struct {
+	current atomic.Pointer[Snapshot]
+
+	mu          sync.Mutex
+	subscribers map[int]chan *Snapshot
+	nextID      int
+}
+
+// NewStore erstellt einen Store mit den lokalen Settings (Umgebung + Override) als Version 1
+func NewStore(cfg *Config) (*Store, error) {
+	settings := Resolve(cfg.BaseSettings(), nil, cfg.Override)
+
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	s := &Store{subscribers: make(map[int]chan *Snapshot)}
+	s.current.Store(&Snapshot{
+		Settings:  settings,
+		Version:   1,
+		Source:    "env",
+		AppliedAt: time.Now(),
+	})
+	return s, nil
+}
+
+// Current gibt den aktuellen Snapshot zurück (darf nicht verändert werden)
+func (s *Store) Current() *Snapshot {
+	return s.current.Load()
+}
+
+// Update wendet mutate auf eine Kopie der aktuellen Settings an, validiert das
+// Ergebnis und tauscht den Snapshot atomar aus. Ändern sich die Settings nicht,
+// bleibt die Version gleich und changed ist false.
+func (s *Store) Update(source, revision string, mutate func(*Settings) error) (snap *Snapshot, changed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.current.Load()
+	next := old.Settings.clone()
+
+	if err := mutate(&next); err != nil {
+		return old, false, err
+	}
+	if err := next.Validate(); err != nil {
+		return old, false, err
+	}
+
+	if reflect.DeepEqual(next, old.Settings) {
+		return old, false, nil
+	}
+
+	snap = &Snapshot{
+		Settings:  next,
+		Version:   old.Version + 1,
+		Revision:  revision,
+		Source:    source,
+		AppliedAt: time.Now(),
+	}
+	s.current.Store(snap)
+
+	for _, ch := range s.subscribers {
+		notify(ch, snap)
+	}
+
+	return snap, true, nil
+}
+
+// Subscribe liefert einen Channel, der bei jeder Änderung den neuen Snapshot
+// erhält. Langsame Subscriber verpassen Zwischenstände, bekommen aber immer den
+// neuesten Snapshot. Die zurückgegebene Funktion beendet das Abo.
+func (s *Store) Subscribe() (<-chan *Snapshot, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	ch := make(chan *Snapshot, 1)
+	s.subscribers[id] = ch
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// notify ersetzt einen noch nicht gelesenen Snapshot durch den neuesten
+func notify(ch chan *Snapshot, snap *Snapshot) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- snap
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestStore erstellt einen Store mit den Defaults aus der Umgebung
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	cfg, err := LoadLocal()
+	if err != nil {
+		t.Fatalf("LoadLocal: %v", err)
+	}
+	store, err := NewStore(cfg)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return store
+}
+
+// TestStoreConcurrent lässt Update, Current und Subscribe parallel laufen -
+// sinnvoll mit go test -race
+func TestStoreConcurrent(t *testing.T) {
+	store := newTestStore(t)
+
+	const writers, updates, readers = 4, 50, 8
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				_, _, err := store.Update("remote", fmt.Sprintf("rev-%d-%d", w, i), func(s *Settings) error {
+					// Slices der Kopie verändern - darf alte Snapshots nicht berühren
+					s.ScanTargets = append(s.ScanTargets[:0], fmt.Sprintf("host-%d-%d.internal", w, i))
+					s.ScanPorts = append(s.ScanPorts, 443)[:1]
+					s.ScanInterval = time.Duration(60+w*updates+i) * time.Second
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Update: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	var readersWG sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			last := int64(0)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				snap := store.Current()
+				if snap.Version < last {
+					t.Errorf("version went backwards: %d after %d", snap.Version, last)
+					return
+				}
+				last = snap.Version
+				// Lesen aller Felder - der Race-Detector meldet geteilte Slices
+				_ = fmt.Sprint(snap.ScanTargets, snap.ScanPorts, snap.Schedules, snap.ScanInterval)
+			}
+		}()
+	}
+
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			for {
+				ch, cancel := store.Subscribe()
+				select {
+				case snap := <-ch:
+					if snap == nil || snap.Version < 2 {
+						t.Errorf("unexpected snapshot %+v", snap)
+					}
+					_ = fmt.Sprint(snap.ScanTargets)
+				case <-stop:
+					cancel()
+					return
+				case <-time.After(time.Millisecond):
+				}
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(stop)
+	readersWG.Wait()
+
+	if got, want := store.Current().Version, int64(1+writers*updates); got != want {
+		t.Errorf("version = %d, want %d (every update changes the interval)", got, want)
+	}
+}
+
+// TestStoreSubscribeLatest: ein langsamer Subscriber bekommt nur den neuesten Snapshot
+func TestStoreSubscribeLatest(t *testing.T) {
+	store := newTestStore(t)
+	ch, cancel := store.Subscribe()
+	defer cancel()
+
+	for i := 1; i <= 3; i++ {
+		if _, _, err := store.Update("remote", "", func(s *Settings) error {
+			s.ScanInterval = time.Duration(100+i) * time.Second
+			return nil
+		}); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	select {
+	case snap := <-ch:
+		if snap.Version != 4 || snap.ScanInterval != 103*time.Second {
+			t.Errorf("got version %d interval %s, want 4 and 1m43s", snap.Version, snap.ScanInterval)
+		}
+	default:
+		t.Fatal("no snapshot delivered")
+	}
+	select {
+	case snap := <-ch:
+		t.Errorf("unexpected second snapshot %d", snap.Version)
+	default:
+	}
+}
+
+// TestStoreUpdateUnchanged: ohne Änderung bleibt die Version gleich, ungültige Werte werden abgelehnt
+func TestStoreUpdateUnchanged(t *testing.T) {
+	store := newTestStore(t)
+
+	snap, changed, err := store.Update("remote", "same", func(*Settings) error { return nil })
+	if err != nil || changed || snap.Version != 1 {
+		t.Errorf("no-op update: version %d changed %v err %v", snap.Version, changed, err)
+	}
+	_, changed, err = store.Update("remote", "bad", func(s *Settings) error {
+		s.ScanPorts = []int{70000}
+		return nil
+	})
+	if err == nil || changed {
+		t.Errorf("invalid port accepted (changed %v)", changed)
+	}
+	if store.Current().ScanPorts[0] == 70000 {
+		t.Error("rejected update leaked into the current snapshot")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+func (a *agent) runNetworkDiscovery(ctx context.Context, snap *config.Snapshot, trigger scanrun.Trigger) {
+	startTime := time.Now()
+	run := a.startRun(ctx, trigger, scanrun.ModeDiscovery, snap)
+	log.WithField("run_id", run.ID()).Info("Starting network discovery...")
+
+	// Send Log zu UI
+	a.logUI("info", "🌐 Netzwerk-Scan gestartet... Scanne alle privaten IP-Bereiche", map[string]interface{}{
+		"scan_mode": "auto-discovery",
+		"run_id":    run.ID(),
+	})
+
+	// Progress Callback
+	a.progress.Start(ctx, "discovery", 0)
+	progressCallback := func(current, total int) {
+		a.progress.Update(current, total, fmt.Sprintf("Scanne Netzwerk: %d/%d", current, total))
+	}
+
+	// Discover hosts im Netzwerk
+	hosts, err := a.networkScanner.DiscoverLocalNetwork(ctx, progressCallback)
+	if err != nil {
+		status := runStatus(ctx, scanrun.StatusFailed)
+		if status == scanrun.StatusCancelled {
+			log.Info("Network discovery cancelled")
+		} else {
+			log.WithError(err).Error("Network discovery failed")
+			a.logUI("error", fmt.Sprintf("❌ Netzwerk-Scan fehlgeschlagen: %v", err), nil)
+		}
+		run.SetHostsFound(len(hosts))
+		a.finishRun(ctx, run, status)
+		a.progress.Finish(ctx, string(status))
+		return
+	}
+	run.SetHostsFound(len(hosts))
+	a.inventory.SetDiscovery(hosts, time.Now())
+
+	scanDuration := time.Since(startTime)
+	log.WithFields(logrus.Fields{
+		"hosts_found": len(hosts),
+		"duration":    scanDuration,
+	}).Info("Network discovery completed")
+	a.logUI("info", fmt.Sprintf("✅ Netzwerk-Scan abgeschlossen: %d Hosts in %s gefunden", len(hosts), scanDuration.Round(time.Second)), map[string]interface{}{
+		"hosts_found": len(hosts),
+		"duration_ms": scanDuration.Milliseconds(),
+	})
+
+	// Für jeden gefundenen Host
+	for idx, host := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// Send Progress
+		a.progress.Update(idx+1, len(hosts), fmt.Sprintf("Analysiere Hosts: %d/%d", idx+1, len(hosts)))
+
+		// IMMER Discovery-Result speichern (auch ohne Zertifikat!)
+		if err := a.client.UpsertDiscoveryResult(ctx, &host); err != nil {
+			log.WithError(err).Warn("Failed to upsert discovery result")
+		} else {
+			a.sinks.Emit(sink.KindDiscovery, sink.TypeHostDiscovered, host)
+
+			// Send Log zu UI
+			servicesStr := "keine Services"
+			if len(host.Services) > 0 {
+				servicesStr = strings.Join(host.Services, ", ")
+			}
+			a.logUI("info", fmt.Sprintf("🌐 Host gefunden: %s (%d Ports: %s)", host.IPAddress, len(host.OpenPorts), servicesStr), map[string]interface{}{
+				"ip":         host.IPAddress,
+				"open_ports": host.OpenPorts,
+				"services":   host.Services,
+			})
+		}
+
+		// Scanne TLS-Zertifikate auf HTTPS/TLS Ports
+		for _, port := range tlsPorts(host.OpenPorts) {
+			cert, err := a.scanEndpoint(ctx, run, host.IPAddress, port)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"host":  host.IPAddress,
+					"port":  port,
+					"error": err,
+				}).Debug("TLS scan failed")
+				continue
+			}
+
+			log.WithFields(logrus.Fields{
+				"host":        host.IPAddress,
+				"port":        port,
+				"subject_cn":  cert.SubjectCN,
+				"fingerprint": cert.Fingerprint,
+			}).Info("Certificate discovered and reported")
+
+			// Send Log zu UI
+			a.logUI("info", fmt.Sprintf("🔐 Zertifikat gefunden: %s auf %s:%d", cert.SubjectCN, host.IPAddress, port), map[string]interface{}{
+				"host":       host.IPAddress,
+				"port":       port,
+				"subject_cn": cert.SubjectCN,
+			})
+		}
+	}
+
+	summary := a.finishRun(ctx, run, runStatus(ctx, scanrun.StatusCompleted))
+
+	log.WithFields(logrus.Fields{
+		"run_id":  summary.ID,
+		"hosts":   len(hosts),
+		"success": summary.EndpointsSucceeded,
+		"failed":  summary.EndpointsFailed,
+	}).Info("Network discovery and certificate scan completed")
+
+	// Send Final Log
+	totalDuration := time.Since(startTime)
+	a.logUI("info", fmt.Sprintf("✅ Scan abgeschlossen: %d Hosts, %d Zertifikate gefunden, %d Fehler (Dauer: %s)", len(hosts), summary.CertificatesFound, summary.EndpointsFailed, totalDuration.Round(time.Second)), map[string]interface{}{
+		"run_id":       summary.ID,
+		"hosts_found":  len(hosts),
+		"certificates": summary.CertificatesFound,
+		"errors":       summary.EndpointsFailed,
+		"duration_ms":  totalDuration.Milliseconds(),
+		"scan_mode":    "auto-discovery",
+	})
+
+	// Scan beendet
+	a.progress.Finish(ctx, string(summary.Status))
+}
+
+// tlsPorts liefert die offenen Ports, auf denen üblicherweise TLS gesprochen wird
+func tlsPorts(openPorts []int) []int {
+	ports := []int{}
+	for _, port := range openPorts {
+		if port == 443 || port == 8443 || port == 636 || port == 993 || port == 995 || port == 465 {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/policy"
+	"github.com/zertifikat-waechter/agent/report"
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+// cmdCheck prüft Endpoints gegen eine Policy (CI/CD-Gate). Exit-Code 1 bei Verstößen.
+func cmdCheck(args []string, out io.Writer) int {
+	fs := newFlagSet("check", "check [flags] host[:port] ...")
+	port := fs.Int("port", 443, "port for endpoints given without port")
+	targetsFile := fs.String("targets-file", "", "file with one endpoint per line (# comments allowed)")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout per connection")
+	concurrency := fs.Int("concurrency", 10, "endpoints checked in parallel")
+	policyFile := fs.String("policy", "", "policy file (JSON), flags below override it")
+	minDays := fs.Int("min-days", 0, "minimum days until expiry (default 14)")
+	issuers := fs.String("allowed-issuers", "", "allowed issuer CNs, comma separated globs (e.g. 'R1?,DigiCert*')")
+	minRSA := fs.Int("min-rsa-bits", 0, "minimum RSA key size (default 2048)")
+	minECDSA := fs.Int("min-ecdsa-bits", 0, "minimum ECDSA key size (default 256)")
+	sigAlgs := fs.String("allowed-signature-algorithms", "", "allowed signature algorithms, comma separated (e.g. 'SHA256-RSA,ECDSA-SHA256')")
+	minTLS := fs.String("min-tls", "", "minimum TLS version the server may accept (default 1.2)")
+	failOn := fs.String("fail-on", "", "fail on findings of at least this severity: critical, high, medium, low, info, none (default high)")
+	requireTrusted := fs.Bool("require-trusted", true, "require a chain to a trusted root")
+	jsonFile := fs.String("json", "", "write JSON report to file ('-' = stdout)")
+	sarifFile := fs.String("sarif", "", "write SARIF 2.1.0 report to file ('-' = stdout)")
+	junitFile := fs.String("junit", "", "write JUnit XML report to file ('-' = stdout)")
+	verbose := fs.Bool("v", false, "verbose logging on stderr")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	pol := policy.Default()
+	if *policyFile != "" {
+		var err error
+		if pol, err = policy.Load(*policyFile); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid policy:", err)
+			return exitUsage
+		}
+	}
+
+	// Explizit gesetzte Flags überschreiben die Policy-Datei
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-days":
+			pol.MinDaysLeft = *minDays
+		case "allowed-issuers":
+			pol.AllowedIssuers = splitList(*issuers)
+		case "min-rsa-bits":
+			pol.MinRSABits = *minRSA
+		case "min-ecdsa-bits":
+			pol.MinECDSABits = *minECDSA
+		case "allowed-signature-algorithms":
+			pol.AllowedSignatureAlg = splitList(*sigAlgs)
+		case "min-tls":
+			pol.MinTLSVersion = *minTLS
+		case "fail-on":
+			severity, err := scanner.ParseSeverity(*failOn)
+			if err != nil {
+				flagErr = err
+			}
+			pol.FailOn = severity
+		case "require-trusted":
+			pol.RequireTrusted = *requireTrusted
+		}
+	})
+	if flagErr == nil {
+		flagErr = pol.Validate()
+	}
+	if flagErr != nil {
+		fmt.Fprintln(os.Stderr, "invalid policy:", flagErr)
+		return exitUsage
+	}
+
+	targets := fs.Args()
+	if *targetsFile != "" {
+		fileTargets, err := readTargetsFile(*targetsFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		targets = append(targets, fileTargets...)
+	}
+	endpoints, err := parseEndpoints(targets, *port)
+	if err != nil || len(endpoints) == 0 {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Fprintln(os.Stderr, "no endpoints given")
+		}
+		fs.Usage()
+		return exitUsage
+	}
+
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	start := time.Now()
+	rep := &report.Report{
+		Tool:        "zertifikat-waechter-agent",
+		Version:     currentBuildInfo().Version,
+		GeneratedAt: start.UTC(),
+		Policy:      pol,
+	}
+
+	certScanner := scanner.NewScanner(*timeout, cliLogger(*verbose))
+	limits := scanner.PoolLimits{Global: *concurrency, PerHost: 2}
+	results, started := scanner.RunPool(ctx, endpoints, limits, func(ctx context.Context, ep scanner.Endpoint) report.Result {
+		return checkEndpoint(ctx, certScanner, pol, ep)
+	})
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "check cancelled")
+		return exitFailure
+	}
+	for i, res := range results {
+		if started[i] {
+			rep.Add(res)
+		}
+	}
+	rep.Duration = time.Since(start)
+
+	// Reports schreiben; geht einer nach stdout, kommt die Zusammenfassung nach stderr
+	summaryOut := out
+	outputs := []struct {
+		file  string
+		write func(io.Writer) error
+	}{
+		{*jsonFile, func(w io.Writer) error { return report.WriteJSON(w, rep) }},
+		{*sarifFile, func(w io.Writer) error { return report.WriteSARIF(w, rep, *targetsFile) }},
+		{*junitFile, func(w io.Writer) error { return report.WriteJUnit(w, rep) }},
+	}
+	for _, o := range outputs {
+		if o.file == "" {
+			continue
+		}
+		if o.file == "-" {
+			summaryOut = os.Stderr
+		}
+		if err := writeReport(o.file, out, o.write); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to write report:", err)
+			return exitFailure
+		}
+	}
+	printCheckSummary(summaryOut, rep)
+
+	if !rep.Passed() {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// checkEndpoint inspiziert einen Endpoint und bewertet ihn gegen die Policy
+func checkEndpoint(ctx context.Context, s *scanner.Scanner, pol policy.Policy, ep scanner.Endpoint) report.Result {
+	start := time.Now()
+	res := report.Result{Host: ep.Host, Port: ep.Port}
+	insp, err := s.Inspect(ctx, ep.Host, ep.Port)
+	res.Duration = time.Since(start)
+	if err != nil {
+		msg := err.Error()
+		var scanErr *scanner.ScanError
+		if errors.As(err, &scanErr) {
+			msg = fmt.Sprintf("%s: %s", scanErr.Class, scanErr.Detail())
+		}
+		res.Violations = []policy.Violation{{Rule: policy.RuleReachable, Severity: scanner.SeverityCritical, Message: msg}}
+		return res
+	}
+	res.Certificate = insp.Certificate
+	res.Protocols = insp.Protocols
+	res.Findings = insp.Findings
+	res.Violations = pol.Evaluate(insp, time.Now())
+	return res
+}
+
+// writeReport schreibt einen Report in eine Datei oder ("-") nach stdout
+func writeReport(file string, stdout io.Writer, write func(io.Writer) error) error {
+	if file == "-" {
+		return write(stdout)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// printCheckSummary gibt das Ergebnis pro Endpoint und die Verstöße aus
+func printCheckSummary(out io.Writer, rep *report.Report) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tRESULT\tSUBJECT\tEXPIRES")
+	for _, res := range rep.Results {
+		result := "PASS"
+		if !res.Passed {
+			result = fmt.Sprintf("FAIL (%d)", len(res.Violations))
+		}
+		subject, expires := "", ""
+		if res.Certificate != nil {
+			subject = res.Certificate.SubjectCN
+			expires = res.Certificate.NotAfter.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", res.Endpoint(), result, subject, expires)
+	}
+	tw.Flush()
+
+	for _, res := range rep.Results {
+		for _, v := range res.Violations {
+			fmt.Fprintf(out, "  %s  [%s] %s: %s\n", res.Endpoint(), v.Severity, v.Rule, v.Message)
+		}
+	}
+	fmt.Fprintf(out, "\n%d endpoints, %d passed, %d failed\n", rep.Summary.Endpoints, rep.Summary.Passed, rep.Summary.Failed)
+}
+
+// readTargetsFile liest eine Target-Liste (ein Endpoint pro Zeile, # Kommentare)
+func readTargetsFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var targets []string
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := lines.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	return targets, lines.Err()
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
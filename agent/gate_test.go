@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/report"
+)
+
+// gateServer liefert ein selbstsigniertes Zertifikat für 127.0.0.1 aus, gültig für validFor
+func gateServer(t *testing.T, validFor time.Duration) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func closedEndpoint(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// TestCheckExitCodes: 0 = Policy erfüllt, 1 = Verstoß oder nicht erreichbar, 2 = Aufruf fehlerhaft
+func TestCheckExitCodes(t *testing.T) {
+	valid := gateServer(t, 60*24*time.Hour)
+	expiring := gateServer(t, 5*24*time.Hour)
+	closed := closedEndpoint(t)
+	relaxed := []string{"-require-trusted=false", "-fail-on", "none", "-timeout", "2s"}
+
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyFile, []byte(`{"min_days_left": 90, "require_trusted": false, "fail_on": "none"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	targetsFile := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(targetsFile, []byte("# staging\n"+valid+"  # web\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"compliant", append(relaxed, valid), exitOK},
+		{"targets file", append(relaxed, "-targets-file", targetsFile), exitOK},
+		{"untrusted by default", []string{valid}, exitFailure},
+		{"expires too soon", append(relaxed, expiring), exitFailure},
+		{"min days lowered", append(relaxed, "-min-days", "3", expiring), exitOK},
+		{"one of several fails", append(relaxed, valid, expiring), exitFailure},
+		{"policy file", []string{"-policy", policyFile, valid}, exitFailure},
+		{"flag overrides policy file", []string{"-policy", policyFile, "-min-days", "30", valid}, exitOK},
+		{"issuer not allowed", append(relaxed, "-allowed-issuers", "DigiCert*", valid), exitFailure},
+		{"TLS 1.3 required", append(relaxed, "-min-tls", "1.3", valid), exitFailure},
+		{"unreachable", append(relaxed, closed), exitFailure},
+		{"no endpoints", relaxed, exitUsage},
+		{"invalid endpoint", append(relaxed, "host:port:extra"), exitUsage},
+		{"invalid min-tls", append(relaxed, "-min-tls", "1.4", valid), exitUsage},
+		{"invalid fail-on", []string{"-fail-on", "urgent", valid}, exitUsage},
+		{"missing policy file", []string{"-policy", filepath.Join(t.TempDir(), "missing.json"), valid}, exitUsage},
+		{"missing targets file", []string{"-targets-file", filepath.Join(t.TempDir(), "missing.txt")}, exitUsage},
+		{"unknown flag", []string{"-strict", valid}, exitUsage},
+		{"help", []string{"-h"}, exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if got := cmdCheck(tt.args, &out); got != tt.want {
+				t.Errorf("exit code = %d, want %d\n%s", got, tt.want, out.String())
+			}
+		})
+	}
+}
+
+// TestCheckReports: JSON, SARIF und JUnit werden geschrieben, "-" geht nach stdout
+func TestCheckReports(t *testing.T) {
+	valid := gateServer(t, 60*24*time.Hour)
+	closed := closedEndpoint(t)
+	dir := t.TempDir()
+	jsonFile, junitFile := filepath.Join(dir, "report.json"), filepath.Join(dir, "junit.xml")
+
+	var out bytes.Buffer
+	code := cmdCheck([]string{"-require-trusted=false", "-fail-on", "none", "-timeout", "2s",
+		"-json", jsonFile, "-junit", junitFile, "-sarif", "-", valid, closed}, &out)
+	if code != exitFailure {
+		t.Errorf("exit code = %d, want %d", code, exitFailure)
+	}
+
+	// stdout enthält nur den SARIF-Report, die Zusammenfassung geht nach stderr
+	var sarif map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &sarif); err != nil || sarif["version"] != "2.1.0" {
+		t.Fatalf("stdout is not a SARIF report (%v):\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), `"ruleId": "reachable"`) {
+		t.Errorf("SARIF without reachable violation:\n%s", out.String())
+	}
+
+	data, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep report.Report
+	if err := json.Unmarshal(data, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Summary != (report.Summary{Endpoints: 2, Passed: 1, Failed: 1, Violations: 1}) || rep.Results[0].Certificate == nil {
+		t.Errorf("JSON report = %+v", rep)
+	}
+	host, port, _ := net.SplitHostPort(closed)
+	if p, _ := strconv.Atoi(port); rep.Results[1].Host != host || rep.Results[1].Port != p || rep.Results[1].Passed {
+		t.Errorf("result for closed port = %+v", rep.Results[1])
+	}
+
+	junit, err := os.ReadFile(junitFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(junit), `tests="2" failures="0" errors="1"`) {
+		t.Errorf("JUnit report:\n%s", junit)
+	}
+}
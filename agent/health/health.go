@@ -0,0 +1,112 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Status ist der Zustand einer Komponente bzw. des Agents
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded" // funktioniert eingeschränkt - bleibt ready
+	StatusFailing  Status = "failing"  // nicht ready
+)
+
+var statusRank = map[Status]int{StatusOK: 0, StatusDegraded: 1, StatusFailing: 2}
+
+// Check ist das Ergebnis einer Komponentenprüfung
+type Check struct {
+	Name    string                 `json:"name"`
+	Status  Status                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report ist der Gesamtzustand: der schlechteste Status aller Komponenten
+type Report struct {
+	Status    Status    `json:"status"`
+	Checks    []Check   `json:"checks"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Ready meldet, ob der Agent Arbeit annehmen kann (ok oder degraded)
+func (r Report) Ready() bool {
+	return r.Status != StatusFailing
+}
+
+// CheckFunc prüft eine Komponente zum Zeitpunkt now
+type CheckFunc func(now time.Time) Check
+
+// Checker sammelt die Komponentenprüfungen (thread-safe)
+type Checker struct {
+	mu     sync.Mutex
+	checks []CheckFunc
+}
+
+// NewChecker erstellt einen Checker ohne Komponenten
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Register fügt eine Komponentenprüfung hinzu
+func (c *Checker) Register(fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, fn)
+}
+
+// Run führt alle Prüfungen aus
+func (c *Checker) Run(now time.Time) Report {
+	c.mu.Lock()
+	checks := append([]CheckFunc{}, c.checks...)
+	c.mu.Unlock()
+
+	report := Report{Status: StatusOK, Checks: make([]Check, 0, len(checks)), CheckedAt: now.UTC()}
+	for _, fn := range checks {
+		check := fn(now)
+		if statusRank[check.Status] > statusRank[report.Status] {
+			report.Status = check.Status
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report
+}
+
+// State ist eine Komponente, deren Zustand von außen gemeldet wird (z.B. vom Config-Polling)
+type State struct {
+	mu      sync.Mutex
+	name    string
+	status  Status
+	message string
+	since   time.Time
+}
+
+// NewState erstellt eine Komponente mit Anfangszustand
+func NewState(name string, status Status, message string) *State {
+	return &State{name: name, status: status, message: message, since: time.Now()}
+}
+
+// Set meldet einen neuen Zustand; since bleibt bei unverändertem Status erhalten
+func (s *State) Set(status Status, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status != s.status {
+		s.since = time.Now()
+	}
+	s.status = status
+	s.message = message
+}
+
+// Check liefert den gemeldeten Zustand
+func (s *State) Check(now time.Time) Check {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Check{
+		Name:    s.name,
+		Status:  s.status,
+		Message: s.message,
+		Details: map[string]interface{}{"since": s.since.UTC()},
+	}
+}
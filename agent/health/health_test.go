@@ -0,0 +1,56 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckerRun(t *testing.T) {
+	now := time.Date(2026, 3, 6, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	component := func(name string, status Status) CheckFunc {
+		return func(time.Time) Check { return Check{Name: name, Status: status} }
+	}
+
+	tests := []struct {
+		name      string
+		statuses  []Status
+		want      Status
+		wantReady bool
+	}{
+		{"no checks", nil, StatusOK, true},
+		{"all ok", []Status{StatusOK, StatusOK}, StatusOK, true},
+		{"degraded stays ready", []Status{StatusOK, StatusDegraded, StatusOK}, StatusDegraded, true},
+		{"failing", []Status{StatusDegraded, StatusFailing, StatusOK}, StatusFailing, false},
+	}
+	for _, tt := range tests {
+		c := NewChecker()
+		for i, status := range tt.statuses {
+			c.Register(component(string(rune('a'+i)), status))
+		}
+		report := c.Run(now)
+		if report.Status != tt.want || report.Ready() != tt.wantReady || len(report.Checks) != len(tt.statuses) {
+			t.Errorf("%s: status %s, ready %v, %d checks", tt.name, report.Status, report.Ready(), len(report.Checks))
+		}
+		if report.CheckedAt.Location() != time.UTC || !report.CheckedAt.Equal(now) {
+			t.Errorf("%s: checked_at %s", tt.name, report.CheckedAt)
+		}
+	}
+}
+
+// TestStateSince: since ändert sich nur mit dem Status, nicht mit der Meldung
+func TestStateSince(t *testing.T) {
+	s := NewState("config", StatusOK, "applied")
+	since := func() time.Time { return s.Check(time.Now()).Details["since"].(time.Time) }
+
+	first := since()
+	time.Sleep(5 * time.Millisecond)
+	s.Set(StatusOK, "applied version 2")
+	if c := s.Check(time.Now()); !since().Equal(first) || c.Message != "applied version 2" {
+		t.Errorf("since changed without status change: %+v", c)
+	}
+
+	s.Set(StatusFailing, "rejected")
+	if c := s.Check(time.Now()); !since().After(first) || c.Status != StatusFailing || c.Name != "config" {
+		t.Errorf("after status change: %+v", c)
+	}
+}
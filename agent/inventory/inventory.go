@@ -0,0 +1,217 @@
+package inventory
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+)
+
+// maxRuns: so viele abgeschlossene Läufe werden vorgehalten
+const maxRuns = 50
+
+// EndpointResult ist das letzte Scan-Ergebnis eines Endpoints
+type EndpointResult struct {
+	Host        string     `json:"host"`
+	Port        int        `json:"port"`
+	SNI         string     `json:"sni,omitempty"`
+	Success     bool       `json:"success"`
+	ErrorClass  string     `json:"error_class,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"` // zuletzt gesehenes Zertifikat (auch nach Fehlern)
+	ScannedAt   time.Time  `json:"scanned_at"`
+	LastSuccess *time.Time `json:"last_success_at,omitempty"`
+}
+
+// Certificate ist ein von diesem Agent gesehenes Zertifikat mit seinen Fundorten
+type Certificate struct {
+	*scanner.CertificateData
+	Endpoints   []string  `json:"endpoints"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// Discovery ist das Ergebnis der letzten Netzwerk-Discovery
+type Discovery struct {
+	Hosts      []scanner.DiscoveryResult `json:"hosts"`
+	FinishedAt time.Time                 `json:"finished_at"`
+}
+
+// Inventory hält fest, was dieser Agent seit dem Start gesehen hat (thread-safe).
+// Es dient nur der lokalen Anzeige - maßgeblich bleibt das Backend.
+type Inventory struct {
+	mu        sync.Mutex
+	endpoints map[string]*EndpointResult
+	certs     map[string]*Certificate // nach Fingerprint
+	discovery *Discovery
+	runs      []scanrun.Summary // neuester zuerst
+}
+
+// New erstellt ein leeres Inventar
+func New() *Inventory {
+	return &Inventory{
+		endpoints: make(map[string]*EndpointResult),
+		certs:     make(map[string]*Certificate),
+	}
+}
+
+func key(host string, port int) string {
+	return host + ":" + strconv.Itoa(port)
+}
+
+// ObserveEndpoint hält das Ergebnis eines Endpoint-Scans fest
+func (inv *Inventory) ObserveEndpoint(host string, port int, cert *scanner.CertificateData, err error, at time.Time) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	k := key(host, port)
+	res, ok := inv.endpoints[k]
+	if !ok {
+		res = &EndpointResult{Host: host, Port: port}
+		inv.endpoints[k] = res
+	}
+	res.ScannedAt = at.UTC()
+	res.Success = err == nil && cert != nil
+	res.ErrorClass, res.Error = "", ""
+	if err != nil {
+		res.ErrorClass = string(scanner.ClassUnknown)
+		res.Error = err.Error()
+		var scanErr *scanner.ScanError
+		if errors.As(err, &scanErr) {
+			res.ErrorClass = string(scanErr.Class)
+			res.Error = scanErr.Detail()
+		}
+	}
+	if !res.Success {
+		return
+	}
+
+	res.SNI = cert.SNI
+	res.Fingerprint = cert.Fingerprint
+	success := at.UTC()
+	res.LastSuccess = &success
+
+	c, ok := inv.certs[cert.Fingerprint]
+	if !ok {
+		c = &Certificate{FirstSeenAt: at.UTC()}
+		inv.certs[cert.Fingerprint] = c
+	}
+	// Kopie - der Scan ergänzt das Original noch (Asset-ID, Lauf)
+	data := *cert
+	c.CertificateData = &data
+	c.LastSeenAt = at.UTC()
+	if !contains(c.Endpoints, k) {
+		c.Endpoints = append(c.Endpoints, k)
+		sort.Strings(c.Endpoints)
+	}
+}
+
+// SetDiscovery ersetzt das Ergebnis der letzten Discovery
+func (inv *Inventory) SetDiscovery(hosts []scanner.DiscoveryResult, at time.Time) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.discovery = &Discovery{Hosts: append([]scanner.DiscoveryResult{}, hosts...), FinishedAt: at.UTC()}
+}
+
+// AddRun hält einen abgeschlossenen Lauf fest (die ältesten fallen heraus)
+func (inv *Inventory) AddRun(summary scanrun.Summary) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.runs = append([]scanrun.Summary{summary}, inv.runs...)
+	if len(inv.runs) > maxRuns {
+		inv.runs = inv.runs[:maxRuns]
+	}
+}
+
+// Endpoint liefert das letzte Ergebnis eines Endpoints
+func (inv *Inventory) Endpoint(host string, port int) (EndpointResult, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if res, ok := inv.endpoints[key(host, port)]; ok {
+		return *res, true
+	}
+	return EndpointResult{}, false
+}
+
+// Endpoints liefert alle gescannten Endpoints, sortiert nach host:port
+func (inv *Inventory) Endpoints() []EndpointResult {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	list := make([]EndpointResult, 0, len(inv.endpoints))
+	for _, res := range inv.endpoints {
+		list = append(list, *res)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Host != list[j].Host {
+			return list[i].Host < list[j].Host
+		}
+		return list[i].Port < list[j].Port
+	})
+	return list
+}
+
+// Certificates liefert alle gesehenen Zertifikate, nach Ablaufdatum sortiert
+func (inv *Inventory) Certificates() []Certificate {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	list := make([]Certificate, 0, len(inv.certs))
+	for _, c := range inv.certs {
+		item := *c
+		item.Endpoints = append([]string{}, c.Endpoints...)
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NotAfter.Before(list[j].NotAfter) })
+	return list
+}
+
+// Locations liefert die Endpoints (host:port), die zuletzt das Zertifikat fingerprint
+// ausgeliefert haben, sortiert
+func (inv *Inventory) Locations(fingerprint string) []string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	c, ok := inv.certs[fingerprint]
+	if !ok {
+		return nil
+	}
+	var list []string
+	for _, k := range c.Endpoints {
+		if res, ok := inv.endpoints[k]; ok && res.Fingerprint == fingerprint {
+			list = append(list, k)
+		}
+	}
+	return list
+}
+
+// Discovery liefert das Ergebnis der letzten Discovery (nil = noch keine)
+func (inv *Inventory) Discovery() *Discovery {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.discovery == nil {
+		return nil
+	}
+	d := *inv.discovery
+	return &d
+}
+
+// Runs liefert die letzten Läufe, neuester zuerst
+func (inv *Inventory) Runs() []scanrun.Summary {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return append([]scanrun.Summary{}, inv.runs...)
+}
+
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
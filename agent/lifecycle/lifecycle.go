@@ -0,0 +1,240 @@
+package lifecycle
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// State ist der Lebenszyklus-Status eines Assets (Endpoint host:port)
+type State string
+
+const (
+	StateActive      State = "active"      // letzter Scan erfolgreich
+	StateDegraded    State = "degraded"    // einzelne Fehlschläge, Endpoint noch nicht aufgegeben
+	StateUnreachable State = "unreachable" // wiederholt nicht erreichbar
+	StateError       State = "error"       // erreichbar, aber TLS-Problem
+	StateRetired     State = "retired"     // lange ohne Erfolg - gilt als abgebaut
+)
+
+// Thresholds steuern die Übergänge zwischen den Zuständen
+type Thresholds struct {
+	DegradedAfter    int           // aufeinanderfolgende Fehlschläge bis degraded
+	UnreachableAfter int           // aufeinanderfolgende Fehlschläge bis unreachable
+	RetireAfter      time.Duration // ohne Erfolg bis retired (0 = nie)
+}
+
+// Record ist der bekannte Zustand eines Assets
+type Record struct {
+	AssetID             string
+	Host                string
+	Port                int
+	State               State
+	ConsecutiveFailures int
+	FirstSeenAt         time.Time
+	LastSuccessAt       *time.Time
+	LastFailureAt       *time.Time
+	LastCheckedAt       *time.Time
+	LastErrorClass      string
+	LastError           string
+}
+
+// Result ist das Ergebnis eines einzelnen Scans
+type Result struct {
+	Success     bool
+	Unreachable bool // Verbindung kam nicht zustande (DNS, Port zu, Timeout beim Connect)
+	ErrorClass  string
+	Error       string
+	At          time.Time
+}
+
+// Transition ist ein Zustandswechsel eines Assets
+type Transition struct {
+	AssetID             string
+	Host                string
+	Port                int
+	From                State
+	To                  State
+	ConsecutiveFailures int
+	LastSuccessAt       *time.Time
+	Reason              string // Fehlerklasse bzw. "recovered" / "stale"
+	At                  time.Time
+}
+
+// Tracker führt den Zustand aller Assets eines Connectors (thread-safe)
+type Tracker struct {
+	mu         sync.Mutex
+	thresholds Thresholds
+	records    map[string]*Record
+}
+
+// NewTracker erstellt einen leeren Tracker
+func NewTracker(thresholds Thresholds) *Tracker {
+	return &Tracker{
+		thresholds: thresholds,
+		records:    make(map[string]*Record),
+	}
+}
+
+func key(host string, port int) string {
+	return host + ":" + strconv.Itoa(port)
+}
+
+// SetThresholds ändert die Schwellwerte für folgende Auswertungen
+func (t *Tracker) SetThresholds(thresholds Thresholds) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.thresholds = thresholds
+}
+
+// Seed übernimmt den im Backend gespeicherten Zustand (z.B. nach einem Neustart)
+func (t *Tracker) Seed(records []Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range records {
+		rec := records[i]
+		if rec.State == "" {
+			rec.State = StateActive
+		}
+		t.records[key(rec.Host, rec.Port)] = &rec
+	}
+}
+
+// Observe wertet ein Scan-Ergebnis aus und liefert den neuen Zustand
+// sowie den Übergang, falls sich der Zustand geändert hat
+func (t *Tracker) Observe(host string, port int, res Result) (Record, *Transition) {
+	if res.At.IsZero() {
+		res.At = time.Now().UTC()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rec, ok := t.records[key(host, port)]
+	if !ok {
+		rec = &Record{Host: host, Port: port, State: StateActive, FirstSeenAt: res.At}
+		t.records[key(host, port)] = rec
+	}
+
+	from := rec.State
+	at := res.At
+	rec.LastCheckedAt = &at
+
+	reason := res.ErrorClass
+	if res.Success {
+		rec.State = StateActive
+		rec.ConsecutiveFailures = 0
+		rec.LastSuccessAt = &at
+		rec.LastErrorClass = ""
+		rec.LastError = ""
+		reason = "recovered"
+	} else {
+		rec.ConsecutiveFailures++
+		rec.LastFailureAt = &at
+		rec.LastErrorClass = res.ErrorClass
+		rec.LastError = res.Error
+		rec.State = t.nextState(rec, res)
+	}
+
+	if rec.State == from {
+		return *rec, nil
+	}
+	return *rec, transition(rec, from, reason, at)
+}
+
+// nextState bestimmt den Zustand nach einem Fehlschlag
+func (t *Tracker) nextState(rec *Record, res Result) State {
+	th := t.thresholds
+
+	// Einmal abgebaut bleibt abgebaut, bis wieder ein Zertifikat gelesen wird
+	if rec.State == StateRetired {
+		return StateRetired
+	}
+	if t.stale(rec, res.At) && rec.ConsecutiveFailures >= th.UnreachableAfter {
+		return StateRetired
+	}
+
+	switch {
+	case res.Unreachable && rec.ConsecutiveFailures >= th.UnreachableAfter:
+		return StateUnreachable
+	case !res.Unreachable && rec.ConsecutiveFailures >= th.DegradedAfter:
+		return StateError
+	case rec.ConsecutiveFailures >= th.DegradedAfter:
+		if rec.State == StateUnreachable {
+			return StateUnreachable
+		}
+		return StateDegraded
+	}
+	return rec.State
+}
+
+// stale meldet, ob ein Asset länger als RetireAfter keinen Erfolg hatte
+func (t *Tracker) stale(rec *Record, now time.Time) bool {
+	if t.thresholds.RetireAfter <= 0 {
+		return false
+	}
+	since := rec.FirstSeenAt
+	if rec.LastSuccessAt != nil {
+		since = *rec.LastSuccessAt
+	}
+	return !since.IsZero() && now.Sub(since) >= t.thresholds.RetireAfter
+}
+
+// Sweep setzt Assets auf retired, die seit RetireAfter keinen Erfolg hatten -
+// auch wenn sie gar nicht mehr gescannt werden (aus den Targets entfernt,
+// von der Discovery nicht mehr gefunden)
+func (t *Tracker) Sweep(now time.Time) []Transition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var transitions []Transition
+	for _, rec := range t.records {
+		if rec.AssetID == "" || rec.State == StateRetired || !t.stale(rec, now) {
+			continue
+		}
+		from := rec.State
+		rec.State = StateRetired
+		transitions = append(transitions, *transition(rec, from, "stale", now))
+	}
+	return transitions
+}
+
+// Get liefert den Zustand eines Assets
+func (t *Tracker) Get(host string, port int) (Record, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rec, ok := t.records[key(host, port)]; ok {
+		return *rec, true
+	}
+	return Record{}, false
+}
+
+// Attach verknüpft den Zustand mit der Asset-ID im Backend. Ohne ID (Asset wurde
+// nicht angelegt) wird der Zustand verworfen.
+func (t *Tracker) Attach(host string, port int, assetID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if assetID == "" {
+		delete(t.records, key(host, port))
+		return
+	}
+	if rec, ok := t.records[key(host, port)]; ok {
+		rec.AssetID = assetID
+	}
+}
+
+func transition(rec *Record, from State, reason string, at time.Time) *Transition {
+	return &Transition{
+		AssetID:             rec.AssetID,
+		Host:                rec.Host,
+		Port:                rec.Port,
+		From:                from,
+		To:                  rec.State,
+		ConsecutiveFailures: rec.ConsecutiveFailures,
+		LastSuccessAt:       rec.LastSuccessAt,
+		Reason:              reason,
+		At:                  at,
+	}
+}
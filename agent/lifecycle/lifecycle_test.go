@@ -0,0 +1,200 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var (
+	start      = time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+	thresholds = Thresholds{DegradedAfter: 1, UnreachableAfter: 3, RetireAfter: 7 * day}
+)
+
+// step ist ein Scan-Ergebnis after nach start mit dem erwarteten Zustand danach;
+// wantFrom leer = kein Übergang erwartet
+type step struct {
+	after      time.Duration
+	result     string // "ok", "unreachable" oder "tls"
+	want       State
+	wantFrom   State
+	wantReason string
+}
+
+func result(kind string, at time.Time) Result {
+	switch kind {
+	case "ok":
+		return Result{Success: true, At: at}
+	case "unreachable":
+		return Result{Unreachable: true, ErrorClass: "timeout", Error: "dial timeout", At: at}
+	default:
+		return Result{ErrorClass: "tls_alert", Error: "remote error", At: at}
+	}
+}
+
+func TestObserveTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"stays active", []step{
+			{0, "ok", StateActive, "", ""},
+			{time.Hour, "ok", StateActive, "", ""},
+		}},
+		{"active, unreachable, retired", []step{
+			{0, "ok", StateActive, "", ""},
+			{1 * time.Hour, "unreachable", StateDegraded, StateActive, "timeout"},
+			{2 * time.Hour, "unreachable", StateDegraded, "", ""},
+			{3 * time.Hour, "unreachable", StateUnreachable, StateDegraded, "timeout"},
+			{2 * day, "unreachable", StateUnreachable, "", ""},
+			{7 * day, "unreachable", StateRetired, StateUnreachable, "timeout"},
+			{8 * day, "unreachable", StateRetired, "", ""},
+		}},
+		{"retired endpoint reappears", []step{
+			{0, "ok", StateActive, "", ""},
+			{1 * day, "unreachable", StateDegraded, StateActive, "timeout"},
+			{2 * day, "unreachable", StateDegraded, "", ""},
+			{8 * day, "unreachable", StateRetired, StateDegraded, "timeout"},
+			{9 * day, "tls", StateRetired, "", ""},
+			{10 * day, "ok", StateActive, StateRetired, "recovered"},
+			{10*day + time.Hour, "unreachable", StateDegraded, StateActive, "timeout"},
+		}},
+		{"unreachable recovers", []step{
+			{0, "unreachable", StateDegraded, StateActive, "timeout"},
+			{time.Hour, "unreachable", StateDegraded, "", ""},
+			{2 * time.Hour, "unreachable", StateUnreachable, StateDegraded, "timeout"},
+			{3 * time.Hour, "ok", StateActive, StateUnreachable, "recovered"},
+		}},
+		{"tls problem", []step{
+			{0, "ok", StateActive, "", ""},
+			{time.Hour, "tls", StateError, StateActive, "tls_alert"},
+			{2 * time.Hour, "unreachable", StateDegraded, StateError, "timeout"},
+			{3 * time.Hour, "tls", StateError, StateDegraded, "tls_alert"},
+		}},
+		{"never seen successfully", []step{
+			{0, "unreachable", StateDegraded, StateActive, "timeout"},
+			{time.Hour, "unreachable", StateDegraded, "", ""},
+			{7 * day, "unreachable", StateRetired, StateDegraded, "timeout"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker(thresholds)
+			failures := 0
+			for i, s := range tt.steps {
+				at := start.Add(s.after)
+				rec, tr := tracker.Observe("a.example", 443, result(s.result, at))
+				if s.result == "ok" {
+					failures = 0
+				} else {
+					failures++
+				}
+
+				if rec.State != s.want || rec.ConsecutiveFailures != failures {
+					t.Errorf("step %d: state %s (%d failures), want %s (%d failures)", i, rec.State, rec.ConsecutiveFailures, s.want, failures)
+				}
+				switch {
+				case s.wantFrom == "" && tr != nil:
+					t.Errorf("step %d: unexpected transition %s -> %s", i, tr.From, tr.To)
+				case s.wantFrom != "" && tr == nil:
+					t.Errorf("step %d: no transition, want %s -> %s", i, s.wantFrom, s.want)
+				case tr != nil:
+					if tr.From != s.wantFrom || tr.To != s.want || tr.Reason != s.wantReason || !tr.At.Equal(at) || tr.ConsecutiveFailures != failures {
+						t.Errorf("step %d: transition %+v, want %s -> %s (%s)", i, *tr, s.wantFrom, s.want, s.wantReason)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestObserveRecord prüft die Zeitstempel und Fehlerangaben des Records
+func TestObserveRecord(t *testing.T) {
+	tracker := NewTracker(thresholds)
+	tracker.Observe("a.example", 443, result("ok", start))
+	rec, _ := tracker.Observe("a.example", 443, result("tls", start.Add(time.Hour)))
+	if !rec.FirstSeenAt.Equal(start) || !rec.LastSuccessAt.Equal(start) || !rec.LastFailureAt.Equal(start.Add(time.Hour)) ||
+		!rec.LastCheckedAt.Equal(start.Add(time.Hour)) || rec.LastErrorClass != "tls_alert" || rec.LastError != "remote error" {
+		t.Errorf("record after failure = %+v", rec)
+	}
+	rec, tr := tracker.Observe("a.example", 443, result("ok", start.Add(2*time.Hour)))
+	if rec.LastErrorClass != "" || rec.LastError != "" || !rec.LastSuccessAt.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("record after recovery = %+v", rec)
+	}
+	if tr == nil || !tr.LastSuccessAt.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("transition = %+v", tr)
+	}
+	// Andere Ports sind eigene Assets
+	if _, ok := tracker.Get("a.example", 8443); ok {
+		t.Error("a.example:8443 known without scan")
+	}
+}
+
+// TestSeedReappearance: ein im Backend als retired gespeichertes Asset wird nach einem
+// Neustart beim ersten erfolgreichen Scan wieder aktiv
+func TestSeedReappearance(t *testing.T) {
+	lastSuccess := start.Add(-30 * day)
+	tracker := NewTracker(thresholds)
+	tracker.Seed([]Record{
+		{AssetID: "asset-1", Host: "old.example", Port: 443, State: StateRetired, ConsecutiveFailures: 12, FirstSeenAt: start.Add(-90 * day), LastSuccessAt: &lastSuccess},
+		{AssetID: "asset-2", Host: "new.example", Port: 443},
+	})
+
+	if rec, tr := tracker.Observe("old.example", 443, result("unreachable", start)); rec.State != StateRetired || tr != nil || rec.ConsecutiveFailures != 13 {
+		t.Errorf("retired asset after failure: %s (%d failures), transition %v", rec.State, rec.ConsecutiveFailures, tr)
+	}
+	rec, tr := tracker.Observe("old.example", 443, result("ok", start.Add(time.Hour)))
+	if rec.State != StateActive || tr == nil || tr.From != StateRetired || tr.Reason != "recovered" || tr.AssetID != "asset-1" {
+		t.Errorf("reappeared asset: %s, transition %+v", rec.State, tr)
+	}
+
+	if rec, ok := tracker.Get("new.example", 443); !ok || rec.State != StateActive {
+		t.Errorf("seeded record without state = %+v, %v", rec, ok)
+	}
+}
+
+// TestSweep: Assets ohne Scan werden nach RetireAfter abgebaut, aber nur mit Asset-ID
+func TestSweep(t *testing.T) {
+	tracker := NewTracker(thresholds)
+	for _, host := range []string{"gone.example", "fresh.example", "unattached.example"} {
+		tracker.Observe(host, 443, result("ok", start))
+	}
+	tracker.Observe("fresh.example", 443, result("ok", start.Add(6*day)))
+	tracker.Attach("gone.example", 443, "asset-gone")
+	tracker.Attach("fresh.example", 443, "asset-fresh")
+
+	if got := tracker.Sweep(start.Add(7*day - time.Second)); len(got) != 0 {
+		t.Errorf("sweep before RetireAfter = %+v", got)
+	}
+	got := tracker.Sweep(start.Add(7 * day))
+	if len(got) != 1 || got[0].AssetID != "asset-gone" || got[0].From != StateActive || got[0].To != StateRetired || got[0].Reason != "stale" {
+		t.Fatalf("sweep = %+v", got)
+	}
+	if got := tracker.Sweep(start.Add(8 * day)); len(got) != 0 {
+		t.Errorf("second sweep = %+v, want retired assets skipped", got)
+	}
+	if rec, _ := tracker.Get("unattached.example", 443); rec.State != StateActive {
+		t.Errorf("asset without ID swept: %s", rec.State)
+	}
+
+	// Ohne RetireAfter wird nie abgebaut
+	tracker.SetThresholds(Thresholds{DegradedAfter: 1, UnreachableAfter: 3})
+	if got := tracker.Sweep(start.Add(365 * day)); len(got) != 0 {
+		t.Errorf("sweep without RetireAfter = %+v", got)
+	}
+}
+
+// TestAttach: ohne Asset-ID wird der Zustand verworfen
+func TestAttach(t *testing.T) {
+	tracker := NewTracker(thresholds)
+	tracker.Observe("a.example", 443, result("ok", start))
+	tracker.Attach("a.example", 443, "asset-1")
+	if rec, _ := tracker.Get("a.example", 443); rec.AssetID != "asset-1" {
+		t.Errorf("AssetID = %q", rec.AssetID)
+	}
+	tracker.Attach("a.example", 443, "")
+	if _, ok := tracker.Get("a.example", 443); ok {
+		t.Error("record kept without asset ID")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/localstore"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/supabase"
+)
+
+const localUsage = `Usage: agent local <query> [flags]
+
+Queries the local database of a standalone agent (STANDALONE_DB). While the agent
+is running the database is locked - use the status API (/api/v1/local/...) instead.
+
+Queries:
+  assets                 Assets with lifecycle state
+  certificates           Certificate inventory with locations
+  expiring [--days n]    Certificates in use that expire within n days (default 30)
+  runs [--limit n]       Scan runs, newest first
+  history [flags] host[:port]
+                         Scan results of an endpoint, newest first
+  changes [--limit n]    Detected certificate changes, newest first
+  export [--out file]    Export all data as JSON (for a later import into the backend)
+`
+
+// cmdLocal beantwortet Abfragen an die lokale Datenbank (Standalone-Modus)
+func cmdLocal(args []string, out io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprint(os.Stderr, localUsage)
+		return exitUsage
+	}
+	query, args := args[0], args[1:]
+	switch query {
+	case "assets", "certificates", "expiring", "runs", "history", "changes", "export":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown query %q\n\n%s", query, localUsage)
+		return exitUsage
+	}
+
+	fs := newFlagSet("local "+query, "local "+query+" [flags]")
+	dbPath := fs.String("db", envOr("STANDALONE_DB", config.DefaultStandaloneDB), "path of the local database")
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	days := fs.Int("days", 30, "expiring: days ahead")
+	limit := fs.Int("limit", 20, "runs, history, changes: number of entries (0 = all)")
+	port := fs.Int("port", 443, "history: port if not given with the host")
+	outFile := fs.String("out", "-", "export: output file (- = stdout)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	db, err := localstore.OpenReadOnly(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer db.Close()
+
+	var result interface{}
+	var printTable func()
+	switch query {
+	case "assets":
+		assets, err := db.Assets()
+		if err != nil {
+			return localError(err)
+		}
+		result, printTable = assets, func() { printLocalAssets(out, assets) }
+	case "certificates", "expiring":
+		var items []localstore.InventoryItem
+		if query == "expiring" {
+			items, err = db.Expiring(time.Now().AddDate(0, 0, *days))
+		} else {
+			items, err = db.Inventory()
+		}
+		if err != nil {
+			return localError(err)
+		}
+		result, printTable = items, func() { printLocalInventory(out, items) }
+	case "runs":
+		runs, err := db.Runs(*limit)
+		if err != nil {
+			return localError(err)
+		}
+		result, printTable = runs, func() {
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "STARTED\tMODE\tTRIGGER\tSTATUS\tENDPOINTS\tFAILED\tCERTIFICATES")
+			for _, run := range runs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\n", run.StartedAt.Local().Format("2006-01-02 15:04"), run.Mode, run.Trigger, run.Status, run.EndpointsTotal, run.EndpointsFailed, run.CertificatesFound)
+			}
+			tw.Flush()
+		}
+	case "history":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: agent local history [flags] host[:port]")
+			return exitUsage
+		}
+		ep, err := scanner.ParseEndpoint(fs.Arg(0), *port)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		history, err := db.History(ep.Host, ep.Port, *limit)
+		if err != nil {
+			return localError(err)
+		}
+		result, printTable = history, func() {
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SCANNED\tSTATUS\tFINGERPRINT\tERROR")
+			for _, o := range history {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", o.ScannedAt.Local().Format("2006-01-02 15:04"), o.Status, shortFingerprint(o.Fingerprint), strings.TrimSpace(o.ErrorClass+" "+o.ErrorMessage))
+			}
+			tw.Flush()
+		}
+	case "changes":
+		changes, err := db.Changes(*limit)
+		if err != nil {
+			return localError(err)
+		}
+		result, printTable = changes, func() {
+			tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "DETECTED\tENDPOINT\tOLD\tNEW\tSUSPICIOUS")
+			for _, c := range changes {
+				fmt.Fprintf(tw, "%s\t%s:%d\t%s\t%s\t%t\n", c.DetectedAt.Local().Format("2006-01-02 15:04"), c.Host, c.Port, shortFingerprint(c.OldFingerprint), shortFingerprint(c.NewFingerprint), c.Suspicious)
+			}
+			tw.Flush()
+		}
+	case "export":
+		if err := writeReport(*outFile, out, db.Export); err != nil {
+			return localError(err)
+		}
+		return exitOK
+	}
+
+	if *jsonOut {
+		writeJSON(out, result)
+	} else {
+		printTable()
+	}
+	return exitOK
+}
+
+func localError(err error) int {
+	fmt.Fprintln(os.Stderr, "local database:", err)
+	return exitFailure
+}
+
+// printLocalAssets gibt die Assets als Tabelle aus
+func printLocalAssets(out io.Writer, assets []supabase.AssetData) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tSTATUS\tFAILURES\tLAST CHECK\tLAST ERROR")
+	for _, a := range assets {
+		lastCheck, lastError := "", ""
+		if a.LastCheckedAt != nil {
+			lastCheck = a.LastCheckedAt.Local().Format("2006-01-02 15:04")
+		}
+		if a.LastError != nil {
+			lastError = *a.LastError
+		}
+		fmt.Fprintf(tw, "%s:%d\t%s\t%d\t%s\t%s\n", a.Host, a.Port, a.Status, a.ConsecutiveFailures, lastCheck, lastError)
+	}
+	tw.Flush()
+}
+
+// printLocalInventory gibt Zertifikate mit Fundorten als Tabelle aus
+func printLocalInventory(out io.Writer, items []localstore.InventoryItem) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBJECT\tISSUER\tEXPIRES\tDAYS\tSTATUS\tENDPOINTS")
+	for _, item := range items {
+		endpoints := make([]string, 0, len(item.Locations))
+		for _, loc := range item.Locations {
+			endpoints = append(endpoints, fmt.Sprintf("%s:%d", loc.Host, loc.Port))
+		}
+		days := daysLeft(item.NotAfter)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", item.SubjectCN, item.Issuer, item.NotAfter.Format("2006-01-02"), days, expiryStatus(days), strings.Join(endpoints, ", "))
+	}
+	tw.Flush()
+}
+
+func shortFingerprint(fp string) string {
+	if len(fp) > 16 {
+		return fp[:16]
+	}
+	return fp
+}
+
+// envOr liefert die Umgebungsvariable name oder def
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
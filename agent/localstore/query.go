@@ -0,0 +1,223 @@
+package localstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/supabase"
+	bolt "go.etcd.io/bbolt"
+)
+
+// exportFormat kennzeichnet Exporte, damit ein späterer Import ins Backend sie erkennt
+const exportFormat = "zertifikat-waechter/standalone-export"
+
+// exportVersion wird erhöht, wenn sich der Aufbau eines Exports ändert
+const exportVersion = 1
+
+// Location ist ein Endpoint, auf dem ein Zertifikat gesehen wurde
+type Location struct {
+	Host        string    `json:"host"`
+	Port        int       `json:"port"`
+	SNI         string    `json:"sni,omitempty"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// InventoryItem ist ein Zertifikat mit seinen Fundorten
+type InventoryItem struct {
+	Certificate
+	Locations []Location `json:"locations"`
+}
+
+// forEach dekodiert alle Einträge eines Buckets in Schlüsselreihenfolge
+func forEach[T any](b *bolt.Bucket, fn func(T) error) error {
+	return b.ForEach(func(k, v []byte) error {
+		var row T
+		if err := json.Unmarshal(v, &row); err != nil {
+			return fmt.Errorf("decode %s: %w", k, err)
+		}
+		return fn(row)
+	})
+}
+
+// forEachReverse dekodiert die Einträge eines Buckets vom neuesten zum ältesten,
+// bis fn false liefert
+func forEachReverse[T any](b *bolt.Bucket, fn func(T) bool) error {
+	c := b.Cursor()
+	for k, v := c.Last(); k != nil; k, v = c.Prev() {
+		var row T
+		if err := json.Unmarshal(v, &row); err != nil {
+			return err
+		}
+		if !fn(row) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func certificatesByID(tx *bolt.Tx) (map[string]Certificate, error) {
+	certs := make(map[string]Certificate)
+	err := forEach(tx.Bucket(bucketCertificates), func(c Certificate) error {
+		certs[c.ID] = c
+		return nil
+	})
+	return certs, err
+}
+
+// Assets liefert alle Assets, sortiert nach host:port
+func (s *Store) Assets() ([]supabase.AssetData, error) {
+	list := []supabase.AssetData{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEach(tx.Bucket(bucketAssets), func(a supabase.AssetData) error {
+			list = append(list, a)
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Host != list[j].Host {
+			return list[i].Host < list[j].Host
+		}
+		return list[i].Port < list[j].Port
+	})
+	return list, err
+}
+
+// Inventory liefert alle Zertifikate mit ihren Fundorten, bald ablaufende zuerst
+func (s *Store) Inventory() ([]InventoryItem, error) {
+	list := []InventoryItem{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		certs, err := certificatesByID(tx)
+		if err != nil {
+			return err
+		}
+		locations := make(map[string][]Location)
+		err = forEach(tx.Bucket(bucketObservations), func(obs Observation) error {
+			locations[obs.CertificateID] = append(locations[obs.CertificateID], Location{
+				Host:        obs.Host,
+				Port:        obs.Port,
+				SNI:         obs.SNI,
+				FirstSeenAt: obs.FirstSeenAt,
+				LastSeenAt:  obs.LastSeenAt,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for id, cert := range certs {
+			item := InventoryItem{Certificate: cert, Locations: locations[id]}
+			if item.Locations == nil {
+				item.Locations = []Location{}
+			}
+			list = append(list, item)
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].NotAfter.Before(list[j].NotAfter) })
+	return list, err
+}
+
+// Expiring liefert die Zertifikate, die vor before ablaufen und noch auf mindestens
+// einem Endpoint ausgeliefert werden (bereits abgelaufene eingeschlossen)
+func (s *Store) Expiring(before time.Time) ([]InventoryItem, error) {
+	all, err := s.Inventory()
+	if err != nil {
+		return nil, err
+	}
+	list := []InventoryItem{}
+	for _, item := range all {
+		if item.NotAfter.Before(before) && len(item.Locations) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+// Runs liefert die letzten Läufe, neuester zuerst (limit <= 0 = alle)
+func (s *Store) Runs(limit int) ([]scanrun.Summary, error) {
+	list := []scanrun.Summary{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEach(tx.Bucket(bucketRuns), func(run scanrun.Summary) error {
+			list = append(list, run)
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.After(list[j].StartedAt) })
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list, err
+}
+
+// History liefert die Scan-Ergebnisse eines Endpoints, neuestes zuerst (limit <= 0 = alle)
+func (s *Store) History(host string, port int, limit int) ([]Outcome, error) {
+	list := []Outcome{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEachReverse(tx.Bucket(bucketOutcomes), func(o Outcome) bool {
+			if o.Host == host && o.Port == port {
+				list = append(list, o)
+			}
+			return limit <= 0 || len(list) < limit
+		})
+	})
+	return list, err
+}
+
+// Changes liefert die erkannten Zertifikatswechsel, neuester zuerst (limit <= 0 = alle)
+func (s *Store) Changes(limit int) ([]Change, error) {
+	list := []Change{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEachReverse(tx.Bucket(bucketChanges), func(c Change) bool {
+			list = append(list, c)
+			return limit <= 0 || len(list) < limit
+		})
+	})
+	return list, err
+}
+
+// Export schreibt den gesamten Datenbestand als JSON - je Tabelle die gespeicherten
+// Zeilen, so dass sie später ins Backend übernommen werden können
+func (s *Store) Export(w io.Writer) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		header, err := json.Marshal(map[string]interface{}{
+			"format":       exportFormat,
+			"version":      exportVersion,
+			"connector_id": s.connectorID,
+			"tenant_id":    TenantID,
+			"exported_at":  time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		// Kopf ohne schließende Klammer, dann die Tabellen als Rohdaten
+		if _, err := fmt.Fprintf(w, "%s,\"tables\":{", header[:len(header)-1]); err != nil {
+			return err
+		}
+		for i, name := range allBuckets[1:] { // ohne meta
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, "%q:[", name)
+			n := 0
+			err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+				if n > 0 {
+					io.WriteString(w, ",")
+				}
+				n++
+				_, err := w.Write(v)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			io.WriteString(w, "]")
+		}
+		_, err = io.WriteString(w, "}}\n")
+		return err
+	})
+}
@@ -0,0 +1,630 @@
+package localstore
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/supabase"
+	bolt "go.etcd.io/bbolt"
+)
+
+// TenantID steht im Standalone-Modus für den (nicht vorhandenen) Backend-Tenant.
+// Beim späteren Import ins Backend wird er durch den echten Tenant ersetzt.
+const TenantID = "local"
+
+// openTimeout: so lange wird auf die Dateisperre gewartet (die Datenbank gehört einem Prozess)
+const openTimeout = time.Second
+
+// Buckets heißen wie die Supabase-Tabellen, damit ein Export 1:1 zugeordnet werden kann
+var (
+	bucketMeta         = []byte("meta")
+	bucketAssets       = []byte("assets")                   // host:port
+	bucketCertificates = []byte("certificates")             // Fingerprint
+	bucketObservations = []byte("certificate_observations") // Zertifikat-ID|host|port|sni
+	bucketChecks       = []byte("checks")                   // Sequenz
+	bucketRuns         = []byte("scan_runs")                // Lauf-ID
+	bucketOutcomes     = []byte("scan_run_endpoints")       // Sequenz
+	bucketTransitions  = []byte("asset_transitions")        // Sequenz
+	bucketChanges      = []byte("certificate_changes")      // Sequenz
+	bucketDiscovery    = []byte("discovery_results")        // IP-Adresse
+	bucketLogs         = []byte("agent_logs")               // Sequenz
+)
+
+var allBuckets = [][]byte{
+	bucketMeta, bucketAssets, bucketCertificates, bucketObservations, bucketChecks, bucketRuns,
+	bucketOutcomes, bucketTransitions, bucketChanges, bucketDiscovery, bucketLogs,
+}
+
+// historyBuckets sind fortlaufende Verläufe mit dem Zeitstempel-Feld, nach dem sie altern
+var historyBuckets = map[string]string{
+	string(bucketChecks):      "ran_at",
+	string(bucketOutcomes):    "scanned_at",
+	string(bucketTransitions): "transitioned_at",
+	string(bucketChanges):     "detected_at",
+	string(bucketLogs):        "timestamp",
+}
+
+// ErrLocked: die Datenbank ist von einem anderen Prozess (dem laufenden Agent) geöffnet
+var ErrLocked = errors.New("database is locked by another process (agent running? use the status API instead)")
+
+// Certificate ist ein gespeichertes Zertifikat
+type Certificate struct {
+	ID string `json:"id"`
+	scanner.CertificateData
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Observation ist ein gespeicherter Fundort eines Zertifikats
+type Observation struct {
+	supabase.CertificateObservation
+	FirstSeenAt time.Time `json:"first_seen_at"`
+}
+
+// Check ist ein gespeichertes Prüfergebnis
+type Check struct {
+	ID string `json:"id"`
+	supabase.CheckData
+}
+
+// Outcome ist ein gespeichertes Endpoint-Ergebnis eines Laufs
+type Outcome struct {
+	ID    string `json:"id"`
+	RunID string `json:"run_id"`
+	scanrun.Outcome
+}
+
+// Transition ist ein gespeicherter Zustandswechsel eines Assets
+type Transition struct {
+	ID                  string     `json:"id"`
+	RunID               string     `json:"run_id,omitempty"`
+	AssetID             string     `json:"asset_id"`
+	Host                string     `json:"host"`
+	Port                int        `json:"port"`
+	FromStatus          string     `json:"from_status"`
+	ToStatus            string     `json:"to_status"`
+	Reason              string     `json:"reason"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccessAt       *time.Time `json:"last_success_at"`
+	TransitionedAt      time.Time  `json:"transitioned_at"`
+}
+
+// Change ist ein gespeicherter Zertifikatswechsel
+type Change struct {
+	ID      string `json:"id"`
+	RunID   string `json:"run_id,omitempty"`
+	AssetID string `json:"asset_id,omitempty"`
+	rotation.Event
+}
+
+// DiscoveryResult ist ein gespeicherter Host der Netzwerk-Discovery
+type DiscoveryResult struct {
+	scanner.DiscoveryResult
+	DiscoveredAt time.Time `json:"discovered_at"`
+}
+
+// LogEntry ist eine gespeicherte Agent-Meldung (in der Cloud das Agent-Log der UI)
+type LogEntry struct {
+	ConnectorName string                 `json:"connector_name"`
+	Level         string                 `json:"level"`
+	Message       string                 `json:"message"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+}
+
+// Store speichert die Ergebnisse des Agents in einer eingebetteten Datenbank (bbolt).
+// Er bietet dieselben Schreibzugriffe wie supabase.Client und ersetzt ihn im Standalone-Modus.
+type Store struct {
+	db          *bolt.DB
+	retention   time.Duration
+	connectorID string
+
+	mu     sync.Mutex
+	stats  map[string]*supabase.RequestStats
+	health supabase.BackendHealth
+}
+
+// Open öffnet (bzw. erstellt) die Datenbank unter path. Verläufe älter als retention
+// werden nach jedem Lauf gelöscht.
+func Open(path string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create database directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, openError(path, err)
+	}
+
+	s := &Store{db: db, retention: retention, stats: make(map[string]*supabase.RequestStats)}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		// Stabile Connector-ID, damit Läufe und Assets nach einem Neustart zusammengehören
+		meta := tx.Bucket(bucketMeta)
+		if id := meta.Get([]byte("connector_id")); id != nil {
+			s.connectorID = string(id)
+			return nil
+		}
+		s.connectorID = scanrun.NewID()
+		if err := meta.Put([]byte("connector_id"), []byte(s.connectorID)); err != nil {
+			return err
+		}
+		return meta.Put([]byte("created_at"), []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialize database: %w", err)
+	}
+	return s, nil
+}
+
+// OpenReadOnly öffnet eine bestehende Datenbank nur lesend (CLI)
+func OpenReadOnly(path string) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, openError(path, err)
+	}
+
+	s := &Store{db: db, stats: make(map[string]*supabase.RequestStats)}
+	db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(bucketMeta); meta != nil {
+			s.connectorID = string(meta.Get([]byte("connector_id")))
+		}
+		return nil
+	})
+	return s, nil
+}
+
+func openError(path string, err error) error {
+	if errors.Is(err, bolt.ErrTimeout) {
+		return fmt.Errorf("open %s: %w", path, ErrLocked)
+	}
+	return fmt.Errorf("open %s: %w", path, err)
+}
+
+// Close schließt die Datenbank
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Path liefert den Pfad der Datenbankdatei
+func (s *Store) Path() string {
+	return s.db.Path()
+}
+
+// ConnectorID liefert die lokal erzeugte Connector-ID
+func (s *Store) ConnectorID() string {
+	return s.connectorID
+}
+
+// update führt einen Schreibzugriff aus und zählt ihn für Metriken und Readiness
+func (s *Store) update(table string, fn func(tx *bolt.Tx) error) error {
+	err := s.db.Update(fn)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.stats[table]
+	if !ok {
+		stats = &supabase.RequestStats{Resource: table}
+		s.stats[table] = stats
+	}
+	stats.Requests++
+	if err != nil {
+		stats.Errors++
+		s.health.LastErrorAt = time.Now()
+		s.health.LastError = fmt.Sprintf("write %s: %v", table, err)
+		s.health.ConsecutiveErrors++
+	} else {
+		s.health.LastSuccessAt = time.Now()
+		s.health.ConsecutiveErrors = 0
+	}
+	return err
+}
+
+// RequestStats liefert die Schreibzugriffe und Fehler pro Tabelle seit dem Start
+func (s *Store) RequestStats() []supabase.RequestStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]supabase.RequestStats, 0, len(s.stats))
+	for _, stats := range s.stats {
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Resource < list[j].Resource })
+	return list
+}
+
+// Health liefert den Zustand der Datenbank anhand der letzten Schreibzugriffe
+func (s *Store) Health() supabase.BackendHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.health
+}
+
+func endpointKey(host string, port int) []byte {
+	return []byte(host + ":" + strconv.Itoa(port))
+}
+
+func put(b *bolt.Bucket, key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// appendRow speichert v unter der nächsten Sequenznummer (Einfügereihenfolge = Zeitreihenfolge)
+func appendRow(b *bolt.Bucket, v interface{}) error {
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return put(b, key, v)
+}
+
+// assetData baut den Asset-Datensatz aus dem Lebenszyklus-Zustand (wie supabase.Client)
+func (s *Store) assetData(rec lifecycle.Record, existing *supabase.AssetData) supabase.AssetData {
+	asset := supabase.AssetData{
+		TenantID:            TenantID,
+		ConnectorID:         s.connectorID,
+		Host:                rec.Host,
+		Port:                rec.Port,
+		Proto:               "tls",
+		Status:              string(rec.State),
+		ConsecutiveFailures: rec.ConsecutiveFailures,
+		LastSuccessAt:       rec.LastSuccessAt,
+		LastFailureAt:       rec.LastFailureAt,
+		LastCheckedAt:       rec.LastCheckedAt,
+	}
+	if rec.LastErrorClass != "" {
+		asset.LastErrorClass = &rec.LastErrorClass
+		asset.LastError = &rec.LastError
+	}
+	if existing != nil {
+		asset.ID = existing.ID
+		asset.CreatedAt = existing.CreatedAt
+	}
+	return asset
+}
+
+func getAsset(b *bolt.Bucket, key []byte) (*supabase.AssetData, error) {
+	data := b.Get(key)
+	if data == nil {
+		return nil, nil
+	}
+	var asset supabase.AssetData
+	if err := json.Unmarshal(data, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// UpsertAsset erstellt oder aktualisiert ein Asset und liefert seine ID
+func (s *Store) UpsertAsset(ctx context.Context, rec lifecycle.Record) (string, error) {
+	var id string
+	err := s.update(string(bucketAssets), func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAssets)
+		key := endpointKey(rec.Host, rec.Port)
+		existing, err := getAsset(b, key)
+		if err != nil {
+			return err
+		}
+		asset := s.assetData(rec, existing)
+		if existing == nil {
+			now := time.Now().UTC()
+			asset.ID = scanrun.NewID()
+			asset.CreatedAt = &now
+		}
+		id = asset.ID
+		return put(b, key, asset)
+	})
+	return id, err
+}
+
+// UpdateAssetStatus aktualisiert ein bestehendes Asset, ohne es anzulegen.
+// Liefert die Asset-ID oder "" wenn es das Asset noch nicht gibt.
+func (s *Store) UpdateAssetStatus(ctx context.Context, rec lifecycle.Record) (string, error) {
+	var id string
+	err := s.update(string(bucketAssets), func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAssets)
+		key := endpointKey(rec.Host, rec.Port)
+		existing, err := getAsset(b, key)
+		if err != nil || existing == nil {
+			return err
+		}
+		id = existing.ID
+		return put(b, key, s.assetData(rec, existing))
+	})
+	return id, err
+}
+
+// SetAssetStatus setzt nur den Status eines Assets
+func (s *Store) SetAssetStatus(ctx context.Context, assetID, status string) error {
+	return s.update(string(bucketAssets), func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAssets)
+		return b.ForEach(func(k, v []byte) error {
+			var asset supabase.AssetData
+			if err := json.Unmarshal(v, &asset); err != nil || asset.ID != assetID {
+				return err
+			}
+			asset.Status = status
+			return put(b, k, asset)
+		})
+	})
+}
+
+// ListAssets lädt alle Assets (Lebenszyklus nach einem Neustart fortsetzen)
+func (s *Store) ListAssets(ctx context.Context) ([]supabase.AssetData, error) {
+	return s.Assets()
+}
+
+// InsertAssetTransition protokolliert einen Zustandswechsel eines Assets
+func (s *Store) InsertAssetTransition(ctx context.Context, runID string, tr lifecycle.Transition) error {
+	row := Transition{
+		ID:                  scanrun.NewID(),
+		RunID:               runID,
+		AssetID:             tr.AssetID,
+		Host:                tr.Host,
+		Port:                tr.Port,
+		FromStatus:          string(tr.From),
+		ToStatus:            string(tr.To),
+		Reason:              tr.Reason,
+		ConsecutiveFailures: tr.ConsecutiveFailures,
+		LastSuccessAt:       tr.LastSuccessAt,
+		TransitionedAt:      tr.At.UTC(),
+	}
+	return s.update(string(bucketTransitions), func(tx *bolt.Tx) error {
+		return appendRow(tx.Bucket(bucketTransitions), row)
+	})
+}
+
+// UpsertCertificate speichert ein Zertifikat (eindeutig per Fingerprint) und liefert seine ID
+func (s *Store) UpsertCertificate(ctx context.Context, cert *scanner.CertificateData) (string, error) {
+	if cert.TenantID == "" {
+		cert.TenantID = TenantID
+	}
+
+	var id string
+	err := s.update(string(bucketCertificates), func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCertificates)
+		key := []byte(cert.Fingerprint)
+		now := time.Now().UTC()
+
+		stored := Certificate{ID: scanrun.NewID(), CreatedAt: now}
+		if data := b.Get(key); data != nil {
+			if err := json.Unmarshal(data, &stored); err != nil {
+				return err
+			}
+		}
+		stored.CertificateData = *cert
+		stored.UpdatedAt = now
+		id = stored.ID
+		return put(b, key, stored)
+	})
+	return id, err
+}
+
+// UpsertCertificateObservation hält fest, dass ein Zertifikat auf einem Endpoint gesehen wurde
+func (s *Store) UpsertCertificateObservation(ctx context.Context, obs supabase.CertificateObservation) error {
+	if obs.TenantID == "" {
+		obs.TenantID = TenantID
+	}
+	return s.update(string(bucketObservations), func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketObservations)
+		key := []byte(fmt.Sprintf("%s|%s|%d|%s", obs.CertificateID, obs.Host, obs.Port, obs.SNI))
+
+		row := Observation{CertificateObservation: obs, FirstSeenAt: obs.LastSeenAt}
+		if data := b.Get(key); data != nil {
+			var existing Observation
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+			row.FirstSeenAt = existing.FirstSeenAt
+		}
+		return put(b, key, row)
+	})
+}
+
+// ListEndpointCertificates lädt alle Fundorte, älteste zuerst - je Endpoint ist damit
+// der letzte Eintrag das aktuelle Zertifikat
+func (s *Store) ListEndpointCertificates(ctx context.Context) ([]supabase.EndpointCertificate, error) {
+	var list []supabase.EndpointCertificate
+	err := s.db.View(func(tx *bolt.Tx) error {
+		certs, err := certificatesByID(tx)
+		if err != nil {
+			return err
+		}
+		return forEach(tx.Bucket(bucketObservations), func(obs Observation) error {
+			cert, ok := certs[obs.CertificateID]
+			if !ok {
+				return nil
+			}
+			list = append(list, supabase.EndpointCertificate{
+				Host:          obs.Host,
+				Port:          obs.Port,
+				SNI:           obs.SNI,
+				CertificateID: obs.CertificateID,
+				LastSeenAt:    obs.LastSeenAt,
+				Certificate:   cert.CertificateData,
+			})
+			return nil
+		})
+	})
+	sort.SliceStable(list, func(i, j int) bool { return list[i].LastSeenAt.Before(list[j].LastSeenAt) })
+	return list, err
+}
+
+// InsertCertificateChange speichert einen erkannten Zertifikatswechsel mit Diff
+func (s *Store) InsertCertificateChange(ctx context.Context, runID, assetID string, event rotation.Event) error {
+	row := Change{ID: scanrun.NewID(), RunID: runID, AssetID: assetID, Event: event}
+	return s.update(string(bucketChanges), func(tx *bolt.Tx) error {
+		return appendRow(tx.Bucket(bucketChanges), row)
+	})
+}
+
+// InsertCheck speichert das Prüfergebnis eines Zertifikats
+func (s *Store) InsertCheck(ctx context.Context, check *supabase.CheckData) error {
+	row := Check{ID: scanrun.NewID(), CheckData: *check}
+	return s.update(string(bucketChecks), func(tx *bolt.Tx) error {
+		return appendRow(tx.Bucket(bucketChecks), row)
+	})
+}
+
+// CreateScanRun legt einen neuen Scan-Lauf an
+func (s *Store) CreateScanRun(ctx context.Context, run scanrun.Summary) error {
+	return s.update(string(bucketRuns), func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucketRuns), []byte(run.ID), run)
+	})
+}
+
+// FinishScanRun schreibt Endstatus und Statistiken eines Laufs und löscht abgelaufene Verläufe
+func (s *Store) FinishScanRun(ctx context.Context, run scanrun.Summary) error {
+	return s.update(string(bucketRuns), func(tx *bolt.Tx) error {
+		if err := put(tx.Bucket(bucketRuns), []byte(run.ID), run); err != nil {
+			return err
+		}
+		if s.retention <= 0 {
+			return nil
+		}
+		return prune(tx, time.Now().Add(-s.retention))
+	})
+}
+
+// InsertScanOutcomes speichert die Endpoint-Ergebnisse eines Laufs
+func (s *Store) InsertScanOutcomes(ctx context.Context, runID string, outcomes []scanrun.Outcome) error {
+	if len(outcomes) == 0 {
+		return nil
+	}
+	return s.update(string(bucketOutcomes), func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketOutcomes)
+		for _, o := range outcomes {
+			if err := appendRow(b, Outcome{ID: scanrun.NewID(), RunID: runID, Outcome: o}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpsertDiscoveryResult speichert einen Host der Netzwerk-Discovery (eindeutig per IP)
+func (s *Store) UpsertDiscoveryResult(ctx context.Context, result *scanner.DiscoveryResult) error {
+	row := DiscoveryResult{DiscoveryResult: *result, DiscoveredAt: time.Now().UTC()}
+	return s.update(string(bucketDiscovery), func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucketDiscovery), []byte(result.IPAddress), row)
+	})
+}
+
+// SendLog speichert eine Agent-Meldung
+func (s *Store) SendLog(ctx context.Context, connectorName, level, message string, metadata map[string]interface{}) error {
+	row := LogEntry{
+		ConnectorName: connectorName,
+		Level:         level,
+		Message:       message,
+		Metadata:      metadata,
+		Timestamp:     time.Now().UTC(),
+	}
+	return s.update(string(bucketLogs), func(tx *bolt.Tx) error {
+		return appendRow(tx.Bucket(bucketLogs), row)
+	})
+}
+
+// ReportScanState speichert den letzten Scan-Zustand
+func (s *Store) ReportScanState(ctx context.Context, seq int64, state interface{}) error {
+	return s.update(string(bucketMeta), func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucketMeta), []byte("scan_state"), state)
+	})
+}
+
+// MarkConnectorOffline hält Zeitpunkt und Grund des Beendens fest
+func (s *Store) MarkConnectorOffline(ctx context.Context, reason string) error {
+	return s.update(string(bucketMeta), func(tx *bolt.Tx) error {
+		return put(tx.Bucket(bucketMeta), []byte("offline"), map[string]interface{}{
+			"offline_at":     time.Now().UTC(),
+			"offline_reason": reason,
+		})
+	})
+}
+
+// prune löscht Verläufe, Läufe und Fundorte, die älter als cutoff sind
+func prune(tx *bolt.Tx, cutoff time.Time) error {
+	// Verläufe sind nach Zeit sortiert - löschen bis zum ersten jüngeren Eintrag
+	for name, field := range historyBuckets {
+		b := tx.Bucket([]byte(name))
+		var keys [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			at, err := timeField(v, field)
+			if err == nil && !at.Before(cutoff) {
+				break
+			}
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		if err := deleteKeys(b, keys); err != nil {
+			return err
+		}
+	}
+
+	if err := deleteWhere(tx.Bucket(bucketRuns), func(v []byte) bool {
+		at, err := timeField(v, "started_at")
+		return err == nil && at.Before(cutoff)
+	}); err != nil {
+		return err
+	}
+	return deleteWhere(tx.Bucket(bucketObservations), func(v []byte) bool {
+		at, err := timeField(v, "last_seen_at")
+		return err == nil && at.Before(cutoff)
+	})
+}
+
+// deleteWhere löscht alle Einträge, für die match true liefert
+func deleteWhere(b *bolt.Bucket, match func(v []byte) bool) error {
+	var keys [][]byte
+	b.ForEach(func(k, v []byte) error {
+		if match(v) {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	return deleteKeys(b, keys)
+}
+
+func deleteKeys(b *bolt.Bucket, keys [][]byte) error {
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeField liest einen Zeitstempel aus einem gespeicherten JSON-Objekt
+func timeField(data []byte, field string) (time.Time, error) {
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return time.Time{}, err
+	}
+	var at time.Time
+	err := json.Unmarshal(row[field], &at)
+	return at, err
+}
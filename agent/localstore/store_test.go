@@ -0,0 +1,524 @@
+package localstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/supabase"
+	bolt "go.etcd.io/bbolt"
+)
+
+const day = 24 * time.Hour
+
+var ctx = context.Background()
+
+// openTemp öffnet eine neue Datenbank im Testverzeichnis
+func openTemp(t *testing.T, retention time.Duration) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "agent.db"), retention)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// cert ist ein Zertifikat mit Fingerprint fp, das nach notAfter abläuft
+func cert(fp string, notAfter time.Time) *scanner.CertificateData {
+	return &scanner.CertificateData{
+		Fingerprint: fp,
+		SubjectCN:   fp + ".example",
+		Issuer:      "Test CA",
+		NotBefore:   notAfter.Add(-90 * day),
+		NotAfter:    notAfter,
+	}
+}
+
+// storeCert speichert ein Zertifikat und seinen Fundort host:443, zuletzt gesehen seen
+func storeCert(t *testing.T, s *Store, c *scanner.CertificateData, host string, seen time.Time) string {
+	t.Helper()
+	id, err := s.UpsertCertificate(ctx, c)
+	if err != nil {
+		t.Fatalf("UpsertCertificate: %v", err)
+	}
+	if host != "" {
+		obs := supabase.CertificateObservation{CertificateID: id, Host: host, Port: 443, SNI: host, LastSeenAt: seen}
+		if err := s.UpsertCertificateObservation(ctx, obs); err != nil {
+			t.Fatalf("UpsertCertificateObservation: %v", err)
+		}
+	}
+	return id
+}
+
+// TestOpenSchema: Open legt alle Tabellen an, die Connector-ID übersteht einen Neustart
+func TestOpenSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "agent.db")
+	s, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	id := s.ConnectorID()
+	if id == "" || s.Path() != path {
+		t.Errorf("ConnectorID = %q, Path = %q", id, s.Path())
+	}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if tx.Bucket(name) == nil {
+				t.Errorf("bucket %s missing", name)
+			}
+		}
+		if tx.Bucket(bucketMeta).Get([]byte("created_at")) == nil {
+			t.Error("created_at missing")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range historyBuckets {
+		if !containsBucket(name) {
+			t.Errorf("history bucket %s not created by Open", name)
+		}
+	}
+	s.Close()
+
+	s, err = Open(path, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if s.ConnectorID() != id {
+		t.Errorf("ConnectorID after reopen = %q, want %q", s.ConnectorID(), id)
+	}
+	s.Close()
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer ro.Close()
+	if ro.ConnectorID() != id {
+		t.Errorf("read-only ConnectorID = %q, want %q", ro.ConnectorID(), id)
+	}
+}
+
+func containsBucket(name string) bool {
+	for _, b := range allBuckets {
+		if string(b) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TestOpenLocked: eine vom laufenden Agent geöffnete Datenbank liefert ErrLocked
+func TestOpenLocked(t *testing.T) {
+	s := openTemp(t, 0)
+	if _, err := Open(s.Path(), 0); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Open = %v, want ErrLocked", err)
+	}
+	if _, err := OpenReadOnly(s.Path()); !errors.Is(err, ErrLocked) {
+		t.Errorf("OpenReadOnly while locked = %v, want ErrLocked", err)
+	}
+	if _, err := OpenReadOnly(filepath.Join(t.TempDir(), "missing.db")); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("OpenReadOnly of a missing file = %v", err)
+	}
+}
+
+func TestAssets(t *testing.T) {
+	s := openTemp(t, 0)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Ein noch nicht gespeichertes Asset wird nicht angelegt
+	if id, err := s.UpdateAssetStatus(ctx, lifecycle.Record{Host: "b.example", Port: 443}); id != "" || err != nil {
+		t.Errorf("UpdateAssetStatus of a missing asset = %q, %v", id, err)
+	}
+
+	ids := make(map[string]string)
+	for _, rec := range []lifecycle.Record{
+		{Host: "b.example", Port: 443, State: lifecycle.StateActive, LastSuccessAt: &now},
+		{Host: "a.example", Port: 8443, State: lifecycle.StateActive},
+		{Host: "a.example", Port: 443, State: lifecycle.StateActive},
+	} {
+		id, err := s.UpsertAsset(ctx, rec)
+		if err != nil || id == "" {
+			t.Fatalf("UpsertAsset(%s:%d) = %q, %v", rec.Host, rec.Port, id, err)
+		}
+		ids[rec.Host+":"+strconv.Itoa(rec.Port)] = id
+	}
+
+	// Upsert und Update behalten ID und Anlagezeitpunkt
+	rec := lifecycle.Record{Host: "b.example", Port: 443, State: lifecycle.StateDegraded, ConsecutiveFailures: 1,
+		LastSuccessAt: &now, LastErrorClass: "timeout", LastError: "dial timeout"}
+	if id, err := s.UpsertAsset(ctx, rec); id != ids["b.example:443"] || err != nil {
+		t.Errorf("second UpsertAsset = %q, %v; want %q", id, err, ids["b.example:443"])
+	}
+	if id, err := s.UpdateAssetStatus(ctx, rec); id != ids["b.example:443"] || err != nil {
+		t.Errorf("UpdateAssetStatus = %q, %v; want %q", id, err, ids["b.example:443"])
+	}
+	if err := s.SetAssetStatus(ctx, ids["a.example:8443"], "retired"); err != nil {
+		t.Fatalf("SetAssetStatus: %v", err)
+	}
+
+	assets, err := s.ListAssets(ctx)
+	if err != nil {
+		t.Fatalf("ListAssets: %v", err)
+	}
+	var got []string
+	for _, a := range assets {
+		got = append(got, a.Host+":"+strconv.Itoa(a.Port)+" "+a.Status)
+		if a.TenantID != TenantID || a.ConnectorID != s.ConnectorID() || a.Proto != "tls" || a.CreatedAt == nil {
+			t.Errorf("asset %s:%d = %+v", a.Host, a.Port, a)
+		}
+	}
+	want := []string{"a.example:443 active", "a.example:8443 retired", "b.example:443 degraded"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("assets = %v, want %v", got, want)
+	}
+	b := assets[2]
+	if b.ConsecutiveFailures != 1 || b.LastErrorClass == nil || *b.LastErrorClass != "timeout" || b.LastSuccessAt == nil || !b.LastSuccessAt.Equal(now) {
+		t.Errorf("lifecycle fields = %+v", b)
+	}
+}
+
+// TestCertificates: Zertifikate sind eindeutig per Fingerprint, Fundorte per Endpoint
+func TestCertificates(t *testing.T) {
+	s := openTemp(t, 0)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	first := cert("aa", now.Add(30*day))
+	id := storeCert(t, s, first, "www.example", now.Add(-2*time.Hour))
+	if first.TenantID != TenantID {
+		t.Errorf("TenantID = %q", first.TenantID)
+	}
+	again := cert("aa", now.Add(30*day))
+	again.Issuer = "Renamed CA"
+	if got := storeCert(t, s, again, "www.example", now.Add(-time.Hour)); got != id {
+		t.Errorf("same fingerprint got ID %q, want %q", got, id)
+	}
+	other := storeCert(t, s, cert("bb", now.Add(60*day)), "api.example", now.Add(-90*time.Minute))
+	if other == id {
+		t.Error("different fingerprints share an ID")
+	}
+
+	list, err := s.ListEndpointCertificates(ctx)
+	if err != nil {
+		t.Fatalf("ListEndpointCertificates: %v", err)
+	}
+	if len(list) != 2 || list[0].Host != "api.example" || list[1].Host != "www.example" {
+		t.Fatalf("endpoint certificates = %+v, want oldest first", list)
+	}
+	if list[1].CertificateID != id || list[1].Certificate.Issuer != "Renamed CA" || !list[1].LastSeenAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("www.example = %+v", list[1])
+	}
+
+	inv, err := s.Inventory()
+	if err != nil {
+		t.Fatalf("Inventory: %v", err)
+	}
+	if len(inv) != 2 || inv[0].ID != id || len(inv[0].Locations) != 1 {
+		t.Fatalf("inventory = %+v", inv)
+	}
+	// Der erste Fund bleibt beim erneuten Sehen erhalten
+	loc := inv[0].Locations[0]
+	if !loc.FirstSeenAt.Equal(now.Add(-2*time.Hour)) || !loc.LastSeenAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("location = %+v", loc)
+	}
+}
+
+// TestExpiring: nur ausgelieferte Zertifikate, bereits abgelaufene eingeschlossen
+func TestExpiring(t *testing.T) {
+	s := openTemp(t, 0)
+	now := time.Now().UTC()
+	storeCert(t, s, cert("expired", now.Add(-day)), "old.example", now)
+	storeCert(t, s, cert("soon", now.Add(10*day)), "soon.example", now)
+	storeCert(t, s, cert("later", now.Add(60*day)), "later.example", now)
+	storeCert(t, s, cert("unused", now.Add(5*day)), "", now)
+
+	inv, err := s.Inventory()
+	if err != nil {
+		t.Fatalf("Inventory: %v", err)
+	}
+	var order []string
+	for _, item := range inv {
+		order = append(order, item.Fingerprint)
+		if item.Locations == nil {
+			t.Errorf("%s: Locations is nil", item.Fingerprint)
+		}
+	}
+	if want := []string{"expired", "unused", "soon", "later"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("inventory order = %v, want %v", order, want)
+	}
+
+	list, err := s.Expiring(now.Add(30 * day))
+	if err != nil {
+		t.Fatalf("Expiring: %v", err)
+	}
+	var got []string
+	for _, item := range list {
+		got = append(got, item.Fingerprint)
+	}
+	if want := []string{"expired", "soon"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expiring = %v, want %v", got, want)
+	}
+}
+
+// TestHistoryQueries: Läufe, Endpoint-Verlauf und Wechsel kommen neueste zuerst
+func TestHistoryQueries(t *testing.T) {
+	s := openTemp(t, 0)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for i, id := range []string{"run-1", "run-2", "run-3"} {
+		run := scanrun.Summary{ID: id, StartedAt: now.Add(time.Duration(i) * time.Hour)}
+		if err := s.CreateScanRun(ctx, run); err != nil {
+			t.Fatalf("CreateScanRun: %v", err)
+		}
+		outcomes := []scanrun.Outcome{
+			{Host: "a.example", Port: 443, Status: "success", ScannedAt: run.StartedAt},
+			{Host: "a.example", Port: 8443, Status: "failed", ScannedAt: run.StartedAt},
+		}
+		if err := s.InsertScanOutcomes(ctx, id, outcomes); err != nil {
+			t.Fatalf("InsertScanOutcomes: %v", err)
+		}
+		event := rotation.Event{Host: "a.example", Port: 443, NewFingerprint: id, DetectedAt: run.StartedAt}
+		if err := s.InsertCertificateChange(ctx, id, "asset-1", event); err != nil {
+			t.Fatalf("InsertCertificateChange: %v", err)
+		}
+	}
+	finished := now.Add(time.Minute)
+	if err := s.FinishScanRun(ctx, scanrun.Summary{ID: "run-1", StartedAt: now, FinishedAt: &finished, EndpointsTotal: 2}); err != nil {
+		t.Fatalf("FinishScanRun: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query func(limit int) ([]string, error)
+		all   []string
+	}{
+		{"runs", func(limit int) ([]string, error) {
+			runs, err := s.Runs(limit)
+			var ids []string
+			for _, r := range runs {
+				ids = append(ids, r.ID)
+			}
+			return ids, err
+		}, []string{"run-3", "run-2", "run-1"}},
+		{"history", func(limit int) ([]string, error) {
+			outcomes, err := s.History("a.example", 443, limit)
+			var ids []string
+			for _, o := range outcomes {
+				if o.Port != 443 {
+					t.Errorf("history contains port %d", o.Port)
+				}
+				ids = append(ids, o.RunID)
+			}
+			return ids, err
+		}, []string{"run-3", "run-2", "run-1"}},
+		{"changes", func(limit int) ([]string, error) {
+			changes, err := s.Changes(limit)
+			var ids []string
+			for _, c := range changes {
+				ids = append(ids, c.NewFingerprint)
+			}
+			return ids, err
+		}, []string{"run-3", "run-2", "run-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, limit := range []int{0, -1, 2, 10} {
+				want := tt.all
+				if limit > 0 && limit < len(want) {
+					want = want[:limit]
+				}
+				got, err := tt.query(limit)
+				if err != nil || !reflect.DeepEqual(got, want) {
+					t.Errorf("limit %d: %v, %v; want %v", limit, got, err, want)
+				}
+			}
+		})
+	}
+
+	runs, _ := s.Runs(0)
+	if last := runs[2]; last.FinishedAt == nil || last.EndpointsTotal != 2 {
+		t.Errorf("finished run = %+v", last)
+	}
+	if list, err := s.History("b.example", 443, 0); err != nil || len(list) != 0 {
+		t.Errorf("history of an unknown endpoint = %+v, %v", list, err)
+	}
+}
+
+// TestRetention: FinishScanRun löscht Verläufe, Läufe und Fundorte älter als retention
+func TestRetention(t *testing.T) {
+	s := openTemp(t, 7*day)
+	now := time.Now().UTC()
+	old, recent := now.Add(-8*day), now.Add(-6*day)
+
+	for _, at := range []time.Time{old, recent} {
+		if err := s.InsertCheck(ctx, &supabase.CheckData{CertificateID: "c", Status: "success", RanAt: at}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertAssetTransition(ctx, "", lifecycle.Transition{Host: "a.example", Port: 443, From: lifecycle.StateActive, To: lifecycle.StateDegraded, At: at}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertCertificateChange(ctx, "", "", rotation.Event{Host: "a.example", Port: 443, DetectedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.InsertScanOutcomes(ctx, "", []scanrun.Outcome{{Host: "a.example", Port: 443, ScannedAt: at}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateScanRun(ctx, scanrun.Summary{ID: at.Format(time.RFC3339), StartedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storeCert(t, s, cert("gone", now.Add(30*day)), "gone.example", old)
+	storeCert(t, s, cert("kept", now.Add(30*day)), "kept.example", recent)
+	if err := s.SendLog(ctx, "agent", "info", "scan finished", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.FinishScanRun(ctx, scanrun.Summary{ID: "current", StartedAt: now}); err != nil {
+		t.Fatalf("FinishScanRun: %v", err)
+	}
+
+	want := map[string]int{
+		string(bucketChecks):       1,
+		string(bucketTransitions):  1,
+		string(bucketChanges):      1,
+		string(bucketOutcomes):     1,
+		string(bucketRuns):         2,
+		string(bucketObservations): 1,
+		string(bucketLogs):         1,
+		string(bucketCertificates): 2, // Zertifikate bleiben für das Inventar erhalten
+	}
+	s.db.View(func(tx *bolt.Tx) error {
+		for name, n := range want {
+			if got := tx.Bucket([]byte(name)).Stats().KeyN; got != n {
+				t.Errorf("%s: %d rows, want %d", name, got, n)
+			}
+		}
+		return nil
+	})
+	if expiring, _ := s.Expiring(now.Add(365 * day)); len(expiring) != 1 || expiring[0].Fingerprint != "kept" {
+		t.Errorf("Expiring after prune = %+v", expiring)
+	}
+}
+
+// TestExport: ein Export enthält Kopf und je Tabelle die gespeicherten Zeilen
+func TestExport(t *testing.T) {
+	s := openTemp(t, 0)
+	now := time.Now().UTC()
+	storeCert(t, s, cert("aa", now.Add(30*day)), "www.example", now)
+	if _, err := s.UpsertAsset(ctx, lifecycle.Record{Host: "www.example", Port: 443, State: lifecycle.StateActive}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertDiscoveryResult(ctx, &scanner.DiscoveryResult{IPAddress: "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendLog(ctx, "agent", "info", "started", map[string]interface{}{"version": "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReportScanState(ctx, 1, map[string]string{"phase": "idle"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Export(&buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	var export struct {
+		Format      string                       `json:"format"`
+		Version     int                          `json:"version"`
+		ConnectorID string                       `json:"connector_id"`
+		TenantID    string                       `json:"tenant_id"`
+		Tables      map[string][]json.RawMessage `json:"tables"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if export.Format != exportFormat || export.Version != exportVersion || export.ConnectorID != s.ConnectorID() || export.TenantID != TenantID {
+		t.Errorf("export header = %+v", export)
+	}
+	if _, ok := export.Tables["meta"]; ok {
+		t.Error("meta exported")
+	}
+	rows := map[string]int{
+		"assets": 1, "certificates": 1, "certificate_observations": 1, "discovery_results": 1, "agent_logs": 1,
+		"checks": 0, "scan_runs": 0, "scan_run_endpoints": 0, "asset_transitions": 0, "certificate_changes": 0,
+	}
+	if len(export.Tables) != len(rows) {
+		t.Errorf("%d tables exported, want %d", len(export.Tables), len(rows))
+	}
+	for name, n := range rows {
+		table, ok := export.Tables[name]
+		if !ok || len(table) != n {
+			t.Errorf("table %s: %d rows (present %v), want %d", name, len(table), ok, n)
+		}
+	}
+}
+
+// TestHealthAndStats: Schreibzugriffe werden pro Tabelle gezählt, Fehler setzen Health
+func TestHealthAndStats(t *testing.T) {
+	s := openTemp(t, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := s.UpsertAsset(ctx, lifecycle.Record{Host: "a.example", Port: 443}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SendLog(ctx, "agent", "info", "ok", nil); err != nil {
+		t.Fatal(err)
+	}
+	if h := s.Health(); h.LastSuccessAt.IsZero() || h.ConsecutiveErrors != 0 {
+		t.Errorf("health after writes = %+v", h)
+	}
+
+	// Ein nicht serialisierbarer Zustand lässt den Schreibzugriff scheitern
+	if err := s.ReportScanState(ctx, 1, func() {}); err == nil {
+		t.Fatal("ReportScanState with a func succeeded")
+	}
+	h := s.Health()
+	if h.ConsecutiveErrors != 1 || h.LastErrorAt.IsZero() || h.LastError == "" {
+		t.Errorf("health after error = %+v", h)
+	}
+
+	want := []supabase.RequestStats{
+		{Resource: "agent_logs", Requests: 1},
+		{Resource: "assets", Requests: 2},
+		{Resource: "meta", Requests: 1, Errors: 1},
+	}
+	if got := s.RequestStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RequestStats = %+v, want %+v", got, want)
+	}
+}
+
+func TestTimeField(t *testing.T) {
+	at := time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		data    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"present", `{"ran_at":"2026-03-06T10:00:00Z"}`, at, false},
+		{"missing", `{"other":1}`, time.Time{}, true},
+		{"not a time", `{"ran_at":"yesterday"}`, time.Time{}, true},
+		{"not json", `nope`, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := timeField([]byte(tt.data), "ran_at")
+			if (err != nil) != tt.wantErr || !got.Equal(tt.want) {
+				t.Errorf("timeField = %s, %v", got, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,7 +14,15 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/health"
+	"github.com/zertifikat-waechter/agent/inventory"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/localstore"
+	"github.com/zertifikat-waechter/agent/metrics"
+	"github.com/zertifikat-waechter/agent/rotation"
 	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/scheduler"
 	"github.com/zertifikat-waechter/agent/supabase"
 )
 
@@ -23,16 +32,22 @@ func main() {
 	// Load .env file
 	_ = godotenv.Load()
 
+	os.Exit(runCLI(os.Args[1:]))
+}
+
+// runAgent startet den Agent als Daemon (Subcommand "run")
+func runAgent() {
+
 	// Configure logging
 	log.SetFormatter(&logrus.JSONFormatter{})
 	log.SetOutput(os.Stdout)
 	log.SetLevel(logrus.InfoLevel)
 
-	if os.Getenv("LOG_LEVEL") == "DEBUG" {
-		log.SetLevel(logrus.DebugLevel)
-	}
-
-	log.Info("Starting Zertifikat-Wächter Agent")
+	info := currentBuildInfo()
+	log.WithFields(logrus.Fields{
+		"version": info.Version,
+		"commit":  info.Commit,
+	}).Info("Starting Zertifikat-Wächter Agent")
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -47,283 +62,385 @@ func main() {
 		"scan_targets":  cfg.ScanTargets,
 	}).Info("Configuration loaded")
 
-	// Initialize Supabase client
-	supabaseClient := supabase.NewClient(cfg.SupabaseURL, cfg.SupabaseAPIKey)
-
-	// Validate and register with token
+	// Ergebnisse an Supabase oder - im Standalone-Modus - in die lokale Datenbank
 	ctx := context.Background()
-	if cfg.ConnectorToken != "" {
-		log.Info("Validating connector token...")
-		connector, err := supabaseClient.ValidateAndRegisterWithToken(ctx, cfg.ConnectorToken)
+	var results backend
+	var supabaseClient *supabase.Client
+	var db *localstore.Store
+	if cfg.Standalone {
+		db, err = localstore.Open(cfg.StandaloneDB, cfg.StandaloneRetention)
 		if err != nil {
-			log.Fatalf("Token validation failed: %v", err)
+			log.Fatalf("Failed to open local database: %v", err)
 		}
-		
+		defer db.Close()
+
+		cfg.ConnectorID = db.ConnectorID()
+		cfg.TenantID = localstore.TenantID
+		results = db
 		log.WithFields(logrus.Fields{
-			"connector_id": connector.ID,
-			"tenant_id":    connector.TenantID,
-			"name":         connector.Name,
-		}).Info("✅ Connector authenticated successfully!")
-		
-		cfg.ConnectorID = connector.ID
-		cfg.TenantID = connector.TenantID
+			"database":     db.Path(),
+			"connector_id": cfg.ConnectorID,
+			"retention":    cfg.StandaloneRetention,
+		}).Info("Running standalone - results are stored in the local database")
 	} else {
-		log.Fatal("CONNECTOR_TOKEN is required! Generiere ihn über die UI (Connectors-Seite)")
+		supabaseClient = connectSupabase(ctx, cfg)
+		results = supabaseClient
+	}
+
+	// Zusätzliche Ergebnis-Sinks (stdout, JSONL-Datei, Webhook) und Benachrichtigungen
+	sinks, notifier, err := newSinks(cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up result sinks: %v", err)
+	}
+	if sinks.Len() > 0 {
+		log.WithFields(logrus.Fields{"sinks": sinks.Len(), "file": cfg.SinksFile}).Info("Result sinks configured")
+	}
+	if notifier != nil {
+		log.WithFields(logrus.Fields{"channels": len(notifier.Stats()), "file": cfg.NotifyFile}).Info("Notifications configured")
+	}
+	sinks.Start()
+
+	// Config-Store: alle Laufzeit-Einstellungen werden als unveränderliche Snapshots gelesen
+	store, err := config.NewStore(cfg)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
 
 	// Initialize scanners
 	certScanner := scanner.NewScanner(cfg.ScanTimeout, log)
+	if cfg.RevocationCheck {
+		certScanner.SetRevocationChecker(scanner.NewRevocationChecker(cfg.ScanTimeout))
+	}
 	networkScanner := scanner.NewNetworkScanner(cfg.ScanTimeout, log)
+	// Asset-Lebenszyklus mit dem gespeicherten Stand fortsetzen; der Scheduler übernimmt
+	// den letzten Scan pro Host, um nach einem Neustart nur Überfälliges nachzuholen
+	assets := lifecycle.NewTracker(assetThresholds(store.Current()))
+	sched := scheduler.New(cfg.ConnectorID)
+	if existing, err := results.ListAssets(ctx); err != nil {
+		log.WithError(err).Warn("Failed to load assets - lifecycle starts fresh")
+	} else {
+		records := make([]lifecycle.Record, 0, len(existing))
+		for _, asset := range existing {
+			records = append(records, asset.Record())
+		}
+		assets.Seed(records)
+		seedSchedule(sched, records)
+	}
 
-	// Start health check server
-	go startHealthCheckServer(cfg.HealthCheckPort, log)
+	// Zuletzt gesehene Zertifikate pro Endpoint für die Wechsel-Erkennung
+	rotations := rotation.NewDetector()
+	if observed, err := results.ListEndpointCertificates(ctx); err != nil {
+		log.WithError(err).Warn("Failed to load known certificates - rotation detection starts fresh")
+	} else {
+		certs := make(map[string][]scheduler.CertTimes)
+		for _, ec := range observed {
+			ec.Certificate.SNI = ec.SNI
+			rotations.Seed(ec.Host, ec.Port, rotation.Known{CertificateID: ec.CertificateID, Certificate: ec.Certificate})
+			certs[ec.Host] = append(certs[ec.Host], scheduler.CertTimes{NotBefore: ec.Certificate.NotBefore, NotAfter: ec.Certificate.NotAfter})
+		}
+		// Adaptive Intervalle aus den bekannten Zertifikaten - gilt schon für den ersten Termin
+		adaptSchedule(sched, store.Current(), certs, time.Now())
+	}
 
-	// Start config polling (liest Änderungen aus Backend)
-	go startConfigPolling(ctx, supabaseClient, cfg, log)
+	applyRuntimeSettings(store.Current(), certScanner, networkScanner, assets)
+
+	// Graceful Shutdown: SIGTERM/SIGINT brechen ctx ab - laufende Scans, Discovery und
+	// Hintergrund-Goroutinen beenden sich, danach wird geordnet heruntergefahren
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		sig := <-sigChan
+		log.WithField("signal", sig.String()).Info("Shutdown requested - cancelling running scans")
+		cancel(fmt.Errorf("agent stopped (%s)", sig))
+	}()
+
+	// Scan-Fortschritt (gedrosselt, eigene Spalte - connectors.config bleibt unangetastet)
+	progress := supabase.NewProgressReporter(results, 2*time.Second, log)
+	go progress.Run(ctx)
+
+	// Outbox für Schreibzugriffe, auf die ein Scan nicht warten muss
+	outbox := supabase.NewOutbox(1000, log)
+	go outbox.Run(ctx)
+
+	// Prometheus-Metriken (/metrics)
+	registry := metrics.New()
+
+	// Readiness aus Komponentenprüfungen (readyz meldet beim Shutdown sofort "not ready")
+	checker := health.NewChecker()
+	connectorState := health.NewState("connector", health.StatusOK, "registered as "+cfg.ConnectorID)
+	if db != nil {
+		connectorState.Set(health.StatusOK, "standalone, database "+db.Path())
+	}
+	configState := health.NewState("config", health.StatusOK, fmt.Sprintf("local configuration applied (version %d)", store.Current().Version))
+
+	a := &agent{
+		cfg:            cfg,
+		client:         results,
+		certScanner:    certScanner,
+		networkScanner: networkScanner,
+		progress:       progress,
+		outbox:         outbox,
+		assets:         assets,
+		rotations:      rotations,
+		schedule:       sched,
+		metrics:        registry,
+		inventory:      inventory.New(),
+		sinks:          sinks,
+		notifier:       notifier,
+	}
+	if notifier != nil {
+		// Tickets listen alle Fundorte eines Zertifikats
+		notifier.SetLocator(a.inventory.Locations)
+	}
+	registerMetrics(registry, a, store)
+	registerReadiness(ctx, checker, a, connectorState, configState)
 
-	// Setup graceful shutdown
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	// Start health check server (mit Status-API, wenn ein Token gesetzt ist)
+	statusAPI := newStatusAPI(cfg.StatusAPIToken, a, store, db)
+	if statusAPI == nil {
+		log.Info("Status API disabled (STATUS_API_TOKEN not set)")
+	}
+	healthServer := newHealthServer(cfg.HealthCheckPort, checker, registry, statusAPI)
+	go serveHealth(healthServer, log)
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	// Start config polling (liest Änderungen aus Backend)
+	triggerChan := make(chan struct{}, 1)
+	if supabaseClient != nil {
+		go startConfigPolling(ctx, supabaseClient, cfg, store, triggerChan, connectorState, configState, log)
+	}
 
-	// Start scan loop
-	scanTicker := time.NewTicker(cfg.ScanInterval)
-	defer scanTicker.Stop()
+	configChanges, unsubscribe := store.Subscribe()
+	defer unsubscribe()
+
+	// Scheduler: Termine pro Target bzw. Gruppe, ein Timer weckt zum frühesten Termin
+	configureSchedule(sched, store.Current(), time.Now())
+	scanTimer := time.NewTimer(0)
+	defer scanTimer.Stop()
+	armScanTimer := func() {
+		next, ok := sched.NextWake()
+		if !ok {
+			// Nichts geplant - warten auf eine Config-Änderung
+			resetTimer(scanTimer, time.Hour)
+			return
+		}
+		resetTimer(scanTimer, time.Until(next))
+	}
+	armScanTimer()
+	logNextRuns(sched)
 
 	// Start heartbeat loop (alle 30 Sekunden)
 	heartbeatTicker := time.NewTicker(30 * time.Second)
 	defer heartbeatTicker.Stop()
 
-	// Network Discovery beim Start (wenn keine Targets konfiguriert)
-	if len(cfg.ScanTargets) == 0 || (len(cfg.ScanTargets) == 1 && cfg.ScanTargets[0] == "localhost") {
-		log.Info("No targets configured - running network discovery...")
-		runNetworkDiscovery(ctx, networkScanner, certScanner, supabaseClient, cfg)
-	} else {
-		// Run initial scan mit konfigurierten Targets
-		runScan(ctx, certScanner, supabaseClient, cfg)
-	}
-
-	// Periodic scanning and heartbeat
+	// Die Schleife bleibt während eines Scans ansprechbar (Heartbeat, Config, Shutdown);
+	// ein neuer Lauf startet erst, wenn der vorherige beendet ist
 	for {
 		select {
-		case <-scanTicker.C:
-			// Check ob Discovery oder normale Scans
-			if len(cfg.ScanTargets) == 0 || (len(cfg.ScanTargets) == 1 && cfg.ScanTargets[0] == "localhost") {
-				runNetworkDiscovery(ctx, networkScanner, certScanner, supabaseClient, cfg)
-			} else {
-				runScan(ctx, certScanner, supabaseClient, cfg)
+		case <-scanTimer.C:
+			now := time.Now()
+			due := sched.Due(now)
+			if len(due) == 0 {
+				armScanTimer()
+				continue
+			}
+			targets, discovery := splitDue(due)
+			if !a.startScan(ctx, store.Current(), scanrun.TriggerSchedule, targets, discovery) {
+				log.WithField("due", len(due)).Debug("Previous scan still running - postponing due targets")
+				resetTimer(scanTimer, busyRetry)
+				continue
 			}
+			sched.MarkRun(due, now)
+			armScanTimer()
+			logNextRuns(sched)
+		case <-triggerChan:
+			log.Info("Triggered scan from backend - running scan now...")
+			snap := store.Current()
+			all := scheduleTargets(snap)
+			targets, discovery := splitDue(all)
+			if !a.startScan(ctx, snap, scanrun.TriggerManual, targets, discovery) {
+				log.Warn("Previous scan still running - skipping triggered scan")
+				a.logUI("warn", "⏳ Scan läuft noch - angeforderter Scan wurde übersprungen", nil)
+				continue
+			}
+			sched.MarkRun(all, time.Now())
+			armScanTimer()
+		case <-sched.Changed():
+			armScanTimer()
+		case newSnap := <-configChanges:
+			applyRuntimeSettings(newSnap, certScanner, networkScanner, assets)
+			configureSchedule(sched, newSnap, time.Now())
+			armScanTimer()
+			log.WithField("config_version", newSnap.Version).Info("Schedule updated")
+			logNextRuns(sched)
 		case <-heartbeatTicker.C:
-			if cfg.ConnectorID != "" {
+			if supabaseClient != nil && cfg.ConnectorID != "" {
 				if err := supabaseClient.UpdateConnectorHeartbeat(ctx); err != nil {
 					log.WithError(err).Warn("Failed to update heartbeat")
 				} else {
 					log.Debug("Heartbeat updated")
 				}
 			}
-		case <-sigChan:
-			log.Info("Shutting down gracefully...")
-			// Update connector status to offline
-			if cfg.ConnectorID != "" {
-				log.Info("Marking connector as offline...")
-			}
-			return
 		case <-ctx.Done():
+			a.shutdown(context.Cause(ctx).Error(), healthServer)
 			return
 		}
 	}
 }
 
-func startConfigPolling(ctx context.Context, client *supabase.Client, cfg *config.Config, log *logrus.Logger) {
+// connectSupabase meldet den Agent mit dem Connector-Token am Backend an
+func connectSupabase(ctx context.Context, cfg *config.Config) *supabase.Client {
+	// Initialize Supabase client
+	supabaseClient := supabase.NewClient(cfg.SupabaseURL, cfg.SupabaseAPIKey)
+
+	// Validate and register with token
+	if cfg.ConnectorToken != "" {
+		log.Info("Validating connector token...")
+		connector, err := supabaseClient.ValidateAndRegisterWithToken(ctx, cfg.ConnectorToken)
+		if err != nil {
+			log.Fatalf("Token validation failed: %v", err)
+		}
+
+		log.WithFields(logrus.Fields{
+			"connector_id": connector.ID,
+			"tenant_id":    connector.TenantID,
+			"name":         connector.Name,
+		}).Info("✅ Connector authenticated successfully!")
+
+		cfg.ConnectorID = connector.ID
+		cfg.TenantID = connector.TenantID
+	} else {
+		log.Fatal("CONNECTOR_TOKEN is required! Generiere ihn über die UI (Connectors-Seite)")
+	}
+
+	return supabaseClient
+}
+
+// applyRuntimeSettings überträgt Settings, die keinen Neustart brauchen, auf die Komponenten
+func applyRuntimeSettings(snap *config.Snapshot, certScanner *scanner.Scanner, networkScanner *scanner.NetworkScanner, assets *lifecycle.Tracker) {
+	if level, err := logrus.ParseLevel(snap.LogLevel); err == nil {
+		log.SetLevel(level)
+	}
+	certScanner.SetTimeout(snap.ScanTimeout)
+	networkScanner.SetTimeout(snap.ScanTimeout)
+	networkScanner.SetLimits(snap.DiscoveryConcurrency, snap.PortConcurrency, snap.ConnectionsPerSecond)
+	assets.SetThresholds(assetThresholds(snap))
+}
+
+// assetThresholds liefert die Schwellwerte für den Asset-Lebenszyklus
+func assetThresholds(snap *config.Snapshot) lifecycle.Thresholds {
+	return lifecycle.Thresholds{
+		DegradedAfter:    snap.AssetDegradedAfter,
+		UnreachableAfter: snap.AssetUnreachableAfter,
+		RetireAfter:      snap.AssetRetireAfter,
+	}
+}
+
+func startConfigPolling(ctx context.Context, client *supabase.Client, cfg *config.Config, store *config.Store, triggerChan chan<- struct{}, connectorState, configState *health.State, log *logrus.Logger) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	lastRevision := ""
+	var lastTrigger int64
+
 	for {
 		select {
 		case <-ticker.C:
 			// Config vom Backend holen und ggf. aktualisieren
-			newConfig, err := client.GetConnectorConfig(ctx, cfg.ConnectorID)
+			remoteConfig, err := client.GetConnectorConfig(ctx, client.ConnectorID)
 			if err != nil {
 				log.WithError(err).Debug("Failed to fetch config")
 				continue
 			}
-
-			// Config aktualisieren wenn geändert
-			if newConfig != nil {
-				if targets, ok := newConfig["scan_targets"].([]interface{}); ok {
-					newTargets := make([]string, 0, len(targets))
-					for _, t := range targets {
-						if str, ok := t.(string); ok {
-							newTargets = append(newTargets, str)
-						}
-					}
-					if len(newTargets) > 0 {
-						cfg.ScanTargets = newTargets
-						log.WithField("targets", newTargets).Info("Updated scan targets from backend")
-					}
-				}
-
-				if ports, ok := newConfig["scan_ports"].([]interface{}); ok {
-					newPorts := make([]int, 0, len(ports))
-					for _, p := range ports {
-						if num, ok := p.(float64); ok {
-							newPorts = append(newPorts, int(num))
-						}
-					}
-					if len(newPorts) > 0 {
-						cfg.ScanPorts = newPorts
-						log.WithField("ports", newPorts).Info("Updated scan ports from backend")
+			if remoteConfig == nil {
+				connectorState.Set(health.StatusFailing, "connector "+client.ConnectorID+" not found in backend (deleted?)")
+				continue
+			}
+			connectorState.Set(health.StatusOK, "registered as "+client.ConnectorID)
+			newConfig := remoteConfig.Config
+
+			// Trigger-Scan prüfen: neuer Trigger = größer als zuletzt quittiert
+			if triggerScan, ok := newConfig["trigger_scan"].(float64); ok {
+				trigger := int64(triggerScan)
+				if trigger > remoteConfig.TriggerAck && trigger > lastTrigger {
+					lastTrigger = trigger
+					select {
+					case triggerChan <- struct{}{}:
+					default: // Scan bereits angefordert
 					}
-				}
-
-				// Trigger-Scan prüfen
-				if triggerScan, ok := newConfig["trigger_scan"].(float64); ok {
-					if triggerScan > 0 {
-						log.Info("Triggered scan from backend - running scan now...")
-						// Lösche Trigger
-						client.ClearScanTrigger(ctx, cfg.ConnectorID)
+					// Quittieren statt aus der Config löschen
+					if err := client.AcknowledgeScanTrigger(ctx, trigger); err != nil {
+						log.WithError(err).Warn("Failed to acknowledge scan trigger")
 					}
 				}
 			}
-		case <-ctx.Done():
-			return
-		}
-	}
-}
 
-func runNetworkDiscovery(ctx context.Context, networkScanner *scanner.NetworkScanner, certScanner *scanner.Scanner, client *supabase.Client, cfg *config.Config) {
-	startTime := time.Now()
-	log.Info("Starting network discovery...")
-	
-	// Send Log zu UI
-	client.SendLog(ctx, cfg.ConnectorName, "info", "🌐 Netzwerk-Scan gestartet... Scanne alle privaten IP-Bereiche", map[string]interface{}{
-		"scan_mode": "auto-discovery",
-	})
-	
-	// Progress Callback
-	progressCallback := func(current, total int) {
-		client.UpdateScanProgress(ctx, current, total, fmt.Sprintf("Scanne Netzwerk: %d/%d", current, total))
-	}
-	
-	// Discover hosts im Netzwerk
-	hosts, err := networkScanner.DiscoverLocalNetwork(ctx, progressCallback)
-	if err != nil {
-		log.WithError(err).Error("Network discovery failed")
-		client.SendLog(ctx, cfg.ConnectorName, "error", fmt.Sprintf("❌ Netzwerk-Scan fehlgeschlagen: %v", err), nil)
-		return
-	}
-
-	scanDuration := time.Since(startTime)
-	log.WithFields(logrus.Fields{
-		"hosts_found": len(hosts),
-		"duration":    scanDuration,
-	}).Info("Network discovery completed")
-	client.SendLog(ctx, cfg.ConnectorName, "info", fmt.Sprintf("✅ Netzwerk-Scan abgeschlossen: %d Hosts in %s gefunden", len(hosts), scanDuration.Round(time.Second)), map[string]interface{}{
-		"hosts_found": len(hosts),
-		"duration_ms": scanDuration.Milliseconds(),
-	})
-
-	// Für jeden gefundenen Host
-	successCount := 0
-	failCount := 0
-
-	for idx, host := range hosts {
-		// Send Progress
-		client.UpdateScanProgress(ctx, idx+1, len(hosts), fmt.Sprintf("Analysiere Hosts: %d/%d", idx+1, len(hosts)))
-		
-		// IMMER Discovery-Result speichern (auch ohne Zertifikat!)
-		if err := client.UpsertDiscoveryResult(ctx, &host); err != nil {
-			log.WithError(err).Warn("Failed to upsert discovery result")
-		} else {
-			// Send Log zu UI
-			servicesStr := "keine Services"
-			if len(host.Services) > 0 {
-				servicesStr = strings.Join(host.Services, ", ")
+			// Nur anwenden wenn sich die Einstellungen geändert haben
+			revision := config.RemoteRevision(newConfig)
+			if revision == lastRevision {
+				continue
 			}
-			client.SendLog(ctx, cfg.ConnectorName, "info", fmt.Sprintf("🌐 Host gefunden: %s (%d Ports: %s)", host.IPAddress, len(host.OpenPorts), servicesStr), map[string]interface{}{
-				"ip":         host.IPAddress,
-				"open_ports": host.OpenPorts,
-				"services":   host.Services,
-			})
-		}
-
-		// Scanne TLS-Zertifikate auf HTTPS/TLS Ports
-		tlsPorts := []int{}
-		for _, port := range host.OpenPorts {
-			if port == 443 || port == 8443 || port == 636 || port == 993 || port == 995 || port == 465 {
-				tlsPorts = append(tlsPorts, port)
+			lastRevision = revision
+
+			// Schema-Validierung, dann Auflösung: lokaler Override > Remote > Umgebung/Defaults
+			remote, errs := config.ParseRemote(newConfig)
+			snap := store.Current()
+			changed := false
+			if len(errs) == 0 {
+				var err error
+				snap, changed, err = store.Update("remote", revision, func(s *config.Settings) error {
+					*s = config.Resolve(cfg.BaseSettings(), remote, cfg.Override)
+					return nil
+				})
+				if err != nil {
+					errs = []string{err.Error()}
+				}
 			}
-		}
 
-		for _, port := range tlsPorts {
-			cert, err := certScanner.ScanHost(ctx, host.IPAddress, port)
-			if err != nil {
+			if len(errs) > 0 {
+				// Neuere Schema-Version: nicht ungültig, sondern Agent zu alt
+				status := "rejected"
+				if config.CheckSchemaVersion(newConfig) != nil {
+					status = "unsupported"
+				}
 				log.WithFields(logrus.Fields{
-					"host":  host.IPAddress,
-					"port":  port,
-					"error": err,
-				}).Debug("TLS scan failed")
-				failCount++
+					"revision": revision,
+					"status":   status,
+					"errors":   errs,
+				}).Error("Rejected config from backend")
+				configState.Set(health.StatusDegraded, fmt.Sprintf("config revision %s %s (%s), running version %d", revision, status, strings.Join(errs, "; "), snap.Version))
+				if err := client.ReportConfigStatus(ctx, snap.Version, revision, status, errs); err != nil {
+					log.WithError(err).Warn("Failed to report config status")
+				}
 				continue
 			}
 
-			// Asset upserten
-			assetID, err := client.UpsertAsset(ctx, host.IPAddress, port)
-			if err != nil {
-				log.WithError(err).Warn("Failed to upsert asset")
-			} else {
-				cert.AssetID = assetID
+			if changed {
+				log.WithFields(logrus.Fields{
+					"version":       snap.Version,
+					"revision":      revision,
+					"scan_targets":  snap.ScanTargets,
+					"scan_ports":    snap.ScanPorts,
+					"scan_interval": snap.ScanInterval,
+					"scan_timeout":  snap.ScanTimeout,
+					"discovery":     snap.DiscoveryMode,
+					"log_level":     snap.LogLevel,
+				}).Info("Applied config from backend")
 			}
-
-			cert.TenantID = cfg.TenantID
-
-			// Certificate upserten
-			if err := client.UpsertCertificate(ctx, cert); err != nil {
-				log.WithError(err).Error("Failed to upsert certificate")
-				failCount++
-				continue
+			configState.Set(health.StatusOK, fmt.Sprintf("config version %d applied (%s, revision %s)", snap.Version, snap.Source, revision))
+			if err := client.ReportConfigStatus(ctx, snap.Version, revision, "applied", nil); err != nil {
+				log.WithError(err).Warn("Failed to report config status")
 			}
-
-			successCount++
-			log.WithFields(logrus.Fields{
-				"host":        host.IPAddress,
-				"port":        port,
-				"subject_cn":  cert.SubjectCN,
-				"fingerprint": cert.Fingerprint,
-			}).Info("Certificate discovered and reported")
-			
-			// Send Log zu UI
-			client.SendLog(ctx, cfg.ConnectorName, "info", fmt.Sprintf("🔐 Zertifikat gefunden: %s auf %s:%d", cert.SubjectCN, host.IPAddress, port), map[string]interface{}{
-				"host":       host.IPAddress,
-				"port":       port,
-				"subject_cn": cert.SubjectCN,
-			})
+		case <-ctx.Done():
+			return
 		}
 	}
-
-	log.WithFields(logrus.Fields{
-		"hosts":   len(hosts),
-		"success": successCount,
-		"failed":  failCount,
-	}).Info("Network discovery and certificate scan completed")
-	
-	// Send Final Log
-	totalDuration := time.Since(startTime)
-	client.SendLog(ctx, cfg.ConnectorName, "info", fmt.Sprintf("✅ Scan abgeschlossen: %d Hosts, %d Zertifikate gefunden, %d Fehler (Dauer: %s)", len(hosts), successCount, failCount, totalDuration.Round(time.Second)), map[string]interface{}{
-		"hosts_found":   len(hosts),
-		"certificates":  successCount,
-		"errors":        failCount,
-		"duration_ms":   totalDuration.Milliseconds(),
-		"scan_mode":     "auto-discovery",
-	})
-	
-	// Clear Progress
-	client.UpdateScanProgress(ctx, 0, 0, "completed")
 }
 
-func startHealthCheckServer(port string, log *logrus.Logger) {
+// newHealthServer erstellt den Health-Check-Server (Liveness, Readiness, Metriken, Status-API).
+// Alles, was Targets nennt, liegt hinter dem Token der Status-API.
+func newHealthServer(port string, checker *health.Checker, registry *metrics.Registry, api http.Handler) *http.Server {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
@@ -331,103 +448,39 @@ func startHealthCheckServer(port string, log *logrus.Logger) {
 		w.Write([]byte("OK"))
 	})
 
+	// Readiness: 200 bei ok/degraded, 503 wenn eine Komponente failing ist
 	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("READY"))
+		report := checker.Run(time.Now())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
 	})
 
-	addr := ":" + port
-	log.WithField("port", port).Info("Health check server starting")
+	// Prometheus-Metriken
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metrics.ContentType)
+		registry.WriteTo(w, time.Now())
+	})
 
-	server := &http.Server{
-		Addr:         addr,
+	// Lokale Status-API (nur lesend, Bearer-Token)
+	if api != nil {
+		mux.Handle("/api/", api)
+	}
+
+	return &http.Server{
+		Addr:         ":" + port,
 		Handler:      mux,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
-
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.WithError(err).Error("Health check server failed")
-	}
 }
 
-func runScan(ctx context.Context, scanner *scanner.Scanner, client *supabase.Client, cfg *config.Config) {
-	log.Info("Starting certificate scan")
-	successCount := 0
-	failCount := 0
-
-	for _, target := range cfg.ScanTargets {
-		for _, port := range cfg.ScanPorts {
-			log.WithFields(logrus.Fields{
-				"host": target,
-				"port": port,
-			}).Debug("Scanning target")
+func serveHealth(server *http.Server, log *logrus.Logger) {
+	log.WithField("addr", server.Addr).Info("Health check server starting")
 
-			cert, err := scanner.ScanHost(ctx, target, port)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"host":  target,
-					"port":  port,
-					"error": err,
-				}).Warn("Scan failed")
-				failCount++
-				continue
-			}
-
-			// Upsert Asset first (wenn TenantID verfügbar)
-			var assetID string
-			if cfg.TenantID != "" && cfg.ConnectorID != "" {
-				assetID, err = client.UpsertAsset(ctx, target, port)
-				if err != nil {
-					log.WithFields(logrus.Fields{
-						"host":  target,
-						"port":  port,
-						"error": err,
-					}).Warn("Failed to upsert asset (continuing without asset_id)")
-				} else {
-					cert.AssetID = assetID
-					log.WithFields(logrus.Fields{
-						"asset_id": assetID,
-						"host":     target,
-						"port":     port,
-					}).Debug("Asset upserted")
-				}
-			}
-
-			// Setze TenantID
-			if cfg.TenantID != "" {
-				cert.TenantID = cfg.TenantID
-			}
-
-			// Send certificate to Supabase
-			if err := client.UpsertCertificate(ctx, cert); err != nil {
-				log.WithFields(logrus.Fields{
-					"host":        target,
-					"port":        port,
-					"fingerprint": cert.Fingerprint,
-					"error":       err,
-				}).Error("Failed to upsert certificate")
-				failCount++
-				continue
-			}
-
-			successCount++
-			log.WithFields(logrus.Fields{
-				"host":        target,
-				"port":        port,
-				"subject_cn":  cert.SubjectCN,
-				"fingerprint": cert.Fingerprint,
-				"not_after":   cert.NotAfter,
-				"asset_id":    assetID,
-			}).Info("Certificate scanned and reported")
-		}
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.WithError(err).Error("Health check server failed")
 	}
-
-	log.WithFields(logrus.Fields{
-		"success": successCount,
-		"failed":  failCount,
-		"total":   successCount + failCount,
-	}).Info("Certificate scan completed")
 }
-
-
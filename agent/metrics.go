@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/metrics"
+	"github.com/zertifikat-waechter/agent/notify"
+)
+
+// registerMetrics meldet die Agent-internen Werte an, die beim Scrape gelesen werden
+func registerMetrics(registry *metrics.Registry, a *agent, store *config.Store) {
+	info := currentBuildInfo()
+
+	registry.Collect(func(w *metrics.Writer) {
+		w.Gauge(metrics.Namespace+"build_info", "Build information of the agent", 1,
+			"version", info.Version, "commit", info.Commit, "goversion", info.GoVersion)
+
+		snap := store.Current()
+		w.Gauge(metrics.Namespace+"config_version", "Version of the active configuration", float64(snap.Version), "source", snap.Source)
+
+		scanning := 0.0
+		if a.scanning.Load() {
+			scanning = 1
+		}
+		w.Gauge(metrics.Namespace+"scan_running", "Whether a scan run is in progress", scanning)
+		w.Gauge(metrics.Namespace+"scheduled_targets", "Targets with a schedule (including discovery)", float64(len(a.schedule.Entries())))
+
+		w.Gauge(metrics.Namespace+"outbox_queue_depth", "Backend writes waiting in the outbox", float64(a.outbox.Len()))
+		w.Gauge(metrics.Namespace+"outbox_capacity", "Capacity of the outbox queue", float64(a.outbox.Cap()))
+		w.Counter(metrics.Namespace+"outbox_dropped_total", "Backend writes dropped because the outbox was full", float64(a.outbox.Dropped()))
+		w.Counter(metrics.Namespace+"outbox_failed_total", "Backend writes that failed after all retries", float64(a.outbox.Failed()))
+
+		for _, stats := range a.sinks.Stats() {
+			w.Counter(metrics.Namespace+"sink_records_total", "Records written to a result sink", float64(stats.Sent), "sink", stats.Name, "type", stats.Type)
+			w.Counter(metrics.Namespace+"sink_failed_total", "Records a result sink failed to write after all retries", float64(stats.Failed), "sink", stats.Name, "type", stats.Type)
+			w.Counter(metrics.Namespace+"sink_dropped_total", "Records dropped because the sink queue or spool was full", float64(stats.Dropped), "sink", stats.Name, "type", stats.Type)
+			w.Gauge(metrics.Namespace+"sink_queue_depth", "Records waiting in the sink queue", float64(stats.QueueDepth), "sink", stats.Name, "type", stats.Type)
+			w.Gauge(metrics.Namespace+"sink_spooled", "Records waiting in the spool of a broker sink", float64(stats.Spooled), "sink", stats.Name, "type", stats.Type)
+		}
+		if a.notifier != nil {
+			for _, stats := range a.notifier.Stats() {
+				w.Counter(metrics.Namespace+"notifications_sent_total", "Notifications sent by channel", float64(stats.Sent), "channel", stats.Name, "type", stats.Type)
+				w.Counter(metrics.Namespace+"notifications_failed_total", "Notifications a channel failed to send", float64(stats.Failed), "channel", stats.Name, "type", stats.Type)
+				w.Counter(metrics.Namespace+"notifications_suppressed_total", "Notifications suppressed because they were already sent", float64(stats.Suppressed), "channel", stats.Name, "type", stats.Type)
+				w.Gauge(metrics.Namespace+"notifications_deferred", "Notifications waiting for the end of quiet hours", float64(stats.Deferred), "channel", stats.Name, "type", stats.Type)
+				if notify.IsTicketType(stats.Type) {
+					w.Counter(metrics.Namespace+"tickets_closed_total", "Renewal tickets closed after the certificate was replaced on all endpoints", float64(stats.Resolved), "channel", stats.Name, "type", stats.Type)
+					w.Gauge(metrics.Namespace+"tickets_open", "Renewal tickets currently open in a ticket system", float64(stats.Open), "channel", stats.Name, "type", stats.Type)
+					continue
+				}
+				w.Counter(metrics.Namespace+"incidents_resolved_total", "Incidents resolved after the certificate was replaced or the endpoint recovered", float64(stats.Resolved), "channel", stats.Name, "type", stats.Type)
+				w.Gauge(metrics.Namespace+"incidents_open", "Incidents currently open in an incident tool", float64(stats.Open), "channel", stats.Name, "type", stats.Type)
+			}
+		}
+
+		for _, stats := range a.client.RequestStats() {
+			w.Counter(metrics.Namespace+"backend_requests_total", "Requests to the backend by resource", float64(stats.Requests), "resource", stats.Resource)
+			w.Counter(metrics.Namespace+"backend_errors_total", "Failed requests to the backend by resource (transport errors and HTTP status >= 400)", float64(stats.Errors), "resource", stats.Resource)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ContentType der Prometheus-Textformat-Version 0.0.4
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// family ist eine Metrik mit ihren Samples
+type family struct {
+	name    string
+	typ     string
+	help    string
+	samples []sample
+}
+
+type sample struct {
+	labels []string // abwechselnd Name, Wert
+	value  float64
+}
+
+// Writer sammelt Metriken und schreibt sie im Prometheus-Textformat.
+// Samples derselben Metrik werden unter einem HELP/TYPE-Block zusammengefasst.
+type Writer struct {
+	families []*family
+	byName   map[string]*family
+}
+
+func newWriter() *Writer {
+	return &Writer{byName: make(map[string]*family)}
+}
+
+// Gauge fügt ein Gauge-Sample hinzu; labels abwechselnd Name, Wert
+func (w *Writer) Gauge(name, help string, value float64, labels ...string) {
+	w.add(name, "gauge", help, value, labels)
+}
+
+// Counter fügt ein Counter-Sample hinzu; labels abwechselnd Name, Wert
+func (w *Writer) Counter(name, help string, value float64, labels ...string) {
+	w.add(name, "counter", help, value, labels)
+}
+
+func (w *Writer) add(name, typ, help string, value float64, labels []string) {
+	f, ok := w.byName[name]
+	if !ok {
+		f = &family{name: name, typ: typ, help: help}
+		w.byName[name] = f
+		w.families = append(w.families, f)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// writeTo schreibt alle Metriken
+func (w *Writer) writeTo(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	for _, f := range w.families {
+		bw.WriteString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+		bw.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+		for _, s := range f.samples {
+			bw.WriteString(f.name)
+			if len(s.labels) > 0 {
+				bw.WriteByte('{')
+				for i := 0; i+1 < len(s.labels); i += 2 {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(s.labels[i] + `="` + escapeLabel(s.labels[i+1]) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+)
+
+// Präfix der agent-eigenen Metriken. Zertifikats-Metriken heißen wie beim
+// ssl_exporter (ssl_*) bzw. blackbox_exporter (probe_*), damit vorhandene
+// Dashboards und Alert-Regeln weiter funktionieren.
+const Namespace = "zertifikat_waechter_"
+
+// staleAfter: Endpoints ohne Scan seit dieser Zeit werden nicht mehr exportiert
+const staleAfter = 7 * 24 * time.Hour
+
+// endpointState ist der zuletzt gesehene Zustand eines Endpoints
+type endpointState struct {
+	host      string
+	port      int
+	sni       string
+	success   bool
+	cert      *scanner.CertificateData // letztes erfolgreich gelesenes Zertifikat
+	scannedAt time.Time
+}
+
+// runState ist der letzte Lauf eines Modus
+type runState struct {
+	summary scanrun.Summary
+	counts  map[scanrun.Status]int64
+}
+
+// Registry hält den Zustand für /metrics (thread-safe)
+type Registry struct {
+	mu         sync.Mutex
+	endpoints  map[string]*endpointState
+	runs       map[scanrun.Mode]*runState
+	collectors []func(w *Writer)
+}
+
+// New erstellt eine leere Registry
+func New() *Registry {
+	return &Registry{
+		endpoints: make(map[string]*endpointState),
+		runs:      make(map[scanrun.Mode]*runState),
+	}
+}
+
+// Collect registriert eine Funktion, die beim Scrape aktuelle Werte liefert
+// (z.B. Outbox-Länge oder Config-Version)
+func (r *Registry) Collect(fn func(w *Writer)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, fn)
+}
+
+// ObserveEndpoint hält das Ergebnis eines Endpoint-Scans fest. Bei Fehlern bleibt
+// das zuletzt gelesene Zertifikat erhalten, damit das Ablaufdatum sichtbar bleibt.
+func (r *Registry) ObserveEndpoint(host string, port int, cert *scanner.CertificateData, err error, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := host + ":" + strconv.Itoa(port)
+	state, ok := r.endpoints[key]
+	if !ok {
+		state = &endpointState{host: host, port: port}
+		r.endpoints[key] = state
+	}
+	state.success = err == nil && cert != nil
+	state.scannedAt = at
+	if state.success {
+		state.cert = cert
+		state.sni = cert.SNI
+	}
+}
+
+// ObserveRun hält einen abgeschlossenen Scan-Lauf fest
+func (r *Registry) ObserveRun(summary scanrun.Summary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.runs[summary.Mode]
+	if !ok {
+		state = &runState{counts: make(map[scanrun.Status]int64)}
+		r.runs[summary.Mode] = state
+	}
+	state.summary = summary
+	state.counts[summary.Status]++
+}
+
+// WriteTo schreibt alle Metriken im Prometheus-Textformat
+func (r *Registry) WriteTo(out io.Writer, now time.Time) error {
+	w := newWriter()
+
+	r.mu.Lock()
+	r.writeEndpoints(w, now)
+	r.writeRuns(w)
+	collectors := append([]func(*Writer){}, r.collectors...)
+	r.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect(w)
+	}
+	return w.writeTo(out)
+}
+
+func (r *Registry) writeEndpoints(w *Writer, now time.Time) {
+	keys := make([]string, 0, len(r.endpoints))
+	for key, state := range r.endpoints {
+		if now.Sub(state.scannedAt) > staleAfter {
+			delete(r.endpoints, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		state := r.endpoints[key]
+		target := []string{"host", state.host, "port", strconv.Itoa(state.port), "sni", state.sni}
+
+		w.Gauge("ssl_tls_connect_success", "If the TLS connection was a success", boolValue(state.success), target...)
+		w.Gauge("probe_success", "Whether the last scan of the endpoint was successful", boolValue(state.success), target...)
+		w.Gauge(Namespace+"endpoint_last_scan_timestamp_seconds", "Time of the last scan of the endpoint", unix(state.scannedAt), target...)
+
+		cert := state.cert
+		if cert == nil {
+			continue
+		}
+		certLabels := append(append([]string{}, target...),
+			"cn", cert.SubjectCN,
+			"dnsnames", joinLabel(dnsNames(cert.SAN)),
+			"ips", joinLabel(ipAddresses(cert.SAN)),
+			"issuer_cn", cert.Issuer,
+			"serial_no", cert.SerialNumber,
+		)
+
+		w.Gauge("ssl_cert_not_after", "NotAfter expressed as a Unix Epoch Time", unix(cert.NotAfter), certLabels...)
+		w.Gauge("ssl_cert_not_before", "NotBefore expressed as a Unix Epoch Time", unix(cert.NotBefore), certLabels...)
+		if cert.IsTrusted {
+			w.Gauge("ssl_verified_cert_not_after", "NotAfter of the verified leaf certificate expressed as a Unix Epoch Time", unix(cert.NotAfter), append(certLabels, "chain_no", "0")...)
+		}
+		w.Gauge("probe_ssl_earliest_cert_expiry", "Returns last SSL chain expiry in unixtime", unix(cert.NotAfter), target...)
+		if cert.TLSVersion != "" {
+			w.Gauge("ssl_tls_version_info", "The TLS version used", 1, append(append([]string{}, target...), "version", cert.TLSVersion)...)
+			w.Gauge("probe_tls_version_info", "Returns the TLS version used or NaN when unknown", 1, append(append([]string{}, target...), "version", cert.TLSVersion)...)
+		}
+
+		w.Gauge(Namespace+"cert_days_remaining", "Days until the certificate expires (negative when expired)", cert.NotAfter.Sub(now).Hours()/24,
+			append(append([]string{}, target...), "cn", cert.SubjectCN, "issuer_cn", cert.Issuer)...)
+		w.Gauge(Namespace+"cert_chain_valid", "Whether the certificate chain ends at a trusted root", boolValue(cert.IsTrusted), target...)
+		w.Gauge(Namespace+"cert_self_signed", "Whether the certificate is self-signed", boolValue(cert.IsSelfSigned), target...)
+	}
+}
+
+func (r *Registry) writeRuns(w *Writer) {
+	modes := make([]string, 0, len(r.runs))
+	for mode := range r.runs {
+		modes = append(modes, string(mode))
+	}
+	sort.Strings(modes)
+
+	for _, mode := range modes {
+		state := r.runs[scanrun.Mode(mode)]
+		s := state.summary
+
+		statuses := make([]string, 0, len(state.counts))
+		for status := range state.counts {
+			statuses = append(statuses, string(status))
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			w.Counter(Namespace+"scan_runs_total", "Finished scan runs by mode and status", float64(state.counts[scanrun.Status(status)]), "mode", mode, "status", status)
+		}
+
+		if s.FinishedAt != nil {
+			w.Gauge(Namespace+"scan_duration_seconds", "Duration of the last scan run", s.FinishedAt.Sub(s.StartedAt).Seconds(), "mode", mode)
+			w.Gauge(Namespace+"scan_last_run_timestamp_seconds", "Time the last scan run finished", unix(*s.FinishedAt), "mode", mode)
+		}
+		w.Gauge(Namespace+"scan_endpoints", "Endpoints of the last scan run by result", float64(s.EndpointsSucceeded), "mode", mode, "result", "succeeded")
+		w.Gauge(Namespace+"scan_endpoints", "Endpoints of the last scan run by result", float64(s.EndpointsFailed), "mode", mode, "result", "failed")
+		if scanrun.Mode(mode) == scanrun.ModeDiscovery {
+			w.Gauge(Namespace+"discovery_hosts", "Hosts found by the last network discovery", float64(s.HostsFound))
+		}
+	}
+}
+
+func unix(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+// dnsNames und ipAddresses trennen die SAN-Liste wie der ssl_exporter
+func dnsNames(san []string) []string {
+	var names []string
+	for _, name := range san {
+		if !isIP(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func ipAddresses(san []string) []string {
+	var ips []string
+	for _, name := range san {
+		if isIP(name) {
+			ips = append(ips, name)
+		}
+	}
+	return ips
+}
+
+func isIP(name string) bool {
+	return net.ParseIP(name) != nil
+}
+
+// joinLabel formatiert Listen wie der ssl_exporter: ",a,b," (leer = "")
+func joinLabel(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return "," + strings.Join(values, ",") + ","
+}
@@ -0,0 +1,337 @@
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+)
+
+var now = time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+
+// scraped ist eine geparste Sample-Zeile
+type scraped struct {
+	labels map[string]string
+	value  float64
+}
+
+// exposition ist ein geparster Scrape: Typ und Samples je Metrik
+type exposition struct {
+	types   map[string]string
+	samples map[string][]scraped
+}
+
+// scrape ruft /metrics über HTTP ab und parst das Textformat. Jede Metrik darf nur
+// einen HELP/TYPE-Block haben, dessen Samples direkt folgen.
+func scrape(t *testing.T, r *Registry, at time.Time) exposition {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w, at)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	exp := exposition{types: make(map[string]string), samples: make(map[string][]scraped)}
+	current := ""
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "# HELP "):
+			name := strings.Fields(line)[2]
+			if _, seen := exp.types[name]; seen {
+				t.Errorf("second HELP block for %s", name)
+			}
+			current = name
+		case strings.HasPrefix(line, "# TYPE "):
+			fields := strings.Fields(line)
+			if fields[2] != current {
+				t.Errorf("TYPE %s after HELP %s", fields[2], current)
+			}
+			exp.types[fields[2]] = fields[3]
+		default:
+			name, s, err := parseSample(line)
+			if err != nil {
+				t.Fatalf("%v: %q", err, line)
+			}
+			if name != current {
+				t.Errorf("sample %s outside its block (%s)", name, current)
+			}
+			exp.samples[name] = append(exp.samples[name], s)
+		}
+	}
+	if err := lines.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return exp
+}
+
+// parseSample zerlegt `name{a="b",c="d"} value`
+func parseSample(line string) (string, scraped, error) {
+	s := scraped{labels: make(map[string]string)}
+	sep := strings.LastIndexByte(line, ' ')
+	if sep < 0 {
+		return "", s, errors.New("no value")
+	}
+	value, err := strconv.ParseFloat(line[sep+1:], 64)
+	if err != nil {
+		return "", s, err
+	}
+	s.value = value
+	series := line[:sep]
+
+	open := strings.IndexByte(series, '{')
+	if open < 0 {
+		return series, s, nil
+	}
+	name, rest := series[:open], series[open+1:]
+	for rest != "}" {
+		eq := strings.Index(rest, `="`)
+		if eq < 0 {
+			return "", s, errors.New("invalid label")
+		}
+		label := rest[:eq]
+		rest = rest[eq+2:]
+		var val strings.Builder
+		i := 0
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				switch rest[i] {
+				case 'n':
+					val.WriteByte('\n')
+				default:
+					val.WriteByte(rest[i])
+				}
+				continue
+			}
+			val.WriteByte(rest[i])
+		}
+		if i == len(rest) {
+			return "", s, errors.New("unterminated label value")
+		}
+		if _, dup := s.labels[label]; dup {
+			return "", s, fmt.Errorf("duplicate label %s", label)
+		}
+		s.labels[label] = val.String()
+		rest = strings.TrimPrefix(rest[i+1:], ",")
+	}
+	return name, s, nil
+}
+
+// labelNames liefert die sortierten Label-Namen eines Samples
+func labelNames(s scraped) []string {
+	names := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testCert(cn, serial string, notAfter time.Time) *scanner.CertificateData {
+	return &scanner.CertificateData{
+		SubjectCN:    cn,
+		SAN:          []string{cn, "www." + cn, "10.0.0.1"},
+		Issuer:       "R11",
+		SerialNumber: serial,
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		IsTrusted:    true,
+		TLSVersion:   "TLS 1.3",
+		SNI:          cn,
+	}
+}
+
+// TestScrapeNamesAndLabels prüft Metriknamen, Typen und Label-Sätze eines Scrapes
+func TestScrapeNamesAndLabels(t *testing.T) {
+	r := New()
+	r.ObserveEndpoint("example.com", 443, testCert("example.com", "1", now.Add(30*24*time.Hour)), nil, now)
+	r.ObserveEndpoint("10.0.0.9", 8443, nil, errors.New("connection refused"), now)
+	finished := now.Add(time.Minute)
+	r.ObserveRun(scanrun.Summary{Mode: scanrun.ModeTargets, Status: scanrun.StatusCompleted, StartedAt: now, FinishedAt: &finished, EndpointsSucceeded: 1, EndpointsFailed: 1})
+	r.ObserveRun(scanrun.Summary{Mode: scanrun.ModeDiscovery, Status: scanrun.StatusFailed, StartedAt: now, HostsFound: 4})
+	r.Collect(func(w *Writer) {
+		w.Gauge(Namespace+"outbox_queue_depth", "Backend writes waiting in the outbox", 3)
+	})
+
+	exp := scrape(t, r, now)
+
+	target := []string{"host", "port", "sni"}
+	cert := []string{"cn", "dnsnames", "host", "ips", "issuer_cn", "port", "serial_no", "sni"}
+	want := map[string]struct {
+		typ    string
+		labels []string
+		series int
+	}{
+		"ssl_tls_connect_success": {"gauge", target, 2},
+		"probe_success":           {"gauge", target, 2},
+		Namespace + "endpoint_last_scan_timestamp_seconds": {"gauge", target, 2},
+		"ssl_cert_not_after":                          {"gauge", cert, 1},
+		"ssl_cert_not_before":                         {"gauge", cert, 1},
+		"ssl_verified_cert_not_after":                 {"gauge", append([]string{"chain_no"}, cert...), 1},
+		"probe_ssl_earliest_cert_expiry":              {"gauge", target, 1},
+		"ssl_tls_version_info":                        {"gauge", []string{"host", "port", "sni", "version"}, 1},
+		"probe_tls_version_info":                      {"gauge", []string{"host", "port", "sni", "version"}, 1},
+		Namespace + "cert_days_remaining":             {"gauge", []string{"cn", "host", "issuer_cn", "port", "sni"}, 1},
+		Namespace + "cert_chain_valid":                {"gauge", target, 1},
+		Namespace + "cert_self_signed":                {"gauge", target, 1},
+		Namespace + "scan_runs_total":                 {"counter", []string{"mode", "status"}, 2},
+		Namespace + "scan_duration_seconds":           {"gauge", []string{"mode"}, 1},
+		Namespace + "scan_last_run_timestamp_seconds": {"gauge", []string{"mode"}, 1},
+		Namespace + "scan_endpoints":                  {"gauge", []string{"mode", "result"}, 4},
+		Namespace + "discovery_hosts":                 {"gauge", nil, 1},
+		Namespace + "outbox_queue_depth":              {"gauge", nil, 1},
+	}
+
+	for name, w := range want {
+		if exp.types[name] != w.typ {
+			t.Errorf("%s: type %q, want %q", name, exp.types[name], w.typ)
+		}
+		if n := len(exp.samples[name]); n != w.series {
+			t.Errorf("%s: %d series, want %d", name, n, w.series)
+		}
+		sort.Strings(w.labels)
+		for _, s := range exp.samples[name] {
+			if got := labelNames(s); !reflect.DeepEqual(got, w.labels) && !(len(got) == 0 && len(w.labels) == 0) {
+				t.Errorf("%s: labels %v, want %v", name, got, w.labels)
+			}
+		}
+	}
+	for name := range exp.types {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected metric %s", name)
+		}
+	}
+
+	// Werte und Label-Inhalte
+	notAfter := exp.samples["ssl_cert_not_after"][0]
+	wantLabels := map[string]string{
+		"host": "example.com", "port": "443", "sni": "example.com", "cn": "example.com",
+		"dnsnames": ",example.com,www.example.com,", "ips": ",10.0.0.1,", "issuer_cn": "R11", "serial_no": "1",
+	}
+	if !reflect.DeepEqual(notAfter.labels, wantLabels) || notAfter.value != float64(now.Add(30*24*time.Hour).Unix()) {
+		t.Errorf("ssl_cert_not_after = %v %v", notAfter.labels, notAfter.value)
+	}
+	if days := exp.samples[Namespace+"cert_days_remaining"][0].value; days != 30 {
+		t.Errorf("cert_days_remaining = %v", days)
+	}
+	for _, s := range exp.samples["probe_success"] {
+		if want := map[string]float64{"443": 1, "8443": 0}[s.labels["port"]]; s.value != want {
+			t.Errorf("probe_success%v = %v, want %v", s.labels, s.value, want)
+		}
+	}
+}
+
+// TestScrapeCardinality: wiederholte Scans und Zertifikatswechsel erzeugen keine neuen
+// Serien, Läufe nur eine Serie je Modus und Status
+func TestScrapeCardinality(t *testing.T) {
+	r := New()
+	hosts := []string{"a.example", "b.example", "c.example"}
+	for i := 0; i < 50; i++ {
+		at := now.Add(time.Duration(i) * time.Hour)
+		for _, host := range hosts {
+			// Jeder Scan liefert ein neues Zertifikat (andere Seriennummer)
+			r.ObserveEndpoint(host, 443, testCert(host, strconv.Itoa(i), at.Add(90*24*time.Hour)), nil, at)
+		}
+		status := scanrun.StatusCompleted
+		if i%5 == 0 {
+			status = scanrun.StatusFailed
+		}
+		r.ObserveRun(scanrun.Summary{Mode: scanrun.ModeTargets, Status: status, StartedAt: at, FinishedAt: &at})
+	}
+
+	exp := scrape(t, r, now.Add(50*time.Hour))
+	for name, samples := range exp.samples {
+		seen := make(map[string]bool)
+		for _, s := range samples {
+			key := fmt.Sprint(s.labels)
+			if seen[key] {
+				t.Errorf("%s: duplicate series %s", name, key)
+			}
+			seen[key] = true
+		}
+	}
+	for _, name := range []string{"ssl_cert_not_after", "probe_success", Namespace + "cert_days_remaining"} {
+		if n := len(exp.samples[name]); n != len(hosts) {
+			t.Errorf("%s: %d series for %d endpoints", name, n, len(hosts))
+		}
+	}
+	if s := exp.samples["ssl_cert_not_after"][0]; s.labels["serial_no"] != "49" {
+		t.Errorf("serial_no = %q, want the latest certificate", s.labels["serial_no"])
+	}
+	runs := exp.samples[Namespace+"scan_runs_total"]
+	counts := map[string]float64{}
+	for _, s := range runs {
+		counts[s.labels["status"]] = s.value
+	}
+	if !reflect.DeepEqual(counts, map[string]float64{"completed": 40, "failed": 10}) {
+		t.Errorf("scan_runs_total = %v", counts)
+	}
+}
+
+// TestScrapeFailedAndStale: ein Fehlschlag behält die Zertifikats-Metriken, nach
+// staleAfter ohne Scan verschwindet der Endpoint
+func TestScrapeFailedAndStale(t *testing.T) {
+	r := New()
+	r.ObserveEndpoint("a.example", 443, testCert("a.example", "1", now.Add(10*24*time.Hour)), nil, now)
+	r.ObserveEndpoint("a.example", 443, nil, errors.New("timeout"), now.Add(time.Hour))
+	r.ObserveEndpoint("b.example", 443, testCert("b.example", "2", now.Add(10*24*time.Hour)), nil, now.Add(6*24*time.Hour))
+
+	exp := scrape(t, r, now.Add(2*time.Hour))
+	if len(exp.samples["ssl_cert_not_after"]) != 2 {
+		t.Errorf("certificate of the failed endpoint not exported: %v", exp.samples["ssl_cert_not_after"])
+	}
+	for _, s := range exp.samples["probe_success"] {
+		if s.labels["host"] == "a.example" && s.value != 0 {
+			t.Errorf("probe_success after failure = %v", s.value)
+		}
+	}
+
+	exp = scrape(t, r, now.Add(7*24*time.Hour+2*time.Hour))
+	if n := len(exp.samples["probe_success"]); n != 1 || exp.samples["probe_success"][0].labels["host"] != "b.example" {
+		t.Errorf("probe_success after staleAfter = %v", exp.samples["probe_success"])
+	}
+}
+
+// TestLabelEscaping: Anführungszeichen, Backslashes und Zeilenumbrüche in Labels
+func TestLabelEscaping(t *testing.T) {
+	r := New()
+	cert := testCert("example.com", "1", now.Add(24*time.Hour))
+	cert.Issuer = "Evil \"CA\"\\\nLine 2"
+	r.ObserveEndpoint("example.com", 443, cert, nil, now)
+
+	exp := scrape(t, r, now)
+	if got := exp.samples["ssl_cert_not_after"][0].labels["issuer_cn"]; got != cert.Issuer {
+		t.Errorf("issuer_cn = %q, want %q", got, cert.Issuer)
+	}
+
+	var sb strings.Builder
+	w := newWriter()
+	w.Gauge("test_metric", "Help with \\ and\nnewline", 1)
+	if err := w.writeTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sb.String(), "# HELP test_metric Help with \\\\ and\\nnewline\n") {
+		t.Errorf("HELP = %q", sb.String())
+	}
+}
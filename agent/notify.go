@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/notify"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// cmdNotify sendet Testmeldungen an die Kanäle aus NOTIFY_FILE
+func cmdNotify(args []string, out io.Writer) int {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprintln(os.Stderr, "Usage: agent notify test [flags]")
+		return exitUsage
+	}
+
+	fs := newFlagSet("notify test", "notify test [flags]\n\nSends a sample notification to the channels of NOTIFY_FILE\n(rules, deduplication and quiet hours are ignored; incidents and tickets are opened and closed again)")
+	file := fs.String("file", os.Getenv("NOTIFY_FILE"), "notification config file")
+	channel := fs.String("channel", "", "send only to this channel (default: all)")
+	event := fs.String("event", notify.EventExpiring, "sample event: expiring, validation_failed, rotation, unreachable")
+	timeout := fs.Duration("timeout", 30*time.Second, "overall timeout")
+	if code, ok := parseFlags(fs, args[1:]); !ok {
+		return code
+	}
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "no notification config: set NOTIFY_FILE or --file")
+		return exitUsage
+	}
+	note, ok := sampleNotification(*event)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown event %q\n", *event)
+		return exitUsage
+	}
+
+	cfg, err := notify.Load(*file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	// Ohne State-Datei - Testmeldungen sollen die Deduplizierung nicht beeinflussen
+	cfg.StateFile = ""
+	notifier, err := notify.New(cfg, cliLogger(false))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer notifier.Close()
+
+	ctx, cancel := cliContext()
+	defer cancel()
+
+	code := exitOK
+	found := false
+	for _, stats := range notifier.Stats() {
+		if *channel != "" && stats.Name != *channel {
+			continue
+		}
+		found = true
+		sendCtx, sendCancel := context.WithTimeout(ctx, *timeout)
+		err := notifier.Test(sendCtx, stats.Name, note)
+		sendCancel()
+		if err != nil {
+			fmt.Fprintf(out, "FAIL  %-20s %-10s %v\n", stats.Name, stats.Type, err)
+			code = exitFailure
+			continue
+		}
+		if notify.IsIncidentType(stats.Type) {
+			fmt.Fprintf(out, "OK    %-20s %-10s incident triggered and resolved\n", stats.Name, stats.Type)
+			continue
+		}
+		if notify.IsTicketType(stats.Type) {
+			fmt.Fprintf(out, "OK    %-20s %-10s ticket created and closed\n", stats.Name, stats.Type)
+			continue
+		}
+		fmt.Fprintf(out, "OK    %-20s %s\n", stats.Name, stats.Type)
+	}
+	if !found {
+		fmt.Fprintf(os.Stderr, "unknown channel %q\n", *channel)
+		return exitUsage
+	}
+	return code
+}
+
+// sampleNotification liefert eine Beispielmeldung für ein Ereignis
+func sampleNotification(event string) (notify.Notification, bool) {
+	now := time.Now()
+	notAfter := now.Add(6 * 24 * time.Hour).UTC()
+	note := notify.Notification{
+		Event:       event,
+		Severity:    sink.SeverityCritical,
+		Rule:        "test",
+		Agent:       "agent notify test",
+		Time:        now,
+		Host:        "www.example.com",
+		Port:        443,
+		SNI:         "www.example.com",
+		Name:        "www.example.com",
+		Issuer:      "Example CA",
+		Fingerprint: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		NotAfter:    &notAfter,
+		DaysLeft:    6,
+		Threshold:   7,
+	}
+	switch event {
+	case notify.EventExpiring:
+	case notify.EventValidationFailed:
+		note.Severity = sink.SeverityError
+		note.Reasons = []string{"untrusted", "hostname_mismatch"}
+	case notify.EventRotation:
+		note.Severity = sink.SeverityWarning
+		note.Suspicious = true
+		note.Reasons = []string{rotation.ReasonPublicToSelfSigned}
+		note.Changes = []string{"issuer", "fingerprint"}
+		note.OldFinger = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	case notify.EventUnreachable:
+		note = notify.Notification{Event: event, Severity: sink.SeverityError, Rule: note.Rule, Agent: note.Agent,
+			Time: now, Host: note.Host, Port: note.Port, ErrorClass: "timeout"}
+	default:
+		return note, false
+	}
+	return note, true
+}
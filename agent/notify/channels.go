@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// Channel stellt eine gerenderte Meldung zu
+type Channel interface {
+	Send(ctx context.Context, title, text string, n Notification) error
+}
+
+// newChannel erstellt den Kanal zu einem geprüften Eintrag
+func newChannel(ch *ChannelConfig) Channel {
+	switch ch.Type {
+	case "smtp":
+		return &smtpChannel{cfg: ch}
+	case "slack":
+		return &webhookChannel{url: ch.webhookURL(), client: &http.Client{Timeout: ch.timeout()}, payload: slackPayload}
+	case "mattermost":
+		return &webhookChannel{url: ch.webhookURL(), client: &http.Client{Timeout: ch.timeout()}, payload: mattermostPayload(ch.Username, ch.Channel)}
+	case "teams":
+		return &webhookChannel{url: ch.webhookURL(), client: &http.Client{Timeout: ch.timeout()}, payload: teamsPayload}
+	}
+	return nil
+}
+
+// webhookChannel sendet an einen Incoming-Webhook (Slack, Mattermost, Teams)
+type webhookChannel struct {
+	url     string
+	client  *http.Client
+	payload func(title, text string, n Notification) interface{}
+}
+
+func (c *webhookChannel) Send(ctx context.Context, title, text string, n Notification) error {
+	return postJSON(ctx, c.client, c.url, nil, c.payload(title, text, n))
+}
+
+// postJSON sendet payload als JSON; Antworten ab 300 liefern *sink.StatusError
+func postJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, payload interface{}) error {
+	return doJSON(ctx, client, "POST", endpoint, header, payload, nil)
+}
+
+// doJSON sendet payload (nil = ohne Body) als JSON und dekodiert die Antwort in result
+// (nil = verwerfen); Antworten ab 300 liefern *sink.StatusError
+func doJSON(ctx context.Context, client *http.Client, method, endpoint string, header http.Header, payload, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "zertifikat-waechter-agent")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Ohne URL - Incoming-Webhooks enthalten ihr Geheimnis im Pfad
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &sink.StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if result != nil {
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(result); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+		return nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// slackPayload: Header und Text als Blocks (wie bei den Cloud-Alerts)
+func slackPayload(title, text string, n Notification) interface{} {
+	return map[string]interface{}{
+		"text": title,
+		"blocks": []map[string]interface{}{
+			{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": truncate(title, 150)}},
+			{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "```" + text + "```"}},
+		},
+	}
+}
+
+// mattermostPayload: Markdown-Text, optional mit Absender und Kanal
+func mattermostPayload(username, channel string) func(title, text string, n Notification) interface{} {
+	return func(title, text string, n Notification) interface{} {
+		payload := map[string]interface{}{"text": "#### " + title + "\n```\n" + text + "\n```"}
+		if username != "" {
+			payload["username"] = username
+		}
+		if channel != "" {
+			payload["channel"] = channel
+		}
+		return payload
+	}
+}
+
+// teamsColors ordnet die Dringlichkeit den Farben der Adaptive Cards zu
+var teamsColors = map[string]string{"critical": "Attention", "error": "Attention", "warning": "Warning", "info": "Accent"}
+
+// teamsPayload: Adaptive Card für Teams-Workflows bzw. Incoming-Webhooks
+func teamsPayload(title, text string, n Notification) interface{} {
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": title, "size": "Medium", "weight": "Bolder", "wrap": true, "color": teamsColors[n.Severity]},
+	}
+	for _, para := range strings.Split(text, "\n\n") {
+		// Adaptive Cards brauchen Leerzeilen für Zeilenumbrüche
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": strings.ReplaceAll(para, "\n", "\n\n"), "wrap": true, "fontType": "Monospace"})
+	}
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
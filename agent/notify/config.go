@@ -0,0 +1,398 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"os"
+	"path"
+	"strings"
+	"time"
+	_ "time/tzdata" // Zeitzonen für Ruhezeiten auch im Alpine-Image
+)
+
+// Ereignisse, auf die Regeln reagieren
+const (
+	EventExpiring         = "expiring"          // Zertifikat läuft innerhalb der Schwellen ab
+	EventValidationFailed = "validation_failed" // abgelaufen, nicht vertrauenswürdig, Hostname passt nicht
+	EventRotation         = "rotation"          // Zertifikat auf einem Endpoint gewechselt
+	EventUnreachable      = "unreachable"       // Endpoint nicht mehr erreichbar
+)
+
+var events = map[string]bool{EventExpiring: true, EventValidationFailed: true, EventRotation: true, EventUnreachable: true}
+
+var severities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// Sprachen der eingebauten Vorlagen
+const (
+	LanguageGerman  = "de"
+	LanguageEnglish = "en"
+)
+
+// defaultDays sind die Ablauf-Schwellen, wenn eine Regel keine angibt
+var defaultDays = []int{30, 14, 7, 1}
+
+// defaultRenotify: dieselbe Meldung frühestens nach so vielen Stunden erneut
+const defaultRenotify = 24
+
+// Config ist der Aufbau der Notifier-Datei (NOTIFY_FILE)
+type Config struct {
+	Language   string          `json:"language,omitempty"`   // Default für alle Kanäle: de oder en
+	StateFile  string          `json:"state_file,omitempty"` // bereits gesendete Meldungen (überlebt Neustarts)
+	QuietHours *QuietHours     `json:"quiet_hours,omitempty"`
+	Channels   []ChannelConfig `json:"channels"`
+	Rules      []Rule          `json:"rules"`
+}
+
+// ChannelConfig beschreibt einen Benachrichtigungskanal
+type ChannelConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`               // smtp, slack, mattermost, teams, pagerduty, opsgenie, alertmanager, jira, servicenow
+	Language string `json:"language,omitempty"` // überschreibt Config.Language
+
+	// Eigene Vorlagen (Go-Templates über die Notification) statt der eingebauten
+	TitleTemplate string `json:"title_template,omitempty"`
+	Template      string `json:"template,omitempty"`
+
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"` // Default 10
+
+	// slack, mattermost, teams: Incoming-Webhook
+	URL      string `json:"url,omitempty"`
+	URLEnv   string `json:"url_env,omitempty"`  // URL aus dieser Umgebungsvariable
+	Username string `json:"username,omitempty"` // mattermost: Absendername; smtp: Login
+	Channel  string `json:"channel,omitempty"`  // mattermost: Ziel-Kanal statt des Webhook-Defaults
+
+	// smtp
+	Host        string   `json:"host,omitempty"`
+	Port        int      `json:"port,omitempty"` // Default 587 (starttls), 465 (tls), 25 (none)
+	Password    string   `json:"password,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+	TLS         string   `json:"tls,omitempty"`     // starttls (Default), tls, none (nur für lokale Test-Server)
+	CAFile      string   `json:"ca_file,omitempty"` // CA des Servers statt der System-Roots
+
+	// pagerduty: Integration Key (Events API v2); opsgenie: API-Key der Integration.
+	// url überschreibt den Endpoint (z.B. https://api.eu.opsgenie.com), alertmanager: Basis-URL.
+	RoutingKey    string            `json:"routing_key,omitempty"`
+	RoutingKeyEnv string            `json:"routing_key_env,omitempty"`
+	APIKey        string            `json:"api_key,omitempty"`
+	APIKeyEnv     string            `json:"api_key_env,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`    // alertmanager: zusätzliche Labels
+	TTLHours      int               `json:"ttl_hours,omitempty"` // alertmanager: Alert bleibt so lange ohne neuen Scan aktiv (Default 24)
+
+	// jira, servicenow: url ist die Basis-URL der Instanz. Anmeldung mit username und
+	// password (Jira Cloud: API-Token) oder bei jira mit api_key (Personal Access Token).
+	Project         string                 `json:"project,omitempty"`          // jira: Projekt-Schlüssel
+	IssueType       string                 `json:"issue_type,omitempty"`       // jira: Vorgangstyp (Default Task)
+	CloseTransition string                 `json:"close_transition,omitempty"` // jira: Übergang oder Ziel-Status zum Schließen (Default Done)
+	Table           string                 `json:"table,omitempty"`            // servicenow: Tabelle (Default incident)
+	Fields          map[string]interface{} `json:"fields,omitempty"`           // zusätzliche Felder; Strings sind Go-Templates über TicketData
+	CloseFields     map[string]interface{} `json:"close_fields,omitempty"`     // servicenow: Felder beim Schließen (Default state 6, close_code)
+	Priorities      map[string]string      `json:"priorities,omitempty"`       // Dringlichkeit -> jira: Priorität, servicenow: urgency
+}
+
+// Rule legt fest, welche Ereignisse an welche Kanäle gehen
+type Rule struct {
+	Name     string   `json:"name"`
+	Events   []string `json:"events"`
+	Channels []string `json:"channels"`
+
+	// expiring: Schwellen in Tagen - pro Schwelle eine Meldung (Default 30, 14, 7, 1)
+	Days []int `json:"days,omitempty"`
+	// rotation: nur verdächtige Wechsel
+	SuspiciousOnly bool `json:"suspicious_only,omitempty"`
+	// expiring/validation_failed: erneut melden nach so vielen Stunden (Default 24, 0 = nie)
+	RenotifyHours *int `json:"renotify_hours,omitempty"`
+	// nur diese Endpoints (Muster auf Host oder host:port, z.B. "*.example.com"; leer = alle)
+	Endpoints []string `json:"endpoints,omitempty"`
+	// überschreibt die Dringlichkeit (critical, error, warning, info) - z.B. für kritische Endpoints
+	Severity string `json:"severity,omitempty"`
+}
+
+// QuietHours verschiebt Meldungen in einem täglichen Zeitfenster auf dessen Ende
+type QuietHours struct {
+	Start          string `json:"start"`              // "22:00"
+	End            string `json:"end"`                // "07:00"
+	Timezone       string `json:"timezone,omitempty"` // Default Ortszeit des Agents
+	ExceptCritical bool   `json:"except_critical,omitempty"`
+
+	start, end int // Minuten seit Mitternacht
+	loc        *time.Location
+}
+
+// Load liest und prüft die Notifier-Datei
+func Load(file string) (*Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read notify file: %w", err)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parse notify file: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid notify file: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Validate prüft die Konfiguration und setzt Defaults
+func (c *Config) Validate() error {
+	c.Language = strings.ToLower(c.Language)
+	if c.Language == "" {
+		c.Language = LanguageGerman
+	}
+	if _, ok := builtin[c.Language]; !ok {
+		return fmt.Errorf("unknown language %q (de, en)", c.Language)
+	}
+
+	if c.QuietHours != nil {
+		if err := c.QuietHours.parse(); err != nil {
+			return fmt.Errorf("quiet_hours: %w", err)
+		}
+	}
+
+	channels := make(map[string]bool)
+	channelTypes := make(map[string]string)
+	for i := range c.Channels {
+		ch := &c.Channels[i]
+		if err := ch.validate(c.Language); err != nil {
+			return fmt.Errorf("channel %d: %w", i+1, err)
+		}
+		if channels[ch.Name] {
+			return fmt.Errorf("duplicate channel name %q", ch.Name)
+		}
+		channels[ch.Name] = true
+		channelTypes[ch.Name] = ch.Type
+	}
+
+	if len(c.Rules) == 0 {
+		return fmt.Errorf("at least one rule is required")
+	}
+	for i := range c.Rules {
+		r := &c.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if len(r.Events) == 0 {
+			return fmt.Errorf("rule %s: events are required", r.Name)
+		}
+		for _, e := range r.Events {
+			if !events[e] {
+				return fmt.Errorf("rule %s: unknown event %q (expiring, validation_failed, rotation, unreachable)", r.Name, e)
+			}
+		}
+		if len(r.Channels) == 0 {
+			return fmt.Errorf("rule %s: channels are required", r.Name)
+		}
+		for _, name := range r.Channels {
+			if !channels[name] {
+				return fmt.Errorf("rule %s: unknown channel %q", r.Name, name)
+			}
+			if incidentTypes[channelTypes[name]] && contains(r.Events, EventRotation) {
+				return fmt.Errorf("rule %s: rotation cannot be sent to incident channel %s (rotations resolve incidents)", r.Name, name)
+			}
+		}
+		for _, pattern := range r.Endpoints {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %s: invalid endpoint pattern %q", r.Name, pattern)
+			}
+		}
+		r.Severity = strings.ToLower(r.Severity)
+		if r.Severity != "" && !severities[r.Severity] {
+			return fmt.Errorf("rule %s: invalid severity %q (critical, error, warning, info)", r.Name, r.Severity)
+		}
+		if len(r.Days) == 0 {
+			r.Days = defaultDays
+		}
+		for _, d := range r.Days {
+			if d < 0 {
+				return fmt.Errorf("rule %s: days must not be negative", r.Name)
+			}
+		}
+		if r.RenotifyHours != nil && *r.RenotifyHours < 0 {
+			return fmt.Errorf("rule %s: renotify_hours must not be negative", r.Name)
+		}
+	}
+	return nil
+}
+
+func (ch *ChannelConfig) validate(language string) error {
+	if ch.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	ch.Type = strings.ToLower(ch.Type)
+	ch.Language = strings.ToLower(ch.Language)
+	if ch.Language == "" {
+		ch.Language = language
+	}
+	if _, ok := builtin[ch.Language]; !ok {
+		return fmt.Errorf("%s: unknown language %q (de, en)", ch.Name, ch.Language)
+	}
+	if ch.TimeoutSeconds < 0 {
+		return fmt.Errorf("%s: timeout_seconds must not be negative", ch.Name)
+	}
+	if _, _, err := ch.templates(); err != nil {
+		return fmt.Errorf("%s: %w", ch.Name, err)
+	}
+
+	switch ch.Type {
+	case "slack", "mattermost", "teams":
+		if ch.URL != "" && ch.URLEnv != "" {
+			return fmt.Errorf("%s: use either url or url_env", ch.Name)
+		}
+		url := ch.webhookURL()
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+			return fmt.Errorf("%s: url (or url_env) must be an http(s) URL", ch.Name)
+		}
+	case "smtp":
+		if ch.Host == "" || ch.From == "" || len(ch.To) == 0 {
+			return fmt.Errorf("%s: host, from and to are required for smtp", ch.Name)
+		}
+		for _, addr := range append([]string{ch.From}, ch.To...) {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("%s: invalid address %q: %w", ch.Name, addr, err)
+			}
+		}
+		ch.TLS = strings.ToLower(ch.TLS)
+		switch ch.TLS {
+		case "":
+			ch.TLS = "starttls"
+		case "starttls", "tls", "none":
+		default:
+			return fmt.Errorf("%s: invalid tls %q (starttls, tls, none)", ch.Name, ch.TLS)
+		}
+		if ch.Port == 0 {
+			ch.Port = map[string]int{"starttls": 587, "tls": 465, "none": 25}[ch.TLS]
+		}
+		if ch.CAFile != "" && ch.TLS == "none" {
+			return fmt.Errorf("%s: ca_file requires tls starttls or tls", ch.Name)
+		}
+		if ch.Password != "" && ch.PasswordEnv != "" {
+			return fmt.Errorf("%s: use either password or password_env", ch.Name)
+		}
+		if ch.PasswordEnv != "" && os.Getenv(ch.PasswordEnv) == "" {
+			return fmt.Errorf("%s: password_env %s is not set", ch.Name, ch.PasswordEnv)
+		}
+	case "pagerduty", "opsgenie", "alertmanager":
+		if err := ch.validateIncident(); err != nil {
+			return fmt.Errorf("%s: %w", ch.Name, err)
+		}
+	case "jira", "servicenow":
+		if err := ch.validateTicket(); err != nil {
+			return fmt.Errorf("%s: %w", ch.Name, err)
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %q (smtp, slack, mattermost, teams, pagerduty, opsgenie, alertmanager, jira, servicenow)", ch.Name, ch.Type)
+	}
+	return nil
+}
+
+func (ch *ChannelConfig) webhookURL() string {
+	if ch.URLEnv != "" {
+		return os.Getenv(ch.URLEnv)
+	}
+	return ch.URL
+}
+
+func (ch *ChannelConfig) password() string {
+	if ch.PasswordEnv != "" {
+		return os.Getenv(ch.PasswordEnv)
+	}
+	return ch.Password
+}
+
+func (ch *ChannelConfig) timeout() time.Duration {
+	if ch.TimeoutSeconds > 0 {
+		return time.Duration(ch.TimeoutSeconds) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// renotify liefert den Abstand für erneute Meldungen (0 = nie)
+func (r *Rule) renotify() time.Duration {
+	hours := defaultRenotify
+	if r.RenotifyHours != nil {
+		hours = *r.RenotifyHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// MaxDays liefert die größte Ablauf-Schwelle aller Regeln mit expiring (0 = keine)
+func (c *Config) MaxDays() int {
+	max := 0
+	for _, r := range c.Rules {
+		if !contains(r.Events, EventExpiring) {
+			continue
+		}
+		for _, d := range r.Days {
+			if d > max {
+				max = d
+			}
+		}
+	}
+	return max
+}
+
+func (q *QuietHours) parse() error {
+	var err error
+	if q.start, err = parseClock(q.Start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if q.end, err = parseClock(q.End); err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	q.loc = time.Local
+	if q.Timezone != "" {
+		if q.loc, err = time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseClock übersetzt "HH:MM" in Minuten seit Mitternacht
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Active meldet, ob t in den Ruhezeiten liegt (Fenster über Mitternacht möglich)
+func (q *QuietHours) Active(t time.Time) bool {
+	if q == nil {
+		return false
+	}
+	local := t.In(q.loc)
+	m := local.Hour()*60 + local.Minute()
+	if q.start <= q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+// Ends liefert das Ende der Ruhezeit, in der t liegt
+func (q *QuietHours) Ends(t time.Time) time.Time {
+	local := t.In(q.loc)
+	end := time.Date(local.Year(), local.Month(), local.Day(), q.end/60, q.end%60, 0, 0, q.loc)
+	if !end.After(local) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,298 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// incidentTypes sind Kanäle, die Incidents öffnen, aktualisieren und wieder schließen
+var incidentTypes = map[string]bool{"pagerduty": true, "opsgenie": true, "alertmanager": true}
+
+// IsIncidentType meldet, ob ein Kanal-Typ Incidents statt Meldungen sendet
+func IsIncidentType(typ string) bool {
+	return incidentTypes[typ]
+}
+
+// Default-Endpoints der Incident-Tools
+const (
+	pagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieURL  = "https://api.opsgenie.com"
+)
+
+// defaultAlertTTL: so viele Stunden bleibt ein Alertmanager-Alert ohne neuen Scan aktiv
+const defaultAlertTTL = 24
+
+// opsgeniePriority ordnet die Dringlichkeit den Opsgenie-Prioritäten zu
+var opsgeniePriority = map[string]string{"critical": "P1", "error": "P2", "warning": "P3", "info": "P5"}
+
+// Incident ist ein Vorfall zu einem Zertifikat auf einem Endpoint (bzw. einem nicht
+// erreichbaren Endpoint)
+type Incident struct {
+	Key          string
+	Title, Text  string
+	Notification Notification
+	Previous     *Notification // zuletzt an diesen Kanal gesendet (nil = neuer Incident)
+}
+
+// IncidentChannel öffnet bzw. aktualisiert (Trigger) und schließt (Resolve) Incidents
+type IncidentChannel interface {
+	Trigger(ctx context.Context, inc Incident) error
+	Resolve(ctx context.Context, inc Incident) error
+}
+
+// IncidentKey liefert den Dedup-Key aus Ereignis, Endpoint und Fingerprint. Sieht der
+// Scanner auf dem Endpoint ein anderes Zertifikat, werden die Incidents des alten geschlossen.
+func IncidentKey(n Notification) string {
+	if n.Event == EventUnreachable {
+		return "zertifikat-waechter:unreachable:" + n.Endpoint()
+	}
+	return "zertifikat-waechter:" + n.Event + ":" + n.Endpoint() + ":" + n.Fingerprint
+}
+
+func (ch *ChannelConfig) validateIncident() error {
+	if ch.URL != "" && ch.URLEnv != "" {
+		return fmt.Errorf("use either url or url_env")
+	}
+	if u := ch.webhookURL(); u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return fmt.Errorf("url (or url_env) must be an http(s) URL")
+	}
+
+	switch ch.Type {
+	case "pagerduty":
+		if ch.RoutingKey != "" && ch.RoutingKeyEnv != "" {
+			return fmt.Errorf("use either routing_key or routing_key_env")
+		}
+		if ch.routingKey() == "" {
+			return fmt.Errorf("routing_key (or routing_key_env) is required for pagerduty")
+		}
+	case "opsgenie":
+		if ch.APIKey != "" && ch.APIKeyEnv != "" {
+			return fmt.Errorf("use either api_key or api_key_env")
+		}
+		if ch.apiKey() == "" {
+			return fmt.Errorf("api_key (or api_key_env) is required for opsgenie")
+		}
+	case "alertmanager":
+		if ch.webhookURL() == "" {
+			return fmt.Errorf("url (or url_env) is required for alertmanager")
+		}
+		if ch.TTLHours < 0 {
+			return fmt.Errorf("ttl_hours must not be negative")
+		}
+		if ch.PasswordEnv != "" && os.Getenv(ch.PasswordEnv) == "" {
+			return fmt.Errorf("password_env %s is not set", ch.PasswordEnv)
+		}
+	}
+	return nil
+}
+
+func (ch *ChannelConfig) routingKey() string {
+	if ch.RoutingKeyEnv != "" {
+		return os.Getenv(ch.RoutingKeyEnv)
+	}
+	return ch.RoutingKey
+}
+
+func (ch *ChannelConfig) apiKey() string {
+	if ch.APIKeyEnv != "" {
+		return os.Getenv(ch.APIKeyEnv)
+	}
+	return ch.APIKey
+}
+
+// endpoint liefert die konfigurierte URL ohne abschließenden Slash, sonst def
+func (ch *ChannelConfig) endpoint(def string) string {
+	if u := ch.webhookURL(); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return def
+}
+
+// newIncidentChannel erstellt den Kanal zu einem geprüften Eintrag
+func newIncidentChannel(ch *ChannelConfig) IncidentChannel {
+	client := &http.Client{Timeout: ch.timeout()}
+	switch ch.Type {
+	case "pagerduty":
+		return &pagerDutyChannel{cfg: ch, client: client}
+	case "opsgenie":
+		return &opsgenieChannel{cfg: ch, client: client}
+	case "alertmanager":
+		return &alertmanagerChannel{cfg: ch, client: client}
+	}
+	return nil
+}
+
+// details sind die Zusatzfelder eines Incidents
+func details(n Notification) map[string]string {
+	d := map[string]string{
+		"event":    n.Event,
+		"endpoint": n.Endpoint(),
+		"rule":     n.Rule,
+		"agent":    n.Agent,
+	}
+	if n.Fingerprint != "" {
+		d["fingerprint"] = n.Fingerprint
+		d["certificate"] = n.Name
+		d["issuer"] = n.Issuer
+		d["days_left"] = strconv.Itoa(n.DaysLeft)
+	}
+	if n.NotAfter != nil {
+		d["not_after"] = n.NotAfter.UTC().Format(time.RFC3339)
+	}
+	if n.SNI != "" {
+		d["sni"] = n.SNI
+	}
+	if len(n.Reasons) > 0 {
+		d["reasons"] = strings.Join(n.Reasons, ", ")
+	}
+	if n.ErrorClass != "" {
+		d["error_class"] = n.ErrorClass
+	}
+	return d
+}
+
+// pagerDutyChannel sendet an die PagerDuty Events API v2
+type pagerDutyChannel struct {
+	cfg    *ChannelConfig
+	client *http.Client
+}
+
+func (c *pagerDutyChannel) Trigger(ctx context.Context, inc Incident) error {
+	n := inc.Notification
+	return postJSON(ctx, c.client, c.cfg.endpoint(pagerDutyURL), nil, map[string]interface{}{
+		"routing_key":  c.cfg.routingKey(),
+		"event_action": "trigger",
+		"dedup_key":    inc.Key,
+		"client":       "Zertifikat-Wächter",
+		"payload": map[string]interface{}{
+			"summary":        truncate(inc.Title, 1024),
+			"source":         n.Endpoint(),
+			"severity":       n.Severity, // critical, error, warning, info wie bei PagerDuty
+			"timestamp":      n.Time.UTC().Format(time.RFC3339),
+			"component":      n.Name,
+			"group":          n.Agent,
+			"class":          n.Event,
+			"custom_details": details(n),
+		},
+	})
+}
+
+func (c *pagerDutyChannel) Resolve(ctx context.Context, inc Incident) error {
+	return postJSON(ctx, c.client, c.cfg.endpoint(pagerDutyURL), nil, map[string]interface{}{
+		"routing_key":  c.cfg.routingKey(),
+		"event_action": "resolve",
+		"dedup_key":    inc.Key,
+	})
+}
+
+// opsgenieChannel sendet an die Opsgenie Alert API (Alias = Dedup-Key)
+type opsgenieChannel struct {
+	cfg    *ChannelConfig
+	client *http.Client
+}
+
+func (c *opsgenieChannel) header() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + c.cfg.apiKey()}}
+}
+
+func (c *opsgenieChannel) Trigger(ctx context.Context, inc Incident) error {
+	n := inc.Notification
+	// Ein Alert mit bestehendem Alias wird von Opsgenie aktualisiert statt neu angelegt
+	return postJSON(ctx, c.client, c.cfg.endpoint(opsgenieURL)+"/v2/alerts", c.header(), map[string]interface{}{
+		"message":     truncate(inc.Title, 130),
+		"alias":       truncate(inc.Key, 512),
+		"description": truncate(inc.Text, 15000),
+		"priority":    opsgeniePriority[n.Severity],
+		"source":      "zertifikat-waechter",
+		"entity":      n.Endpoint(),
+		"tags":        []string{"zertifikat-waechter", n.Event},
+		"details":     details(n),
+	})
+}
+
+func (c *opsgenieChannel) Resolve(ctx context.Context, inc Incident) error {
+	endpoint := c.cfg.endpoint(opsgenieURL) + "/v2/alerts/" + url.PathEscape(truncate(inc.Key, 512)) + "/close?identifierType=alias"
+	return postJSON(ctx, c.client, endpoint, c.header(), map[string]interface{}{
+		"source": "zertifikat-waechter",
+		"note":   "Resolved: certificate on " + inc.Notification.Endpoint() + " replaced, endpoint reachable again or retired",
+	})
+}
+
+// alertmanagerChannel sendet an Alertmanager (/api/v2/alerts). Alerts werden bei jedem Scan
+// erneuert und laufen ohne neuen Scan nach ttl_hours ab.
+type alertmanagerChannel struct {
+	cfg    *ChannelConfig
+	client *http.Client
+}
+
+// alertmanagerAlert ist ein Alert der Alertmanager-API v2
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    *time.Time        `json:"startsAt,omitempty"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// alert baut den Alert; die Labels bestimmen die Identität und müssen zum Schließen gleich sein
+func (c *alertmanagerChannel) alert(key, title, text string, n Notification, endsAt time.Time) alertmanagerAlert {
+	labels := map[string]string{
+		"alertname": "TLSCertificateIncident",
+		"dedup_key": key,
+		"instance":  n.Endpoint(),
+		"severity":  n.Severity,
+		"event":     n.Event,
+	}
+	if n.Agent != "" {
+		labels["agent"] = n.Agent
+	}
+	for k, v := range c.cfg.Labels {
+		labels[k] = v
+	}
+	annotations := map[string]string{}
+	if title != "" {
+		annotations["summary"] = title
+		annotations["description"] = text
+	}
+	return alertmanagerAlert{Labels: labels, Annotations: annotations, EndsAt: endsAt.UTC()}
+}
+
+func (c *alertmanagerChannel) header() http.Header {
+	if c.cfg.Username == "" {
+		return nil
+	}
+	return basicAuth(c.cfg.Username, c.cfg.password())
+}
+
+func (c *alertmanagerChannel) post(ctx context.Context, alerts []alertmanagerAlert) error {
+	return postJSON(ctx, c.client, c.cfg.endpoint("")+"/api/v2/alerts", c.header(), alerts)
+}
+
+func (c *alertmanagerChannel) Trigger(ctx context.Context, inc Incident) error {
+	ttl := defaultAlertTTL
+	if c.cfg.TTLHours > 0 {
+		ttl = c.cfg.TTLHours
+	}
+	now := time.Now()
+	firing := c.alert(inc.Key, inc.Title, inc.Text, inc.Notification, now.Add(time.Duration(ttl)*time.Hour))
+	startsAt := inc.Notification.Time.UTC()
+	if !startsAt.IsZero() {
+		firing.StartsAt = &startsAt
+	}
+	alerts := []alertmanagerAlert{firing}
+
+	// Andere Dringlichkeit = andere Labels: den alten Alert schließen
+	if prev := inc.Previous; prev != nil && prev.Severity != inc.Notification.Severity {
+		alerts = append(alerts, c.alert(inc.Key, "", "", *prev, now))
+	}
+	return c.post(ctx, alerts)
+}
+
+func (c *alertmanagerChannel) Resolve(ctx context.Context, inc Incident) error {
+	return c.post(ctx, []alertmanagerAlert{c.alert(inc.Key, inc.Title, inc.Text, inc.Notification, time.Now())})
+}
@@ -0,0 +1,309 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// apiRequest ist ein Request an eine nachgebildete API
+type apiRequest struct {
+	Method string
+	Path   string // ohne Query, unescaped
+	Query  string
+	Header http.Header
+	Body   interface{}
+}
+
+// apiServer bildet die API eines Incident- bzw. Ticket-Tools nach und merkt sich die
+// Requests; respond liefert die Antwort (nil = 202 ohne Body)
+type apiServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []apiRequest
+}
+
+func newAPIServer(t *testing.T, respond func(r apiRequest) (int, interface{})) *apiServer {
+	t.Helper()
+	s := &apiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := apiRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: r.Header.Clone()}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req.Body); err != nil {
+				t.Errorf("%s %s: invalid body: %v", r.Method, r.URL.Path, err)
+			}
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+
+		status, body := http.StatusAccepted, interface{}(nil)
+		if respond != nil {
+			status, body = respond(req)
+		}
+		if body != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// take liefert die Requests seit dem letzten Aufruf
+func (s *apiServer) take() []apiRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.requests
+	s.requests = nil
+	return list
+}
+
+// observedCert erstellt einen Record für ein gesehenes Zertifikat
+func observedCert(host string, port int, fingerprint string) sink.Record {
+	return sink.Record{ID: "observed-" + fingerprint, Kind: sink.KindCertificate, Type: sink.TypeCertificateObserved, Time: time.Now(),
+		Data: map[string]interface{}{"host": host, "port": port, "certificate": &scanner.CertificateData{Fingerprint: fingerprint}}}
+}
+
+func unreachableEvent(host string) sink.Record {
+	return sink.Record{ID: "unreachable-" + host, Kind: sink.KindEvent, Type: sink.TypeEndpointUnreachable, Time: time.Now(), Agent: sink.Agent{Name: "agent-test"},
+		Data: sink.CertificateEvent{Host: host, Port: 443, Severity: sink.SeverityError, ErrorClass: "timeout"}}
+}
+
+func incidentConfig(ch ChannelConfig) *Config {
+	return &Config{
+		Channels: []ChannelConfig{ch},
+		Rules:    []Rule{{Name: "incidents", Events: []string{EventExpiring, EventUnreachable}, Channels: []string{ch.Name}}},
+	}
+}
+
+// body liefert einen Wert aus dem JSON-Body (Pfad mit Punkten, Arrays mit Index)
+func body(t *testing.T, req apiRequest, path string) interface{} {
+	t.Helper()
+	v := req.Body
+	for _, part := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i >= len(node) {
+				t.Fatalf("%s: invalid index %q", path, part)
+			}
+			v = node[i]
+		default:
+			t.Fatalf("%s: no value at %q in %v", path, part, req.Body)
+		}
+	}
+	return v
+}
+
+func TestIncidentKey(t *testing.T) {
+	expiring := Notification{Event: EventExpiring, Host: "api.example", Port: 443, Fingerprint: "aa"}
+	if got, want := IncidentKey(expiring), "zertifikat-waechter:expiring:api.example:443:aa"; got != want {
+		t.Errorf("IncidentKey = %q, want %q", got, want)
+	}
+	rotated := expiring
+	rotated.Fingerprint = "bb"
+	if IncidentKey(rotated) == IncidentKey(expiring) {
+		t.Error("a new certificate must get a new incident key")
+	}
+	unreachable := Notification{Event: EventUnreachable, Host: "api.example", Port: 443, Fingerprint: "aa"}
+	if got, want := IncidentKey(unreachable), "zertifikat-waechter:unreachable:api.example:443"; got != want {
+		t.Errorf("IncidentKey = %q, want %q", got, want)
+	}
+}
+
+// TestPagerDuty: Trigger und Update mit demselben dedup_key, Resolve beim Zertifikatswechsel
+// bzw. wenn der Endpoint wieder erreichbar ist
+func TestPagerDuty(t *testing.T) {
+	api := newAPIServer(t, nil)
+	n := newTestNotifier(t, incidentConfig(ChannelConfig{Name: "pd", Type: "pagerduty", URL: api.URL + "/v2/enqueue", RoutingKey: "rk-123"}))
+	ctx := context.Background()
+	key := "zertifikat-waechter:expiring:api.example:443:aa"
+
+	for _, days := range []int{20, 5} {
+		if err := n.Write(ctx, expiringEvent("api.example", "aa", days)); err != nil {
+			t.Fatalf("trigger (%d days): %v", days, err)
+		}
+	}
+	reqs := api.take()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want trigger and update", len(reqs))
+	}
+	for _, req := range reqs {
+		if req.Method != "POST" || req.Path != "/v2/enqueue" || body(t, req, "routing_key") != "rk-123" ||
+			body(t, req, "event_action") != "trigger" || body(t, req, "dedup_key") != key {
+			t.Errorf("trigger request = %+v", req)
+		}
+	}
+	if body(t, reqs[0], "payload.severity") != sink.SeverityWarning || body(t, reqs[1], "payload.severity") != sink.SeverityCritical {
+		t.Errorf("severities = %v, %v", body(t, reqs[0], "payload.severity"), body(t, reqs[1], "payload.severity"))
+	}
+	if body(t, reqs[1], "payload.source") != "api.example:443" || body(t, reqs[1], "payload.custom_details.fingerprint") != "aa" {
+		t.Errorf("payload = %v", reqs[1].Body)
+	}
+	if got := n.Incidents(); len(got) != 1 || got[0].Key != key || got[0].Severity != sink.SeverityCritical {
+		t.Errorf("Incidents() = %+v", got)
+	}
+
+	// Dasselbe Zertifikat gesehen: Incident bleibt offen
+	if err := n.Write(ctx, observedCert("api.example", 443, "aa")); err != sink.ErrSkipped {
+		t.Errorf("same certificate: got %v, want ErrSkipped", err)
+	}
+	// Neues Zertifikat: Incident des alten wird geschlossen
+	if err := n.Write(ctx, observedCert("api.example", 443, "bb")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	reqs = api.take()
+	if len(reqs) != 1 || body(t, reqs[0], "event_action") != "resolve" || body(t, reqs[0], "dedup_key") != key {
+		t.Errorf("resolve requests = %+v", reqs)
+	}
+	if got := n.Incidents(); len(got) != 0 {
+		t.Errorf("Incidents() after rotation = %+v", got)
+	}
+
+	// Nicht erreichbar: ein Incident pro Endpoint, geschlossen mit dem nächsten Zertifikat
+	n.Write(ctx, unreachableEvent("api.example"))
+	n.Write(ctx, observedCert("api.example", 443, "bb"))
+	reqs = api.take()
+	if len(reqs) != 2 || body(t, reqs[0], "dedup_key") != "zertifikat-waechter:unreachable:api.example:443" ||
+		body(t, reqs[1], "event_action") != "resolve" || body(t, reqs[1], "dedup_key") != body(t, reqs[0], "dedup_key") {
+		t.Errorf("unreachable requests = %+v", reqs)
+	}
+	if got := stats(n, "pd"); got.Sent != 3 || got.Resolved != 2 || got.Open != 0 {
+		t.Errorf("stats = %+v", got)
+	}
+}
+
+// TestOpsgenie: Alias als Dedup-Key, Schließen über /v2/alerts/<alias>/close
+func TestOpsgenie(t *testing.T) {
+	api := newAPIServer(t, nil)
+	n := newTestNotifier(t, incidentConfig(ChannelConfig{Name: "og", Type: "opsgenie", URL: api.URL, APIKey: "genie"}))
+	ctx := context.Background()
+	key := "zertifikat-waechter:expiring:api.example:443:aa"
+
+	n.Write(ctx, expiringEvent("api.example", "aa", 20))
+	n.Write(ctx, expiringEvent("api.example", "aa", 5))
+	reqs := api.take()
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want trigger and update", len(reqs))
+	}
+	for _, req := range reqs {
+		if req.Path != "/v2/alerts" || req.Header.Get("Authorization") != "GenieKey genie" || body(t, req, "alias") != key {
+			t.Errorf("trigger request = %+v", req)
+		}
+	}
+	if body(t, reqs[0], "priority") != "P3" || body(t, reqs[1], "priority") != "P1" || body(t, reqs[1], "entity") != "api.example:443" {
+		t.Errorf("update = %v", reqs[1].Body)
+	}
+
+	n.Write(ctx, observedCert("api.example", 443, "bb"))
+	reqs = api.take()
+	if len(reqs) != 1 || reqs[0].Path != "/v2/alerts/"+key+"/close" || reqs[0].Query != "identifierType=alias" ||
+		body(t, reqs[0], "source") != "zertifikat-waechter" {
+		t.Errorf("close requests = %+v", reqs)
+	}
+	if got := n.Incidents(); len(got) != 0 {
+		t.Errorf("Incidents() after rotation = %+v", got)
+	}
+}
+
+// TestAlertmanager: Alerts mit dedup_key-Label, Wechsel der Dringlichkeit schließt den
+// alten Alert, Resolve setzt endsAt auf jetzt
+func TestAlertmanager(t *testing.T) {
+	api := newAPIServer(t, func(apiRequest) (int, interface{}) { return http.StatusOK, nil })
+	n := newTestNotifier(t, incidentConfig(ChannelConfig{Name: "am", Type: "alertmanager", URL: api.URL + "/", TTLHours: 2,
+		Labels: map[string]string{"team": "platform"}, Username: "am", Password: "secret"}))
+	ctx := context.Background()
+	key := "zertifikat-waechter:expiring:api.example:443:aa"
+	endsAt := func(req apiRequest, i int) time.Time {
+		t.Helper()
+		at, err := time.Parse(time.RFC3339, body(t, req, strconv.Itoa(i)+".endsAt").(string))
+		if err != nil {
+			t.Fatalf("endsAt: %v", err)
+		}
+		return at
+	}
+
+	n.Write(ctx, expiringEvent("api.example", "aa", 20))
+	reqs := api.take()
+	if len(reqs) != 1 || reqs[0].Path != "/api/v2/alerts" || !strings.HasPrefix(reqs[0].Header.Get("Authorization"), "Basic ") {
+		t.Fatalf("trigger requests = %+v", reqs)
+	}
+	if body(t, reqs[0], "0.labels.dedup_key") != key || body(t, reqs[0], "0.labels.team") != "platform" ||
+		body(t, reqs[0], "0.labels.severity") != sink.SeverityWarning || body(t, reqs[0], "0.labels.instance") != "api.example:443" {
+		t.Errorf("alert = %v", reqs[0].Body)
+	}
+	if ttl := time.Until(endsAt(reqs[0], 0)); ttl < 110*time.Minute || ttl > 2*time.Hour {
+		t.Errorf("endsAt in %s, want ttl_hours 2", ttl)
+	}
+
+	// Dringlichkeit steigt: neuer Alert, der alte (andere Labels) wird beendet
+	n.Write(ctx, expiringEvent("api.example", "aa", 5))
+	reqs = api.take()
+	if len(reqs) != 1 || len(reqs[0].Body.([]interface{})) != 2 {
+		t.Fatalf("update requests = %+v", reqs)
+	}
+	if body(t, reqs[0], "0.labels.severity") != sink.SeverityCritical || body(t, reqs[0], "1.labels.severity") != sink.SeverityWarning ||
+		body(t, reqs[0], "1.labels.dedup_key") != key || time.Until(endsAt(reqs[0], 1)) > time.Minute {
+		t.Errorf("update = %v", reqs[0].Body)
+	}
+
+	n.Write(ctx, observedCert("api.example", 443, "bb"))
+	reqs = api.take()
+	if len(reqs) != 1 || body(t, reqs[0], "0.labels.dedup_key") != key || body(t, reqs[0], "0.labels.severity") != sink.SeverityCritical ||
+		time.Until(endsAt(reqs[0], 0)) > time.Minute {
+		t.Errorf("resolve requests = %+v", reqs)
+	}
+	if got := n.Incidents(); len(got) != 0 {
+		t.Errorf("Incidents() after rotation = %+v", got)
+	}
+}
+
+// TestIncidentRetry: ein gescheiterter Resolve bleibt offen und wird beim nächsten Scan wiederholt
+func TestIncidentRetry(t *testing.T) {
+	failing := true
+	var mu sync.Mutex
+	api := newAPIServer(t, func(req apiRequest) (int, interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing && req.Body.(map[string]interface{})["event_action"] == "resolve" {
+			return http.StatusServiceUnavailable, map[string]string{"status": "unavailable"}
+		}
+		return http.StatusAccepted, nil
+	})
+	n := newTestNotifier(t, incidentConfig(ChannelConfig{Name: "pd", Type: "pagerduty", URL: api.URL, RoutingKey: "rk"}))
+	ctx := context.Background()
+
+	n.Write(ctx, expiringEvent("api.example", "aa", 5))
+	if err := n.Write(ctx, observedCert("api.example", 443, "bb")); err == nil {
+		t.Fatal("failed resolve reported success")
+	}
+	if got := n.Incidents(); len(got) != 1 {
+		t.Fatalf("incident dropped after failed resolve: %+v", got)
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	if err := n.Write(ctx, observedCert("api.example", 443, "bb")); err != nil {
+		t.Fatalf("retry resolve: %v", err)
+	}
+	if got := n.Incidents(); len(got) != 0 {
+		t.Errorf("Incidents() = %+v", got)
+	}
+}
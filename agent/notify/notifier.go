@@ -0,0 +1,1185 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// flushInterval: so oft werden in den Ruhezeiten zurückgestellte Meldungen geprüft
+const flushInterval = 30 * time.Second
+
+// stateRetention: gesendete Meldungen werden so lange für die Deduplizierung gemerkt
+const stateRetention = 90 * 24 * time.Hour
+
+// recordTypes ordnet die Ereignisse den Record-Typen der Sinks zu
+var recordTypes = map[string]string{
+	EventExpiring:         sink.TypeCertificateExpiring,
+	EventValidationFailed: sink.TypeValidationFailed,
+	EventRotation:         sink.TypeCertificateChange,
+	EventUnreachable:      sink.TypeEndpointUnreachable,
+}
+
+// ChannelStats sind die Zähler eines Kanals
+type ChannelStats struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Sent       int64  `json:"sent"`
+	Failed     int64  `json:"failed"`
+	Suppressed int64  `json:"suppressed"` // bereits gemeldet (Deduplizierung)
+	Deferred   int    `json:"deferred"`   // wartet auf das Ende der Ruhezeit
+	Resolved   int64  `json:"resolved"`   // Incident- und Ticket-Kanäle: geschlossene Incidents bzw. Tickets
+	Open       int    `json:"open"`       // Incident- und Ticket-Kanäle: offene Incidents bzw. Tickets
+}
+
+// channel ist ein Kanal mit Vorlagen und Zählern
+type channel struct {
+	cfg      *ChannelConfig
+	sender   Channel         // Meldungen (smtp, slack, mattermost, teams)
+	incident IncidentChannel // Incidents (pagerduty, opsgenie, alertmanager)
+	ticket   TicketChannel   // Erneuerungs-Tickets (jira, servicenow)
+	renderer *renderer
+
+	sent       atomic.Int64
+	failed     atomic.Int64
+	suppressed atomic.Int64
+	resolved   atomic.Int64
+}
+
+// deferred ist eine Meldung, die auf das Ende der Ruhezeit wartet
+type deferred struct {
+	channel      *channel
+	notification Notification
+}
+
+// openIncident ist ein offener Incident mit der zuletzt gesendeten Meldung pro Kanal
+type openIncident struct {
+	Host        string                  `json:"host"`
+	Port        int                     `json:"port"`
+	Fingerprint string                  `json:"fingerprint,omitempty"` // leer = Endpoint nicht erreichbar
+	Opened      time.Time               `json:"opened"`
+	Channels    map[string]Notification `json:"channels"`
+}
+
+// openTicket ist ein offenes Erneuerungs-Ticket zu einem Zertifikat
+type openTicket struct {
+	Channel     string          `json:"channel"`
+	Fingerprint string          `json:"fingerprint"`
+	Ref         TicketRef       `json:"ref"`
+	Endpoints   map[string]bool `json:"endpoints"` // host:port -> liefert das Zertifikat noch aus
+	Threshold   int             `json:"threshold"`
+	Note        Notification    `json:"notification"` // zuletzt ins Ticket übernommen
+	Opened      time.Time       `json:"opened"`
+	Updated     time.Time       `json:"updated"`
+}
+
+// Notifier wertet Zertifikats-Ereignisse nach Regeln aus und benachrichtigt die Kanäle.
+// Er ist als Sink am Dispatcher angemeldet (eigene Warteschlange, Wiederholungen).
+type Notifier struct {
+	cfg      *Config
+	log      *logrus.Logger
+	channels map[string]*channel
+
+	// work serialisiert Write, flush und Close: sie ändern Incidents und Tickets über
+	// mehrere Aufrufe der Kanäle hinweg. mu schützt nur die Maps und wird nie während
+	// Netzwerk- oder Datei-Zugriffen gehalten - Stats, Incidents und Tickets (Status-API,
+	// /metrics) warten so nicht auf einen hängenden Kanal.
+	work sync.Mutex
+
+	mu      sync.Mutex
+	sent    map[string]time.Time // Dedup-Schlüssel -> zuletzt gesendet
+	pending map[string]deferred  // Ruhezeit: Dedup-Schlüssel -> Meldung
+	open    map[string]*openIncident
+	tickets map[string]*openTicket // Kanal|Fingerprint -> Ticket
+	locate  func(fingerprint string) []string
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New erstellt den Notifier, lädt den gespeicherten Stand und startet die Zustellung
+// zurückgestellter Meldungen
+func New(cfg *Config, log *logrus.Logger) (*Notifier, error) {
+	n := &Notifier{
+		cfg:      cfg,
+		log:      log,
+		channels: make(map[string]*channel),
+		sent:     make(map[string]time.Time),
+		pending:  make(map[string]deferred),
+		open:     make(map[string]*openIncident),
+		tickets:  make(map[string]*openTicket),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for i := range cfg.Channels {
+		ch := &cfg.Channels[i]
+		r, err := newRenderer(ch)
+		if err != nil {
+			return nil, fmt.Errorf("channel %s: %w", ch.Name, err)
+		}
+		c := &channel{cfg: ch, renderer: r}
+		switch {
+		case incidentTypes[ch.Type]:
+			c.incident = newIncidentChannel(ch)
+		case ticketTypes[ch.Type]:
+			c.ticket = newTicketChannel(ch)
+		default:
+			c.sender = newChannel(ch)
+		}
+		n.channels[ch.Name] = c
+	}
+	if err := n.loadState(); err != nil {
+		return nil, err
+	}
+
+	go n.run()
+	return n, nil
+}
+
+// SinkConfig liefert den Sink-Eintrag, mit dem der Notifier am Dispatcher angemeldet wird
+func (n *Notifier) SinkConfig(name string) sink.Config {
+	kinds := []sink.Kind{sink.KindEvent}
+	types := []string{}
+	for _, r := range n.cfg.Rules {
+		for _, e := range r.Events {
+			if !contains(types, recordTypes[e]) {
+				types = append(types, recordTypes[e])
+			}
+		}
+	}
+	for _, ch := range n.channels {
+		if ch.incident != nil || ch.ticket != nil {
+			// Gesehene und gewechselte Zertifikate schließen Incidents und Tickets,
+			kinds = append(kinds, sink.KindCertificate)
+			// ausgemusterte Endpoints schließen sie ebenfalls
+			for _, t := range []string{sink.TypeCertificateObserved, sink.TypeCertificateChange, sink.TypeAssetTransition} {
+				if !contains(types, t) {
+					types = append(types, t)
+				}
+			}
+			break
+		}
+	}
+	return sink.Config{
+		Name:              name,
+		Type:              "notify",
+		Filter:            sink.Filter{Kinds: kinds, Types: types, ExpiringDays: n.cfg.MaxDays()},
+		RetryDelaySeconds: 5,
+	}
+}
+
+// SetLocator legt fest, wie die Fundorte eines Zertifikats für Tickets ermittelt werden
+// (ohne Locator nur die Endpoints aus den Ereignissen)
+func (n *Notifier) SetLocator(locate func(fingerprint string) []string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.locate = locate
+}
+
+// Write wertet einen Record aus: ein gesehenes Zertifikat schließt offene Incidents des
+// Endpoints und die Tickets ersetzter Zertifikate, ein ausgemusterter Endpoint seine
+// Incidents und Tickets; Ereignisse gehen nach den passenden Regeln an die Kanäle
+func (n *Notifier) Write(ctx context.Context, rec sink.Record) error {
+	n.work.Lock()
+	defer n.work.Unlock()
+	defer n.saveState()
+
+	var errs []error
+	handled := false
+	if host, port, fingerprint, ok := observed(rec); ok {
+		resolved, err := n.resolve(ctx, host, port, fingerprint)
+		handled = resolved
+		if err != nil {
+			errs = append(errs, err)
+		}
+		closed, err := n.closeTickets(ctx, host, port, fingerprint)
+		handled = handled || closed
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if host, port, ok := retired(rec); ok {
+		resolved, err := n.resolve(ctx, host, port, "")
+		handled = handled || resolved
+		if err != nil {
+			errs = append(errs, err)
+		}
+		closed, err := n.retireTickets(ctx, host, port)
+		handled = handled || closed
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if note, ok := fromRecord(rec); ok {
+		sent, err := n.notify(ctx, rec, note)
+		handled = handled || sent
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if !handled {
+		return sink.ErrSkipped
+	}
+	return nil
+}
+
+// notify benachrichtigt die Kanäle der passenden Regeln. Bereits benachrichtigte Kanäle
+// werden bei einer Wiederholung übersprungen; Incident-Kanäle werden bei jedem Scan
+// aktualisiert (die Deduplizierung übernimmt das Tool über den Dedup-Key).
+func (n *Notifier) notify(ctx context.Context, rec sink.Record, note Notification) (bool, error) {
+	now := time.Now()
+	quietHours := n.cfg.QuietHours.Active(now)
+
+	var errs []error
+	handled := false
+	for i := range n.cfg.Rules {
+		rule := &n.cfg.Rules[i]
+		ruleNote, ok := rule.apply(note)
+		if !ok {
+			continue
+		}
+		for _, name := range rule.Channels {
+			ch := n.channels[name]
+			if ch.incident != nil {
+				if err := n.trigger(ctx, ch, ruleNote); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					continue
+				}
+				handled = true
+				continue
+			}
+			if ch.ticket != nil {
+				updated, err := n.ticket(ctx, ch, ruleNote)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					continue
+				}
+				handled = handled || updated
+				continue
+			}
+			key, edge := dedupeKey(rule, name, ruleNote, rec.ID)
+			n.mu.Lock()
+			last, sent := n.sent[key]
+			n.mu.Unlock()
+			if sent && (edge || rule.renotify() == 0 || now.Sub(last) < rule.renotify()) {
+				ch.suppressed.Add(1)
+				continue
+			}
+			if quietHours && !(n.cfg.QuietHours.ExceptCritical && ruleNote.Severity == sink.SeverityCritical) {
+				// Neuere Daten ersetzen eine bereits zurückgestellte Meldung
+				n.mu.Lock()
+				_, waiting := n.pending[key]
+				n.pending[key] = deferred{channel: ch, notification: ruleNote}
+				n.mu.Unlock()
+				if !waiting {
+					n.log.WithFields(logrus.Fields{
+						"channel": name,
+						"rule":    rule.Name,
+						"until":   n.cfg.QuietHours.Ends(now).Format(time.RFC3339),
+					}).Info("Notification deferred by quiet hours")
+				}
+				handled = true
+				continue
+			}
+			if err := n.send(ctx, ch, ruleNote); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			n.mu.Lock()
+			n.sent[key] = now
+			n.dirty = true
+			n.mu.Unlock()
+			handled = true
+		}
+	}
+
+	return handled, errors.Join(errs...)
+}
+
+// send rendert und stellt eine Meldung über einen Kanal zu
+func (n *Notifier) send(ctx context.Context, ch *channel, note Notification) error {
+	title, text, err := ch.renderer.render(note)
+	if err != nil {
+		ch.failed.Add(1)
+		return sink.Permanent(err)
+	}
+	if err := ch.sender.Send(ctx, title, text, note); err != nil {
+		ch.failed.Add(1)
+		return err
+	}
+	ch.sent.Add(1)
+	n.log.WithFields(logrus.Fields{
+		"channel":  ch.cfg.Name,
+		"rule":     note.Rule,
+		"event":    note.Event,
+		"endpoint": note.Endpoint(),
+	}).Info("Notification sent")
+	return nil
+}
+
+// trigger öffnet bzw. aktualisiert den Incident zu einer Meldung
+func (n *Notifier) trigger(ctx context.Context, ch *channel, note Notification) error {
+	key := IncidentKey(note)
+	var previous *Notification
+	n.mu.Lock()
+	if inc := n.open[key]; inc != nil {
+		if last, ok := inc.Channels[ch.cfg.Name]; ok {
+			previous = &last
+		}
+	}
+	n.mu.Unlock()
+
+	title, text, err := ch.renderer.render(note)
+	if err != nil {
+		ch.failed.Add(1)
+		return sink.Permanent(err)
+	}
+	if err := ch.incident.Trigger(ctx, Incident{Key: key, Title: title, Text: text, Notification: note, Previous: previous}); err != nil {
+		ch.failed.Add(1)
+		return err
+	}
+	ch.sent.Add(1)
+
+	n.mu.Lock()
+	inc := n.open[key]
+	if inc == nil {
+		inc = &openIncident{Host: note.Host, Port: note.Port, Opened: time.Now(), Channels: make(map[string]Notification)}
+		if note.Event != EventUnreachable {
+			inc.Fingerprint = note.Fingerprint
+		}
+		n.open[key] = inc
+	}
+	inc.Channels[ch.cfg.Name] = note
+	n.dirty = true
+	n.mu.Unlock()
+
+	if previous == nil {
+		n.log.WithFields(logrus.Fields{
+			"channel":  ch.cfg.Name,
+			"rule":     note.Rule,
+			"event":    note.Event,
+			"endpoint": note.Endpoint(),
+			"key":      key,
+		}).Info("Incident opened")
+	}
+	return nil
+}
+
+// resolving ist ein Incident, der in einem Kanal geschlossen wird
+type resolving struct {
+	key     string
+	channel *channel
+	last    Notification
+}
+
+// resolve schließt die offenen Incidents eines Endpoints, auf dem jetzt das Zertifikat
+// fingerprint zu sehen ist: die anderer Zertifikate und den des nicht erreichbaren Endpoints
+// (fingerprint "" = alle, der Endpoint wurde ausgemustert)
+func (n *Notifier) resolve(ctx context.Context, host string, port int, fingerprint string) (bool, error) {
+	var todo []resolving
+	n.mu.Lock()
+	for key, inc := range n.open {
+		if inc.Host != host || inc.Port != port || (inc.Fingerprint != "" && inc.Fingerprint == fingerprint) {
+			continue
+		}
+		for name, last := range inc.Channels {
+			ch, ok := n.channels[name]
+			if !ok || ch.incident == nil {
+				// Kanal nicht mehr konfiguriert
+				delete(inc.Channels, name)
+				n.dirty = true
+				continue
+			}
+			todo = append(todo, resolving{key: key, channel: ch, last: last})
+		}
+		if len(inc.Channels) == 0 {
+			delete(n.open, key)
+			n.dirty = true
+		}
+	}
+	n.mu.Unlock()
+
+	var errs []error
+	resolved := false
+	for _, r := range todo {
+		name := r.channel.cfg.Name
+		title, text, _ := r.channel.renderer.render(r.last)
+		if err := r.channel.incident.Resolve(ctx, Incident{Key: r.key, Title: title, Text: text, Notification: r.last}); err != nil {
+			r.channel.failed.Add(1)
+			errs = append(errs, fmt.Errorf("%s: resolve: %w", name, err))
+			continue
+		}
+		r.channel.resolved.Add(1)
+		resolved = true
+
+		n.mu.Lock()
+		if inc := n.open[r.key]; inc != nil {
+			delete(inc.Channels, name)
+			if len(inc.Channels) == 0 {
+				delete(n.open, r.key)
+			}
+		}
+		n.dirty = true
+		n.mu.Unlock()
+		n.log.WithFields(logrus.Fields{"channel": name, "endpoint": r.last.Endpoint(), "key": r.key}).Info("Incident resolved")
+	}
+	return resolved, errors.Join(errs...)
+}
+
+// ticket legt das Ticket zum Zertifikat der Meldung an bzw. aktualisiert es, wenn ein neuer
+// Fundort dazukommt oder eine niedrigere Ablauf-Schwelle erreicht ist
+func (n *Notifier) ticket(ctx context.Context, ch *channel, note Notification) (bool, error) {
+	key := ch.cfg.Name + "|" + note.Fingerprint
+
+	// Änderungen an einer Kopie - bei Fehlern wird der nächste Versuch gleich entschieden
+	t := &openTicket{Channel: ch.cfg.Name, Fingerprint: note.Fingerprint, Endpoints: make(map[string]bool),
+		Threshold: note.Threshold, Opened: time.Now()}
+	n.mu.Lock()
+	current := n.tickets[key]
+	if current != nil {
+		copied := *current
+		t = &copied
+		t.Endpoints = make(map[string]bool, len(current.Endpoints))
+		for ep, serving := range current.Endpoints {
+			t.Endpoints[ep] = serving
+		}
+	}
+	locate := n.locate
+	n.mu.Unlock()
+
+	texts := ticketTexts[ch.cfg.Language]
+	var comments []string
+	locations := []string{note.Endpoint()}
+	if locate != nil {
+		locations = append(locations, locate(note.Fingerprint)...)
+	}
+	for _, ep := range locations {
+		if !t.Endpoints[ep] {
+			t.Endpoints[ep] = true
+			comments = append(comments, fmt.Sprintf(texts["endpoint"], ep))
+		}
+	}
+	if current != nil && note.Threshold < t.Threshold {
+		t.Threshold = note.Threshold
+		if note.DaysLeft < 0 {
+			comments = append(comments, fmt.Sprintf(texts["expired"], -note.DaysLeft))
+		} else {
+			comments = append(comments, fmt.Sprintf(texts["expiring"], note.DaysLeft))
+		}
+	}
+	if current != nil && len(comments) == 0 {
+		ch.suppressed.Add(1)
+		return false, nil
+	}
+	t.Note = note
+	t.Updated = time.Now()
+
+	ticket, err := n.buildTicket(ch, t)
+	if err != nil {
+		ch.failed.Add(1)
+		return false, sink.Permanent(err)
+	}
+	fields := logrus.Fields{"channel": ch.cfg.Name, "rule": note.Rule, "endpoint": note.Endpoint(), "fingerprint": note.Fingerprint}
+	if current == nil {
+		ref, err := ch.ticket.Create(ctx, ticket)
+		if err != nil {
+			ch.failed.Add(1)
+			return false, err
+		}
+		t.Ref = ref
+		n.log.WithFields(fields).WithField("ticket", ref.Number).Info("Ticket created")
+	} else {
+		if err := ch.ticket.Update(ctx, t.Ref, ticket, strings.Join(comments, "\n")); err != nil {
+			ch.failed.Add(1)
+			return false, err
+		}
+		n.log.WithFields(fields).WithField("ticket", t.Ref.Number).Info("Ticket updated")
+	}
+	ch.sent.Add(1)
+	n.mu.Lock()
+	n.tickets[key] = t
+	n.dirty = true
+	n.mu.Unlock()
+	return true, nil
+}
+
+// buildTicket rendert Zusammenfassung, Beschreibung und Feld-Zuordnung eines Tickets
+func (n *Notifier) buildTicket(ch *channel, t *openTicket) (Ticket, error) {
+	data := TicketData{Notification: t.Note, Ticket: t.Ref.Number}
+	for ep := range t.Endpoints {
+		data.Endpoints = append(data.Endpoints, ep)
+	}
+	sort.Strings(data.Endpoints)
+
+	summary, description, err := ch.renderer.renderTicket(data)
+	if err != nil {
+		return Ticket{}, err
+	}
+	fields, err := ch.cfg.renderFields(ch.cfg.Fields, data)
+	if err != nil {
+		return Ticket{}, fmt.Errorf("render fields: %w", err)
+	}
+	return Ticket{Summary: summary, Description: description, Data: data, Fields: fields}, nil
+}
+
+// closing ist ein Ticket, das geschlossen wird
+type closing struct {
+	key     string
+	channel *channel
+	ticket  *openTicket
+}
+
+// closeTickets vermerkt, welches Zertifikat auf einem Endpoint zu sehen ist, und schließt
+// die Tickets, deren Zertifikat auf keinem Fundort mehr ausgeliefert wird
+func (n *Notifier) closeTickets(ctx context.Context, host string, port int, fingerprint string) (bool, error) {
+	endpoint := Notification{Host: host, Port: port}.Endpoint()
+	var todo []closing
+	n.mu.Lock()
+	for key, t := range n.tickets {
+		serving, ok := t.Endpoints[endpoint]
+		if !ok {
+			continue
+		}
+		if now := t.Fingerprint == fingerprint; now != serving {
+			t.Endpoints[endpoint] = now
+			n.dirty = true
+		}
+		if anyServing(t.Endpoints) {
+			continue
+		}
+
+		ch, ok := n.channels[t.Channel]
+		if !ok || ch.ticket == nil {
+			// Kanal nicht mehr konfiguriert
+			delete(n.tickets, key)
+			n.dirty = true
+			continue
+		}
+		todo = append(todo, closing{key: key, channel: ch, ticket: t})
+	}
+	n.mu.Unlock()
+
+	short := fingerprint
+	if len(short) > 16 {
+		short = short[:16]
+	}
+	var errs []error
+	closed := false
+	for _, c := range todo {
+		// Tickets ändern sich nur unter n.work - c.ticket darf ohne n.mu gelesen werden
+		comment := fmt.Sprintf(ticketTexts[c.channel.cfg.Language]["closed"], short)
+		if err := n.closeTicket(ctx, c, comment); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		closed = true
+	}
+	return closed, errors.Join(errs...)
+}
+
+// closeTicket schließt ein Ticket mit einem Kommentar und vergisst es
+func (n *Notifier) closeTicket(ctx context.Context, c closing, comment string) error {
+	t := c.ticket
+	ticket, err := n.buildTicket(c.channel, t)
+	if err == nil {
+		err = c.channel.ticket.Close(ctx, t.Ref, ticket, comment)
+	}
+	if err != nil {
+		c.channel.failed.Add(1)
+		return fmt.Errorf("%s: close ticket %s: %w", t.Channel, t.Ref.Number, err)
+	}
+	c.channel.resolved.Add(1)
+
+	n.mu.Lock()
+	delete(n.tickets, c.key)
+	n.dirty = true
+	n.mu.Unlock()
+	n.log.WithFields(logrus.Fields{"channel": t.Channel, "ticket": t.Ref.Number, "fingerprint": t.Fingerprint}).Info("Ticket closed")
+	return nil
+}
+
+// retireTickets nimmt einen ausgemusterten Fundort aus den Tickets: Tickets ohne weiteren
+// Fundort, der das Zertifikat noch ausliefert, werden geschlossen, die übrigen bekommen
+// einen Kommentar. Der Stand ändert sich erst nach Erfolg - eine Wiederholung des
+// Records findet den Fundort noch.
+func (n *Notifier) retireTickets(ctx context.Context, host string, port int) (bool, error) {
+	endpoint := Notification{Host: host, Port: port}.Endpoint()
+	var todo []closing
+	n.mu.Lock()
+	for key, t := range n.tickets {
+		if _, ok := t.Endpoints[endpoint]; !ok {
+			continue
+		}
+		ch, ok := n.channels[t.Channel]
+		if !ok || ch.ticket == nil {
+			// Kanal nicht mehr konfiguriert
+			delete(n.tickets, key)
+			n.dirty = true
+			continue
+		}
+		copied := *t
+		copied.Endpoints = make(map[string]bool, len(t.Endpoints))
+		for ep, serving := range t.Endpoints {
+			if ep != endpoint {
+				copied.Endpoints[ep] = serving
+			}
+		}
+		todo = append(todo, closing{key: key, channel: ch, ticket: &copied})
+	}
+	n.mu.Unlock()
+
+	var errs []error
+	changed := false
+	for _, c := range todo {
+		texts := ticketTexts[c.channel.cfg.Language]
+		if !anyServing(c.ticket.Endpoints) {
+			if err := n.closeTicket(ctx, c, fmt.Sprintf(texts["abandoned"], endpoint)); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			changed = true
+			continue
+		}
+
+		t := c.ticket
+		t.Updated = time.Now()
+		ticket, err := n.buildTicket(c.channel, t)
+		if err == nil {
+			err = c.channel.ticket.Update(ctx, t.Ref, ticket, fmt.Sprintf(texts["retired"], endpoint))
+		}
+		if err != nil {
+			c.channel.failed.Add(1)
+			errs = append(errs, fmt.Errorf("%s: update ticket %s: %w", t.Channel, t.Ref.Number, err))
+			continue
+		}
+		n.mu.Lock()
+		n.tickets[c.key] = t
+		n.dirty = true
+		n.mu.Unlock()
+		changed = true
+		n.log.WithFields(logrus.Fields{"channel": t.Channel, "ticket": t.Ref.Number, "endpoint": endpoint}).Info("Ticket updated: endpoint retired")
+	}
+	return changed, errors.Join(errs...)
+}
+
+func anyServing(endpoints map[string]bool) bool {
+	for _, serving := range endpoints {
+		if serving {
+			return true
+		}
+	}
+	return false
+}
+
+// Test sendet eine Beispielmeldung an einen Kanal (ohne Regeln, Deduplizierung und Ruhezeiten)
+func (n *Notifier) Test(ctx context.Context, channelName string, note Notification) error {
+	ch, ok := n.channels[channelName]
+	if !ok {
+		return fmt.Errorf("unknown channel %q", channelName)
+	}
+	title, text, err := ch.renderer.render(note)
+	if err != nil {
+		return err
+	}
+	if ch.incident != nil {
+		// Incident öffnen und gleich wieder schließen
+		inc := Incident{Key: IncidentKey(note) + ":test", Title: title, Text: text, Notification: note}
+		if err := ch.incident.Trigger(ctx, inc); err != nil {
+			return err
+		}
+		ch.sent.Add(1)
+		if err := ch.incident.Resolve(ctx, inc); err != nil {
+			return fmt.Errorf("resolve: %w", err)
+		}
+		ch.resolved.Add(1)
+		return nil
+	}
+	if ch.ticket != nil {
+		// Ticket anlegen und gleich wieder schließen
+		t := &openTicket{Note: note, Endpoints: map[string]bool{note.Endpoint(): true}}
+		ticket, err := n.buildTicket(ch, t)
+		if err != nil {
+			return err
+		}
+		ref, err := ch.ticket.Create(ctx, ticket)
+		if err != nil {
+			return err
+		}
+		ch.sent.Add(1)
+		t.Ref = ref
+		if ticket, err = n.buildTicket(ch, t); err == nil {
+			err = ch.ticket.Close(ctx, ref, ticket, fmt.Sprintf(ticketTexts[ch.cfg.Language]["closed"], "test"))
+		}
+		if err != nil {
+			return fmt.Errorf("close %s: %w", ref.Number, err)
+		}
+		ch.resolved.Add(1)
+		return nil
+	}
+	if err := ch.sender.Send(ctx, title, text, note); err != nil {
+		return err
+	}
+	ch.sent.Add(1)
+	return nil
+}
+
+// run stellt zurückgestellte Meldungen nach dem Ende der Ruhezeit zu
+func (n *Notifier) run() {
+	defer close(n.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.flush()
+		case <-n.stop:
+			return
+		}
+	}
+}
+
+// flush sendet zurückgestellte Meldungen, sobald keine Ruhezeit mehr ist
+func (n *Notifier) flush() {
+	if n.cfg.QuietHours.Active(time.Now()) {
+		return
+	}
+	n.work.Lock()
+	defer n.work.Unlock()
+
+	n.mu.Lock()
+	pending := make(map[string]deferred, len(n.pending))
+	for key, d := range n.pending {
+		pending[key] = d
+	}
+	n.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	for key, d := range pending {
+		ctx, cancel := context.WithTimeout(context.Background(), d.channel.cfg.timeout())
+		err := n.send(ctx, d.channel, d.notification)
+		cancel()
+		if err != nil {
+			// Beim nächsten Durchlauf erneut versuchen
+			n.log.WithError(err).WithField("channel", d.channel.cfg.Name).Warn("Failed to send deferred notification")
+			continue
+		}
+		n.mu.Lock()
+		delete(n.pending, key)
+		n.sent[key] = time.Now()
+		n.dirty = true
+		n.mu.Unlock()
+	}
+	n.saveState()
+}
+
+// Close beendet die Zustellung und speichert den Stand. Zurückgestellte Meldungen
+// gehen verloren - sie werden beim nächsten Scan nach einem Neustart erneut erkannt.
+func (n *Notifier) Close() error {
+	close(n.stop)
+	<-n.done
+
+	n.work.Lock()
+	defer n.work.Unlock()
+	n.mu.Lock()
+	dropped := len(n.pending)
+	n.mu.Unlock()
+	if dropped > 0 {
+		n.log.WithField("deferred", dropped).Warn("Notifier stopped during quiet hours - deferred notifications dropped")
+	}
+	return n.saveState()
+}
+
+// Stats liefert die Zähler aller Kanäle in Konfigurationsreihenfolge
+func (n *Notifier) Stats() []ChannelStats {
+	n.mu.Lock()
+	deferredBy := make(map[string]int)
+	for _, d := range n.pending {
+		deferredBy[d.channel.cfg.Name]++
+	}
+	openBy := make(map[string]int)
+	for _, inc := range n.open {
+		for name := range inc.Channels {
+			openBy[name]++
+		}
+	}
+	for _, t := range n.tickets {
+		openBy[t.Channel]++
+	}
+	n.mu.Unlock()
+
+	list := make([]ChannelStats, 0, len(n.cfg.Channels))
+	for _, c := range n.cfg.Channels {
+		ch := n.channels[c.Name]
+		list = append(list, ChannelStats{
+			Name:       c.Name,
+			Type:       c.Type,
+			Sent:       ch.sent.Load(),
+			Failed:     ch.failed.Load(),
+			Suppressed: ch.suppressed.Load(),
+			Deferred:   deferredBy[c.Name],
+			Resolved:   ch.resolved.Load(),
+			Open:       openBy[c.Name],
+		})
+	}
+	return list
+}
+
+// IncidentStatus ist ein offener Incident (für die Status-API)
+type IncidentStatus struct {
+	Key         string    `json:"key"`
+	Host        string    `json:"host"`
+	Port        int       `json:"port"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Event       string    `json:"event"`
+	Severity    string    `json:"severity"`
+	Opened      time.Time `json:"opened"`
+	Updated     time.Time `json:"updated"`
+	Channels    []string  `json:"channels"`
+}
+
+// Incidents liefert die offenen Incidents, älteste zuerst
+func (n *Notifier) Incidents() []IncidentStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := make([]IncidentStatus, 0, len(n.open))
+	for key, inc := range n.open {
+		status := IncidentStatus{Key: key, Host: inc.Host, Port: inc.Port, Fingerprint: inc.Fingerprint, Opened: inc.Opened}
+		for name, last := range inc.Channels {
+			status.Channels = append(status.Channels, name)
+			if !last.Time.Before(status.Updated) {
+				status.Updated, status.Event, status.Severity = last.Time, last.Event, last.Severity
+			}
+		}
+		sort.Strings(status.Channels)
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Opened.Equal(list[j].Opened) {
+			return list[i].Opened.Before(list[j].Opened)
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// TicketStatus ist ein offenes Erneuerungs-Ticket (für die Status-API)
+type TicketStatus struct {
+	Channel     string          `json:"channel"`
+	Ticket      string          `json:"ticket"`
+	Fingerprint string          `json:"fingerprint"`
+	Name        string          `json:"name"`
+	NotAfter    *time.Time      `json:"not_after,omitempty"`
+	Threshold   int             `json:"threshold"`
+	Endpoints   map[string]bool `json:"endpoints"` // host:port -> liefert das Zertifikat noch aus
+	Opened      time.Time       `json:"opened"`
+	Updated     time.Time       `json:"updated"`
+}
+
+// Tickets liefert die offenen Erneuerungs-Tickets, älteste zuerst
+func (n *Notifier) Tickets() []TicketStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	list := make([]TicketStatus, 0, len(n.tickets))
+	for _, t := range n.tickets {
+		status := TicketStatus{Channel: t.Channel, Ticket: t.Ref.Number, Fingerprint: t.Fingerprint, Name: t.Note.Name,
+			NotAfter: t.Note.NotAfter, Threshold: t.Threshold, Endpoints: make(map[string]bool, len(t.Endpoints)),
+			Opened: t.Opened, Updated: t.Updated}
+		for ep, serving := range t.Endpoints {
+			status.Endpoints[ep] = serving
+		}
+		list = append(list, status)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Opened.Equal(list[j].Opened) {
+			return list[i].Opened.Before(list[j].Opened)
+		}
+		return list[i].Channel+list[i].Ticket < list[j].Channel+list[j].Ticket
+	})
+	return list
+}
+
+// Summary beschreibt Kanäle und Regeln ohne Geheimnisse (für die Status-API)
+func (n *Notifier) Summary() map[string]interface{} {
+	channels := make([]map[string]interface{}, 0, len(n.cfg.Channels))
+	for _, c := range n.cfg.Channels {
+		entry := map[string]interface{}{"name": c.Name, "type": c.Type, "language": c.Language}
+		if c.Type == "smtp" {
+			entry["host"] = c.Host
+			entry["port"] = c.Port
+			entry["tls"] = c.TLS
+			entry["to"] = c.To
+		}
+		if incidentTypes[c.Type] {
+			entry["incidents"] = true
+		}
+		if c.Type == "alertmanager" {
+			entry["labels"] = c.Labels
+			entry["ttl_hours"] = c.TTLHours
+		}
+		if ticketTypes[c.Type] {
+			entry["tickets"] = true
+			entry["url"] = c.endpoint("")
+			entry["priorities"] = c.Priorities
+			if c.Type == "jira" {
+				entry["project"] = c.Project
+				entry["issue_type"] = c.IssueType
+			} else {
+				entry["table"] = c.Table
+			}
+		}
+		channels = append(channels, entry)
+	}
+	return map[string]interface{}{
+		"channels":    channels,
+		"rules":       n.cfg.Rules,
+		"quiet_hours": n.cfg.QuietHours,
+	}
+}
+
+// apply prüft, ob die Regel für die Meldung gilt, und setzt Regel und Ablauf-Schwelle
+func (r *Rule) apply(note Notification) (Notification, bool) {
+	if !contains(r.Events, note.Event) || !r.matchEndpoint(note) {
+		return note, false
+	}
+	note.Rule = r.Name
+	if r.Severity != "" {
+		note.Severity = r.Severity
+	}
+	switch note.Event {
+	case EventRotation:
+		if r.SuspiciousOnly && !note.Suspicious {
+			return note, false
+		}
+	case EventExpiring:
+		// kleinste Schwelle, die das Zertifikat schon erreicht hat
+		threshold := -1
+		for _, d := range r.Days {
+			if note.DaysLeft <= d && (threshold < 0 || d < threshold) {
+				threshold = d
+			}
+		}
+		if threshold < 0 {
+			return note, false
+		}
+		note.Threshold = threshold
+	}
+	return note, true
+}
+
+// matchEndpoint prüft die Endpoint-Muster der Regel gegen Host und host:port
+func (r *Rule) matchEndpoint(note Notification) bool {
+	if len(r.Endpoints) == 0 {
+		return true
+	}
+	for _, pattern := range r.Endpoints {
+		if ok, _ := path.Match(pattern, note.Host); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, note.Endpoint()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupeKey liefert den Schlüssel für die Deduplizierung. Ablauf und fehlgeschlagene
+// Prüfung werden bei jedem Scan erneut gemeldet und pro Zertifikat (und Schwelle bzw.
+// Gründen) zusammengefasst; Wechsel und Ausfälle sind einmalige Ereignisse (edge).
+func dedupeKey(r *Rule, channel string, note Notification, recordID string) (string, bool) {
+	base := r.Name + "|" + channel + "|" + note.Event + "|"
+	switch note.Event {
+	case EventExpiring:
+		return fmt.Sprintf("%s%s|%s|%d", base, note.Endpoint(), note.Fingerprint, note.Threshold), false
+	case EventValidationFailed:
+		reasons := append([]string(nil), note.Reasons...)
+		sort.Strings(reasons)
+		return fmt.Sprintf("%s%s|%s|%s", base, note.Endpoint(), note.Fingerprint, strings.Join(reasons, ",")), false
+	}
+	return base + recordID, true
+}
+
+// observed liefert Endpoint und Fingerprint eines gesehenen bzw. gewechselten Zertifikats
+func observed(rec sink.Record) (string, int, string, bool) {
+	data, ok := rec.Data.(map[string]interface{})
+	if !ok {
+		return "", 0, "", false
+	}
+	switch rec.Type {
+	case sink.TypeCertificateObserved:
+		cert, ok := data["certificate"].(*scanner.CertificateData)
+		host, _ := data["host"].(string)
+		port, _ := data["port"].(int)
+		if !ok || cert == nil {
+			return "", 0, "", false
+		}
+		return host, port, cert.Fingerprint, true
+	case sink.TypeCertificateChange:
+		event, ok := data["change"].(rotation.Event)
+		return event.Host, event.Port, event.NewFingerprint, ok
+	}
+	return "", 0, "", false
+}
+
+// retired liefert den Endpoint eines Assets, das nach retired gewechselt ist
+func retired(rec sink.Record) (string, int, bool) {
+	data, ok := rec.Data.(map[string]interface{})
+	if !ok || rec.Type != sink.TypeAssetTransition || data["to"] != lifecycle.StateRetired {
+		return "", 0, false
+	}
+	host, _ := data["host"].(string)
+	port, _ := data["port"].(int)
+	return host, port, host != ""
+}
+
+// fromRecord übersetzt einen Record in eine Meldung
+func fromRecord(rec sink.Record) (Notification, bool) {
+	note := Notification{Agent: rec.Agent.Name, Time: rec.Time}
+
+	switch data := rec.Data.(type) {
+	case sink.CertificateEvent:
+		switch rec.Type {
+		case sink.TypeCertificateExpiring:
+			note.Event = EventExpiring
+		case sink.TypeValidationFailed:
+			note.Event = EventValidationFailed
+		case sink.TypeEndpointUnreachable:
+			note.Event = EventUnreachable
+		default:
+			return note, false
+		}
+		note.Severity = data.Severity
+		note.Host, note.Port, note.SNI = data.Host, data.Port, data.SNI
+		note.Name = data.SubjectCN
+		note.Issuer = data.Issuer
+		note.Fingerprint = data.Fingerprint
+		note.NotAfter = data.NotAfter
+		note.DaysLeft = data.DaysLeft
+		note.Reasons = data.Reasons
+		note.ErrorClass = data.ErrorClass
+		if note.Name == "" && len(note.Fingerprint) > 16 {
+			note.Name = note.Fingerprint[:16]
+		}
+		return note, true
+
+	case map[string]interface{}:
+		event, ok := data["change"].(rotation.Event)
+		if rec.Type != sink.TypeCertificateChange || !ok {
+			return note, false
+		}
+		note.Event = EventRotation
+		note.Severity = sink.SeverityInfo
+		if event.Suspicious {
+			note.Severity = sink.SeverityWarning
+		}
+		note.Host, note.Port, note.SNI = event.Host, event.Port, event.SNI
+		note.Fingerprint = event.NewFingerprint
+		note.OldFinger = event.OldFingerprint
+		note.Suspicious = event.Suspicious
+		note.Reasons = event.SuspiciousReasons
+		for _, c := range event.Changes {
+			note.Changes = append(note.Changes, c.Field)
+		}
+		return note, true
+	}
+	return note, false
+}
+
+// stateFile ist der gespeicherte Stand der Deduplizierung
+type stateFile struct {
+	Sent      map[string]time.Time     `json:"sent"`
+	Incidents map[string]*openIncident `json:"incidents,omitempty"`
+	Tickets   map[string]*openTicket   `json:"tickets,omitempty"`
+}
+
+func (n *Notifier) loadState() error {
+	if n.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(n.cfg.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read notify state: %w", err)
+	}
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("parse notify state %s: %w", n.cfg.StateFile, err)
+	}
+	for k, v := range state.Sent {
+		n.sent[k] = v
+	}
+	for k, v := range state.Incidents {
+		if v != nil && len(v.Channels) > 0 {
+			n.open[k] = v
+		}
+	}
+	for k, v := range state.Tickets {
+		if v != nil && len(v.Endpoints) > 0 {
+			n.tickets[k] = v
+		}
+	}
+	return nil
+}
+
+// saveState schreibt den Stand atomar (nur bei Änderungen); n.work muss gehalten werden.
+// Unter n.mu wird nur serialisiert, die Datei danach ohne n.mu geschrieben.
+func (n *Notifier) saveState() error {
+	if n.cfg.StateFile == "" {
+		return nil
+	}
+	n.mu.Lock()
+	if !n.dirty {
+		n.mu.Unlock()
+		return nil
+	}
+	cutoff := time.Now().Add(-stateRetention)
+	for k, v := range n.sent {
+		if v.Before(cutoff) {
+			delete(n.sent, k)
+		}
+	}
+	data, err := json.Marshal(stateFile{Sent: n.sent, Incidents: n.open, Tickets: n.tickets})
+	n.dirty = false
+	n.mu.Unlock()
+
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(n.cfg.StateFile), 0o750)
+	}
+	if err == nil {
+		tmp := n.cfg.StateFile + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o640); err == nil {
+			err = os.Rename(tmp, n.cfg.StateFile)
+		}
+	}
+	if err != nil {
+		n.mu.Lock()
+		n.dirty = true
+		n.mu.Unlock()
+		n.log.WithError(err).Warn("Failed to save notify state")
+		return err
+	}
+	return nil
+}
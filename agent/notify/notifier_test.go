@@ -0,0 +1,600 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// newTestNotifier prüft cfg und erstellt einen Notifier, der am Testende geschlossen wird
+func newTestNotifier(t *testing.T, cfg *Config) *Notifier {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	n, err := New(cfg, log)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+// certEvent erstellt einen Record mit einem Zertifikats-Ereignis
+func certEvent(typ, host, fingerprint string, days int, severity string, reasons ...string) sink.Record {
+	notAfter := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+	return sink.Record{
+		ID:    "rec-" + typ + "-" + host + "-" + strconv.Itoa(days),
+		Kind:  sink.KindEvent,
+		Type:  typ,
+		Time:  time.Now(),
+		Agent: sink.Agent{Name: "agent-test"},
+		Data: sink.CertificateEvent{
+			Host: host, Port: 443, Severity: severity, Fingerprint: fingerprint,
+			SubjectCN: host, Issuer: "Test CA", NotAfter: &notAfter, DaysLeft: days, Reasons: reasons,
+		},
+	}
+}
+
+func expiringEvent(host, fingerprint string, days int) sink.Record {
+	severity := sink.SeverityWarning
+	if days <= 7 {
+		severity = sink.SeverityCritical
+	}
+	return certEvent(sink.TypeCertificateExpiring, host, fingerprint, days, severity)
+}
+
+// hookServer ist ein Incoming-Webhook (Slack, Mattermost, Teams), der die Payloads sammelt
+type hookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	payloads []map[string]interface{}
+}
+
+func newHookServer(t *testing.T) *hookServer {
+	t.Helper()
+	h := &hookServer{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		h.mu.Lock()
+		h.payloads = append(h.payloads, payload)
+		h.mu.Unlock()
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *hookServer) received() []map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]map[string]interface{}(nil), h.payloads...)
+}
+
+// smtpServer ist ein minimaler SMTP-Server im Prozess
+type smtpServer struct {
+	ln   net.Listener
+	host string
+	port int
+
+	// Mit tls bietet der Server STARTTLS an, mit user auch AUTH PLAIN (erst nach STARTTLS)
+	// und nimmt Mails nur nach erfolgreicher Anmeldung an
+	tls        *tls.Config
+	user, pass string
+
+	mu       sync.Mutex
+	messages []*mail.Message
+	bodies   []string
+	rcpts    [][]string
+	secure   []bool // Mail kam über TLS
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpServer{ln: ln, host: "127.0.0.1", port: ln.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(t, conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// newSecureSMTPServer startet einen SMTP-Server mit STARTTLS und AUTH PLAIN; caFile enthält
+// die CA seines Zertifikats
+func newSecureSMTPServer(t *testing.T, user, pass string) (s *smtpServer, caFile string) {
+	t.Helper()
+	cert, caPEM := selfSignedCert(t, "127.0.0.1")
+	caFile = filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s = newSMTPServer(t)
+	s.mu.Lock()
+	s.tls = &tls.Config{Certificates: []tls.Certificate{cert}}
+	s.user, s.pass = user, pass
+	s.mu.Unlock()
+	return s, caFile
+}
+
+// selfSignedCert erstellt ein selbstsigniertes Server-Zertifikat für eine IP-Adresse
+func selfSignedCert(t *testing.T, ip string) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smtp test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP(ip)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func (s *smtpServer) serve(t *testing.T, conn net.Conn) {
+	defer func() { conn.Close() }()
+	s.mu.Lock()
+	tlsConfig, user, pass := s.tls, s.user, s.pass
+	s.mu.Unlock()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP test")
+	var rcpts []string
+	secure, authenticated := false, user == ""
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			lines := []string{"localhost"}
+			if tlsConfig != nil && !secure {
+				lines = append(lines, "STARTTLS")
+			}
+			if user != "" && secure {
+				lines = append(lines, "AUTH PLAIN")
+			}
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			if tlsConfig == nil || secure {
+				tp.PrintfLine("502 not implemented")
+				continue
+			}
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return // Client lehnt das Zertifikat ab
+			}
+			conn, tp, secure = tlsConn, textproto.NewConn(tlsConn), true
+		case "AUTH":
+			mech, resp, _ := strings.Cut(arg, " ")
+			creds, _ := base64.StdEncoding.DecodeString(resp)
+			if !secure || !strings.EqualFold(mech, "PLAIN") || string(creds) != "\x00"+user+"\x00"+pass {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			authenticated = true
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			if !authenticated {
+				tp.PrintfLine("530 authentication required")
+				continue
+			}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			_, addr, _ := strings.Cut(arg, ":")
+			rcpts = append(rcpts, strings.Trim(addr, "<> "))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Errorf("invalid mail: %v", err)
+				tp.PrintfLine("554 invalid")
+				continue
+			}
+			body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.bodies = append(s.bodies, string(body))
+			s.rcpts = append(s.rcpts, rcpts)
+			s.secure = append(s.secure, secure)
+			s.mu.Unlock()
+			rcpts = nil
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.messages)
+}
+
+func slackChannel(name, url string) ChannelConfig {
+	return ChannelConfig{Name: name, Type: "slack", URL: url}
+}
+
+func renotify(hours int) *int {
+	return &hours
+}
+
+func stats(n *Notifier, channel string) ChannelStats {
+	for _, s := range n.Stats() {
+		if s.Name == channel {
+			return s
+		}
+	}
+	return ChannelStats{}
+}
+
+// TestRules: Ereignis, Endpoint-Muster, Ablauf-Schwellen und verdächtige Wechsel
+// entscheiden über die Kanäle
+func TestRules(t *testing.T) {
+	prod, all, rotations := newHookServer(t), newHookServer(t), newHookServer(t)
+	n := newTestNotifier(t, &Config{
+		Channels: []ChannelConfig{slackChannel("prod", prod.URL), slackChannel("all", all.URL), slackChannel("rotations", rotations.URL)},
+		Rules: []Rule{
+			{Name: "prod", Events: []string{EventExpiring}, Channels: []string{"prod"}, Endpoints: []string{"*.prod.example"}, Severity: "critical"},
+			{Name: "all", Events: []string{EventExpiring, EventValidationFailed}, Channels: []string{"all"}, Days: []int{14}},
+			{Name: "rotations", Events: []string{EventRotation}, Channels: []string{"rotations"}, SuspiciousOnly: true},
+		},
+	})
+	ctx := context.Background()
+
+	if err := n.Write(ctx, expiringEvent("api.prod.example", "aa", 20)); err != nil {
+		t.Fatalf("prod expiring: %v", err)
+	}
+	if err := n.Write(ctx, expiringEvent("api.test.example", "bb", 10)); err != nil {
+		t.Fatalf("test expiring: %v", err)
+	}
+	if err := n.Write(ctx, expiringEvent("api.test.example", "cc", 60)); err != sink.ErrSkipped {
+		t.Errorf("certificate beyond all thresholds: got %v, want ErrSkipped", err)
+	}
+	change := func(suspicious bool) sink.Record {
+		return sink.Record{ID: "change-" + strconv.FormatBool(suspicious), Kind: sink.KindEvent, Type: sink.TypeCertificateChange, Time: time.Now(),
+			Data: map[string]interface{}{"change": rotation.Event{Host: "api.test.example", Port: 443, OldFingerprint: "bb", NewFingerprint: "dd", Suspicious: suspicious}}}
+	}
+	if err := n.Write(ctx, change(false)); err != sink.ErrSkipped {
+		t.Errorf("unsuspicious rotation: got %v, want ErrSkipped", err)
+	}
+	if err := n.Write(ctx, change(true)); err != nil {
+		t.Errorf("suspicious rotation: %v", err)
+	}
+
+	if got := prod.received(); len(got) != 1 || !strings.Contains(got[0]["text"].(string), "🔴") {
+		t.Errorf("prod channel got %v, want one critical notification (severity override)", got)
+	}
+	if got := all.received(); len(got) != 1 || !strings.Contains(got[0]["text"].(string), "api.test.example") {
+		t.Errorf("all channel got %v, want only api.test.example (20 days is beyond its threshold)", got)
+	}
+	if got := rotations.received(); len(got) != 1 {
+		t.Errorf("rotations channel got %d notifications, want 1", len(got))
+	}
+}
+
+// TestDedupeWindow: dieselbe Meldung erst nach renotify_hours erneut, eine neue
+// Ablauf-Schwelle sofort; der Stand überlebt einen Neustart
+func TestDedupeWindow(t *testing.T) {
+	hook := newHookServer(t)
+	stateFile := filepath.Join(t.TempDir(), "notify-state.json")
+	cfg := func() *Config {
+		return &Config{
+			StateFile: stateFile,
+			Channels:  []ChannelConfig{slackChannel("ops", hook.URL)},
+			Rules:     []Rule{{Name: "expiring", Events: []string{EventExpiring}, Channels: []string{"ops"}, RenotifyHours: renotify(24)}},
+		}
+	}
+	n := newTestNotifier(t, cfg())
+	ctx := context.Background()
+
+	n.Write(ctx, expiringEvent("api.example", "aa", 20))
+	if err := n.Write(ctx, expiringEvent("api.example", "aa", 19)); err != sink.ErrSkipped {
+		t.Errorf("repeat within window: got %v, want ErrSkipped", err)
+	}
+	if got := stats(n, "ops"); got.Sent != 1 || got.Suppressed != 1 {
+		t.Errorf("stats = %+v, want sent 1 suppressed 1", got)
+	}
+
+	// 30-Tage-Schwelle vor mehr als 24 Stunden gemeldet
+	n.mu.Lock()
+	for key := range n.sent {
+		n.sent[key] = time.Now().Add(-25 * time.Hour)
+	}
+	n.mu.Unlock()
+	if err := n.Write(ctx, expiringEvent("api.example", "aa", 18)); err != nil {
+		t.Errorf("repeat after window: %v", err)
+	}
+	if err := n.Write(ctx, expiringEvent("api.example", "aa", 13)); err != nil {
+		t.Errorf("new threshold: %v", err)
+	}
+	if got := len(hook.received()); got != 3 {
+		t.Errorf("sent %d notifications, want 3", got)
+	}
+
+	// Write speichert den Stand nach jedem Record
+	restarted := newTestNotifier(t, cfg())
+	if err := restarted.Write(ctx, expiringEvent("api.example", "aa", 12)); err != sink.ErrSkipped {
+		t.Errorf("after restart: got %v, want ErrSkipped", err)
+	}
+}
+
+// TestQuietHours: Meldungen in den Ruhezeiten werden zurückgestellt (kritische mit
+// except_critical nicht) und nach dem Ende einmal zugestellt
+func TestQuietHours(t *testing.T) {
+	hook := newHookServer(t)
+	now := time.Now().UTC()
+	n := newTestNotifier(t, &Config{
+		QuietHours: &QuietHours{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04"), Timezone: "UTC", ExceptCritical: true},
+		Channels:   []ChannelConfig{slackChannel("ops", hook.URL)},
+		Rules:      []Rule{{Name: "expiring", Events: []string{EventExpiring}, Channels: []string{"ops"}}},
+	})
+	ctx := context.Background()
+
+	if err := n.Write(ctx, expiringEvent("a.example", "aa", 20)); err != nil {
+		t.Fatalf("deferred write: %v", err)
+	}
+	n.Write(ctx, expiringEvent("a.example", "aa", 19)) // ersetzt die zurückgestellte Meldung
+	if err := n.Write(ctx, expiringEvent("b.example", "bb", 3)); err != nil {
+		t.Fatalf("critical write: %v", err)
+	}
+	if got := len(hook.received()); got != 1 {
+		t.Fatalf("sent %d notifications during quiet hours, want only the critical one", got)
+	}
+	if got := stats(n, "ops").Deferred; got != 1 {
+		t.Errorf("deferred = %d, want 1", got)
+	}
+
+	n.flush()
+	if got := len(hook.received()); got != 1 {
+		t.Errorf("flush during quiet hours sent %d notifications, want 1", got)
+	}
+
+	n.cfg.QuietHours = nil
+	n.flush()
+	got := hook.received()
+	if len(got) != 2 || !strings.Contains(got[1]["text"].(string), "19") {
+		t.Errorf("after quiet hours got %v, want the latest deferred notification (19 days)", got)
+	}
+	if got := stats(n, "ops"); got.Deferred != 0 || got.Sent != 2 {
+		t.Errorf("stats = %+v, want deferred 0 sent 2", got)
+	}
+	if err := n.Write(ctx, expiringEvent("a.example", "aa", 18)); err != sink.ErrSkipped {
+		t.Errorf("flushed notification not deduplicated: %v", err)
+	}
+}
+
+// TestChannelPayloads prüft den Aufbau der Meldung für SMTP, Slack, Teams und Mattermost
+func TestChannelPayloads(t *testing.T) {
+	slack, teams, mattermost := newHookServer(t), newHookServer(t), newHookServer(t)
+	mailServer := newSMTPServer(t)
+	n := newTestNotifier(t, &Config{
+		Channels: []ChannelConfig{
+			slackChannel("slack", slack.URL),
+			{Name: "teams", Type: "teams", URL: teams.URL},
+			{Name: "mattermost", Type: "mattermost", URL: mattermost.URL, Username: "waechter", Channel: "certs"},
+			{Name: "mail", Type: "smtp", Host: mailServer.host, Port: mailServer.port, TLS: "none",
+				From: "Zertifikat-Wächter <agent@example.com>", To: []string{"ops@example.com", "Sec <sec@example.com>"}},
+		},
+		Rules: []Rule{{Name: "all", Events: []string{EventExpiring}, Channels: []string{"slack", "teams", "mattermost", "mail"}}},
+	})
+	if err := n.Write(context.Background(), expiringEvent("api.example", "aa", 5)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	got := slack.received()
+	if len(got) != 1 {
+		t.Fatalf("slack got %d payloads", len(got))
+	}
+	blocks := got[0]["blocks"].([]interface{})
+	header := blocks[0].(map[string]interface{})["text"].(map[string]interface{})
+	if header["type"] != "plain_text" || !strings.Contains(header["text"].(string), "läuft in 5 Tagen ab") {
+		t.Errorf("slack header = %v", header)
+	}
+	if text := blocks[1].(map[string]interface{})["text"].(map[string]interface{})["text"].(string); !strings.HasPrefix(text, "```") || !strings.Contains(text, "Fingerprint: aa") {
+		t.Errorf("slack section = %q", text)
+	}
+
+	got = teams.received()
+	if len(got) != 1 {
+		t.Fatalf("teams got %d payloads", len(got))
+	}
+	attachment := got[0]["attachments"].([]interface{})[0].(map[string]interface{})
+	card := attachment["content"].(map[string]interface{})
+	title := card["body"].([]interface{})[0].(map[string]interface{})
+	if attachment["contentType"] != "application/vnd.microsoft.card.adaptive" || card["type"] != "AdaptiveCard" || title["color"] != "Attention" {
+		t.Errorf("teams card = %v", attachment)
+	}
+
+	got = mattermost.received()
+	if len(got) != 1 || got[0]["username"] != "waechter" || got[0]["channel"] != "certs" || !strings.HasPrefix(got[0]["text"].(string), "#### 🔴") {
+		t.Errorf("mattermost payload = %v", got)
+	}
+
+	if mailServer.count() != 1 {
+		t.Fatalf("smtp got %d messages", mailServer.count())
+	}
+	msg := mailServer.messages[0]
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || !strings.Contains(subject, "läuft in 5 Tagen ab: api.example (api.example:443)") {
+		t.Errorf("subject = %q (%v)", subject, err)
+	}
+	if msg.Header.Get("X-Zertifikat-Waechter-Event") != EventExpiring || msg.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+		t.Errorf("headers = %v", msg.Header)
+	}
+	if rcpts := mailServer.rcpts[0]; len(rcpts) != 2 || rcpts[1] != "sec@example.com" {
+		t.Errorf("recipients = %v", rcpts)
+	}
+	if body := mailServer.bodies[0]; !strings.Contains(body, "Das Zertifikat läuft in 5 Tagen ab.") || !strings.Contains(body, "Endpoint:    api.example:443") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+// TestSMTPStartTLSAuth prüft STARTTLS mit eigener CA und die Anmeldung per AUTH PLAIN
+func TestSMTPStartTLSAuth(t *testing.T) {
+	mailServer, caFile := newSecureSMTPServer(t, "agent@example.com", "geheim")
+	t.Setenv("TEST_SMTP_PASSWORD", "geheim")
+	channel := func(name, caFile, passwordEnv string) ChannelConfig {
+		return ChannelConfig{Name: name, Type: "smtp", Host: mailServer.host, Port: mailServer.port, CAFile: caFile,
+			Username: "agent@example.com", PasswordEnv: passwordEnv, From: "agent@example.com", To: []string{"ops@example.com"}}
+	}
+	t.Setenv("TEST_SMTP_WRONG", "falsch")
+
+	tests := []struct {
+		name    string
+		channel ChannelConfig
+		wantErr string
+	}{
+		{"starttls and auth", channel("mail", caFile, "TEST_SMTP_PASSWORD"), ""},
+		{"wrong password", channel("mail", caFile, "TEST_SMTP_WRONG"), "smtp auth"},
+		{"unknown ca", channel("mail", "", "TEST_SMTP_PASSWORD"), "starttls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNotifier(t, &Config{
+				Channels: []ChannelConfig{tt.channel},
+				Rules:    []Rule{{Name: "all", Events: []string{EventExpiring}, Channels: []string{"mail"}}},
+			})
+			before := mailServer.count()
+			err := n.Write(context.Background(), expiringEvent("api.example", "aa", 5))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				if mailServer.count() != before+1 {
+					t.Fatalf("smtp got %d messages, want %d", mailServer.count(), before+1)
+				}
+				mailServer.mu.Lock()
+				secure := mailServer.secure[before]
+				mailServer.mu.Unlock()
+				if !secure {
+					t.Error("mail was sent without TLS")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Write error = %v, want %q", err, tt.wantErr)
+			}
+			if mailServer.count() != before {
+				t.Errorf("mail delivered despite %s", tt.name)
+			}
+		})
+	}
+}
+
+// TestSMTPCAFileRequiresTLS: ca_file ist ohne TLS sinnlos
+func TestSMTPCAFileRequiresTLS(t *testing.T) {
+	cfg := &Config{
+		Channels: []ChannelConfig{{Name: "mail", Type: "smtp", Host: "localhost", TLS: "none", CAFile: "ca.pem",
+			From: "agent@example.com", To: []string{"ops@example.com"}}},
+		Rules: []Rule{{Name: "all", Events: []string{EventExpiring}, Channels: []string{"mail"}}},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ca_file requires tls") {
+		t.Errorf("Validate = %v", err)
+	}
+}
+
+// TestStatusNotBlockedBySend: Stats, Incidents und Tickets warten nicht auf einen
+// hängenden Kanal
+func TestStatusNotBlockedBySend(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	n := newTestNotifier(t, &Config{
+		StateFile: filepath.Join(t.TempDir(), "state.json"),
+		Channels:  []ChannelConfig{slackChannel("slow", slow.URL)},
+		Rules:     []Rule{{Name: "expiring", Events: []string{EventExpiring}, Channels: []string{"slow"}}},
+	})
+	go n.Write(context.Background(), expiringEvent("api.example", "aa", 20))
+	<-entered
+
+	done := make(chan struct{})
+	go func() {
+		n.Stats()
+		n.Incidents()
+		n.Tickets()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("status calls blocked while a channel was sending")
+	}
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpChannel versendet E-Mails per SMTP (STARTTLS, implizites TLS oder - für lokale
+// Test-Server - unverschlüsselt)
+type smtpChannel struct {
+	cfg *ChannelConfig
+}
+
+func (c *smtpChannel) Send(ctx context.Context, title, text string, n Notification) error {
+	addr := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+	deadline := time.Now().Add(c.cfg.timeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	if c.cfg.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", addr, err)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, c.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer client.Close()
+
+	if c.cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server %s does not offer STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if c.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.cfg.Username, c.cfg.password(), c.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := client.Mail(address(c.cfg.From)); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, to := range c.cfg.To {
+		if err := client.Rcpt(address(to)); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(c.message(title, text, n)); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return client.Quit()
+}
+
+// tlsConfig liefert die TLS-Config für den Server, mit ca_file statt der System-Roots
+func (c *smtpChannel) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: c.cfg.Host}
+	if c.cfg.CAFile == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(c.cfg.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca_file: %w", err)
+	}
+	cfg.RootCAs = x509.NewCertPool()
+	if !cfg.RootCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("ca_file %s contains no PEM certificates", c.cfg.CAFile)
+	}
+	return cfg, nil
+}
+
+// message baut die E-Mail (UTF-8, quoted-printable)
+func (c *smtpChannel) message(title, text string, n Notification) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+
+	header("From", formatAddress(c.cfg.From))
+	to := make([]string, len(c.cfg.To))
+	for i, addr := range c.cfg.To {
+		to[i] = formatAddress(addr)
+	}
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", title))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(address(c.cfg.From)))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	header("X-Zertifikat-Waechter-Event", n.Event)
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	qp.Close()
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// address liefert die reine Adresse aus "Name <adresse>" (geprüft in validate)
+func address(s string) string {
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.Address
+	}
+	return s
+}
+
+// formatAddress kodiert Anzeigenamen mit Umlauten für den Header
+func formatAddress(s string) string {
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.String()
+	}
+	return s
+}
+
+// messageID erzeugt eine Message-ID in der Domain des Absenders
+func messageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 {
+		domain = strings.Trim(from[i+1:], "> ")
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
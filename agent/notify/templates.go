@@ -0,0 +1,325 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/rotation"
+)
+
+// Notification ist eine Meldung, wie sie in die Vorlagen eingeht
+type Notification struct {
+	Event    string    `json:"event"`
+	Severity string    `json:"severity"` // info, warning, error, critical
+	Rule     string    `json:"rule"`
+	Agent    string    `json:"agent"`
+	Time     time.Time `json:"time"`
+
+	Host        string     `json:"host"`
+	Port        int        `json:"port"`
+	SNI         string     `json:"sni,omitempty"`
+	Name        string     `json:"name,omitempty"` // CN bzw. erster SAN
+	Issuer      string     `json:"issuer,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+	DaysLeft    int        `json:"days_left"`
+	Threshold   int        `json:"threshold,omitempty"` // expiring: erreichte Schwelle
+
+	Reasons    []string `json:"reasons,omitempty"`    // validation_failed bzw. verdächtiger Wechsel
+	Changes    []string `json:"changes,omitempty"`    // rotation: geänderte Felder
+	Suspicious bool     `json:"suspicious,omitempty"` // rotation
+	OldFinger  string   `json:"old_fingerprint,omitempty"`
+	ErrorClass string   `json:"error_class,omitempty"` // unreachable
+}
+
+// Endpoint liefert host:port
+func (n Notification) Endpoint() string {
+	return fmt.Sprintf("%s:%d", n.Host, n.Port)
+}
+
+// Emoji liefert das Symbol zur Dringlichkeit (wie bei den Cloud-Alerts)
+func (n Notification) Emoji() string {
+	switch n.Severity {
+	case "critical":
+		return "🔴"
+	case "error":
+		return "🟠"
+	case "warning":
+		return "🟡"
+	}
+	return "🔵"
+}
+
+// TicketData geht in die Vorlagen und Feld-Zuordnungen der Ticket-Kanäle ein
+type TicketData struct {
+	Notification
+	Endpoints []string // alle Fundorte des Zertifikats (host:port)
+	Ticket    string   // Ticket-Nummer (leer beim Anlegen)
+}
+
+// templateSet sind Titel und Text einer Meldung
+type templateSet struct {
+	title, text string
+}
+
+// builtin sind die eingebauten Vorlagen pro Sprache und Ereignis
+var builtin = map[string]map[string]templateSet{
+	LanguageGerman: {
+		EventExpiring: {
+			title: `{{.Emoji}} {{if lt .DaysLeft 0}}Zertifikat abgelaufen{{else}}Zertifikat läuft in {{.DaysLeft}} Tagen ab{{end}}: {{.Name}} ({{.Endpoint}})`,
+			text: `{{if lt .DaysLeft 0}}Das Zertifikat ist seit {{neg .DaysLeft}} Tagen abgelaufen.{{else}}Das Zertifikat läuft in {{.DaysLeft}} Tagen ab.{{end}}
+
+Endpoint:    {{.Endpoint}}
+Zertifikat:  {{.Name}}
+Aussteller:  {{.Issuer}}
+Gültig bis:  {{date .NotAfter}}
+Fingerprint: {{.Fingerprint}}
+Agent:       {{.Agent}}`,
+		},
+		EventValidationFailed: {
+			title: `{{.Emoji}} Zertifikatsprüfung fehlgeschlagen: {{.Endpoint}}`,
+			text: `Das Zertifikat auf {{.Endpoint}} ist nicht gültig: {{reasons .Reasons}}.
+
+Zertifikat:  {{.Name}}
+Aussteller:  {{.Issuer}}
+Gültig bis:  {{date .NotAfter}}
+Fingerprint: {{.Fingerprint}}
+Agent:       {{.Agent}}`,
+		},
+		EventRotation: {
+			title: `{{.Emoji}} {{if .Suspicious}}Verdächtiger Zertifikatswechsel{{else}}Zertifikat gewechselt{{end}}: {{.Endpoint}}`,
+			text: `Auf {{.Endpoint}} wird ein neues Zertifikat ausgeliefert.{{if .Suspicious}}
+Verdächtig: {{reasons .Reasons}}{{end}}
+
+Geändert:          {{join .Changes ", "}}
+Alter Fingerprint: {{.OldFinger}}
+Neuer Fingerprint: {{.Fingerprint}}
+Agent:             {{.Agent}}`,
+		},
+		EventUnreachable: {
+			title: `{{.Emoji}} Endpoint nicht erreichbar: {{.Endpoint}}`,
+			text: `Der Endpoint {{.Endpoint}} ist wiederholt nicht erreichbar (Fehler: {{.ErrorClass}}).
+
+Agent: {{.Agent}}`,
+		},
+	},
+	LanguageEnglish: {
+		EventExpiring: {
+			title: `{{.Emoji}} {{if lt .DaysLeft 0}}Certificate expired{{else}}Certificate expires in {{.DaysLeft}} days{{end}}: {{.Name}} ({{.Endpoint}})`,
+			text: `{{if lt .DaysLeft 0}}The certificate expired {{neg .DaysLeft}} days ago.{{else}}The certificate expires in {{.DaysLeft}} days.{{end}}
+
+Endpoint:    {{.Endpoint}}
+Certificate: {{.Name}}
+Issuer:      {{.Issuer}}
+Valid until: {{date .NotAfter}}
+Fingerprint: {{.Fingerprint}}
+Agent:       {{.Agent}}`,
+		},
+		EventValidationFailed: {
+			title: `{{.Emoji}} Certificate validation failed: {{.Endpoint}}`,
+			text: `The certificate on {{.Endpoint}} is not valid: {{reasons .Reasons}}.
+
+Certificate: {{.Name}}
+Issuer:      {{.Issuer}}
+Valid until: {{date .NotAfter}}
+Fingerprint: {{.Fingerprint}}
+Agent:       {{.Agent}}`,
+		},
+		EventRotation: {
+			title: `{{.Emoji}} {{if .Suspicious}}Suspicious certificate change{{else}}Certificate changed{{end}}: {{.Endpoint}}`,
+			text: `{{.Endpoint}} now serves a new certificate.{{if .Suspicious}}
+Suspicious: {{reasons .Reasons}}{{end}}
+
+Changed:         {{join .Changes ", "}}
+Old fingerprint: {{.OldFinger}}
+New fingerprint: {{.Fingerprint}}
+Agent:           {{.Agent}}`,
+		},
+		EventUnreachable: {
+			title: `{{.Emoji}} Endpoint unreachable: {{.Endpoint}}`,
+			text: `The endpoint {{.Endpoint}} is repeatedly unreachable (error: {{.ErrorClass}}).
+
+Agent: {{.Agent}}`,
+		},
+	},
+}
+
+// ticketTemplate ist der Eintrag der eingebauten Ticket-Vorlagen
+const ticketTemplate = "ticket"
+
+// ticketBuiltin sind die eingebauten Vorlagen für Erneuerungs-Tickets (ein Ticket pro Zertifikat)
+var ticketBuiltin = map[string]templateSet{
+	LanguageGerman: {
+		title: `Zertifikat erneuern: {{.Name}} (gültig bis {{day .NotAfter}})`,
+		text: `{{if lt .DaysLeft 0}}Das Zertifikat {{.Name}} ist seit {{neg .DaysLeft}} Tagen abgelaufen{{else}}Das Zertifikat {{.Name}} läuft in {{.DaysLeft}} Tagen ab{{end}} und muss erneuert werden.
+
+Aussteller:  {{.Issuer}}
+Gültig bis:  {{date .NotAfter}}
+Fingerprint: {{.Fingerprint}}
+
+Fundorte ({{len .Endpoints}}):
+{{range .Endpoints}}- {{.}}
+{{end}}
+Das Ticket wird an jeder Ablauf-Schwelle aktualisiert und geschlossen, sobald auf allen Fundorten ein neues Zertifikat zu sehen ist.
+Agent: {{.Agent}}`,
+	},
+	LanguageEnglish: {
+		title: `Renew certificate: {{.Name}} (valid until {{day .NotAfter}})`,
+		text: `{{if lt .DaysLeft 0}}The certificate {{.Name}} expired {{neg .DaysLeft}} days ago{{else}}The certificate {{.Name}} expires in {{.DaysLeft}} days{{end}} and must be renewed.
+
+Issuer:      {{.Issuer}}
+Valid until: {{date .NotAfter}}
+Fingerprint: {{.Fingerprint}}
+
+Endpoints ({{len .Endpoints}}):
+{{range .Endpoints}}- {{.}}
+{{end}}
+This ticket is updated at every expiry threshold and closed once a new certificate is seen on all endpoints.
+Agent: {{.Agent}}`,
+	},
+}
+
+// reasonTexts übersetzt Prüf- und Wechselgründe
+var reasonTexts = map[string]map[string]string{
+	LanguageGerman: {
+		"expired":                         "abgelaufen",
+		"not_yet_valid":                   "noch nicht gültig",
+		"self_signed":                     "selbstsigniert",
+		"untrusted":                       "nicht vertrauenswürdig",
+		"hostname_mismatch":               "Hostname nicht im Zertifikat",
+		"revoked":                         "vom Aussteller gesperrt",
+		rotation.ReasonPublicToSelfSigned: "öffentliche CA durch selbstsigniertes Zertifikat ersetzt",
+		rotation.ReasonTrustLost:          "nicht mehr vertrauenswürdig",
+		rotation.ReasonKeySizeDecreased:   "kleinerer Schlüssel",
+		rotation.ReasonValidityRollback:   "früheres Ablaufdatum",
+		rotation.ReasonHostnameUncovered:  "Hostname nicht mehr abgedeckt",
+		rotation.ReasonIssuerChangedEarly: "Aussteller vor der Erneuerung gewechselt",
+		rotation.ReasonKeyChangedEarly:    "Schlüsseltyp vor der Erneuerung gewechselt",
+	},
+	LanguageEnglish: {
+		"expired":                         "expired",
+		"not_yet_valid":                   "not yet valid",
+		"self_signed":                     "self-signed",
+		"untrusted":                       "untrusted",
+		"hostname_mismatch":               "hostname not in certificate",
+		"revoked":                         "revoked by the issuer",
+		rotation.ReasonPublicToSelfSigned: "public CA replaced by self-signed certificate",
+		rotation.ReasonTrustLost:          "no longer trusted",
+		rotation.ReasonKeySizeDecreased:   "smaller key",
+		rotation.ReasonValidityRollback:   "earlier expiry date",
+		rotation.ReasonHostnameUncovered:  "hostname no longer covered",
+		rotation.ReasonIssuerChangedEarly: "issuer changed before renewal was due",
+		rotation.ReasonKeyChangedEarly:    "key type changed before renewal was due",
+	},
+}
+
+// funcs liefert die Template-Funktionen für eine Sprache
+func funcs(language string) template.FuncMap {
+	return template.FuncMap{
+		"join": strings.Join,
+		"neg":  func(i int) int { return -i },
+		"date": func(t *time.Time) string {
+			if t == nil {
+				return "-"
+			}
+			return t.UTC().Format("2006-01-02 15:04 MST")
+		},
+		"day": func(t *time.Time) string {
+			if t == nil {
+				return "-"
+			}
+			return t.UTC().Format("2006-01-02")
+		},
+		"reasons": func(reasons []string) string {
+			texts := make([]string, 0, len(reasons))
+			for _, r := range reasons {
+				if text, ok := reasonTexts[language][r]; ok {
+					texts = append(texts, text)
+				} else {
+					texts = append(texts, r)
+				}
+			}
+			return strings.Join(texts, ", ")
+		},
+	}
+}
+
+// templates übersetzt die eigenen Vorlagen eines Kanals (nil = eingebaute)
+func (ch *ChannelConfig) templates() (*template.Template, *template.Template, error) {
+	parse := func(name, text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		tmpl, err := template.New(name).Funcs(funcs(ch.Language)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return tmpl, nil
+	}
+	title, err := parse("title_template", ch.TitleTemplate)
+	if err != nil {
+		return nil, nil, err
+	}
+	text, err := parse("template", ch.Template)
+	return title, text, err
+}
+
+// renderer erzeugt Titel und Text einer Meldung für einen Kanal
+type renderer struct {
+	language    string
+	title, text *template.Template // eigene Vorlagen, nil = eingebaute
+	builtin     map[string]*[2]*template.Template
+}
+
+func newRenderer(ch *ChannelConfig) (*renderer, error) {
+	title, text, err := ch.templates()
+	if err != nil {
+		return nil, err
+	}
+	r := &renderer{language: ch.Language, title: title, text: text, builtin: make(map[string]*[2]*template.Template)}
+	sets := map[string]templateSet{ticketTemplate: ticketBuiltin[ch.Language]}
+	for event, set := range builtin[ch.Language] {
+		sets[event] = set
+	}
+	for name, set := range sets {
+		r.builtin[name] = &[2]*template.Template{
+			template.Must(template.New(name + "_title").Funcs(funcs(ch.Language)).Parse(set.title)),
+			template.Must(template.New(name).Funcs(funcs(ch.Language)).Parse(set.text)),
+		}
+	}
+	return r, nil
+}
+
+// render liefert Titel und Text einer Meldung
+func (r *renderer) render(n Notification) (string, string, error) {
+	return r.execute(n.Event, n)
+}
+
+// renderTicket liefert Zusammenfassung und Beschreibung eines Tickets
+func (r *renderer) renderTicket(data TicketData) (string, string, error) {
+	return r.execute(ticketTemplate, data)
+}
+
+// execute führt die eigenen bzw. eingebauten Vorlagen name aus
+func (r *renderer) execute(name string, data interface{}) (string, string, error) {
+	def := r.builtin[name]
+	title, text := def[0], def[1]
+	if r.title != nil {
+		title = r.title
+	}
+	if r.text != nil {
+		text = r.text
+	}
+
+	var t, b bytes.Buffer
+	if err := title.Execute(&t, data); err != nil {
+		return "", "", fmt.Errorf("render title: %w", err)
+	}
+	if err := text.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("render text: %w", err)
+	}
+	return strings.TrimSpace(t.String()), strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,370 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// ticketTypes sind Kanäle, die pro Zertifikat ein Erneuerungs-Ticket führen
+var ticketTypes = map[string]bool{"jira": true, "servicenow": true}
+
+// IsTicketType meldet, ob ein Kanal-Typ Tickets statt Meldungen anlegt
+func IsTicketType(typ string) bool {
+	return ticketTypes[typ]
+}
+
+// ticketTexts sind die Kommentare an Tickets
+var ticketTexts = map[string]map[string]string{
+	LanguageGerman: {
+		"endpoint":  "Neuer Fundort: %s",
+		"expiring":  "Das Zertifikat läuft in %d Tagen ab.",
+		"expired":   "Das Zertifikat ist seit %d Tagen abgelaufen.",
+		"closed":    "Auf allen Fundorten ist ein neues Zertifikat zu sehen (Fingerprint %s) - das Ticket wird geschlossen.",
+		"retired":   "Fundort ausgemustert (lange nicht erreichbar): %s",
+		"abandoned": "Der letzte Fundort %s wurde ausgemustert (lange nicht erreichbar) - das Ticket wird geschlossen.",
+	},
+	LanguageEnglish: {
+		"endpoint":  "New endpoint: %s",
+		"expiring":  "The certificate expires in %d days.",
+		"expired":   "The certificate expired %d days ago.",
+		"closed":    "A new certificate is served on all endpoints (fingerprint %s) - closing this ticket.",
+		"retired":   "Endpoint retired (unreachable for a long time): %s",
+		"abandoned": "The last endpoint %s was retired (unreachable for a long time) - closing this ticket.",
+	},
+}
+
+// defaultCloseFields schließen ein ServiceNow-Incident (state 6 = Resolved)
+var defaultCloseFields = map[string]interface{}{"state": "6", "close_code": "Solved (Permanently)"}
+
+// TicketRef identifiziert ein Ticket
+type TicketRef struct {
+	ID     string `json:"id"`     // jira: Issue-Key, servicenow: sys_id
+	Number string `json:"number"` // jira: Issue-Key, servicenow: Nummer (z.B. CHG0030001)
+}
+
+// Ticket ist der Inhalt eines Erneuerungs-Tickets
+type Ticket struct {
+	Summary     string
+	Description string
+	Data        TicketData
+	Fields      map[string]interface{} // gerenderte Feld-Zuordnung (fields)
+}
+
+// TicketChannel legt Tickets an, aktualisiert und schließt sie
+type TicketChannel interface {
+	Create(ctx context.Context, t Ticket) (TicketRef, error)
+	Update(ctx context.Context, ref TicketRef, t Ticket, comment string) error
+	Close(ctx context.Context, ref TicketRef, t Ticket, comment string) error
+}
+
+func (ch *ChannelConfig) validateTicket() error {
+	if ch.URL != "" && ch.URLEnv != "" {
+		return fmt.Errorf("use either url or url_env")
+	}
+	u := ch.webhookURL()
+	if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return fmt.Errorf("url (or url_env) must be the http(s) base URL of the instance")
+	}
+	if ch.Password != "" && ch.PasswordEnv != "" {
+		return fmt.Errorf("use either password or password_env")
+	}
+	if ch.APIKey != "" && ch.APIKeyEnv != "" {
+		return fmt.Errorf("use either api_key or api_key_env")
+	}
+	for severity := range ch.Priorities {
+		if !severities[severity] {
+			return fmt.Errorf("priorities: unknown severity %q (critical, error, warning, info)", severity)
+		}
+	}
+
+	switch ch.Type {
+	case "jira":
+		if ch.Project == "" {
+			return fmt.Errorf("project is required for jira")
+		}
+		if (ch.Username == "" || ch.password() == "") && ch.apiKey() == "" {
+			return fmt.Errorf("username and password (API token) or api_key (personal access token) are required for jira")
+		}
+		if ch.IssueType == "" {
+			ch.IssueType = "Task"
+		}
+		if ch.CloseTransition == "" {
+			ch.CloseTransition = "Done"
+		}
+		if len(ch.CloseFields) > 0 {
+			return fmt.Errorf("close_fields is only supported for servicenow (jira: close_transition)")
+		}
+	case "servicenow":
+		if ch.Username == "" || ch.password() == "" {
+			return fmt.Errorf("username and password are required for servicenow")
+		}
+		if ch.Table == "" {
+			ch.Table = "incident"
+		}
+		if ch.CloseFields == nil {
+			ch.CloseFields = defaultCloseFields
+		}
+	}
+
+	if err := walkFields(ch.Fields, func(s string) (interface{}, error) { return ch.fieldTemplate(s) }); err != nil {
+		return fmt.Errorf("fields: %w", err)
+	}
+	if err := walkFields(ch.CloseFields, func(s string) (interface{}, error) { return ch.fieldTemplate(s) }); err != nil {
+		return fmt.Errorf("close_fields: %w", err)
+	}
+	return nil
+}
+
+// fieldTemplate übersetzt einen Feldwert als Go-Template
+func (ch *ChannelConfig) fieldTemplate(s string) (*template.Template, error) {
+	tmpl, err := template.New("field").Funcs(funcs(ch.Language)).Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("parse %q: %w", s, err)
+	}
+	return tmpl, nil
+}
+
+// renderFields liefert eine Kopie der Feld-Zuordnung mit gerenderten Strings
+func (ch *ChannelConfig) renderFields(fields map[string]interface{}, data TicketData) (map[string]interface{}, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	rendered, err := mapFields(fields, func(s string) (interface{}, error) {
+		tmpl, err := ch.fieldTemplate(s)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rendered.(map[string]interface{}), nil
+}
+
+// walkFields ruft fn für alle Strings in Maps und Listen auf
+func walkFields(fields map[string]interface{}, fn func(string) (interface{}, error)) error {
+	_, err := mapFields(fields, fn)
+	return err
+}
+
+// mapFields ersetzt alle Strings in Maps und Listen durch das Ergebnis von fn
+func mapFields(value interface{}, fn func(string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			mapped, err := mapFields(item, fn)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			out[k] = mapped
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			mapped, err := mapFields(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = mapped
+		}
+		return out, nil
+	}
+	return value, nil
+}
+
+// newTicketChannel erstellt den Kanal zu einem geprüften Eintrag
+func newTicketChannel(ch *ChannelConfig) TicketChannel {
+	client := &http.Client{Timeout: ch.timeout()}
+	switch ch.Type {
+	case "jira":
+		return &jiraChannel{cfg: ch, client: client}
+	case "servicenow":
+		return &serviceNowChannel{cfg: ch, client: client}
+	}
+	return nil
+}
+
+// basicAuth liefert den Authorization-Header für Benutzer und Passwort
+func basicAuth(username, password string) http.Header {
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return http.Header{"Authorization": {"Basic " + auth}}
+}
+
+// jiraChannel führt Tickets über die Jira REST API v2 (Cloud und Data Center)
+type jiraChannel struct {
+	cfg    *ChannelConfig
+	client *http.Client
+}
+
+func (c *jiraChannel) header() http.Header {
+	if c.cfg.Username != "" {
+		return basicAuth(c.cfg.Username, c.cfg.password())
+	}
+	return http.Header{"Authorization": {"Bearer " + c.cfg.apiKey()}}
+}
+
+func (c *jiraChannel) issueURL(parts ...string) string {
+	return c.cfg.endpoint("") + "/rest/api/2/issue" + strings.Join(parts, "")
+}
+
+// fields liefert Zusammenfassung, Beschreibung, Priorität und die Feld-Zuordnung
+func (c *jiraChannel) fields(t Ticket) map[string]interface{} {
+	fields := map[string]interface{}{
+		"summary":     truncate(t.Summary, 255),
+		"description": t.Description,
+	}
+	if p := c.cfg.Priorities[t.Data.Severity]; p != "" {
+		fields["priority"] = map[string]string{"name": p}
+	}
+	for k, v := range t.Fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+func (c *jiraChannel) Create(ctx context.Context, t Ticket) (TicketRef, error) {
+	fields := c.fields(t)
+	fields["project"] = map[string]string{"key": c.cfg.Project}
+	fields["issuetype"] = map[string]string{"name": c.cfg.IssueType}
+
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := doJSON(ctx, c.client, "POST", c.issueURL(), c.header(), map[string]interface{}{"fields": fields}, &created); err != nil {
+		return TicketRef{}, err
+	}
+	return TicketRef{ID: created.Key, Number: created.Key}, nil
+}
+
+func (c *jiraChannel) comment(ctx context.Context, ref TicketRef, comment string) error {
+	if comment == "" {
+		return nil
+	}
+	return postJSON(ctx, c.client, c.issueURL("/", url.PathEscape(ref.ID), "/comment"), c.header(), map[string]string{"body": comment})
+}
+
+func (c *jiraChannel) Update(ctx context.Context, ref TicketRef, t Ticket, comment string) error {
+	if err := doJSON(ctx, c.client, "PUT", c.issueURL("/", url.PathEscape(ref.ID)), c.header(), map[string]interface{}{"fields": c.fields(t)}, nil); err != nil {
+		return err
+	}
+	return c.comment(ctx, ref, comment)
+}
+
+func (c *jiraChannel) Close(ctx context.Context, ref TicketRef, t Ticket, comment string) error {
+	transitionsURL := c.issueURL("/", url.PathEscape(ref.ID), "/transitions")
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := doJSON(ctx, c.client, "GET", transitionsURL, c.header(), nil, &available); err != nil {
+		return err
+	}
+
+	// Übergang nach Name oder Ziel-Status ("Done", "Erledigt", "Closed")
+	id := ""
+	var names []string
+	for _, tr := range available.Transitions {
+		names = append(names, tr.Name)
+		if strings.EqualFold(tr.Name, c.cfg.CloseTransition) || strings.EqualFold(tr.To.Name, c.cfg.CloseTransition) {
+			id = tr.ID
+			break
+		}
+	}
+	if id == "" {
+		return fmt.Errorf("jira transition %q not available for %s (available: %s)", c.cfg.CloseTransition, ref.Number, strings.Join(names, ", "))
+	}
+
+	if err := c.comment(ctx, ref, comment); err != nil {
+		return err
+	}
+	return postJSON(ctx, c.client, transitionsURL, c.header(), map[string]interface{}{"transition": map[string]string{"id": id}})
+}
+
+// serviceNowChannel führt Tickets über die ServiceNow Table API (z.B. incident, change_request)
+type serviceNowChannel struct {
+	cfg    *ChannelConfig
+	client *http.Client
+}
+
+func (c *serviceNowChannel) tableURL(sysID string) string {
+	u := c.cfg.endpoint("") + "/api/now/table/" + url.PathEscape(c.cfg.Table)
+	if sysID != "" {
+		u += "/" + url.PathEscape(sysID)
+	}
+	return u
+}
+
+// record liefert Kurzbeschreibung, Beschreibung, Dringlichkeit und die Feld-Zuordnung
+func (c *serviceNowChannel) record(t Ticket) map[string]interface{} {
+	record := map[string]interface{}{
+		"short_description": truncate(t.Summary, 160),
+		"description":       t.Description,
+	}
+	if p := c.cfg.Priorities[t.Data.Severity]; p != "" {
+		record["urgency"] = p
+	}
+	for k, v := range t.Fields {
+		record[k] = v
+	}
+	return record
+}
+
+func (c *serviceNowChannel) Create(ctx context.Context, t Ticket) (TicketRef, error) {
+	var created struct {
+		Result struct {
+			SysID  string `json:"sys_id"`
+			Number string `json:"number"`
+		} `json:"result"`
+	}
+	if err := doJSON(ctx, c.client, "POST", c.tableURL(""), basicAuth(c.cfg.Username, c.cfg.password()), c.record(t), &created); err != nil {
+		return TicketRef{}, err
+	}
+	if created.Result.SysID == "" {
+		return TicketRef{}, fmt.Errorf("servicenow response without sys_id")
+	}
+	return TicketRef{ID: created.Result.SysID, Number: created.Result.Number}, nil
+}
+
+func (c *serviceNowChannel) Update(ctx context.Context, ref TicketRef, t Ticket, comment string) error {
+	record := c.record(t)
+	if comment != "" {
+		record["work_notes"] = comment
+	}
+	return doJSON(ctx, c.client, "PATCH", c.tableURL(ref.ID), basicAuth(c.cfg.Username, c.cfg.password()), record, nil)
+}
+
+func (c *serviceNowChannel) Close(ctx context.Context, ref TicketRef, t Ticket, comment string) error {
+	record, err := c.cfg.renderFields(c.cfg.CloseFields, t.Data)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		record = make(map[string]interface{})
+	}
+	if _, ok := record["close_notes"]; !ok {
+		record["close_notes"] = comment
+	}
+	record["work_notes"] = comment
+	return doJSON(ctx, c.client, "PATCH", c.tableURL(ref.ID), basicAuth(c.cfg.Username, c.cfg.password()), record, nil)
+}
@@ -0,0 +1,246 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// retiredAsset erstellt den Record eines Assets, das nach retired wechselt
+func retiredAsset(host string) sink.Record {
+	return sink.Record{ID: "retired-" + host, Kind: sink.KindEvent, Type: sink.TypeAssetTransition, Time: time.Now(),
+		Data: map[string]interface{}{"host": host, "port": 443, "from": lifecycle.StateUnreachable, "to": lifecycle.StateRetired}}
+}
+
+func ticketConfig(stateFile string, ch ChannelConfig) *Config {
+	return &Config{
+		StateFile: stateFile,
+		Channels:  []ChannelConfig{ch},
+		Rules:     []Rule{{Name: "erneuerung", Events: []string{EventExpiring}, Days: []int{30, 14, 7}, Channels: []string{ch.Name}}},
+	}
+}
+
+// jiraAPI bildet die Jira REST API v2 nach: Issue PKI-1, Übergang "Erledigt" nach Done
+func jiraAPI(t *testing.T) *apiServer {
+	return newAPIServer(t, func(req apiRequest) (int, interface{}) {
+		switch {
+		case req.Method == "POST" && req.Path == "/rest/api/2/issue":
+			return http.StatusCreated, map[string]string{"id": "10001", "key": "PKI-1"}
+		case req.Method == "GET" && req.Path == "/rest/api/2/issue/PKI-1/transitions":
+			return http.StatusOK, map[string]interface{}{"transitions": []map[string]interface{}{
+				{"id": "21", "name": "In Arbeit", "to": map[string]string{"name": "In Progress"}},
+				{"id": "31", "name": "Erledigt", "to": map[string]string{"name": "Done"}},
+			}}
+		case req.Method == "POST" && req.Path == "/rest/api/2/issue/PKI-1/comment":
+			return http.StatusCreated, map[string]string{"id": "1"}
+		case req.Path == "/rest/api/2/issue/PKI-1" || req.Path == "/rest/api/2/issue/PKI-1/transitions":
+			return http.StatusNoContent, nil
+		}
+		t.Errorf("unexpected jira request %s %s", req.Method, req.Path)
+		return http.StatusNotFound, nil
+	})
+}
+
+// TestJiraTicket: Anlegen, Aktualisieren bei neuem Fundort und neuer Schwelle, Schließen
+// über den Übergang - über einen Neustart hinweg
+func TestJiraTicket(t *testing.T) {
+	api := jiraAPI(t)
+	stateFile := filepath.Join(t.TempDir(), "notify-state.json")
+	cfg := func() *Config {
+		return ticketConfig(stateFile, ChannelConfig{Name: "jira", Type: "jira", URL: api.URL, Project: "PKI",
+			Username: "bot@example.com", Password: "token", Priorities: map[string]string{"warning": "Medium", "critical": "Highest"},
+			Fields: map[string]interface{}{"labels": []interface{}{"zertifikat", "{{.Host}}"}}})
+	}
+	n := newTestNotifier(t, cfg())
+	ctx := context.Background()
+
+	if err := n.Write(ctx, expiringEvent("a.example", "aa", 25)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	reqs := api.take()
+	if len(reqs) != 1 || reqs[0].Method != "POST" || !strings.HasPrefix(reqs[0].Header.Get("Authorization"), "Basic ") {
+		t.Fatalf("create requests = %+v", reqs)
+	}
+	if body(t, reqs[0], "fields.project.key") != "PKI" || body(t, reqs[0], "fields.issuetype.name") != "Task" ||
+		body(t, reqs[0], "fields.priority.name") != "Medium" || body(t, reqs[0], "fields.labels.1") != "a.example" ||
+		!strings.Contains(body(t, reqs[0], "fields.summary").(string), "a.example") {
+		t.Errorf("create body = %v", reqs[0].Body)
+	}
+
+	// Weiterer Fundort: Felder neu setzen und kommentieren
+	if err := n.Write(ctx, expiringEvent("b.example", "aa", 25)); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	reqs = api.take()
+	if len(reqs) != 2 || reqs[0].Method != "PUT" || reqs[1].Path != "/rest/api/2/issue/PKI-1/comment" ||
+		body(t, reqs[1], "body") != "Neuer Fundort: b.example:443" {
+		t.Errorf("update requests = %+v", reqs)
+	}
+	if err := n.Write(ctx, expiringEvent("b.example", "aa", 24)); err != sink.ErrSkipped {
+		t.Errorf("unchanged ticket: got %v, want ErrSkipped", err)
+	}
+	if reqs := api.take(); len(reqs) != 0 {
+		t.Errorf("unchanged ticket sent %+v", reqs)
+	}
+
+	// Nächste Schwelle
+	n.Write(ctx, expiringEvent("a.example", "aa", 5))
+	reqs = api.take()
+	if len(reqs) != 2 || body(t, reqs[0], "fields.priority.name") != "Highest" || body(t, reqs[1], "body") != "Das Zertifikat läuft in 5 Tagen ab." {
+		t.Errorf("threshold requests = %+v", reqs)
+	}
+
+	// Neustart: das Ticket kommt aus dem state_file
+	restarted := newTestNotifier(t, cfg())
+	tickets := restarted.Tickets()
+	if len(tickets) != 1 || tickets[0].Ticket != "PKI-1" || tickets[0].Threshold != 7 || len(tickets[0].Endpoints) != 2 {
+		t.Fatalf("Tickets() after restart = %+v", tickets)
+	}
+
+	// Erst wenn beide Fundorte ein neues Zertifikat ausliefern, wird geschlossen
+	if err := restarted.Write(ctx, observedCert("a.example", 443, "bb")); err != sink.ErrSkipped {
+		t.Errorf("first endpoint renewed: got %v, want ErrSkipped", err)
+	}
+	if reqs := api.take(); len(reqs) != 0 {
+		t.Errorf("ticket touched while still served: %+v", reqs)
+	}
+	if err := restarted.Write(ctx, observedCert("b.example", 443, "bb")); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	reqs = api.take()
+	if len(reqs) != 3 || reqs[0].Method != "GET" || reqs[1].Path != "/rest/api/2/issue/PKI-1/comment" ||
+		!strings.Contains(body(t, reqs[1], "body").(string), "Fingerprint bb") ||
+		reqs[2].Path != "/rest/api/2/issue/PKI-1/transitions" || body(t, reqs[2], "transition.id") != "31" {
+		t.Errorf("close requests = %+v", reqs)
+	}
+	if got := restarted.Tickets(); len(got) != 0 {
+		t.Errorf("Tickets() after close = %+v", got)
+	}
+	if got := stats(restarted, "jira"); got.Resolved != 1 || got.Open != 0 {
+		t.Errorf("stats = %+v", got)
+	}
+}
+
+// serviceNowAPI bildet die Table API nach: Datensatz INC0010001 mit sys_id abc123
+func serviceNowAPI(t *testing.T) *apiServer {
+	return newAPIServer(t, func(req apiRequest) (int, interface{}) {
+		switch {
+		case req.Method == "POST" && req.Path == "/api/now/table/incident":
+			return http.StatusCreated, map[string]interface{}{"result": map[string]string{"sys_id": "abc123", "number": "INC0010001"}}
+		case req.Method == "PATCH" && req.Path == "/api/now/table/incident/abc123":
+			return http.StatusOK, map[string]interface{}{"result": map[string]string{"sys_id": "abc123"}}
+		}
+		t.Errorf("unexpected servicenow request %s %s", req.Method, req.Path)
+		return http.StatusNotFound, nil
+	})
+}
+
+// TestServiceNowTicket: Anlegen, Kommentar bei ausgemustertem Fundort und Schließen mit
+// close_fields, wenn der letzte Fundort ausgemustert wird - über einen Neustart hinweg
+func TestServiceNowTicket(t *testing.T) {
+	api := serviceNowAPI(t)
+	stateFile := filepath.Join(t.TempDir(), "notify-state.json")
+	cfg := func() *Config {
+		return ticketConfig(stateFile, ChannelConfig{Name: "snow", Type: "servicenow", URL: api.URL + "/", Username: "pki", Password: "secret",
+			Priorities: map[string]string{"warning": "2"}, Fields: map[string]interface{}{"assignment_group": "PKI", "cmdb_ci": "{{.Host}}"}})
+	}
+	n := newTestNotifier(t, cfg())
+	ctx := context.Background()
+
+	n.Write(ctx, expiringEvent("a.example", "aa", 25))
+	n.Write(ctx, expiringEvent("b.example", "aa", 25))
+	reqs := api.take()
+	if len(reqs) != 2 || reqs[0].Method != "POST" || reqs[1].Method != "PATCH" {
+		t.Fatalf("create/update requests = %+v", reqs)
+	}
+	if body(t, reqs[0], "urgency") != "2" || body(t, reqs[0], "assignment_group") != "PKI" || body(t, reqs[0], "cmdb_ci") != "a.example" ||
+		!strings.Contains(body(t, reqs[0], "short_description").(string), "a.example") {
+		t.Errorf("create body = %v", reqs[0].Body)
+	}
+	if body(t, reqs[1], "work_notes") != "Neuer Fundort: b.example:443" {
+		t.Errorf("update body = %v", reqs[1].Body)
+	}
+
+	// Ausgemusterter Fundort: Kommentar, das Ticket bleibt für a.example offen
+	if err := n.Write(ctx, retiredAsset("b.example")); err != nil {
+		t.Fatalf("retire b: %v", err)
+	}
+	reqs = api.take()
+	if len(reqs) != 1 || reqs[0].Method != "PATCH" || body(t, reqs[0], "work_notes") != "Fundort ausgemustert (lange nicht erreichbar): b.example:443" ||
+		strings.Contains(body(t, reqs[0], "description").(string), "b.example:443") {
+		t.Errorf("retire requests = %+v", reqs)
+	}
+	if tickets := n.Tickets(); len(tickets) != 1 || len(tickets[0].Endpoints) != 1 || !tickets[0].Endpoints["a.example:443"] {
+		t.Fatalf("Tickets() after retire = %+v", tickets)
+	}
+
+	// Neustart, dann wird auch der letzte Fundort ausgemustert
+	restarted := newTestNotifier(t, cfg())
+	if err := restarted.Write(ctx, retiredAsset("a.example")); err != nil {
+		t.Fatalf("retire a: %v", err)
+	}
+	reqs = api.take()
+	if len(reqs) != 1 || reqs[0].Path != "/api/now/table/incident/abc123" || body(t, reqs[0], "state") != "6" ||
+		body(t, reqs[0], "close_code") != "Solved (Permanently)" || !strings.Contains(body(t, reqs[0], "close_notes").(string), "a.example:443") {
+		t.Errorf("close requests = %+v", reqs)
+	}
+	if got := restarted.Tickets(); len(got) != 0 {
+		t.Errorf("Tickets() after close = %+v", got)
+	}
+
+	// Ein weiterer Neustart findet kein offenes Ticket mehr
+	if got := newTestNotifier(t, cfg()).Tickets(); len(got) != 0 {
+		t.Errorf("closed ticket came back after restart: %+v", got)
+	}
+}
+
+// TestTicketRetireRetry: ein gescheiterter Kommentar lässt den Fundort im Ticket, die
+// Wiederholung des Records holt ihn nach
+func TestTicketRetireRetry(t *testing.T) {
+	failing := true
+	api := newAPIServer(t, func(req apiRequest) (int, interface{}) {
+		if req.Method == "POST" {
+			return http.StatusCreated, map[string]interface{}{"result": map[string]string{"sys_id": "abc123", "number": "INC0010001"}}
+		}
+		if failing && req.Body.(map[string]interface{})["work_notes"] != "Neuer Fundort: b.example:443" {
+			return http.StatusBadGateway, map[string]string{"error": "down"}
+		}
+		return http.StatusOK, map[string]interface{}{"result": map[string]string{}}
+	})
+	n := newTestNotifier(t, ticketConfig("", ChannelConfig{Name: "snow", Type: "servicenow", URL: api.URL, Username: "pki", Password: "secret"}))
+	ctx := context.Background()
+
+	n.Write(ctx, expiringEvent("a.example", "aa", 25))
+	n.Write(ctx, expiringEvent("b.example", "aa", 25))
+	api.take()
+
+	if err := n.Write(ctx, retiredAsset("b.example")); err == nil {
+		t.Fatal("failed update reported success")
+	}
+	if tickets := n.Tickets(); len(tickets[0].Endpoints) != 2 {
+		t.Fatalf("endpoint removed despite failed update: %+v", tickets)
+	}
+	failing = false
+	if err := n.Write(ctx, retiredAsset("b.example")); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if tickets := n.Tickets(); len(tickets[0].Endpoints) != 1 {
+		t.Errorf("Tickets() after retry = %+v", tickets)
+	}
+}
+
+func TestSinkConfigTicketTypes(t *testing.T) {
+	n := newTestNotifier(t, ticketConfig("", ChannelConfig{Name: "snow", Type: "servicenow", URL: "https://snow.example", Username: "pki", Password: "secret"}))
+	cfg := n.SinkConfig("notify")
+	for _, typ := range []string{sink.TypeCertificateExpiring, sink.TypeCertificateObserved, sink.TypeAssetTransition} {
+		if !cfg.Match(sink.Record{Kind: sink.KindEvent, Type: typ}) && !cfg.Match(sink.Record{Kind: sink.KindCertificate, Type: typ}) {
+			t.Errorf("notifier sink does not receive %s", typ)
+		}
+	}
+}
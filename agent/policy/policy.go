@@ -0,0 +1,219 @@
+package policy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+// Regeln einer Policy (IDs in SARIF/JUnit)
+const (
+	RuleReachable    = "reachable"
+	RuleDaysLeft     = "min-days-left"
+	RuleIssuer       = "allowed-issuer"
+	RuleKeySize      = "min-key-size"
+	RuleSignatureAlg = "allowed-signature-algorithm"
+	RuleTLSVersion   = "min-tls-version"
+	RuleTrusted      = "trusted-chain"
+	RuleFinding      = "finding" // Befund aus scanner.Assess, ID "finding/<code>"
+)
+
+// Policy sind die Anforderungen, die ein Endpoint erfüllen muss.
+// Leere Listen bzw. 0 bedeuten "keine Einschränkung".
+type Policy struct {
+	MinDaysLeft         int              `json:"min_days_left"`
+	AllowedIssuers      []string         `json:"allowed_issuers,omitempty"` // Glob auf Issuer-CN, z.B. "R1?" oder "DigiCert*"
+	MinRSABits          int              `json:"min_rsa_bits"`
+	MinECDSABits        int              `json:"min_ecdsa_bits"`
+	AllowedSignatureAlg []string         `json:"allowed_signature_algorithms,omitempty"` // z.B. "SHA256-RSA", "ECDSA-SHA384"
+	MinTLSVersion       string           `json:"min_tls_version,omitempty"`              // "1.2" oder "1.3"
+	RequireTrusted      bool             `json:"require_trusted"`
+	FailOn              scanner.Severity `json:"fail_on"` // Befunde ab diesem Schweregrad verletzen die Policy ("none" = aus)
+}
+
+// Default liefert die Policy ohne weitere Angaben
+func Default() Policy {
+	return Policy{
+		MinDaysLeft:    14,
+		MinRSABits:     2048,
+		MinECDSABits:   256,
+		MinTLSVersion:  "1.2",
+		RequireTrusted: true,
+		FailOn:         scanner.SeverityHigh,
+	}
+}
+
+// Load liest eine Policy-Datei (JSON); nicht angegebene Felder behalten die Defaults
+func Load(file string) (Policy, error) {
+	p := Default()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return p, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("%s: %w", file, err)
+	}
+	if severity, err := scanner.ParseSeverity(string(p.FailOn)); err == nil {
+		p.FailOn = severity
+	}
+	return p, p.Validate()
+}
+
+// Validate prüft die Policy auf unsinnige Werte
+func (p Policy) Validate() error {
+	if p.MinDaysLeft < 0 || p.MinRSABits < 0 || p.MinECDSABits < 0 {
+		return fmt.Errorf("min_days_left, min_rsa_bits and min_ecdsa_bits must not be negative")
+	}
+	if _, err := tlsVersion(p.MinTLSVersion); err != nil {
+		return err
+	}
+	if _, err := scanner.ParseSeverity(string(p.FailOn)); err != nil {
+		return fmt.Errorf("fail_on: %w", err)
+	}
+	for _, pattern := range p.AllowedIssuers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("allowed_issuers: invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Violation ist ein Verstoß gegen die Policy
+type Violation struct {
+	Rule     string           `json:"rule"`
+	Severity scanner.Severity `json:"severity"`
+	Message  string           `json:"message"`
+}
+
+// Evaluate prüft eine Inspektion gegen die Policy
+func (p Policy) Evaluate(insp *scanner.Inspection, now time.Time) []Violation {
+	var violations []Violation
+	add := func(rule string, severity scanner.Severity, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+	cert := insp.Certificate
+
+	if daysLeft := int(cert.NotAfter.Sub(now).Hours() / 24); daysLeft < p.MinDaysLeft {
+		severity := scanner.SeverityHigh
+		if now.After(cert.NotAfter) {
+			severity = scanner.SeverityCritical
+		}
+		add(RuleDaysLeft, severity, "certificate expires in %d days (%s), policy requires at least %d", daysLeft, cert.NotAfter.Format("2006-01-02"), p.MinDaysLeft)
+	}
+
+	if len(p.AllowedIssuers) > 0 && !matchAny(p.AllowedIssuers, cert.Issuer) {
+		add(RuleIssuer, scanner.SeverityHigh, "issuer %q is not in the allowed issuers", cert.Issuer)
+	}
+
+	switch cert.KeyAlgorithm {
+	case "RSA":
+		if p.MinRSABits > 0 && cert.KeySize < p.MinRSABits {
+			add(RuleKeySize, scanner.SeverityHigh, "RSA key has %d bits, policy requires at least %d", cert.KeySize, p.MinRSABits)
+		}
+	case "ECDSA":
+		if p.MinECDSABits > 0 && cert.KeySize < p.MinECDSABits {
+			add(RuleKeySize, scanner.SeverityHigh, "ECDSA key has %d bits, policy requires at least %d", cert.KeySize, p.MinECDSABits)
+		}
+	}
+
+	if len(p.AllowedSignatureAlg) > 0 && !containsFold(p.AllowedSignatureAlg, cert.SignatureAlg) {
+		add(RuleSignatureAlg, scanner.SeverityHigh, "signature algorithm %s is not allowed", cert.SignatureAlg)
+	}
+
+	if min, _ := tlsVersion(p.MinTLSVersion); min != 0 {
+		for _, proto := range insp.Protocols {
+			if v, ok := versionByName[proto.Version]; ok && proto.Supported && v < min {
+				add(RuleTLSVersion, scanner.SeverityMedium, "server accepts %s, policy requires at least %s", proto.Version, tls.VersionName(min))
+			}
+		}
+	}
+
+	if p.RequireTrusted && !cert.IsTrusted {
+		add(RuleTrusted, scanner.SeverityHigh, "certificate chain is not trusted (self-signed: %t)", cert.IsSelfSigned)
+	}
+
+	if p.FailOn != "none" {
+		for _, f := range insp.Findings {
+			if f.Severity.AtLeast(p.FailOn) && !coveredByRule(f.Code, violations) {
+				add(RuleFinding+"/"+f.Code, f.Severity, "%s", f.Message)
+			}
+		}
+	}
+	return violations
+}
+
+// findingRules: Befunde, die bereits durch eine eigene Regel abgedeckt sind
+var findingRules = map[string]string{
+	"expired":         RuleDaysLeft,
+	"expires_soon":    RuleDaysLeft,
+	"self_signed":     RuleTrusted,
+	"untrusted":       RuleTrusted,
+	"weak_key":        RuleKeySize,
+	"legacy_protocol": RuleTLSVersion,
+}
+
+// coveredByRule verhindert doppelte Meldungen für Befund und Regel
+func coveredByRule(code string, violations []Violation) bool {
+	rule, ok := findingRules[code]
+	if !ok {
+		return false
+	}
+	for _, v := range violations {
+		if v.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+var versionByName = map[string]uint16{
+	tls.VersionName(tls.VersionTLS10): tls.VersionTLS10,
+	tls.VersionName(tls.VersionTLS11): tls.VersionTLS11,
+	tls.VersionName(tls.VersionTLS12): tls.VersionTLS12,
+	tls.VersionName(tls.VersionTLS13): tls.VersionTLS13,
+}
+
+// tlsVersion liest "1.2", "TLS 1.2" oder "tls1.2" (leer = keine Anforderung)
+func tlsVersion(value string) (uint16, error) {
+	v := strings.TrimPrefix(strings.ReplaceAll(strings.ToLower(value), " ", ""), "tls")
+	switch v {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("min_tls_version: invalid TLS version %q", value)
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
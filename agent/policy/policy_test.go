@@ -0,0 +1,141 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+var now = time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+
+// compliant erfüllt die Default-Policy
+func compliant() *scanner.Inspection {
+	return &scanner.Inspection{
+		Host: "www.example.com",
+		Port: 443,
+		Certificate: &scanner.CertificateData{
+			SubjectCN:    "www.example.com",
+			Issuer:       "R11",
+			NotAfter:     now.Add(60 * 24 * time.Hour),
+			KeyAlgorithm: "RSA",
+			KeySize:      2048,
+			SignatureAlg: "SHA256-RSA",
+			IsTrusted:    true,
+		},
+		Protocols: []scanner.ProtocolSupport{
+			{Version: "TLS 1.0"}, {Version: "TLS 1.1"},
+			{Version: "TLS 1.2", Supported: true}, {Version: "TLS 1.3", Supported: true},
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy func(p *Policy)
+		modify func(insp *scanner.Inspection)
+		want   []string // Regel/Schweregrad
+	}{
+		{"compliant", nil, nil, nil},
+		{"expires soon", nil, func(i *scanner.Inspection) { i.Certificate.NotAfter = now.Add(10 * 24 * time.Hour) }, []string{"min-days-left/high"}},
+		{"expired", nil, func(i *scanner.Inspection) { i.Certificate.NotAfter = now.Add(-48 * time.Hour) }, []string{"min-days-left/critical"}},
+		{"no minimum days", func(p *Policy) { p.MinDaysLeft = 0 }, func(i *scanner.Inspection) { i.Certificate.NotAfter = now.Add(time.Hour) }, nil},
+		{"issuer allowed by glob", func(p *Policy) { p.AllowedIssuers = []string{"r1?", "DigiCert*"} }, nil, nil},
+		{"issuer not allowed", func(p *Policy) { p.AllowedIssuers = []string{"DigiCert*"} }, nil, []string{"allowed-issuer/high"}},
+		{"small RSA key", nil, func(i *scanner.Inspection) { i.Certificate.KeySize = 1024 }, []string{"min-key-size/high"}},
+		{"small ECDSA key", func(p *Policy) { p.MinECDSABits = 384 }, func(i *scanner.Inspection) {
+			i.Certificate.KeyAlgorithm, i.Certificate.KeySize = "ECDSA", 256
+		}, []string{"min-key-size/high"}},
+		{"RSA limit does not apply to ECDSA", nil, func(i *scanner.Inspection) {
+			i.Certificate.KeyAlgorithm, i.Certificate.KeySize = "ECDSA", 256
+		}, nil},
+		{"signature algorithm allowed", func(p *Policy) { p.AllowedSignatureAlg = []string{"sha256-rsa"} }, nil, nil},
+		{"signature algorithm not allowed", func(p *Policy) { p.AllowedSignatureAlg = []string{"ECDSA-SHA256"} }, nil, []string{"allowed-signature-algorithm/high"}},
+		{"legacy TLS", nil, func(i *scanner.Inspection) {
+			i.Protocols[0].Supported, i.Protocols[1].Supported = true, true
+		}, []string{"min-tls-version/medium", "min-tls-version/medium"}},
+		{"TLS 1.3 required", func(p *Policy) { p.MinTLSVersion = "TLS 1.3" }, nil, []string{"min-tls-version/medium"}},
+		{"untrusted", nil, func(i *scanner.Inspection) { i.Certificate.IsTrusted = false }, []string{"trusted-chain/high"}},
+		{"untrusted allowed", func(p *Policy) { p.RequireTrusted = false }, func(i *scanner.Inspection) { i.Certificate.IsTrusted = false }, nil},
+		{"finding at fail_on", nil, func(i *scanner.Inspection) {
+			i.Findings = []scanner.Finding{{Code: "hostname_mismatch", Severity: scanner.SeverityHigh, Message: "hostname not covered"}}
+		}, []string{"finding/hostname_mismatch/high"}},
+		{"finding below fail_on", nil, func(i *scanner.Inspection) {
+			i.Findings = []scanner.Finding{{Code: "weak_signature", Severity: scanner.SeverityMedium}}
+		}, nil},
+		{"fail_on none", func(p *Policy) { p.FailOn = "none" }, func(i *scanner.Inspection) {
+			i.Findings = []scanner.Finding{{Code: "hostname_mismatch", Severity: scanner.SeverityCritical}}
+		}, nil},
+		{"finding covered by rule", nil, func(i *scanner.Inspection) {
+			i.Certificate.NotAfter = now.Add(-time.Hour)
+			i.Findings = []scanner.Finding{{Code: "expired", Severity: scanner.SeverityCritical}}
+		}, []string{"min-days-left/critical"}},
+		{"finding not covered without rule", func(p *Policy) { p.RequireTrusted = false }, func(i *scanner.Inspection) {
+			i.Certificate.IsTrusted = false
+			i.Findings = []scanner.Finding{{Code: "untrusted", Severity: scanner.SeverityHigh}}
+		}, []string{"finding/untrusted/high"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Default()
+			if tt.policy != nil {
+				tt.policy(&p)
+			}
+			insp := compliant()
+			if tt.modify != nil {
+				tt.modify(insp)
+			}
+			var got []string
+			for _, v := range p.Evaluate(insp, now) {
+				got = append(got, v.Rule+"/"+string(v.Severity))
+				if v.Message == "" && !strings.HasPrefix(v.Rule, RuleFinding) {
+					t.Errorf("%s without message", v.Rule)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	write := func(content string) string {
+		file := filepath.Join(t.TempDir(), "policy.json")
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	p, err := Load(write(`{"min_days_left": 30, "allowed_issuers": ["R1?"], "fail_on": "CRITICAL"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	want.MinDaysLeft, want.AllowedIssuers, want.FailOn = 30, []string{"R1?"}, scanner.SeverityCritical
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("Load = %+v, want %+v", p, want)
+	}
+
+	for content, wantErr := range map[string]string{
+		`{"min_days": 30}`:             "unknown field",
+		`{"min_days_left": -1}`:        "must not be negative",
+		`{"min_tls_version": "1.4"}`:   `invalid TLS version "1.4"`,
+		`{"fail_on": "urgent"}`:        "fail_on",
+		`{"allowed_issuers": ["[R1"]}`: "invalid pattern",
+		`{"min_days_left": "thirty"}`:  "cannot unmarshal",
+	} {
+		if _, err := Load(write(content)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Load(%s) = %v, want %q", content, err, wantErr)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
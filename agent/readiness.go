@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/health"
+	"github.com/zertifikat-waechter/agent/scanrun"
+)
+
+// backendFailures: so viele Verbindungsfehler in Folge machen den Agent not ready
+const backendFailures = 5
+
+// outboxDropWindow: so lange nach einem verworfenen Schreibzugriff gilt die Outbox als degraded
+const outboxDropWindow = 5 * time.Minute
+
+// maxListedTargets begrenzt die Target-Liste in den Details
+const maxListedTargets = 10
+
+// registerReadiness meldet die Komponentenprüfungen für /readyz an
+func registerReadiness(ctx context.Context, checker *health.Checker, a *agent, connector, configState *health.State) {
+	checker.Register(func(now time.Time) health.Check {
+		if ctx.Err() != nil {
+			return health.Check{Name: "agent", Status: health.StatusFailing, Message: "shutting down"}
+		}
+		return health.Check{Name: "agent", Status: health.StatusOK, Details: map[string]interface{}{"version": currentBuildInfo().Version}}
+	})
+	checker.Register(a.checkBackend)
+	checker.Register(connector.Check)
+	checker.Register(a.checkScans)
+	checker.Register(a.checkOutbox)
+	if a.sinks.Len() > 0 {
+		checker.Register(a.checkSinks)
+	}
+	checker.Register(configState.Check)
+}
+
+// checkBackend: Backend erreichbar, solange nicht mehrere Requests in Folge scheitern
+func (a *agent) checkBackend(now time.Time) health.Check {
+	h := a.client.Health()
+	check := health.Check{Name: "backend", Status: health.StatusOK, Details: map[string]interface{}{
+		"consecutive_errors": h.ConsecutiveErrors,
+	}}
+	if !h.LastSuccessAt.IsZero() {
+		check.Details["last_success_at"] = h.LastSuccessAt.UTC()
+	}
+
+	switch {
+	case h.ConsecutiveErrors >= backendFailures:
+		check.Status = health.StatusFailing
+		check.Message = fmt.Sprintf("backend unreachable (%d failed requests): %s", h.ConsecutiveErrors, h.LastError)
+	case h.ConsecutiveErrors > 0:
+		check.Status = health.StatusDegraded
+		check.Message = "last backend request failed: " + h.LastError
+	}
+	return check
+}
+
+// checkScans: keine überfälligen Targets, letzter Lauf nicht komplett fehlgeschlagen
+func (a *agent) checkScans(now time.Time) health.Check {
+	check := health.Check{Name: "scan", Status: health.StatusOK, Details: map[string]interface{}{}}
+
+	if overdue := a.schedule.Overdue(now, a.cfg.ReadinessScanIntervals); len(overdue) > 0 {
+		check.Status = health.StatusFailing
+		check.Message = fmt.Sprintf("%d targets not scanned for more than %d intervals", len(overdue), a.cfg.ReadinessScanIntervals)
+		if len(overdue) > maxListedTargets {
+			overdue = overdue[:maxListedTargets]
+		}
+		check.Details["overdue"] = overdue
+	}
+
+	last := a.lastRun.Load()
+	if last == nil {
+		if check.Status == health.StatusOK {
+			check.Message = "waiting for first scan"
+		}
+		return check
+	}
+
+	check.Details["last_run_id"] = last.ID
+	check.Details["last_run_status"] = last.Status
+	if last.FinishedAt != nil {
+		check.Details["last_run_finished_at"] = last.FinishedAt.UTC()
+	}
+	if check.Status != health.StatusOK {
+		return check
+	}
+
+	switch {
+	case last.Status == scanrun.StatusFailed:
+		check.Status = health.StatusDegraded
+		check.Message = "last scan run failed"
+	case last.EndpointsTotal > 0 && last.EndpointsSucceeded == 0 && last.Status == scanrun.StatusCompleted:
+		check.Status = health.StatusDegraded
+		check.Message = fmt.Sprintf("all %d endpoints of the last scan run failed", last.EndpointsTotal)
+	}
+	return check
+}
+
+// checkOutbox: Schreibzugriffe werden nicht verworfen
+func (a *agent) checkOutbox(now time.Time) health.Check {
+	depth, capacity := a.outbox.Len(), a.outbox.Cap()
+	check := health.Check{Name: "outbox", Status: health.StatusOK, Details: map[string]interface{}{
+		"queue_depth":   depth,
+		"capacity":      capacity,
+		"dropped_total": a.outbox.Dropped(),
+	}}
+
+	lastDrop := a.outbox.LastDrop()
+	switch {
+	case depth >= capacity:
+		check.Status = health.StatusFailing
+		check.Message = "outbox full - backend writes are dropped"
+	case !lastDrop.IsZero() && now.Sub(lastDrop) < outboxDropWindow:
+		check.Status = health.StatusDegraded
+		check.Message = fmt.Sprintf("backend writes dropped %s ago", now.Sub(lastDrop).Round(time.Second))
+	case depth*5 >= capacity*4:
+		check.Status = health.StatusDegraded
+		check.Message = fmt.Sprintf("outbox %d%% full", depth*100/capacity)
+	}
+	return check
+}
+
+// checkSinks: Sinks sind zusätzliche Ausgaben - ein Ausfall macht den Agent höchstens degraded
+func (a *agent) checkSinks(now time.Time) health.Check {
+	check := health.Check{Name: "sinks", Status: health.StatusOK}
+	details := make(map[string]interface{})
+	var failing []string
+	for _, stats := range a.sinks.Stats() {
+		details[stats.Name] = map[string]interface{}{
+			"type":        stats.Type,
+			"sent":        stats.Sent,
+			"failed":      stats.Failed,
+			"dropped":     stats.Dropped,
+			"queue_depth": stats.QueueDepth,
+			"spooled":     stats.Spooled,
+		}
+		if stats.QueueDepth >= stats.Capacity || (stats.LastFailAt != nil && now.Sub(*stats.LastFailAt) < outboxDropWindow) {
+			failing = append(failing, stats.Name)
+		}
+	}
+	check.Details = details
+
+	if len(failing) > 0 {
+		check.Status = health.StatusDegraded
+		check.Message = fmt.Sprintf("records not delivered to %s", strings.Join(failing, ", "))
+	}
+	return check
+}
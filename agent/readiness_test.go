@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/health"
+	"github.com/zertifikat-waechter/agent/metrics"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/scheduler"
+	"github.com/zertifikat-waechter/agent/sink"
+	"github.com/zertifikat-waechter/agent/supabase"
+)
+
+// readinessFixture ist ein Agent mit Fake-Backend hinter dem echten Health-Server
+type readinessFixture struct {
+	agent         *agent
+	backendStatus atomic.Int32 // HTTP-Status des Fake-Backends
+	connector     *health.State
+	configState   *health.State
+	cancel        context.CancelFunc
+	server        *httptest.Server
+}
+
+func newReadinessFixture(t *testing.T) *readinessFixture {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	f := &readinessFixture{}
+	f.backendStatus.Store(http.StatusCreated)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(f.backendStatus.Load()))
+	}))
+	t.Cleanup(backend.Close)
+
+	f.agent = &agent{
+		cfg:      &config.Config{ReadinessScanIntervals: 3},
+		client:   supabase.NewClient(backend.URL, "test-key"),
+		outbox:   supabase.NewOutbox(5, log),
+		schedule: scheduler.New("connector"),
+		sinks:    sink.NewDispatcher(sink.Agent{ConnectorID: "connector"}, log),
+	}
+	f.connector = health.NewState("connector", health.StatusOK, "registered")
+	f.configState = health.NewState("config", health.StatusOK, "applied")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	f.cancel = cancel
+	checker := health.NewChecker()
+	registerReadiness(ctx, checker, f.agent, f.connector, f.configState)
+
+	f.server = httptest.NewServer(newHealthServer("0", checker, metrics.New(), nil).Handler)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// ready ruft /readyz ab und liefert Statuscode und Bericht
+func (f *readinessFixture) ready(t *testing.T) (int, health.Report) {
+	t.Helper()
+	resp, err := http.Get(f.server.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var report health.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decode /readyz: %v", err)
+	}
+	return resp.StatusCode, report
+}
+
+// check liefert die Prüfung einer Komponente aus dem Bericht
+func check(t *testing.T, report health.Report, name string) health.Check {
+	t.Helper()
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no check %q in %+v", name, report.Checks)
+	return health.Check{}
+}
+
+// TestReadinessTransitions spielt die Übergänge zwischen 200 und 503 je Komponente durch
+func TestReadinessTransitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      func(t *testing.T, f *readinessFixture) // macht die Komponente failing
+		recover   func(t *testing.T, f *readinessFixture) // nil = bleibt failing
+		component string
+	}{
+		{"backend", func(t *testing.T, f *readinessFixture) {
+			f.backendStatus.Store(http.StatusBadGateway)
+			for i := 0; i < backendFailures; i++ {
+				f.agent.client.SendLog(context.Background(), "agent", "info", "test", nil)
+			}
+		}, func(t *testing.T, f *readinessFixture) {
+			f.backendStatus.Store(http.StatusCreated)
+			f.agent.client.SendLog(context.Background(), "agent", "info", "test", nil)
+		}, "backend"},
+		{"outbox", func(t *testing.T, f *readinessFixture) {
+			for i := 0; i < f.agent.outbox.Cap(); i++ {
+				f.agent.outbox.Enqueue("test", func(context.Context) error { return nil })
+			}
+		}, func(t *testing.T, f *readinessFixture) {
+			ctx, cancel := context.WithCancel(context.Background())
+			t.Cleanup(cancel)
+			go f.agent.outbox.Run(ctx)
+			deadline := time.Now().Add(5 * time.Second)
+			for f.agent.outbox.Len() > 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}, "outbox"},
+		{"overdue scans", func(t *testing.T, f *readinessFixture) {
+			f.agent.schedule.Configure([]string{"a.example"}, nil, scheduler.Schedule{Name: "default", Interval: time.Hour},
+				scheduler.Options{}, time.Now().Add(-10*time.Hour))
+		}, func(t *testing.T, f *readinessFixture) {
+			f.agent.schedule.MarkRun([]string{"a.example"}, time.Now())
+		}, "scan"},
+		{"connector", func(t *testing.T, f *readinessFixture) {
+			f.connector.Set(health.StatusFailing, "connector deleted")
+		}, func(t *testing.T, f *readinessFixture) {
+			f.connector.Set(health.StatusOK, "registered")
+		}, "connector"},
+		{"shutdown", func(t *testing.T, f *readinessFixture) { f.cancel() }, nil, "agent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReadinessFixture(t)
+			if code, report := f.ready(t); code != http.StatusOK || report.Status != health.StatusOK {
+				t.Fatalf("initial: %d %s %+v", code, report.Status, report.Checks)
+			}
+
+			tt.fail(t, f)
+			code, report := f.ready(t)
+			if code != http.StatusServiceUnavailable || report.Status != health.StatusFailing {
+				t.Errorf("failing: %d %s", code, report.Status)
+			}
+			if c := check(t, report, tt.component); c.Status != health.StatusFailing || c.Message == "" {
+				t.Errorf("failing check = %+v", c)
+			}
+
+			if tt.recover == nil {
+				return
+			}
+			tt.recover(t, f)
+			if code, report := f.ready(t); code != http.StatusOK {
+				t.Errorf("recovered: %d %s %+v", code, report.Status, check(t, report, tt.component))
+			}
+		})
+	}
+}
+
+// TestReadinessDegraded: degraded bleibt ready (200), der Bericht nennt den Grund
+func TestReadinessDegraded(t *testing.T) {
+	f := newReadinessFixture(t)
+
+	finished := time.Now()
+	f.agent.lastRun.Store(&scanrun.Summary{ID: "run-1", Status: scanrun.StatusFailed, FinishedAt: &finished})
+	f.configState.Set(health.StatusDegraded, "config rejected, keeping version 3")
+	f.backendStatus.Store(http.StatusInternalServerError)
+	f.agent.client.SendLog(context.Background(), "agent", "info", "test", nil)
+
+	code, report := f.ready(t)
+	if code != http.StatusOK || report.Status != health.StatusDegraded {
+		t.Fatalf("degraded: %d %s", code, report.Status)
+	}
+	for _, name := range []string{"scan", "config", "backend"} {
+		if c := check(t, report, name); c.Status != health.StatusDegraded {
+			t.Errorf("%s = %+v, want degraded", name, c)
+		}
+	}
+	if c := check(t, report, "scan"); c.Details["last_run_id"] != "run-1" || c.Message != "last scan run failed" {
+		t.Errorf("scan check = %+v", c)
+	}
+
+	// /healthz hängt nicht von den Komponenten ab
+	f.connector.Set(health.StatusFailing, "connector deleted")
+	resp, err := http.Get(f.server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/healthz = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,107 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/policy"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit schreibt den Report als JUnit-XML: ein Testfall pro Endpoint, nicht
+// erreichbare Endpoints als <error>, Policy-Verstöße als <failure>
+func WriteJUnit(w io.Writer, r *Report) error {
+	suite := junitTestSuite{
+		Name:      "certificate-policy",
+		Timestamp: r.GeneratedAt.UTC().Format("2006-01-02T15:04:05"),
+		Time:      seconds(r.Duration),
+	}
+
+	for _, res := range r.Results {
+		tc := junitTestCase{ClassName: "tls." + res.Host, Name: res.Endpoint(), Time: seconds(res.Duration)}
+		if res.Certificate != nil {
+			tc.SystemOut = fmt.Sprintf("subject=%s issuer=%s not_after=%s fingerprint=%s",
+				res.Certificate.SubjectCN, res.Certificate.Issuer, res.Certificate.NotAfter.Format(time.RFC3339), res.Certificate.Fingerprint)
+		}
+
+		switch {
+		case len(res.Violations) == 1 && res.Violations[0].Rule == policy.RuleReachable:
+			tc.Error = &junitProblem{Message: res.Violations[0].Message, Type: policy.RuleReachable, Text: res.Violations[0].Message}
+			suite.Errors++
+		case len(res.Violations) > 0:
+			lines := make([]string, len(res.Violations))
+			for i, v := range res.Violations {
+				lines[i] = fmt.Sprintf("[%s] %s: %s", v.Severity, v.Rule, v.Message)
+			}
+			tc.Failure = &junitProblem{
+				Message: fmt.Sprintf("%d policy violation(s): %s", len(res.Violations), res.Violations[0].Message),
+				Type:    res.Violations[0].Rule,
+				Text:    strings.Join(lines, "\n"),
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+	}
+
+	doc := junitTestSuites{
+		Name:     r.Tool,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/policy"
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+// Result ist das Prüfergebnis eines Endpoints
+type Result struct {
+	Host        string                    `json:"host"`
+	Port        int                       `json:"port"`
+	Passed      bool                      `json:"passed"`
+	Certificate *scanner.CertificateData  `json:"certificate,omitempty"`
+	Protocols   []scanner.ProtocolSupport `json:"protocols,omitempty"`
+	Findings    []scanner.Finding         `json:"findings,omitempty"`
+	Violations  []policy.Violation        `json:"violations,omitempty"`
+	Duration    time.Duration             `json:"-"`
+}
+
+// Endpoint liefert "host:port"
+func (r Result) Endpoint() string {
+	return fmt.Sprintf("%s:%d", r.Host, r.Port)
+}
+
+// Report ist das Ergebnis eines Gate-Laufs
+type Report struct {
+	Tool        string        `json:"tool"`
+	Version     string        `json:"version"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Duration    time.Duration `json:"-"`
+	Policy      policy.Policy `json:"policy"`
+	Summary     Summary       `json:"summary"`
+	Results     []Result      `json:"results"`
+}
+
+// Summary zählt die Ergebnisse
+type Summary struct {
+	Endpoints  int `json:"endpoints"`
+	Passed     int `json:"passed"`
+	Failed     int `json:"failed"`
+	Violations int `json:"violations"`
+}
+
+// Add übernimmt ein Ergebnis und aktualisiert die Zusammenfassung
+func (r *Report) Add(res Result) {
+	res.Passed = len(res.Violations) == 0
+	r.Results = append(r.Results, res)
+	r.Summary.Endpoints++
+	r.Summary.Violations += len(res.Violations)
+	if res.Passed {
+		r.Summary.Passed++
+	} else {
+		r.Summary.Failed++
+	}
+}
+
+// Passed meldet, ob alle Endpoints die Policy erfüllen
+func (r *Report) Passed() bool {
+	return r.Summary.Failed == 0
+}
+
+// WriteJSON schreibt den Report als JSON
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
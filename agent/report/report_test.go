@@ -0,0 +1,140 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/policy"
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+var update = flag.Bool("update", false, "Golden-Dateien in testdata neu schreiben")
+
+// testReport enthält einen bestandenen, einen fehlgeschlagenen und einen nicht
+// erreichbaren Endpoint
+func testReport() *Report {
+	at := time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+	r := &Report{
+		Tool:        "zertifikat-waechter-agent",
+		Version:     "1.4.0",
+		GeneratedAt: at,
+		Duration:    1500 * time.Millisecond,
+		Policy:      policy.Default(),
+	}
+	r.Add(Result{
+		Host: "www.example.com", Port: 443, Duration: 120 * time.Millisecond,
+		Certificate: &scanner.CertificateData{SubjectCN: "www.example.com", Issuer: "R11", NotAfter: at.Add(60 * 24 * time.Hour), Fingerprint: "aa:bb"},
+	})
+	r.Add(Result{
+		Host: "legacy.example.com", Port: 443, Duration: 340 * time.Millisecond,
+		Certificate: &scanner.CertificateData{SubjectCN: "legacy.example.com", Issuer: `Evil & Co "<CA>"`, NotAfter: at.Add(5 * 24 * time.Hour), Fingerprint: "cc:dd"},
+		Violations: []policy.Violation{
+			{Rule: policy.RuleDaysLeft, Severity: scanner.SeverityHigh, Message: "certificate expires in 5 days (2026-03-11), policy requires at least 14"},
+			{Rule: policy.RuleIssuer, Severity: scanner.SeverityHigh, Message: `issuer "Evil & Co \"<CA>\"" is not in the allowed issuers`},
+			{Rule: policy.RuleTLSVersion, Severity: scanner.SeverityMedium, Message: "server accepts TLS 1.0, policy requires at least TLS 1.2"},
+			{Rule: policy.RuleFinding + "/weak_signature", Severity: scanner.SeverityLow, Message: "certificate signed with SHA-1"},
+		},
+	})
+	r.Add(Result{
+		Host: "down.example.com", Port: 8443, Duration: 5 * time.Second,
+		Violations: []policy.Violation{{Rule: policy.RuleReachable, Severity: scanner.SeverityCritical, Message: "connection_refused: connect: connection refused"}},
+	})
+	return r
+}
+
+// golden vergleicht got mit testdata/name (neu schreiben mit go test ./report -update)
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from golden file:\n%s", name, got)
+	}
+}
+
+func TestReportSummary(t *testing.T) {
+	r := testReport()
+	want := Summary{Endpoints: 3, Passed: 1, Failed: 2, Violations: 5}
+	if r.Summary != want || r.Passed() {
+		t.Errorf("summary = %+v, passed %v, want %+v", r.Summary, r.Passed(), want)
+	}
+	if !r.Results[0].Passed || r.Results[1].Passed || r.Results[2].Endpoint() != "down.example.com:8443" {
+		t.Errorf("results = %+v", r.Results)
+	}
+
+	empty := &Report{}
+	empty.Add(Result{Host: "a.example", Port: 443})
+	if !empty.Passed() {
+		t.Error("report without violations failed")
+	}
+}
+
+func TestWriteSARIFGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, testReport(), ""); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "report.sarif.golden", buf.Bytes())
+}
+
+// TestWriteSARIFArtifact: mit Target-Datei zeigen alle Befunde auf diese Datei
+func TestWriteSARIFArtifact(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, testReport(), "ci/targets.txt"); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 5 {
+		t.Fatalf("%d results, want 5", len(results))
+	}
+	for _, res := range results {
+		if uri := res.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "ci/targets.txt" {
+			t.Errorf("%s: uri = %q", res.RuleID, uri)
+		}
+	}
+
+	// Ohne Verstöße bleiben results und rules leere Listen (nicht null)
+	buf.Reset()
+	passed := &Report{Tool: "zertifikat-waechter-agent"}
+	passed.Add(Result{Host: "a.example", Port: 443})
+	if err := WriteSARIF(&buf, passed, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"results": []`)) || !bytes.Contains(buf.Bytes(), []byte(`"rules": []`)) {
+		t.Errorf("SARIF without violations:\n%s", buf.String())
+	}
+}
+
+func TestWriteJUnitGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, testReport()); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "report.junit.golden", buf.Bytes())
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v", err)
+	}
+	if doc.Tests != 3 || doc.Failures != 1 || doc.Errors != 1 {
+		t.Errorf("tests=%d failures=%d errors=%d", doc.Tests, doc.Failures, doc.Errors)
+	}
+}
@@ -0,0 +1,152 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// Ausschnitt aus SARIF 2.1.0, soweit für Code-Scanning-Ansichten nötig
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	DefaultConfiguration sarifRuleDefaults `json:"defaultConfiguration"`
+}
+
+type sarifRuleDefaults struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// WriteSARIF schreibt die Verstöße als SARIF 2.1.0. artifact ist die Datei, der die
+// Befunde zugeordnet werden (z.B. die Target-Liste); leer = Endpoint als URI.
+func WriteSARIF(w io.Writer, r *Report, artifact string) error {
+	rules := map[string]sarifRule{}
+	results := []sarifResult{}
+
+	for _, res := range r.Results {
+		uri := artifact
+		if uri == "" {
+			uri = "tls://" + res.Endpoint()
+		}
+		for _, v := range res.Violations {
+			level := sarifLevel(v.Severity)
+			if _, ok := rules[v.Rule]; !ok {
+				rules[v.Rule] = sarifRule{
+					ID:                   v.Rule,
+					ShortDescription:     sarifMessage{Text: ruleDescription(v.Rule)},
+					DefaultConfiguration: sarifRuleDefaults{Level: level},
+				}
+			}
+			results = append(results, sarifResult{
+				RuleID:  v.Rule,
+				Level:   level,
+				Message: sarifMessage{Text: res.Endpoint() + ": " + v.Message},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}},
+					LogicalLocations: []sarifLogicalLocation{{Name: res.Endpoint(), Kind: "endpoint"}},
+				}},
+			})
+		}
+	}
+
+	driver := sarifDriver{Name: r.Tool, Version: r.Version, Rules: []sarifRule{}}
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, rule)
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool { return driver.Rules[i].ID < driver.Rules[j].ID })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
+
+// sarifLevel bildet den Schweregrad auf die SARIF-Level ab
+func sarifLevel(s scanner.Severity) string {
+	switch {
+	case s.AtLeast(scanner.SeverityHigh):
+		return "error"
+	case s.AtLeast(scanner.SeverityMedium):
+		return "warning"
+	}
+	return "note"
+}
+
+var ruleDescriptions = map[string]string{
+	"reachable":                   "Endpoint must be reachable and complete a TLS handshake",
+	"min-days-left":               "Certificate must be valid for the minimum number of days",
+	"allowed-issuer":              "Certificate must be issued by an allowed CA",
+	"min-key-size":                "Certificate key must have the minimum size",
+	"allowed-signature-algorithm": "Certificate must use an allowed signature algorithm",
+	"min-tls-version":             "Server must not accept TLS versions below the minimum",
+	"trusted-chain":               "Certificate chain must end at a trusted root",
+}
+
+func ruleDescription(rule string) string {
+	if desc, ok := ruleDescriptions[rule]; ok {
+		return desc
+	}
+	if code, ok := strings.CutPrefix(rule, "finding/"); ok {
+		return "Certificate finding: " + strings.ReplaceAll(code, "_", " ")
+	}
+	return rule
+}
@@ -0,0 +1,235 @@
+package rotation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scheduler"
+)
+
+// Gründe, aus denen ein Zertifikatswechsel als verdächtig gilt
+const (
+	ReasonPublicToSelfSigned = "public_ca_to_self_signed" // mögliche TLS-Interception
+	ReasonTrustLost          = "trusted_to_untrusted"     // Chain endet nicht mehr bei einer öffentlichen CA
+	ReasonKeySizeDecreased   = "key_size_decreased"
+	ReasonValidityRollback   = "validity_rollback"    // neues Zertifikat läuft früher ab als das alte
+	ReasonHostnameUncovered  = "hostname_not_covered" // SNI-Hostname steht nicht mehr im Zertifikat
+	ReasonIssuerChangedEarly = "issuer_changed_early" // anderer Aussteller, bevor die Erneuerung fällig war
+	ReasonKeyChangedEarly    = "key_changed_early"    // anderer Schlüsseltyp, bevor die Erneuerung fällig war
+)
+
+// Change ist eine geänderte Eigenschaft zwischen altem und neuem Zertifikat
+type Change struct {
+	Field     string      `json:"field"`
+	Old       interface{} `json:"old,omitempty"`
+	New       interface{} `json:"new,omitempty"`
+	Added     []string    `json:"added,omitempty"`      // nur bei san
+	Removed   []string    `json:"removed,omitempty"`    // nur bei san
+	ShiftDays *int        `json:"shift_days,omitempty"` // nur bei not_before/not_after
+}
+
+// Event meldet, dass ein Endpoint ein anderes Zertifikat ausliefert als beim letzten Scan
+type Event struct {
+	Host              string    `json:"host"`
+	Port              int       `json:"port"`
+	SNI               string    `json:"sni"`
+	OldCertificateID  string    `json:"old_certificate_id,omitempty"`
+	NewCertificateID  string    `json:"new_certificate_id,omitempty"`
+	OldFingerprint    string    `json:"old_fingerprint"`
+	NewFingerprint    string    `json:"new_fingerprint"`
+	Changes           []Change  `json:"changes"`
+	Suspicious        bool      `json:"suspicious"`
+	SuspiciousReasons []string  `json:"suspicious_reasons,omitempty"`
+	DetectedAt        time.Time `json:"detected_at"`
+}
+
+// Known ist das zuletzt gesehene Zertifikat eines Endpoints
+type Known struct {
+	CertificateID string
+	Certificate   scanner.CertificateData
+}
+
+// Detector merkt sich pro Endpoint das zuletzt gesehene Zertifikat (thread-safe)
+type Detector struct {
+	mu      sync.Mutex
+	last    map[string]Known
+	rotated map[string]time.Time
+}
+
+// NewDetector erstellt einen leeren Detector
+func NewDetector() *Detector {
+	return &Detector{last: make(map[string]Known), rotated: make(map[string]time.Time)}
+}
+
+func key(host string, port int) string {
+	return host + ":" + strconv.Itoa(port)
+}
+
+// Seed übernimmt das zuletzt bekannte Zertifikat eines Endpoints (z.B. aus dem Backend)
+func (d *Detector) Seed(host string, port int, known Known) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.last[key(host, port)] = known
+}
+
+// Known meldet, ob für den Endpoint schon ein Zertifikat bekannt ist
+func (d *Detector) Known(host string, port int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.last[key(host, port)]
+	return ok
+}
+
+// Observe vergleicht das gescannte Zertifikat mit dem zuletzt gesehenen.
+// Liefert nil beim ersten Scan eines Endpoints oder wenn sich nichts geändert hat.
+func (d *Detector) Observe(host string, port int, certID string, cert *scanner.CertificateData) *Event {
+	now := time.Now().UTC()
+
+	d.mu.Lock()
+	prev, ok := d.last[key(host, port)]
+	d.last[key(host, port)] = Known{CertificateID: certID, Certificate: *cert}
+	changed := ok && prev.Certificate.Fingerprint != cert.Fingerprint
+	if changed {
+		d.rotated[key(host, port)] = now
+	}
+	d.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	event := &Event{
+		Host:             host,
+		Port:             port,
+		SNI:              cert.SNI,
+		OldCertificateID: prev.CertificateID,
+		NewCertificateID: certID,
+		OldFingerprint:   prev.Certificate.Fingerprint,
+		NewFingerprint:   cert.Fingerprint,
+		Changes:          Diff(&prev.Certificate, cert),
+		DetectedAt:       now,
+	}
+	event.SuspiciousReasons = suspiciousReasons(&prev.Certificate, cert, now)
+	event.Suspicious = len(event.SuspiciousReasons) > 0
+	return event
+}
+
+// RotatedAt liefert den Zeitpunkt des letzten erkannten Wechsels (Nullzeit = keiner seit dem Start)
+func (d *Detector) RotatedAt(host string, port int) time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rotated[key(host, port)]
+}
+
+// Diff vergleicht zwei Zertifikate Feld für Feld
+func Diff(prev, cur *scanner.CertificateData) []Change {
+	var changes []Change
+
+	field := func(name string, o, n interface{}) {
+		if o != n {
+			changes = append(changes, Change{Field: name, Old: o, New: n})
+		}
+	}
+	field("subject_cn", prev.SubjectCN, cur.SubjectCN)
+	field("issuer", prev.Issuer, cur.Issuer)
+	field("key_alg", prev.KeyAlgorithm, cur.KeyAlgorithm)
+	field("key_size", prev.KeySize, cur.KeySize)
+	field("signature_algorithm", prev.SignatureAlg, cur.SignatureAlg)
+	field("is_trusted", prev.IsTrusted, cur.IsTrusted)
+	field("is_self_signed", prev.IsSelfSigned, cur.IsSelfSigned)
+	field("serial", prev.SerialNumber, cur.SerialNumber)
+
+	if added, removed := diffSAN(prev.SAN, cur.SAN); len(added) > 0 || len(removed) > 0 {
+		changes = append(changes, Change{Field: "san", Added: added, Removed: removed})
+	}
+
+	validity := func(name string, o, n time.Time) {
+		if o.Equal(n) {
+			return
+		}
+		shift := int(math.Round(n.Sub(o).Hours() / 24))
+		changes = append(changes, Change{Field: name, Old: o, New: n, ShiftDays: &shift})
+	}
+	validity("not_before", prev.NotBefore, cur.NotBefore)
+	validity("not_after", prev.NotAfter, cur.NotAfter)
+
+	return changes
+}
+
+// diffSAN liefert hinzugekommene und entfernte SAN-Einträge (sortiert)
+func diffSAN(prev, cur []string) (added, removed []string) {
+	oldSet := make(map[string]bool, len(prev))
+	for _, s := range prev {
+		oldSet[strings.ToLower(s)] = true
+	}
+	newSet := make(map[string]bool, len(cur))
+	for _, s := range cur {
+		newSet[strings.ToLower(s)] = true
+	}
+	for s := range newSet {
+		if !oldSet[s] {
+			added = append(added, s)
+		}
+	}
+	for s := range oldSet {
+		if !newSet[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// suspiciousReasons prüft, ob ein Wechsel nach Interception oder missglücktem Deployment aussieht.
+// now ist der Zeitpunkt, zu dem der Wechsel erkannt wurde.
+func suspiciousReasons(prev, cur *scanner.CertificateData, now time.Time) []string {
+	var reasons []string
+	switch {
+	case prev.IsTrusted && cur.IsSelfSigned:
+		reasons = append(reasons, ReasonPublicToSelfSigned)
+	case prev.IsTrusted && !cur.IsTrusted:
+		reasons = append(reasons, ReasonTrustLost)
+	}
+	if cur.KeySize > 0 && prev.KeySize > 0 && cur.KeySize < prev.KeySize && cur.KeyAlgorithm == prev.KeyAlgorithm {
+		reasons = append(reasons, ReasonKeySizeDecreased)
+	}
+	if cur.NotAfter.Before(prev.NotAfter) {
+		reasons = append(reasons, ReasonValidityRollback)
+	}
+	if cur.SNI != "" && Covers(prev.SAN, cur.SNI) && !Covers(cur.SAN, cur.SNI) {
+		reasons = append(reasons, ReasonHostnameUncovered)
+	}
+	// Bei der regulären Erneuerung darf sich Aussteller oder Schlüssel ändern, lange vorher nicht
+	if now.Before(scheduler.CertTimes{NotBefore: prev.NotBefore, NotAfter: prev.NotAfter}.RenewalAt()) {
+		if prev.Issuer != cur.Issuer {
+			reasons = append(reasons, ReasonIssuerChangedEarly)
+		}
+		if prev.KeyAlgorithm != cur.KeyAlgorithm {
+			reasons = append(reasons, ReasonKeyChangedEarly)
+		}
+	}
+	return reasons
+}
+
+// Covers prüft, ob ein Hostname von einem SAN-Eintrag abgedeckt ist (inkl. Wildcard *.domain)
+func Covers(san []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, name := range san {
+		name = strings.ToLower(name)
+		if name == host {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") {
+			if i := strings.IndexByte(host, '.'); i > 0 && host[i:] == name[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package rotation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+const day = 24 * time.Hour
+
+var now = time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+
+// publicCert ist ein 90-Tage-Zertifikat einer öffentlichen CA, ausgestellt vor issuedAgo
+func publicCert(issuedAgo time.Duration) scanner.CertificateData {
+	return scanner.CertificateData{
+		Fingerprint:  "aa",
+		SubjectCN:    "www.example.com",
+		SAN:          []string{"www.example.com", "example.com"},
+		Issuer:       "R11",
+		NotBefore:    now.Add(-issuedAgo),
+		NotAfter:     now.Add(90*day - issuedAgo),
+		KeyAlgorithm: "RSA",
+		KeySize:      2048,
+		SerialNumber: "1",
+		SignatureAlg: "SHA256-RSA",
+		IsTrusted:    true,
+		SNI:          "www.example.com",
+	}
+}
+
+// renewed ist der reguläre Nachfolger von prev, ausgestellt jetzt
+func renewed(prev scanner.CertificateData) scanner.CertificateData {
+	cur := prev
+	cur.Fingerprint = "bb"
+	cur.SerialNumber = "2"
+	cur.NotBefore = now
+	cur.NotAfter = now.Add(90 * day)
+	return cur
+}
+
+func TestDiff(t *testing.T) {
+	prev := publicCert(80 * day)
+	shift := func(d int) *int { return &d }
+
+	tests := []struct {
+		name   string
+		modify func(c *scanner.CertificateData)
+		want   []Change
+	}{
+		{"renewal", func(c *scanner.CertificateData) {}, []Change{
+			{Field: "serial", Old: "1", New: "2"},
+			{Field: "not_before", Old: prev.NotBefore, New: now, ShiftDays: shift(80)},
+			{Field: "not_after", Old: prev.NotAfter, New: now.Add(90 * day), ShiftDays: shift(80)},
+		}},
+		{"issuer and key", func(c *scanner.CertificateData) {
+			c.Issuer = "E6"
+			c.KeyAlgorithm, c.KeySize = "ECDSA", 256
+			c.SignatureAlg = "ECDSA-SHA384"
+			c.NotBefore, c.NotAfter = prev.NotBefore, prev.NotAfter
+		}, []Change{
+			{Field: "issuer", Old: "R11", New: "E6"},
+			{Field: "key_alg", Old: "RSA", New: "ECDSA"},
+			{Field: "key_size", Old: 2048, New: 256},
+			{Field: "signature_algorithm", Old: "SHA256-RSA", New: "ECDSA-SHA384"},
+			{Field: "serial", Old: "1", New: "2"},
+		}},
+		{"san added and removed", func(c *scanner.CertificateData) {
+			c.SerialNumber = "1"
+			c.SAN = []string{"WWW.example.com", "api.example.com", "*.cdn.example.com"}
+			c.NotBefore, c.NotAfter = prev.NotBefore, prev.NotAfter
+		}, []Change{
+			{Field: "san", Added: []string{"*.cdn.example.com", "api.example.com"}, Removed: []string{"example.com"}},
+		}},
+		{"self-signed", func(c *scanner.CertificateData) {
+			c.SerialNumber = "1"
+			c.Issuer = c.SubjectCN
+			c.IsTrusted, c.IsSelfSigned = false, true
+			c.NotBefore, c.NotAfter = prev.NotBefore, prev.NotAfter.Add(-12*time.Hour)
+		}, []Change{
+			{Field: "issuer", Old: "R11", New: "www.example.com"},
+			{Field: "is_trusted", Old: true, New: false},
+			{Field: "is_self_signed", Old: false, New: true},
+			{Field: "not_after", Old: prev.NotAfter, New: prev.NotAfter.Add(-12 * time.Hour), ShiftDays: shift(-1)},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := renewed(prev)
+			tt.modify(&cur)
+			got := Diff(&prev, &cur)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := Diff(&prev, &prev); got != nil {
+		t.Errorf("Diff of identical certificates = %+v", got)
+	}
+}
+
+func TestSuspiciousReasons(t *testing.T) {
+	tests := []struct {
+		name   string
+		prev   scanner.CertificateData
+		modify func(c *scanner.CertificateData)
+		want   []string
+	}{
+		{"regular renewal", publicCert(80 * day), func(c *scanner.CertificateData) {}, nil},
+		{"early renewal, same issuer and key", publicCert(10 * day), func(c *scanner.CertificateData) {}, nil},
+		{"issuer changed at renewal", publicCert(70 * day), func(c *scanner.CertificateData) { c.Issuer = "Other CA" }, nil},
+		{"key changed at renewal", publicCert(70 * day), func(c *scanner.CertificateData) { c.KeyAlgorithm, c.KeySize = "ECDSA", 256 }, nil},
+		{"issuer changed before expiry", publicCert(10 * day), func(c *scanner.CertificateData) { c.Issuer = "Other CA" },
+			[]string{ReasonIssuerChangedEarly}},
+		{"key changed before expiry", publicCert(59 * day), func(c *scanner.CertificateData) { c.KeyAlgorithm, c.KeySize = "ECDSA", 256 },
+			[]string{ReasonKeyChangedEarly}},
+		{"interception", publicCert(10 * day), func(c *scanner.CertificateData) {
+			c.Issuer = "Corporate Proxy CA"
+			c.IsTrusted = false
+		}, []string{ReasonTrustLost, ReasonIssuerChangedEarly}},
+		{"public to self-signed", publicCert(10 * day), func(c *scanner.CertificateData) {
+			c.Issuer = c.SubjectCN
+			c.IsTrusted, c.IsSelfSigned = false, true
+		}, []string{ReasonPublicToSelfSigned, ReasonIssuerChangedEarly}},
+		{"key size decreased", publicCert(80 * day), func(c *scanner.CertificateData) { c.KeySize = 1024 },
+			[]string{ReasonKeySizeDecreased}},
+		{"validity rollback", publicCert(10 * day), func(c *scanner.CertificateData) { c.NotAfter = now.Add(30 * day) },
+			[]string{ReasonValidityRollback}},
+		{"hostname no longer covered", publicCert(80 * day), func(c *scanner.CertificateData) { c.SAN = []string{"example.com"} },
+			[]string{ReasonHostnameUncovered}},
+		{"hostname covered by wildcard", publicCert(80 * day), func(c *scanner.CertificateData) { c.SAN = []string{"*.example.com"} }, nil},
+		{"untrusted before", func() scanner.CertificateData {
+			c := publicCert(10 * day)
+			c.IsTrusted, c.IsSelfSigned = false, true
+			return c
+		}(), func(c *scanner.CertificateData) {}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur := renewed(tt.prev)
+			tt.modify(&cur)
+			if got := suspiciousReasons(&tt.prev, &cur, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reasons = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestObserve: der erste Scan und unveränderte Zertifikate liefern kein Event
+func TestObserve(t *testing.T) {
+	d := NewDetector()
+	// Observe arbeitet mit der echten Uhr: ausgestellt vor 10 Tagen
+	first := publicCert(10 * day)
+	first.NotBefore, first.NotAfter = time.Now().Add(-10*day), time.Now().Add(80*day)
+
+	if ev := d.Observe("www.example.com", 443, "cert-1", &first); ev != nil || !d.Known("www.example.com", 443) {
+		t.Fatalf("first scan: event %+v", ev)
+	}
+	if ev := d.Observe("www.example.com", 443, "cert-1", &first); ev != nil || !d.RotatedAt("www.example.com", 443).IsZero() {
+		t.Fatalf("unchanged certificate: event %+v", ev)
+	}
+
+	next := first
+	next.Fingerprint = "cc"
+	next.Issuer = "Corporate Proxy CA"
+	ev := d.Observe("www.example.com", 443, "cert-2", &next)
+	if ev == nil {
+		t.Fatal("no event for a changed fingerprint")
+	}
+	if ev.OldCertificateID != "cert-1" || ev.NewCertificateID != "cert-2" || ev.OldFingerprint != "aa" || ev.NewFingerprint != "cc" || ev.SNI != "www.example.com" {
+		t.Errorf("event = %+v", ev)
+	}
+	if !ev.Suspicious || !reflect.DeepEqual(ev.SuspiciousReasons, []string{ReasonIssuerChangedEarly}) {
+		t.Errorf("suspicious = %v %v", ev.Suspicious, ev.SuspiciousReasons)
+	}
+	if !reflect.DeepEqual(ev.Changes, []Change{{Field: "issuer", Old: "R11", New: "Corporate Proxy CA"}}) {
+		t.Errorf("changes = %+v", ev.Changes)
+	}
+	if !d.RotatedAt("www.example.com", 443).Equal(ev.DetectedAt) {
+		t.Errorf("RotatedAt = %s, want %s", d.RotatedAt("www.example.com", 443), ev.DetectedAt)
+	}
+	if d.Known("www.example.com", 8443) {
+		t.Error("other port known")
+	}
+}
+
+func TestCovers(t *testing.T) {
+	san := []string{"Example.com", "*.example.com"}
+	tests := map[string]bool{
+		"example.com":     true,
+		"WWW.example.com": true,
+		"a.b.example.com": false,
+		"example.org":     false,
+		".example.com":    false,
+	}
+	for host, want := range tests {
+		if got := Covers(san, host); got != want {
+			t.Errorf("Covers(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
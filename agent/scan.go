@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/inventory"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/metrics"
+	"github.com/zertifikat-waechter/agent/notify"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
+	"github.com/zertifikat-waechter/agent/scheduler"
+	"github.com/zertifikat-waechter/agent/sink"
+	"github.com/zertifikat-waechter/agent/supabase"
+)
+
+// agent bündelt die Abhängigkeiten der Scan-Läufe
+type agent struct {
+	cfg            *config.Config
+	client         backend
+	certScanner    *scanner.Scanner
+	networkScanner *scanner.NetworkScanner
+	progress       *supabase.ProgressReporter
+	outbox         *supabase.Outbox
+	assets         *lifecycle.Tracker
+	rotations      *rotation.Detector
+	schedule       *scheduler.Scheduler
+	metrics        *metrics.Registry
+	inventory      *inventory.Inventory
+	sinks          *sink.Dispatcher
+	notifier       *notify.Notifier // nil ohne NOTIFY_FILE
+
+	scanning atomic.Bool    // true solange ein Lauf aktiv ist
+	scans    sync.WaitGroup // laufende Scan-Goroutine (für den Shutdown)
+	lastRun  atomic.Pointer[scanrun.Summary]
+}
+
+// startScan startet Discovery und/oder den Scan der angegebenen Targets im Hintergrund.
+// Läuft noch ein Scan, wird nichts gestartet und false geliefert - Läufe überlappen nie.
+func (a *agent) startScan(ctx context.Context, snap *config.Snapshot, trigger scanrun.Trigger, targets []string, discovery bool) bool {
+	if !a.scanning.CompareAndSwap(false, true) {
+		return false
+	}
+
+	// Nur die fälligen Targets scannen, alle übrigen Settings aus dem Snapshot
+	scoped := *snap
+	scoped.ScanTargets = targets
+
+	a.scans.Add(1)
+	go func() {
+		defer a.scans.Done()
+		defer a.scanning.Store(false)
+
+		if discovery {
+			log.WithField("discovery_mode", snap.DiscoveryMode).Info("Running network discovery...")
+			a.runNetworkDiscovery(ctx, &scoped, trigger)
+		}
+		if len(targets) > 0 && ctx.Err() == nil {
+			a.runScan(ctx, &scoped, trigger)
+		}
+	}()
+	return true
+}
+
+// waitScans wartet, bis der laufende Scan beendet ist, höchstens bis ctx abläuft
+func (a *agent) waitScans(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		a.scans.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (a *agent) runScan(ctx context.Context, snap *config.Snapshot, trigger scanrun.Trigger) {
+	run := a.startRun(ctx, trigger, scanrun.ModeTargets, snap)
+	limits := scanLimits(snap)
+	log.WithFields(logrus.Fields{
+		"run_id":           run.ID(),
+		"trigger":          trigger,
+		"config_version":   snap.Version,
+		"concurrency":      limits.Global,
+		"host_concurrency": limits.PerHost,
+	}).Info("Starting certificate scan")
+
+	endpoints := make([]scanner.Endpoint, 0, len(snap.ScanTargets)*len(snap.ScanPorts))
+	for _, target := range snap.ScanTargets {
+		for _, port := range snap.ScanPorts {
+			endpoints = append(endpoints, scanner.Endpoint{Host: target, Port: port})
+		}
+	}
+
+	total := len(endpoints)
+	a.progress.Start(ctx, "targets", total)
+
+	var done atomic.Int64
+	results, started := scanner.RunPool(ctx, endpoints, limits, func(ctx context.Context, ep scanner.Endpoint) endpointResult {
+		log.WithFields(logrus.Fields{
+			"host": ep.Host,
+			"port": ep.Port,
+		}).Debug("Scanning target")
+
+		cert, outcome, err := a.probeEndpoint(ctx, run, ep.Host, ep.Port)
+
+		n := int(done.Add(1))
+		a.progress.Update(n, total, fmt.Sprintf("Scanne Targets: %d/%d", n, total))
+		return endpointResult{cert: cert, outcome: outcome, err: err}
+	})
+
+	// Auswertung in Target-Reihenfolge - unabhängig davon, welcher Scan zuerst fertig war
+	certs := make(map[string][]scheduler.CertTimes)
+	for i, ep := range endpoints {
+		if !started[i] {
+			continue
+		}
+		res := results[i]
+		recordOutcome(run, res.outcome)
+		if _, ok := certs[ep.Host]; !ok {
+			certs[ep.Host] = nil
+		}
+
+		if res.err != nil {
+			if errors.Is(res.err, context.Canceled) {
+				continue
+			}
+			log.WithFields(logrus.Fields{
+				"host":  ep.Host,
+				"port":  ep.Port,
+				"error": res.err,
+			}).Warn("Scan failed")
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"host":        ep.Host,
+			"port":        ep.Port,
+			"subject_cn":  res.cert.SubjectCN,
+			"fingerprint": res.cert.Fingerprint,
+			"not_after":   res.cert.NotAfter,
+			"asset_id":    res.outcome.AssetID,
+		}).Info("Certificate scanned and reported")
+
+		certs[ep.Host] = append(certs[ep.Host], scheduler.CertTimes{
+			NotBefore: res.cert.NotBefore,
+			NotAfter:  res.cert.NotAfter,
+			RotatedAt: a.rotations.RotatedAt(ep.Host, ep.Port),
+		})
+	}
+
+	// Nächsten Scan pro Target nach dem Ablaufdatum planen (nicht bei Abbruch)
+	if ctx.Err() == nil {
+		adaptSchedule(a.schedule, snap, certs, time.Now())
+	}
+
+	summary := a.finishRun(ctx, run, runStatus(ctx, scanrun.StatusCompleted))
+	a.progress.Finish(ctx, string(summary.Status))
+
+	log.WithFields(logrus.Fields{
+		"run_id":   summary.ID,
+		"success":  summary.EndpointsSucceeded,
+		"failed":   summary.EndpointsFailed,
+		"total":    summary.EndpointsTotal,
+		"duration": time.Since(summary.StartedAt).Round(time.Millisecond),
+	}).Info("Certificate scan completed")
+}
+
+// endpointResult ist das Ergebnis eines Endpoint-Scans aus dem Worker-Pool
+type endpointResult struct {
+	cert    *scanner.CertificateData
+	outcome scanrun.Outcome
+	err     error
+}
+
+// scanLimits liefert die Parallelität für Target-Scans; sequentiell heißt ein
+// Endpoint nach dem anderen in Konfigurationsreihenfolge
+func scanLimits(snap *config.Snapshot) scanner.PoolLimits {
+	if snap.ScanSequential {
+		return scanner.PoolLimits{Global: 1, PerHost: 1}
+	}
+	return scanner.PoolLimits{Global: snap.ScanConcurrency, PerHost: snap.ScanHostConcurrency}
+}
+
+// scanEndpoint scannt einen Endpoint, meldet Asset, Zertifikat und Check an das Backend
+// und hält das Ergebnis im Scan-Lauf fest
+func (a *agent) scanEndpoint(ctx context.Context, run *scanrun.Run, host string, port int) (*scanner.CertificateData, error) {
+	cert, outcome, err := a.probeEndpoint(ctx, run, host, port)
+	recordOutcome(run, outcome)
+	return cert, err
+}
+
+// recordOutcome hält ein Endpoint-Ergebnis im Scan-Lauf fest.
+// Abgebrochene Endpoints (Shutdown) zählen nicht als Fehlschlag.
+func recordOutcome(run *scanrun.Run, outcome scanrun.Outcome) {
+	if outcome.ErrorClass == string(scanner.ClassCancelled) {
+		return
+	}
+	run.Record(outcome)
+}
+
+// probeEndpoint scannt einen Endpoint und meldet Asset, Zertifikat und Check an das
+// Backend. Das Ergebnis wird nur zurückgegeben, nicht im Lauf festgehalten.
+func (a *agent) probeEndpoint(ctx context.Context, run *scanrun.Run, host string, port int) (*scanner.CertificateData, scanrun.Outcome, error) {
+	started := time.Now()
+	outcome := scanrun.Outcome{Host: host, Port: port}
+	cert, err := a.checkEndpoint(ctx, run, host, port, &outcome)
+	outcome.DurationMs = time.Since(started).Milliseconds()
+	outcome.ScannedAt = time.Now().UTC()
+	return cert, outcome, err
+}
+
+// checkEndpoint führt Scan und Meldungen für einen Endpoint aus und füllt outcome
+func (a *agent) checkEndpoint(ctx context.Context, run *scanrun.Run, host string, port int, outcome *scanrun.Outcome) (*scanner.CertificateData, error) {
+	cert, scanErr := a.certScanner.ScanHost(ctx, host, port)
+	if scanErr != nil {
+		recordScanError(outcome, scanErr)
+		if errors.Is(scanErr, context.Canceled) {
+			// Abbruch sagt nichts über den Endpoint aus
+			return nil, scanErr
+		}
+	}
+	a.metrics.ObserveEndpoint(host, port, cert, scanErr, time.Now())
+	a.inventory.ObserveEndpoint(host, port, cert, scanErr, time.Now())
+
+	// Lebenszyklus des Assets fortschreiben (wenn TenantID verfügbar)
+	if a.cfg.TenantID != "" && a.cfg.ConnectorID != "" {
+		outcome.AssetID = a.observeAsset(ctx, run, host, port, scanErr)
+	}
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	// Das Asset gehört zum Fundort, nicht zum Zertifikat - ein Zertifikat kann auf vielen
+	// Endpoints liegen, die Zuordnung steht nur in certificate_observations
+	assetID := outcome.AssetID
+	cert.TenantID = a.cfg.TenantID
+	cert.LastScanRunID = run.ID()
+
+	// Send certificate to Supabase
+	certID, err := a.client.UpsertCertificate(ctx, cert)
+	if err != nil {
+		outcome.ErrorClass = "backend_error"
+		outcome.ErrorMessage = err.Error()
+		return nil, fmt.Errorf("upsert certificate: %w", err)
+	}
+
+	outcome.Success = true
+	outcome.Fingerprint = cert.Fingerprint
+
+	observed := *cert
+	a.sinks.Emit(sink.KindCertificate, sink.TypeCertificateObserved, map[string]interface{}{
+		"host":           host,
+		"port":           port,
+		"sni":            cert.SNI,
+		"run_id":         run.ID(),
+		"asset_id":       assetID,
+		"certificate_id": certID,
+		"days_left":      daysLeft(cert.NotAfter),
+		"certificate":    &observed,
+	})
+
+	// Fundort festhalten - dasselbe Zertifikat kann auf vielen Endpoints liegen
+	observation := supabase.CertificateObservation{
+		CertificateID: certID,
+		AssetID:       assetID,
+		Host:          host,
+		Port:          port,
+		SNI:           cert.SNI,
+		LastSeenAt:    time.Now().UTC(),
+		LastScanRunID: run.ID(),
+	}
+	a.outbox.Enqueue("certificate_observation", func(ctx context.Context) error {
+		return a.client.UpsertCertificateObservation(ctx, observation)
+	})
+
+	// Zertifikatswechsel auf diesem Endpoint?
+	known := a.rotations.Known(host, port)
+	if event := a.rotations.Observe(host, port, certID, cert); event != nil {
+		a.reportRotation(ctx, run.ID(), assetID, *event)
+	}
+	a.emitCertificateEvents(run.ID(), certID, assetID, host, port, cert, known)
+
+	// Prüfergebnis mit Verweis auf den Lauf
+	check := newCheck(certID, run.ID(), assetID, host, port, cert)
+	a.outbox.Enqueue("check", func(ctx context.Context) error {
+		return a.client.InsertCheck(ctx, check)
+	})
+
+	return cert, nil
+}
+
+// observeAsset wertet das Scan-Ergebnis im Lebenszyklus aus, schreibt das Asset
+// und meldet Zustandswechsel. Liefert die Asset-ID ("" wenn kein Asset existiert).
+func (a *agent) observeAsset(ctx context.Context, run *scanrun.Run, host string, port int, scanErr error) string {
+	rec, transition := a.assets.Observe(host, port, lifecycleResult(scanErr))
+
+	// Konfigurierte Targets werden immer angelegt, bei der Discovery nur erfolgreich
+	// gescannte Endpoints - sonst würde jeder offene Nicht-TLS-Port zum Asset
+	var assetID string
+	var err error
+	if scanErr == nil || run.Mode() == scanrun.ModeTargets {
+		assetID, err = a.client.UpsertAsset(ctx, rec)
+	} else {
+		assetID, err = a.client.UpdateAssetStatus(ctx, rec)
+	}
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"host":  host,
+			"port":  port,
+			"error": err,
+		}).Warn("Failed to upsert asset (continuing without asset_id)")
+		return ""
+	}
+
+	a.assets.Attach(host, port, assetID)
+	a.sinks.Emit(sink.KindAsset, sink.TypeAssetUpdated, map[string]interface{}{
+		"asset_id":             assetID,
+		"run_id":               run.ID(),
+		"host":                 host,
+		"port":                 port,
+		"status":               rec.State,
+		"consecutive_failures": rec.ConsecutiveFailures,
+		"last_success_at":      rec.LastSuccessAt,
+		"last_checked_at":      rec.LastCheckedAt,
+		"last_error_class":     rec.LastErrorClass,
+		"last_error":           rec.LastError,
+	})
+	if transition != nil && assetID != "" {
+		transition.AssetID = assetID
+		a.reportTransition(ctx, run.ID(), *transition)
+	}
+	return assetID
+}
+
+// retireStale setzt Assets ohne Erfolg seit AssetRetireAfter auf retired
+func (a *agent) retireStale(ctx context.Context, runID string) {
+	for _, transition := range a.assets.Sweep(time.Now().UTC()) {
+		if err := a.client.SetAssetStatus(ctx, transition.AssetID, string(transition.To)); err != nil {
+			log.WithError(err).WithField("asset_id", transition.AssetID).Warn("Failed to retire asset")
+			continue
+		}
+		a.reportTransition(ctx, runID, transition)
+	}
+}
+
+// reportTransition meldet einen Zustandswechsel an Log, UI und Backend
+func (a *agent) reportTransition(ctx context.Context, runID string, transition lifecycle.Transition) {
+	log.WithFields(logrus.Fields{
+		"asset_id":             transition.AssetID,
+		"host":                 transition.Host,
+		"port":                 transition.Port,
+		"from":                 transition.From,
+		"to":                   transition.To,
+		"reason":               transition.Reason,
+		"consecutive_failures": transition.ConsecutiveFailures,
+	}).Info("Asset status changed")
+
+	level := "info"
+	if transition.To != lifecycle.StateActive {
+		level = "warn"
+	}
+	a.logUI(level, fmt.Sprintf("🔁 Asset %s:%d: %s → %s (%s)", transition.Host, transition.Port, transition.From, transition.To, transition.Reason), map[string]interface{}{
+		"asset_id":             transition.AssetID,
+		"from":                 transition.From,
+		"to":                   transition.To,
+		"reason":               transition.Reason,
+		"consecutive_failures": transition.ConsecutiveFailures,
+	})
+
+	a.sinks.Emit(sink.KindEvent, sink.TypeAssetTransition, map[string]interface{}{
+		"run_id":               runID,
+		"asset_id":             transition.AssetID,
+		"host":                 transition.Host,
+		"port":                 transition.Port,
+		"from":                 transition.From,
+		"to":                   transition.To,
+		"reason":               transition.Reason,
+		"consecutive_failures": transition.ConsecutiveFailures,
+		"last_success_at":      transition.LastSuccessAt,
+		"transitioned_at":      transition.At,
+	})
+	if transition.To == lifecycle.StateUnreachable {
+		a.emitUnreachable(runID, transition)
+	}
+
+	a.outbox.Enqueue("asset_transition", func(ctx context.Context) error {
+		return a.client.InsertAssetTransition(ctx, runID, transition)
+	})
+}
+
+// recordScanError überträgt Klasse und Details eines Scan-Fehlers in das Endpoint-Ergebnis
+func recordScanError(outcome *scanrun.Outcome, err error) {
+	var scanErr *scanner.ScanError
+	if !errors.As(err, &scanErr) {
+		outcome.ErrorClass = string(scanner.ClassUnknown)
+		outcome.ErrorMessage = err.Error()
+		return
+	}
+
+	outcome.ErrorClass = string(scanErr.Class)
+	outcome.ErrorMessage = scanErr.Detail()
+	outcome.ErrorPhase = string(scanErr.Phase)
+	if scanErr.Alert != "" {
+		code := scanErr.AlertCode
+		outcome.TLSAlertCode = &code
+		outcome.TLSAlert = scanErr.Alert
+	}
+}
+
+// reportRotation meldet einen Zertifikatswechsel an Log, UI und Backend
+func (a *agent) reportRotation(ctx context.Context, runID, assetID string, event rotation.Event) {
+	fields := make([]string, 0, len(event.Changes))
+	for _, change := range event.Changes {
+		fields = append(fields, change.Field)
+	}
+
+	entry := log.WithFields(logrus.Fields{
+		"host":            event.Host,
+		"port":            event.Port,
+		"old_fingerprint": event.OldFingerprint,
+		"new_fingerprint": event.NewFingerprint,
+		"changed_fields":  fields,
+		"suspicious":      event.Suspicious,
+		"reasons":         event.SuspiciousReasons,
+	})
+
+	level := "info"
+	message := fmt.Sprintf("🔄 Zertifikat gewechselt auf %s:%d (geändert: %s)", event.Host, event.Port, strings.Join(fields, ", "))
+	if event.Suspicious {
+		level = "warn"
+		message = fmt.Sprintf("⚠️ Verdächtiger Zertifikatswechsel auf %s:%d: %s", event.Host, event.Port, strings.Join(event.SuspiciousReasons, ", "))
+		entry.Warn("Suspicious certificate change detected")
+	} else {
+		entry.Info("Certificate rotation detected")
+	}
+
+	a.logUI(level, message, map[string]interface{}{
+		"host":               event.Host,
+		"port":               event.Port,
+		"old_fingerprint":    event.OldFingerprint,
+		"new_fingerprint":    event.NewFingerprint,
+		"changes":            event.Changes,
+		"suspicious":         event.Suspicious,
+		"suspicious_reasons": event.SuspiciousReasons,
+	})
+
+	a.sinks.Emit(sink.KindEvent, sink.TypeCertificateChange, map[string]interface{}{
+		"run_id":   runID,
+		"asset_id": assetID,
+		"change":   event,
+	})
+
+	a.outbox.Enqueue("certificate_change", func(ctx context.Context) error {
+		return a.client.InsertCertificateChange(ctx, runID, assetID, event)
+	})
+}
+
+// lifecycleResult übersetzt das Scan-Ergebnis für den Asset-Lebenszyklus
+func lifecycleResult(err error) lifecycle.Result {
+	res := lifecycle.Result{Success: err == nil, At: time.Now().UTC()}
+	if err == nil {
+		return res
+	}
+
+	res.ErrorClass = string(scanner.ClassUnknown)
+	res.Error = err.Error()
+
+	var scanErr *scanner.ScanError
+	if errors.As(err, &scanErr) {
+		res.ErrorClass = string(scanErr.Class)
+		res.Error = scanErr.Detail()
+		res.Unreachable = scanErr.Unreachable()
+	}
+	return res
+}
+
+// newCheck bewertet ein Zertifikat für die checks-Tabelle
+func newCheck(certID, runID, assetID, host string, port int, cert *scanner.CertificateData) *supabase.CheckData {
+	daysLeft := int(math.Floor(time.Until(cert.NotAfter).Hours() / 24))
+
+	status := "success"
+	switch {
+	case daysLeft < 0:
+		status = "expired"
+	case daysLeft < 30:
+		status = "warning"
+	}
+
+	return &supabase.CheckData{
+		CertificateID: certID,
+		ScanRunID:     runID,
+		AssetID:       assetID,
+		Status:        status,
+		RanAt:         time.Now().UTC(),
+		Details: map[string]interface{}{
+			"host":        host,
+			"port":        port,
+			"fingerprint": cert.Fingerprint,
+			"not_after":   cert.NotAfter,
+			"days_left":   daysLeft,
+		},
+	}
+}
+
+// startRun legt einen Scan-Lauf an und meldet ihn dem Backend
+func (a *agent) startRun(ctx context.Context, trigger scanrun.Trigger, mode scanrun.Mode, snap *config.Snapshot) *scanrun.Run {
+	run := scanrun.New(trigger, mode, snap.Version)
+	run.SetOwner(a.cfg.TenantID, a.cfg.ConnectorID)
+
+	if err := a.client.CreateScanRun(ctx, run.Summary()); err != nil {
+		log.WithError(err).WithField("run_id", run.ID()).Warn("Failed to create scan run")
+	}
+	return run
+}
+
+// finishRun schließt einen Scan-Lauf ab und schreibt Statistiken und Endpoint-Ergebnisse.
+// Die Schreibzugriffe laufen über die Outbox - auch bei abgebrochenem ctx (Shutdown).
+func (a *agent) finishRun(ctx context.Context, run *scanrun.Run, status scanrun.Status) scanrun.Summary {
+	run.Finish(status)
+	summary := run.Summary()
+	outcomes := run.Outcomes()
+	a.metrics.ObserveRun(summary)
+	a.lastRun.Store(&summary)
+	a.inventory.AddRun(summary)
+	a.sinks.Emit(sink.KindEvent, sink.TypeScanRunFinished, summary)
+
+	a.outbox.Enqueue("scan_run", func(ctx context.Context) error {
+		return a.client.FinishScanRun(ctx, summary)
+	})
+	a.outbox.Enqueue("scan_outcomes", func(ctx context.Context) error {
+		return a.client.InsertScanOutcomes(ctx, summary.ID, outcomes)
+	})
+
+	if ctx.Err() == nil {
+		a.retireStale(ctx, summary.ID)
+	}
+	return summary
+}
+
+// logUI schickt eine Meldung an das Agent-Log der UI (über die Outbox, blockiert nie)
+// und an die Sinks
+func (a *agent) logUI(level, message string, metadata map[string]interface{}) {
+	a.sinks.EmitLog(level, message, metadata)
+	a.outbox.Enqueue("agent_log", func(ctx context.Context) error {
+		return a.client.SendLog(ctx, a.cfg.ConnectorName, level, message, metadata)
+	})
+}
+
+// runStatus liefert den Endstatus eines Laufs: cancelled, wenn ctx abgebrochen wurde
+func runStatus(ctx context.Context, status scanrun.Status) scanrun.Status {
+	if ctx.Err() != nil {
+		return scanrun.StatusCancelled
+	}
+	return status
+}
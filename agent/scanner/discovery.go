@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -19,8 +21,12 @@ type DiscoveryResult struct {
 }
 
 type NetworkScanner struct {
-	timeout time.Duration
+	timeout atomic.Int64 // time.Duration, zur Laufzeit änderbar
 	log     *logrus.Logger
+
+	hostConcurrency atomic.Int64 // Parallel geprüfte Hosts
+	portConcurrency atomic.Int64 // Parallel geprüfte Ports pro Host
+	limiter         rateLimiter
 }
 
 // ScanPriority für intelligente Scan-Reihenfolge
@@ -42,30 +48,63 @@ type HostProfile struct {
 }
 
 func NewNetworkScanner(timeout time.Duration, log *logrus.Logger) *NetworkScanner {
-	return &NetworkScanner{
-		timeout: timeout,
-		log:     log,
+	ns := &NetworkScanner{
+		log: log,
 	}
+	ns.SetTimeout(timeout)
+	ns.SetLimits(100, 10, 0)
+	return ns
+}
+
+// SetTimeout ändert den Port-Timeout für folgende Scans
+func (ns *NetworkScanner) SetTimeout(timeout time.Duration) {
+	ns.timeout.Store(int64(timeout))
+}
+
+// SetLimits setzt Parallelität und Verbindungsrate für folgende Scans
+func (ns *NetworkScanner) SetLimits(hostConcurrency, portConcurrency, connectionsPerSecond int) {
+	ns.hostConcurrency.Store(int64(hostConcurrency))
+	ns.portConcurrency.Store(int64(portConcurrency))
+	ns.limiter.SetRate(connectionsPerSecond)
 }
 
 // DiscoverLocalNetwork scannt ALLE lokalen Netzwerke nach Hosts mit Hacker-Intelligenz
 func (ns *NetworkScanner) DiscoverLocalNetwork(ctx context.Context, progressCallback func(current, total int)) ([]DiscoveryResult, error) {
-	results := []DiscoveryResult{}
-	mu := &sync.Mutex{}
-	
 	// Hole ALLE lokalen Netzwerke mit intelligenter CIDR-Erkennung
 	networkInfos, err := getLocalNetworksWithCIDR()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get local networks: %w", err)
 	}
 
+	return ns.discover(ctx, networkInfos, progressCallback)
+}
+
+// DiscoverCIDR scannt die angegebenen Netze (z.B. "10.0.1.0/24") statt der lokalen Interfaces
+func (ns *NetworkScanner) DiscoverCIDR(ctx context.Context, cidrs []string, progressCallback func(current, total int)) ([]DiscoveryResult, error) {
+	networkInfos := make([]NetworkInfo, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		netInfo, err := networkFromCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networkInfos = append(networkInfos, netInfo)
+	}
+
+	return ns.discover(ctx, networkInfos, progressCallback)
+}
+
+// discover prüft alle IPs der Netze: erst ein schneller Scan, dann ein Deep Scan interessanter Hosts
+func (ns *NetworkScanner) discover(ctx context.Context, networkInfos []NetworkInfo, progressCallback func(current, total int)) ([]DiscoveryResult, error) {
+	results := []DiscoveryResult{}
+	mu := &sync.Mutex{}
+
 	ns.log.WithFields(logrus.Fields{
 		"networks_found": len(networkInfos),
 		"networks":       networkInfos,
 	}).Info("🧠 Starting INTELLIGENT network discovery (Hacker-Mode)")
 
 	// Scanne mit intelligenter Priorisierung (parallel, aber limitiert)
-	sem := make(chan struct{}, 100) // Max 100 parallel
+	sem := make(chan struct{}, ns.hostConcurrency.Load()) // Max parallele Hosts (Default 100)
 	var wg sync.WaitGroup
 	scanned := 0
 	total := 0
@@ -92,6 +131,9 @@ func (ns *NetworkScanner) DiscoverLocalNetwork(ctx context.Context, progressCall
 		quickMu := &sync.Mutex{}
 
 		for idx, ip := range netInfo.ScanIPs {
+			if ctx.Err() != nil {
+				break
+			}
 			wg.Add(1)
 			go func(targetIP string, index int) {
 				defer wg.Done()
@@ -107,7 +149,7 @@ func (ns *NetworkScanner) DiscoverLocalNetwork(ctx context.Context, progressCall
 				}()
 
 				// Quick Alive-Check
-				if !ns.isHostAlive(ctx, targetIP) {
+				if ctx.Err() != nil || !ns.isHostAlive(ctx, targetIP) {
 					return
 				}
 
@@ -133,6 +175,10 @@ func (ns *NetworkScanner) DiscoverLocalNetwork(ctx context.Context, progressCall
 		ns.log.WithField("hosts_found", len(quickResults)).Info("🔬 Starting DEEP scan for interesting hosts...")
 
 		for ip, quickResult := range quickResults {
+			if ctx.Err() != nil {
+				break
+			}
+
 			// Erkenne OS-Typ
 			osType := detectOSType(quickResult.OpenPorts, quickResult.Services)
 			
@@ -172,6 +218,11 @@ func (ns *NetworkScanner) DiscoverLocalNetwork(ctx context.Context, progressCall
 	}
 
 	wg.Wait()
+
+	// Abgebrochen (z.B. Shutdown): bisherige Funde zurückgeben
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
 	
 	if progressCallback != nil {
 		progressCallback(total, total) // 100%
@@ -192,9 +243,13 @@ func (ns *NetworkScanner) isHostAlive(ctx context.Context, ip string) bool {
 	quickPorts := []int{80, 443, 22, 3389, 445, 8080, 8443, 21, 25, 23} // HTTP, HTTPS, SSH, RDP, SMB, Alt-HTTP, FTP, SMTP, Telnet
 	
 	for _, port := range quickPorts {
-		address := fmt.Sprintf("%s:%d", ip, port)
+		if err := ns.limiter.Wait(ctx); err != nil {
+			return false
+		}
+		address := net.JoinHostPort(ip, strconv.Itoa(port))
 		// Schnellerer Timeout für Alive-Check (300ms statt 500ms)
-		conn, err := net.DialTimeout("tcp", address, 300*time.Millisecond)
+		dialer := net.Dialer{Timeout: 300 * time.Millisecond}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
 		if err == nil {
 			conn.Close()
 			return true
@@ -250,7 +305,7 @@ func (ns *NetworkScanner) scanHostWithPorts(ctx context.Context, ip string, port
 	startTime := time.Now()
 	
 	// Scanne Ports parallel
-	sem := make(chan struct{}, 10) // Max 10 parallel für Deep Scan
+	sem := make(chan struct{}, ns.portConcurrency.Load()) // Max parallele Ports (Default 10)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
@@ -261,7 +316,7 @@ func (ns *NetworkScanner) scanHostWithPorts(ctx context.Context, ip string, port
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			if ns.isPortOpen(ip, p) {
+			if ns.isPortOpen(ctx, ip, p) {
 				service := identifyService(p)
 				
 				mu.Lock()
@@ -291,9 +346,13 @@ func contains(slice []string, item string) bool {
 }
 
 // isPortOpen prüft ob Port offen ist
-func (ns *NetworkScanner) isPortOpen(ip string, port int) bool {
-	address := fmt.Sprintf("%s:%d", ip, port)
-	conn, err := net.DialTimeout("tcp", address, ns.timeout)
+func (ns *NetworkScanner) isPortOpen(ctx context.Context, ip string, port int) bool {
+	if err := ns.limiter.Wait(ctx); err != nil {
+		return false
+	}
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: time.Duration(ns.timeout.Load())}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		return false
 	}
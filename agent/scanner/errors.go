@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+)
+
+// ErrorClass ordnet einen Scan-Fehler einer Ursache zu
+type ErrorClass string
+
+const (
+	ClassDNS                ErrorClass = "dns"                  // Hostname nicht auflösbar
+	ClassConnectionRefused  ErrorClass = "connection_refused"   // Port geschlossen
+	ClassHostUnreachable    ErrorClass = "host_unreachable"     // keine Route zum Host
+	ClassTimeout            ErrorClass = "timeout"              // Verbindungsaufbau oder Handshake zu langsam
+	ClassConnectionReset    ErrorClass = "connection_reset"     // Gegenstelle hat die Verbindung abgebrochen
+	ClassTLSAlert           ErrorClass = "tls_alert"            // Server hat den Handshake mit einem Alert beendet
+	ClassNotTLS             ErrorClass = "not_tls"              // Dienst spricht kein TLS
+	ClassProtocolVersion    ErrorClass = "protocol_version"     // keine gemeinsame TLS-Version
+	ClassClientCertRequired ErrorClass = "client_cert_required" // Server verlangt ein Client-Zertifikat
+	ClassSNIRejected        ErrorClass = "sni_rejected"         // Server kennt den Hostnamen (SNI) nicht
+	ClassHandshake          ErrorClass = "handshake_failed"     // sonstiger Fehler im Handshake
+	ClassNoCertificate      ErrorClass = "no_certificate"       // Handshake ohne Zertifikat
+	ClassCancelled          ErrorClass = "cancelled"            // Scan abgebrochen (Shutdown)
+	ClassUnknown            ErrorClass = "unknown"
+)
+
+// Phase gibt an, in welchem Schritt des Scans der Fehler aufgetreten ist
+type Phase string
+
+const (
+	PhaseConnect   Phase = "connect"
+	PhaseHandshake Phase = "handshake"
+)
+
+// TLS-Alert-Codes (RFC 8446, Abschnitt 6)
+const (
+	alertHandshakeFailure    = 40
+	alertBadCertificate      = 42
+	alertProtocolVersion     = 70
+	alertUnrecognizedName    = 112
+	alertCertificateRequired = 116
+)
+
+var alertNames = map[int]string{
+	0:   "close_notify",
+	10:  "unexpected_message",
+	20:  "bad_record_mac",
+	22:  "record_overflow",
+	40:  "handshake_failure",
+	42:  "bad_certificate",
+	43:  "unsupported_certificate",
+	44:  "certificate_revoked",
+	45:  "certificate_expired",
+	46:  "certificate_unknown",
+	47:  "illegal_parameter",
+	48:  "unknown_ca",
+	49:  "access_denied",
+	50:  "decode_error",
+	51:  "decrypt_error",
+	70:  "protocol_version",
+	71:  "insufficient_security",
+	80:  "internal_error",
+	86:  "inappropriate_fallback",
+	90:  "user_canceled",
+	109: "missing_extension",
+	110: "unsupported_extension",
+	112: "unrecognized_name",
+	113: "bad_certificate_status_response",
+	115: "unknown_psk_identity",
+	116: "certificate_required",
+	120: "no_application_protocol",
+}
+
+// ScanError ist ein klassifizierter Fehler von ScanHost
+type ScanError struct {
+	Class     ErrorClass
+	Phase     Phase
+	AlertCode int    // TLS-Alert-Code, nur gültig wenn Alert gesetzt ist
+	Alert     string // Name des TLS-Alerts, leer wenn kein Alert empfangen wurde
+	Err       error  // ursprünglicher Fehler
+}
+
+func (e *ScanError) Error() string {
+	if e.Alert != "" {
+		return fmt.Sprintf("%s (alert %d %s): %v", e.Class, e.AlertCode, e.Alert, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Class, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Detail liefert die ursprüngliche Fehlermeldung
+func (e *ScanError) Detail() string {
+	if e.Err == nil {
+		return ""
+	}
+	return e.Err.Error()
+}
+
+// Unreachable meldet, ob der Endpoint gar nicht erreichbar war (Host down, Port zu)
+// - im Gegensatz zu einem Problem mit TLS oder dem Zertifikat
+func (e *ScanError) Unreachable() bool {
+	return e.Phase == PhaseConnect && e.Class != ClassCancelled
+}
+
+// classifyError ordnet einen Fehler aus Verbindungsaufbau oder Handshake einer Klasse zu.
+// certRequested gibt an, ob der Server im Handshake ein Client-Zertifikat angefordert hat.
+func classifyError(phase Phase, err error, certRequested bool) *ScanError {
+	se := &ScanError{Class: ClassUnknown, Phase: phase, Err: err}
+
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		se.Class = ClassCancelled
+	case errors.As(err, &dnsErr):
+		se.Class = ClassDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		se.Class = ClassConnectionRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		se.Class = ClassHostUnreachable
+	case isTimeout(err):
+		se.Class = ClassTimeout
+	case errors.As(err, &recordErr):
+		se.Class = ClassNotTLS
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		code, ok := alertCode(opErr.Err)
+		if !ok {
+			se.Class = ClassTLSAlert
+			break
+		}
+		se.AlertCode = code
+		se.Alert = alertName(code)
+		se.Class = classifyAlert(code, certRequested)
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		se.Class = ClassConnectionReset
+	case isVersionMismatch(err):
+		se.Class = ClassProtocolVersion
+	case phase == PhaseHandshake:
+		se.Class = ClassHandshake
+	}
+
+	return se
+}
+
+// classifyAlert leitet aus dem Alert-Code die Ursache ab
+func classifyAlert(code int, certRequested bool) ErrorClass {
+	switch code {
+	case alertProtocolVersion:
+		return ClassProtocolVersion
+	case alertUnrecognizedName:
+		return ClassSNIRejected
+	case alertCertificateRequired:
+		return ClassClientCertRequired
+	case alertHandshakeFailure, alertBadCertificate:
+		// TLS 1.2: Server bricht nach leerem Client-Zertifikat mit einem dieser Alerts ab
+		if certRequested {
+			return ClassClientCertRequired
+		}
+	}
+	return ClassTLSAlert
+}
+
+// alertCode liest den Code aus einem TLS-Alert. crypto/tls exportiert den Typ nicht,
+// er ist aber ein uint8.
+func alertCode(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+	v := reflect.ValueOf(err)
+	if v.Kind() != reflect.Uint8 {
+		return 0, false
+	}
+	return int(v.Uint()), true
+}
+
+func alertName(code int) string {
+	if name, ok := alertNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("alert_%d", code)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isVersionMismatch erkennt lokale Fehler von crypto/tls, wenn keine gemeinsame Version existiert
+func isVersionMismatch(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "unsupported protocol version") ||
+		strings.Contains(msg, "no supported versions") ||
+		strings.Contains(msg, "protocol version not supported")
+}
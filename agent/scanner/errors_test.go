@@ -0,0 +1,283 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestClassifyError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	unreachable := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	dnsErr := &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "missing.invalid", IsNotFound: true}}
+	dialTimeout := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	remote := func(code uint8) error {
+		return &net.OpError{Op: "remote error", Err: tls.AlertError(code)}
+	}
+
+	tests := []struct {
+		name          string
+		phase         Phase
+		err           error
+		certRequested bool
+		want          ErrorClass
+		wantAlert     string
+	}{
+		{"cancelled", PhaseConnect, fmt.Errorf("dial: %w", context.Canceled), false, ClassCancelled, ""},
+		{"dns", PhaseConnect, dnsErr, false, ClassDNS, ""},
+		{"dns timeout", PhaseConnect, &net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}, false, ClassDNS, ""},
+		{"connection refused", PhaseConnect, refused, false, ClassConnectionRefused, ""},
+		{"host unreachable", PhaseConnect, unreachable, false, ClassHostUnreachable, ""},
+		{"dial timeout", PhaseConnect, dialTimeout, false, ClassTimeout, ""},
+		{"handshake deadline", PhaseHandshake, context.DeadlineExceeded, false, ClassTimeout, ""},
+		{"not tls", PhaseHandshake, tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, false, ClassNotTLS, ""},
+		{"alert protocol_version", PhaseHandshake, remote(70), false, ClassProtocolVersion, "protocol_version"},
+		{"alert unrecognized_name", PhaseHandshake, remote(112), false, ClassSNIRejected, "unrecognized_name"},
+		{"alert certificate_required", PhaseHandshake, remote(116), false, ClassClientCertRequired, "certificate_required"},
+		{"alert handshake_failure after cert request", PhaseHandshake, remote(40), true, ClassClientCertRequired, "handshake_failure"},
+		{"alert bad_certificate after cert request", PhaseHandshake, remote(42), true, ClassClientCertRequired, "bad_certificate"},
+		{"alert handshake_failure", PhaseHandshake, remote(40), false, ClassTLSAlert, "handshake_failure"},
+		{"alert internal_error", PhaseHandshake, remote(80), false, ClassTLSAlert, "internal_error"},
+		{"unknown alert", PhaseHandshake, remote(200), false, ClassTLSAlert, "alert_200"},
+		{"remote error without code", PhaseHandshake, &net.OpError{Op: "remote error", Err: errors.New("tls: something")}, false, ClassTLSAlert, ""},
+		{"connection reset", PhaseHandshake, reset, false, ClassConnectionReset, ""},
+		{"eof", PhaseHandshake, io.EOF, false, ClassConnectionReset, ""},
+		{"no common version", PhaseHandshake, errors.New("tls: no supported versions satisfy MinVersion and MaxVersion"), false, ClassProtocolVersion, ""},
+		{"other handshake error", PhaseHandshake, errors.New("tls: unexpected message"), false, ClassHandshake, ""},
+		{"other connect error", PhaseConnect, errors.New("socket: too many open files"), false, ClassUnknown, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			se := classifyError(tt.phase, tt.err, tt.certRequested)
+			if se.Class != tt.want || se.Alert != tt.wantAlert {
+				t.Errorf("class = %s (alert %q), want %s (alert %q)", se.Class, se.Alert, tt.want, tt.wantAlert)
+			}
+			if se.Phase != tt.phase || !errors.Is(se, tt.err) {
+				t.Errorf("phase = %s, err = %v", se.Phase, se.Err)
+			}
+		})
+	}
+}
+
+func TestAlertCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   int
+		wantOK bool
+	}{
+		{"alert error", tls.AlertError(112), 112, true},
+		{"nil", nil, 0, false},
+		{"plain error", errors.New("remote error: tls: unrecognized name"), 0, false},
+		{"pointer", &net.OpError{Op: "remote error"}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := alertCode(tt.err)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: alertCode = %d, %v, want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// TestScanErrorFormat prüft Fehlermeldung und Unreachable
+func TestScanErrorFormat(t *testing.T) {
+	alert := classifyError(PhaseHandshake, &net.OpError{Op: "remote error", Err: tls.AlertError(112)}, false)
+	if got := alert.Error(); got != "sni_rejected (alert 112 unrecognized_name): remote error: tls: unrecognized name" {
+		t.Errorf("Error() = %q", got)
+	}
+	refused := classifyError(PhaseConnect, syscall.ECONNREFUSED, false)
+	if got := refused.Error(); got != "connection_refused: connection refused" {
+		t.Errorf("Error() = %q", got)
+	}
+	if refused.Detail() != "connection refused" || (&ScanError{}).Detail() != "" {
+		t.Errorf("Detail() = %q", refused.Detail())
+	}
+
+	tests := []struct {
+		se   *ScanError
+		want bool
+	}{
+		{refused, true},
+		{classifyError(PhaseConnect, context.DeadlineExceeded, false), true},
+		{classifyError(PhaseConnect, context.Canceled, false), false},
+		{classifyError(PhaseHandshake, context.DeadlineExceeded, false), false},
+		{alert, false},
+	}
+	for _, tt := range tests {
+		if got := tt.se.Unreachable(); got != tt.want {
+			t.Errorf("%s/%s: Unreachable = %v, want %v", tt.se.Phase, tt.se.Class, got, tt.want)
+		}
+	}
+}
+
+// rawServer nimmt Verbindungen an und übergibt sie an handle
+func rawServer(t *testing.T, handle func(net.Conn)) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// tlsConfigServer beantwortet Handshakes mit config
+func tlsConfigServer(t *testing.T, config *tls.Config) int {
+	t.Helper()
+	return rawServer(t, func(conn net.Conn) {
+		tls.Server(conn, config).Handshake()
+	})
+}
+
+// closedPort liefert einen Port, auf dem niemand lauscht
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// TestScanHostErrors klassifiziert Fehler echter Verbindungen
+func TestScanHostErrors(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	cert := ca.leaf(t, 2)
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	silent := rawServer(t, func(conn net.Conn) {
+		// Verbindung annehmen, aber nie antworten
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		io.Copy(io.Discard, conn)
+	})
+	ssh := rawServer(t, func(conn net.Conn) {
+		io.WriteString(conn, "SSH-2.0-OpenSSH_9.6\r\n")
+		io.Copy(io.Discard, conn)
+	})
+	hangup := rawServer(t, func(net.Conn) {})
+	tls13 := tlsConfigServer(t, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13})
+	clientAuth := tlsConfigServer(t, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		MaxVersion:   tls.VersionTLS12,
+	})
+	failing := tlsConfigServer(t, &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return nil, errors.New("no certificate for this name")
+		},
+	})
+
+	tests := []struct {
+		name      string
+		host      string
+		port      int
+		version   uint16
+		want      ErrorClass
+		wantPhase Phase
+		wantAlert string
+	}{
+		{"connection refused", "127.0.0.1", closedPort(t), 0, ClassConnectionRefused, PhaseConnect, ""},
+		{"dns failure", "does-not-exist.invalid", 443, 0, ClassDNS, PhaseConnect, ""},
+		{"handshake timeout", "127.0.0.1", silent, 0, ClassTimeout, PhaseHandshake, ""},
+		{"not tls", "127.0.0.1", ssh, 0, ClassNotTLS, PhaseHandshake, ""},
+		{"connection closed", "127.0.0.1", hangup, 0, ClassConnectionReset, PhaseHandshake, ""},
+		{"protocol version alert", "127.0.0.1", tls13, tls.VersionTLS12, ClassProtocolVersion, PhaseHandshake, "protocol_version"},
+		{"client certificate required", "127.0.0.1", clientAuth, 0, ClassClientCertRequired, PhaseHandshake, "handshake_failure"},
+		{"other alert", "127.0.0.1", failing, 0, ClassTLSAlert, PhaseHandshake, "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(300*time.Millisecond, log)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := s.handshake(ctx, tt.host, tt.port, tt.version)
+			var se *ScanError
+			if !errors.As(err, &se) {
+				t.Fatalf("handshake error = %v, want *ScanError", err)
+			}
+			if se.Class != tt.want || se.Phase != tt.wantPhase || se.Alert != tt.wantAlert {
+				t.Errorf("got %s/%s (alert %q), want %s/%s (alert %q): %v",
+					se.Phase, se.Class, se.Alert, tt.wantPhase, tt.want, tt.wantAlert, se.Err)
+			}
+		})
+	}
+}
+
+// TestScanHostHostnameMismatch: ein Zertifikat für einen anderen Namen ist kein Scan-Fehler,
+// sondern ein Befund der Bewertung
+func TestScanHostHostnameMismatch(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "other.example"},
+		DNSNames:     []string{"other.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := tlsServer(t, tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key})
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	s := NewScanner(5*time.Second, log)
+	cert, err := s.ScanHost(context.Background(), "127.0.0.1", port)
+	if err != nil {
+		t.Fatalf("ScanHost = %v, want no error for a hostname mismatch", err)
+	}
+	if cert.SubjectCN != "other.example" || cert.SNI != "" {
+		t.Errorf("certificate = %s (SNI %q)", cert.SubjectCN, cert.SNI)
+	}
+
+	insp, err := s.Inspect(context.Background(), "127.0.0.1", port)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	found := false
+	for _, f := range insp.Findings {
+		found = found || f.Code == "hostname_mismatch"
+	}
+	if !found {
+		t.Errorf("findings = %+v, want hostname_mismatch", insp.Findings)
+	}
+}
@@ -0,0 +1,288 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity ist die Schwere eines Befunds
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+	SeverityInfo     Severity = "info"
+)
+
+// severityRank ordnet die Schweregrade (höher = schwerer)
+var severityRank = map[Severity]int{
+	SeverityInfo:     1,
+	SeverityLow:      2,
+	SeverityMedium:   3,
+	SeverityHigh:     4,
+	SeverityCritical: 5,
+}
+
+// ParseSeverity liest einen Schweregrad ("none" = kein Befund ist schwer genug)
+func ParseSeverity(value string) (Severity, error) {
+	s := Severity(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := severityRank[s]; ok || s == "none" {
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid severity %q (critical, high, medium, low, info, none)", value)
+}
+
+// AtLeast meldet, ob s mindestens so schwer wie min ist
+func (s Severity) AtLeast(min Severity) bool {
+	rank, ok := severityRank[min]
+	return ok && severityRank[s] >= rank
+}
+
+// Finding ist ein Befund zu einem Endpoint
+type Finding struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// ChainCertificate beschreibt ein Zertifikat der ausgelieferten Chain
+type ChainCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	KeyAlgorithm string    `json:"key_alg"`
+	KeySize      int       `json:"key_size,omitempty"`
+	SignatureAlg string    `json:"signature_algorithm"`
+	IsCA         bool      `json:"is_ca"`
+	Fingerprint  string    `json:"fingerprint"`
+}
+
+// ProtocolSupport gibt an, ob der Server eine TLS-Version annimmt
+type ProtocolSupport struct {
+	Version     string `json:"version"`
+	Supported   bool   `json:"supported"`
+	CipherSuite string `json:"cipher_suite,omitempty"`
+}
+
+// Inspection ist die ausführliche Analyse eines Endpoints
+type Inspection struct {
+	Host              string             `json:"host"`
+	Port              int                `json:"port"`
+	Certificate       *CertificateData   `json:"certificate"`
+	Chain             []ChainCertificate `json:"chain"`
+	NegotiatedVersion string             `json:"negotiated_version"`
+	CipherSuite       string             `json:"cipher_suite"`
+	Protocols         []ProtocolSupport  `json:"protocols"`
+	Findings          []Finding          `json:"findings"`
+	InspectedAt       time.Time          `json:"inspected_at"`
+}
+
+// inspectedVersions werden einzeln geprüft (älteste zuerst)
+var inspectedVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// Inspect liest Zertifikat und Chain eines Endpoints, prüft die unterstützten
+// TLS-Versionen und bewertet das Ergebnis. Fehler wie bei ScanHost.
+func (s *Scanner) Inspect(ctx context.Context, host string, port int) (*Inspection, error) {
+	connState, err := s.handshake(ctx, host, port, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	insp := &Inspection{
+		Host:              host,
+		Port:              port,
+		Certificate:       certificateData(host, connState),
+		NegotiatedVersion: tls.VersionName(connState.Version),
+		CipherSuite:       tls.CipherSuiteName(connState.CipherSuite),
+		InspectedAt:       time.Now().UTC(),
+	}
+	for _, cert := range connState.PeerCertificates {
+		insp.Chain = append(insp.Chain, chainCertificate(cert))
+	}
+
+	for _, version := range inspectedVersions {
+		support := ProtocolSupport{Version: tls.VersionName(version)}
+		state, err := s.handshake(ctx, host, port, version)
+		if err == nil {
+			support.Supported = true
+			support.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		} else if ctx.Err() != nil {
+			return nil, err
+		}
+		insp.Protocols = append(insp.Protocols, support)
+	}
+
+	insp.Findings = Assess(host, connState.PeerCertificates, insp.Protocols, insp.InspectedAt)
+	return insp, nil
+}
+
+// Assess bewertet Chain und unterstützte Protokolle eines Endpoints.
+// protocols darf leer sein (dann werden keine Protokoll-Befunde erzeugt).
+func Assess(host string, chain []*x509.Certificate, protocols []ProtocolSupport, now time.Time) []Finding {
+	if len(chain) == 0 {
+		return nil
+	}
+	leaf := chain[0]
+	var findings []Finding
+	add := func(code string, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{Code: code, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Laufzeit
+	daysLeft := int(leaf.NotAfter.Sub(now).Hours() / 24)
+	switch {
+	case now.After(leaf.NotAfter):
+		add("expired", SeverityCritical, "certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))
+	case daysLeft < 7:
+		add("expires_soon", SeverityHigh, "certificate expires in %d days", daysLeft)
+	case daysLeft < 30:
+		add("expires_soon", SeverityMedium, "certificate expires in %d days", daysLeft)
+	}
+	if now.Before(leaf.NotBefore) {
+		add("not_yet_valid", SeverityHigh, "certificate is not valid before %s", leaf.NotBefore.Format("2006-01-02"))
+	}
+	if leaf.NotAfter.Sub(leaf.NotBefore) > 398*24*time.Hour {
+		add("long_validity", SeverityLow, "validity of %d days exceeds the 398 days accepted by browsers", int(leaf.NotAfter.Sub(leaf.NotBefore).Hours()/24))
+	}
+
+	// Vertrauen und Hostname
+	switch {
+	case isSelfSigned(leaf):
+		add("self_signed", SeverityHigh, "certificate is self-signed")
+	case !verifyChain(chain):
+		if len(chain) == 1 {
+			add("chain_incomplete", SeverityHigh, "server sends no intermediate certificates and the chain cannot be verified")
+		} else {
+			add("untrusted", SeverityHigh, "chain does not end at a trusted root (issuer %q)", chain[len(chain)-1].Issuer.CommonName)
+		}
+	}
+	if err := leaf.VerifyHostname(host); err != nil && hostnameCheckable(host) {
+		add("hostname_mismatch", SeverityHigh, "certificate is not valid for %s", host)
+	}
+
+	// Schlüssel und Signatur
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < minRSABits {
+			add("weak_key", SeverityHigh, "RSA key has only %d bits", bits)
+		}
+	case *ecdsa.PublicKey:
+		if bits := key.Curve.Params().BitSize; bits < minECDSABits {
+			add("weak_key", SeverityMedium, "ECDSA key has only %d bits", bits)
+		}
+	}
+	if weakSignatures[leaf.SignatureAlgorithm] {
+		add("weak_signature", SeverityHigh, "certificate is signed with %s", leaf.SignatureAlgorithm)
+	}
+
+	// Protokolle
+	supportsTLS13 := false
+	for _, p := range protocols {
+		if !p.Supported {
+			continue
+		}
+		switch p.Version {
+		case tls.VersionName(tls.VersionTLS10), tls.VersionName(tls.VersionTLS11):
+			add("legacy_protocol", SeverityMedium, "server accepts deprecated %s", p.Version)
+		case tls.VersionName(tls.VersionTLS13):
+			supportsTLS13 = true
+		}
+	}
+	if len(protocols) > 0 && !supportsTLS13 {
+		add("no_tls13", SeverityLow, "server does not support TLS 1.3")
+	}
+
+	return findings
+}
+
+// Mindest-Schlüssellängen; kürzere Schlüssel gelten als schwach
+const (
+	minRSABits   = 2048
+	minECDSABits = 256
+)
+
+// weakSignatures sind gebrochene bzw. veraltete Signaturverfahren
+var weakSignatures = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.ECDSAWithSHA1: true,
+	x509.DSAWithSHA1:   true,
+}
+
+// WeakCrypto prüft Schlüssel und Signatur eines gescannten Zertifikats (Codes wie bei
+// Assess: weak_key, weak_signature) und die ausgehandelte Version (legacy_protocol)
+func WeakCrypto(cert *CertificateData) []Finding {
+	var findings []Finding
+	switch {
+	case cert.KeyAlgorithm == x509.RSA.String() && cert.KeySize > 0 && cert.KeySize < minRSABits:
+		findings = append(findings, Finding{Code: "weak_key", Severity: SeverityHigh, Message: fmt.Sprintf("RSA key has only %d bits", cert.KeySize)})
+	case cert.KeyAlgorithm == x509.ECDSA.String() && cert.KeySize > 0 && cert.KeySize < minECDSABits:
+		findings = append(findings, Finding{Code: "weak_key", Severity: SeverityMedium, Message: fmt.Sprintf("ECDSA key has only %d bits", cert.KeySize)})
+	}
+	for alg := range weakSignatures {
+		if cert.SignatureAlg == alg.String() {
+			findings = append(findings, Finding{Code: "weak_signature", Severity: SeverityHigh, Message: "certificate is signed with " + cert.SignatureAlg})
+		}
+	}
+	switch cert.TLSVersion {
+	case tls.VersionName(tls.VersionTLS10), tls.VersionName(tls.VersionTLS11):
+		findings = append(findings, Finding{Code: "legacy_protocol", Severity: SeverityMedium, Message: "server negotiated deprecated " + cert.TLSVersion})
+	}
+	return findings
+}
+
+// hostnameCheckable meldet, ob ein Hostname sinnvoll gegen das Zertifikat geprüft
+// werden kann (nicht bei "localhost" oder leeren Namen)
+func hostnameCheckable(host string) bool {
+	return host != "" && !strings.EqualFold(host, "localhost")
+}
+
+// chainCertificate übernimmt die Anzeigedaten eines Chain-Zertifikats
+func chainCertificate(cert *x509.Certificate) ChainCertificate {
+	return ChainCertificate{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		KeyAlgorithm: cert.PublicKeyAlgorithm.String(),
+		KeySize:      getKeySize(cert),
+		SignatureAlg: cert.SignatureAlgorithm.String(),
+		IsCA:         cert.IsCA,
+		Fingerprint:  calculateFingerprint(cert),
+	}
+}
+
+// ParseEndpoint zerlegt "host:port", "[v6]:port" oder "host" (dann defaultPort)
+func ParseEndpoint(value string, defaultPort int) (Endpoint, error) {
+	host, portStr, err := net.SplitHostPort(value)
+	if err != nil {
+		var addrErr *net.AddrError
+		if errors.As(err, &addrErr) && strings.Contains(addrErr.Err, "missing port") {
+			return Endpoint{Host: strings.Trim(value, "[]"), Port: defaultPort}, nil
+		}
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: %w", value, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return Endpoint{}, fmt.Errorf("invalid port in %q", value)
+	}
+	if host == "" {
+		return Endpoint{}, fmt.Errorf("missing host in %q", value)
+	}
+	return Endpoint{Host: host, Port: port}, nil
+}
@@ -1,9 +1,11 @@
 package scanner
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -93,6 +95,51 @@ func getLocalNetworksWithCIDR() ([]NetworkInfo, error) {
 	return networks, nil
 }
 
+// maxCIDRHosts begrenzt die Größe eines per CIDR angegebenen Netzes (/16)
+const maxCIDRHosts = 1 << 16
+
+// networkFromCIDR erstellt die Netzwerk-Info für ein explizit angegebenes IPv4-Netz.
+// Netz- und Broadcast-Adresse werden ausgelassen (außer bei /31 und /32).
+func networkFromCIDR(cidr string) (NetworkInfo, error) {
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		// Einzelne IP-Adresse als /32
+		if single := net.ParseIP(cidr); single != nil && single.To4() != nil {
+			ip, ipNet = single, &net.IPNet{IP: single.To4(), Mask: net.CIDRMask(32, 32)}
+		} else {
+			return NetworkInfo{}, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+		}
+	}
+	if ip.To4() == nil {
+		return NetworkInfo{}, fmt.Errorf("invalid CIDR %q: only IPv4 networks are supported", cidr)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	size := 1 << uint(bits-ones)
+	if size > maxCIDRHosts {
+		return NetworkInfo{}, fmt.Errorf("CIDR %q too large (max /16)", cidr)
+	}
+
+	base := binary.BigEndian.Uint32(ipNet.IP.To4())
+	first, last := 0, size-1
+	if size > 2 {
+		first, last = 1, size-2
+	}
+	scanIPs := make([]string, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		addr := make(net.IP, 4)
+		binary.BigEndian.PutUint32(addr, base+uint32(i))
+		scanIPs = append(scanIPs, addr.String())
+	}
+
+	return NetworkInfo{
+		Network: ipNet.IP.String(),
+		CIDR:    ipNet.String(),
+		Gateway: scanIPs[0],
+		ScanIPs: scanIPs,
+	}, nil
+}
+
 // detectGateway versucht Gateway zu finden (meist .1 oder .254)
 func detectGateway(networkPrefix string) string {
 	// Versuche übliche Gateway-IPs
@@ -104,7 +151,7 @@ func detectGateway(networkPrefix string) string {
 	// Quick-Check auf Port 80 oder 443
 	for _, gateway := range possibleGateways {
 		for _, port := range []int{80, 443} {
-			address := fmt.Sprintf("%s:%d", gateway, port)
+			address := net.JoinHostPort(gateway, strconv.Itoa(port))
 			conn, err := net.DialTimeout("tcp", address, 200*time.Millisecond)
 			if err == nil {
 				conn.Close()
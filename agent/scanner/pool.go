@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+)
+
+// Endpoint ist ein Scan-Ziel host:port
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+// PoolLimits begrenzt die Parallelität eines Scan-Laufs
+type PoolLimits struct {
+	Global  int // gleichzeitige Scans insgesamt
+	PerHost int // gleichzeitige Scans pro Host (schont einzelne Server)
+}
+
+// RunPool führt fn für alle Endpoints mit begrenzter Parallelität aus.
+// results[i] gehört immer zu endpoints[i] - unabhängig davon, in welcher Reihenfolge
+// die Scans fertig werden. Wird ctx abgebrochen, starten keine weiteren Scans;
+// für nicht gestartete Endpoints bleibt started[i] false.
+// Mit Global = 1 wird strikt nacheinander in der Reihenfolge von endpoints gescannt.
+func RunPool[T any](ctx context.Context, endpoints []Endpoint, limits PoolLimits, fn func(ctx context.Context, ep Endpoint) T) (results []T, started []bool) {
+	results = make([]T, len(endpoints))
+	started = make([]bool, len(endpoints))
+
+	workers := limits.Global
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(endpoints) {
+		workers = len(endpoints)
+	}
+	perHost := limits.PerHost
+	if perHost < 1 {
+		perHost = 1
+	}
+
+	// Ein Semaphor pro Host
+	hostSlots := make(map[string]chan struct{})
+	for _, ep := range endpoints {
+		if _, ok := hostSlots[ep.Host]; !ok {
+			hostSlots[ep.Host] = make(chan struct{}, perHost)
+		}
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				ep := endpoints[idx]
+				slot := hostSlots[ep.Host]
+
+				select {
+				case slot <- struct{}{}:
+				case <-ctx.Done():
+					continue
+				}
+				if ctx.Err() != nil {
+					// Slot und Abbruch gleichzeitig bereit - nicht mehr starten
+					<-slot
+					continue
+				}
+				started[idx] = true
+				results[idx] = fn(ctx, ep)
+				<-slot
+			}
+		}()
+	}
+
+	// Parallel: Ports-zuerst verteilen, damit aufeinanderfolgende Jobs verschiedene
+	// Hosts treffen und Worker nicht am Host-Limit warten
+	for _, idx := range dispatchOrder(endpoints, workers > 1) {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, started
+}
+
+// dispatchOrder liefert die Reihenfolge, in der Endpoints an die Worker gehen.
+// interleave verteilt reihum über die Hosts (Round-Robin), sonst Originalreihenfolge.
+func dispatchOrder(endpoints []Endpoint, interleave bool) []int {
+	order := make([]int, 0, len(endpoints))
+	if !interleave {
+		for i := range endpoints {
+			order = append(order, i)
+		}
+		return order
+	}
+
+	var hosts []string
+	byHost := make(map[string][]int)
+	for i, ep := range endpoints {
+		if _, ok := byHost[ep.Host]; !ok {
+			hosts = append(hosts, ep.Host)
+		}
+		byHost[ep.Host] = append(byHost[ep.Host], i)
+	}
+	for len(order) < len(endpoints) {
+		for _, host := range hosts {
+			if queue := byHost[host]; len(queue) > 0 {
+				order = append(order, queue[0])
+				byHost[host] = queue[1:]
+			}
+		}
+	}
+	return order
+}
@@ -0,0 +1,227 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDialer ersetzt den Verbindungsaufbau eines Scans: jeder Aufruf hält eine
+// Verbindung für delay offen und merkt sich, wie viele gleichzeitig offen waren
+type fakeDialer struct {
+	delay func(ep Endpoint) time.Duration
+	block bool // bis zum Abbruch von ctx warten
+
+	mu         sync.Mutex
+	active     map[string]int
+	maxPerHost map[string]int
+	total      int
+	maxTotal   int
+	calls      []Endpoint
+	entered    chan Endpoint
+}
+
+func newFakeDialer(delay time.Duration) *fakeDialer {
+	return &fakeDialer{
+		delay:      func(Endpoint) time.Duration { return delay },
+		active:     make(map[string]int),
+		maxPerHost: make(map[string]int),
+		entered:    make(chan Endpoint, 100),
+	}
+}
+
+func (d *fakeDialer) dial(ctx context.Context, ep Endpoint) string {
+	d.mu.Lock()
+	d.calls = append(d.calls, ep)
+	d.active[ep.Host]++
+	d.total++
+	d.maxPerHost[ep.Host] = max(d.maxPerHost[ep.Host], d.active[ep.Host])
+	d.maxTotal = max(d.maxTotal, d.total)
+	d.mu.Unlock()
+	d.entered <- ep
+
+	if d.block {
+		<-ctx.Done()
+	} else {
+		time.Sleep(d.delay(ep))
+	}
+
+	d.mu.Lock()
+	d.active[ep.Host]--
+	d.total--
+	d.mu.Unlock()
+	return net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port))
+}
+
+// endpointsOf erstellt ports Endpoints je Host
+func endpointsOf(hosts []string, ports int) []Endpoint {
+	var eps []Endpoint
+	for _, host := range hosts {
+		for p := 0; p < ports; p++ {
+			eps = append(eps, Endpoint{Host: host, Port: 8000 + p})
+		}
+	}
+	return eps
+}
+
+func TestRunPoolLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		hosts       []string
+		ports       int
+		limits      PoolLimits
+		wantTotal   int // erwartete Höchstzahl gleichzeitiger Scans
+		wantPerHost int
+	}{
+		{"sequential", []string{"a", "b"}, 3, PoolLimits{Global: 1, PerHost: 5}, 1, 1},
+		{"per host limit", []string{"a"}, 6, PoolLimits{Global: 10, PerHost: 2}, 2, 2},
+		{"global limit", []string{"a", "b", "c", "d"}, 2, PoolLimits{Global: 3, PerHost: 2}, 3, 2},
+		{"both", []string{"a", "b", "c"}, 4, PoolLimits{Global: 4, PerHost: 1}, 3, 1},
+		{"zero limits", []string{"a", "b"}, 2, PoolLimits{}, 1, 1},
+		{"more workers than endpoints", []string{"a"}, 2, PoolLimits{Global: 50, PerHost: 4}, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := newFakeDialer(20 * time.Millisecond)
+			endpoints := endpointsOf(tt.hosts, tt.ports)
+
+			results, started := RunPool(context.Background(), endpoints, tt.limits, dialer.dial)
+
+			for i, ep := range endpoints {
+				if !started[i] {
+					t.Errorf("%v not started", ep)
+				}
+				if want := net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port)); results[i] != want {
+					t.Errorf("results[%d] = %q, want %q", i, results[i], want)
+				}
+			}
+			if len(dialer.calls) != len(endpoints) {
+				t.Errorf("%d dials for %d endpoints", len(dialer.calls), len(endpoints))
+			}
+			if dialer.maxTotal != tt.wantTotal {
+				t.Errorf("max concurrent scans = %d, want %d", dialer.maxTotal, tt.wantTotal)
+			}
+			for host, n := range dialer.maxPerHost {
+				if n > tt.wantPerHost {
+					t.Errorf("max concurrent scans on %s = %d, limit %d", host, n, tt.wantPerHost)
+				}
+			}
+		})
+	}
+}
+
+// TestRunPoolOrder: results[i] gehört zu endpoints[i], auch wenn spätere Endpoints
+// zuerst fertig werden; mit Global = 1 wird in der Reihenfolge der Endpoints gescannt
+func TestRunPoolOrder(t *testing.T) {
+	endpoints := endpointsOf([]string{"a", "b", "c"}, 3)
+	for _, global := range []int{1, 4} {
+		t.Run(fmt.Sprintf("global %d", global), func(t *testing.T) {
+			dialer := newFakeDialer(0)
+			dialer.delay = func(ep Endpoint) time.Duration {
+				// Je später der Endpoint, desto schneller der Scan
+				return time.Duration(8010-ep.Port) * time.Millisecond
+			}
+			results, _ := RunPool(context.Background(), endpoints, PoolLimits{Global: global, PerHost: 3}, dialer.dial)
+			for i, ep := range endpoints {
+				if want := net.JoinHostPort(ep.Host, strconv.Itoa(ep.Port)); results[i] != want {
+					t.Errorf("results[%d] = %q, want %q", i, results[i], want)
+				}
+			}
+			if global == 1 {
+				for i, ep := range dialer.calls {
+					if ep != endpoints[i] {
+						t.Errorf("dial %d = %v, want %v", i, ep, endpoints[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRunPoolCancel: nach dem Abbruch starten keine weiteren Scans, laufende bekommen
+// den abgebrochenen Context
+func TestRunPoolCancel(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits PoolLimits
+		hosts  []string
+		ports  int
+	}{
+		{"global limit", PoolLimits{Global: 2, PerHost: 2}, []string{"a", "b", "c"}, 3},
+		{"waiting for host slot", PoolLimits{Global: 4, PerHost: 1}, []string{"a"}, 4},
+		{"sequential", PoolLimits{Global: 1, PerHost: 1}, []string{"a", "b"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := newFakeDialer(0)
+			dialer.block = true
+			endpoints := endpointsOf(tt.hosts, tt.ports)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			done := make(chan struct{})
+			var started []bool
+			go func() {
+				defer close(done)
+				_, started = RunPool(ctx, endpoints, tt.limits, dialer.dial)
+			}()
+
+			// Warten, bis alle Slots belegt sind, dann abbrechen
+			want := min(tt.limits.Global, tt.limits.PerHost*len(tt.hosts))
+			for i := 0; i < want; i++ {
+				select {
+				case <-dialer.entered:
+				case <-time.After(5 * time.Second):
+					t.Fatalf("%d of %d scans started", i, want)
+				}
+			}
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("RunPool did not return after cancel")
+			}
+
+			n := 0
+			for _, s := range started {
+				if s {
+					n++
+				}
+			}
+			if n != want || len(dialer.calls) != want {
+				t.Errorf("started %d, dialed %d, want %d", n, len(dialer.calls), want)
+			}
+			for i, ep := range dialer.calls {
+				idx := -1
+				for j := range endpoints {
+					if endpoints[j] == ep {
+						idx = j
+					}
+				}
+				if idx < 0 || !started[idx] {
+					t.Errorf("dial %d (%v) not marked as started", i, ep)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchOrder(t *testing.T) {
+	endpoints := []Endpoint{{"a", 1}, {"a", 2}, {"a", 3}, {"b", 1}, {"c", 1}, {"c", 2}}
+	tests := []struct {
+		interleave bool
+		want       []int
+	}{
+		{false, []int{0, 1, 2, 3, 4, 5}},
+		{true, []int{0, 3, 4, 1, 5, 2}},
+	}
+	for _, tt := range tests {
+		got := dispatchOrder(endpoints, tt.interleave)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("dispatchOrder(interleave=%v) = %v, want %v", tt.interleave, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter verteilt neue Verbindungen gleichmäßig über die Zeit
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // Abstand zwischen zwei Verbindungen (0 = unbegrenzt)
+	next     time.Time
+}
+
+// SetRate setzt die maximale Anzahl Verbindungen pro Sekunde (0 = unbegrenzt)
+func (r *rateLimiter) SetRate(perSecond int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if perSecond <= 0 {
+		r.interval = 0
+		return
+	}
+	r.interval = time.Second / time.Duration(perSecond)
+}
+
+// Wait blockiert bis die nächste Verbindung erlaubt ist
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	if r.interval == 0 {
+		r.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,166 @@
+package scanner
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ergebnisse der Sperrprüfung (CertificateData.Revocation)
+const (
+	RevocationGood    = "good"    // in der Sperrliste des Ausstellers nicht enthalten
+	RevocationRevoked = "revoked" // vom Aussteller gesperrt
+)
+
+// maxCRLSize begrenzt die Größe einer heruntergeladenen Sperrliste
+const maxCRLSize = 32 << 20
+
+// Sperrlisten werden bis zu ihrem nextUpdate zwischengespeichert, höchstens maxCRLAge
+// und mindestens minCRLAge (auch ohne nextUpdate bzw. nach einem Fehler)
+const (
+	maxCRLAge = 24 * time.Hour
+	minCRLAge = 15 * time.Minute
+)
+
+// RevocationChecker prüft Zertifikate gegen die Sperrlisten (CRL) ihres Ausstellers.
+// Die Adressen stehen im Zertifikat (CRL Distribution Points); OCSP wird nicht genutzt.
+type RevocationChecker struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]*cachedCRL // nach URL
+}
+
+// cachedCRL ist eine geladene Sperrliste (bzw. der Fehler beim Laden)
+type cachedCRL struct {
+	list    *x509.RevocationList
+	revoked map[string]bool // Seriennummern
+	err     error
+	expires time.Time
+}
+
+// NewRevocationChecker erstellt die Sperrprüfung; timeout gilt pro Download
+func NewRevocationChecker(timeout time.Duration) *RevocationChecker {
+	return &RevocationChecker{
+		client: &http.Client{Timeout: timeout},
+		cache:  make(map[string]*cachedCRL),
+	}
+}
+
+// Check prüft leaf gegen die Sperrlisten, die issuer signiert hat. Liefert "" ohne
+// Ergebnis (keine Sperrliste im Zertifikat, Aussteller nicht ausgeliefert, Download
+// fehlgeschlagen, Sperrliste abgelaufen - dann mit Fehler). Eine Sperrung gilt auch aus
+// einer abgelaufenen Sperrliste.
+func (c *RevocationChecker) Check(ctx context.Context, leaf, issuer *x509.Certificate) (string, error) {
+	var urls []string
+	for _, u := range leaf.CRLDistributionPoints {
+		if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return "", nil
+	}
+	if issuer == nil {
+		return "", fmt.Errorf("issuer certificate not sent by server")
+	}
+
+	var errs []error
+	for _, u := range urls {
+		crl, err := c.load(ctx, u)
+		if err == nil {
+			err = crl.list.CheckSignatureFrom(issuer)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("crl %s: %w", u, err))
+			continue
+		}
+		if crl.revoked[leaf.SerialNumber.String()] {
+			return RevocationRevoked, nil
+		}
+		if next := crl.list.NextUpdate; !next.IsZero() && time.Now().After(next) {
+			errs = append(errs, fmt.Errorf("crl %s: expired since %s", u, next.UTC().Format(time.RFC3339)))
+			continue
+		}
+		return RevocationGood, nil
+	}
+	return "", errors.Join(errs...)
+}
+
+// load liefert die Sperrliste einer URL aus dem Cache bzw. lädt sie neu
+func (c *RevocationChecker) load(ctx context.Context, url string) (*cachedCRL, error) {
+	now := time.Now()
+	c.mu.Lock()
+	cached, ok := c.cache[url]
+	c.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached, cached.err
+	}
+
+	cached = &cachedCRL{expires: now.Add(minCRLAge)}
+	cached.list, cached.err = c.fetch(ctx, url)
+	if cached.err == nil {
+		cached.revoked = make(map[string]bool, len(cached.list.RevokedCertificateEntries))
+		for _, entry := range cached.list.RevokedCertificateEntries {
+			cached.revoked[entry.SerialNumber.String()] = true
+		}
+		cached.expires = now.Add(maxCRLAge)
+		if next := cached.list.NextUpdate; !next.IsZero() && next.Before(cached.expires) {
+			cached.expires = next
+		}
+		if cached.expires.Before(now.Add(minCRLAge)) {
+			cached.expires = now.Add(minCRLAge)
+		}
+	}
+	if ctx.Err() == nil {
+		// Abbrüche (Shutdown) nicht zwischenspeichern
+		c.mu.Lock()
+		c.cache[url] = cached
+		c.mu.Unlock()
+	}
+	return cached, cached.err
+}
+
+func (c *RevocationChecker) fetch(ctx context.Context, url string) (*x509.RevocationList, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "zertifikat-waechter-agent")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCRLSize {
+		return nil, fmt.Errorf("larger than %d MB", maxCRLSize>>20)
+	}
+	if block, _ := pem.Decode(data); block != nil && block.Type == "X509 CRL" {
+		data = block.Bytes
+	}
+	return x509.ParseRevocationList(data)
+}
+
+// issuerOf liefert das Zertifikat aus der Chain, das leaf ausgestellt hat (nil = nicht ausgeliefert)
+func issuerOf(leaf *x509.Certificate, chain []*x509.Certificate) *x509.Certificate {
+	for _, cert := range chain {
+		if cert != leaf && leaf.CheckSignatureFrom(cert) == nil {
+			return cert
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// testCA ist eine Zertifizierungsstelle für Tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// leaf stellt ein Server-Zertifikat für 127.0.0.1 mit den Sperrlisten crlURLs aus
+func (ca *testCA) leaf(t *testing.T, serial int64, crlURLs ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		CRLDistributionPoints: crlURLs,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key, Leaf: leaf}
+}
+
+// crl erstellt eine Sperrliste mit den gesperrten Seriennummern, gültig bis nextUpdate
+func (ca *testCA) crl(t *testing.T, nextUpdate time.Time, revoked ...int64) []byte {
+	t.Helper()
+	tmpl := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: nextUpdate.Add(-48 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	for _, serial := range revoked {
+		tmpl.RevokedCertificateEntries = append(tmpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now().Add(-time.Hour),
+		})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, tmpl, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// crlServer liefert body mit status aus und zählt die Abrufe
+type crlServer struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newCRLServer(t *testing.T, status int, body []byte) *crlServer {
+	t.Helper()
+	s := &crlServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if ua := r.Header.Get("User-Agent"); ua != "zertifikat-waechter-agent" {
+			t.Errorf("User-Agent = %q", ua)
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestRevocationCheck(t *testing.T) {
+	ca, other := newTestCA(t, "Test CA"), newTestCA(t, "Other CA")
+	valid := time.Now().Add(24 * time.Hour)
+	expired := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		status  int
+		crl     []byte
+		noCRL   bool // Zertifikat ohne CRL Distribution Point
+		issuer  *x509.Certificate
+		want    string
+		wantErr string
+	}{
+		{name: "good", status: 200, crl: ca.crl(t, valid, 7), issuer: ca.cert, want: RevocationGood},
+		{name: "revoked", status: 200, crl: ca.crl(t, valid, 7, 42), issuer: ca.cert, want: RevocationRevoked},
+		{name: "pem", status: 200, crl: pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: ca.crl(t, valid, 42)}), issuer: ca.cert, want: RevocationRevoked},
+		{name: "expired crl", status: 200, crl: ca.crl(t, expired), issuer: ca.cert, wantErr: "expired since"},
+		{name: "revoked in expired crl", status: 200, crl: ca.crl(t, expired, 42), issuer: ca.cert, want: RevocationRevoked},
+		{name: "fetch error", status: 503, issuer: ca.cert, wantErr: "status 503"},
+		{name: "not a crl", status: 200, crl: []byte("<html>"), issuer: ca.cert, wantErr: "crl http://"},
+		{name: "wrong signer", status: 200, crl: other.crl(t, valid, 42), issuer: ca.cert, wantErr: "verification failure"},
+		{name: "issuer missing", status: 200, crl: ca.crl(t, valid), wantErr: "issuer certificate not sent"},
+		{name: "no distribution point", noCRL: true, issuer: ca.cert},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newCRLServer(t, tt.status, tt.crl)
+			var urls []string
+			if !tt.noCRL {
+				urls = []string{"ldap://ldap.example/cn=crl", srv.URL + "/ca.crl"}
+			}
+			leaf := ca.leaf(t, 42, urls...).Leaf
+
+			got, err := NewRevocationChecker(time.Second).Check(context.Background(), leaf, tt.issuer)
+			if got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Check error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Check error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRevocationFallback: die zweite Sperrliste zählt, wenn die erste nicht zu laden ist
+func TestRevocationFallback(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	broken := newCRLServer(t, 404, nil)
+	working := newCRLServer(t, 200, ca.crl(t, time.Now().Add(time.Hour), 42))
+	leaf := ca.leaf(t, 42, broken.URL+"/ca.crl", working.URL+"/ca.crl").Leaf
+
+	got, err := NewRevocationChecker(time.Second).Check(context.Background(), leaf, ca.cert)
+	if got != RevocationRevoked || err != nil {
+		t.Errorf("Check = %q, %v; want revoked", got, err)
+	}
+}
+
+// TestRevocationCache: Sperrlisten und Fehler werden nicht bei jedem Scan neu geladen
+func TestRevocationCache(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	good := newCRLServer(t, 200, ca.crl(t, time.Now().Add(time.Hour)))
+	failing := newCRLServer(t, 500, nil)
+	checker := NewRevocationChecker(time.Second)
+
+	for _, srv := range []*crlServer{good, failing} {
+		leaf := ca.leaf(t, 42, srv.URL+"/ca.crl").Leaf
+		first, _ := checker.Check(context.Background(), leaf, ca.cert)
+		second, _ := checker.Check(context.Background(), leaf, ca.cert)
+		if first != second {
+			t.Errorf("cached result %q differs from %q", second, first)
+		}
+		if n := srv.requests.Load(); n != 1 {
+			t.Errorf("%d downloads, want 1", n)
+		}
+	}
+
+	// Ein abgebrochener Download wird nicht zwischengespeichert
+	cancelled := newCRLServer(t, 200, ca.crl(t, time.Now().Add(time.Hour)))
+	leaf := ca.leaf(t, 42, cancelled.URL+"/ca.crl").Leaf
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got, err := checker.Check(ctx, leaf, ca.cert); got != "" || err == nil {
+		t.Errorf("cancelled Check = %q, %v", got, err)
+	}
+	if got, err := checker.Check(context.Background(), leaf, ca.cert); got != RevocationGood || err != nil {
+		t.Errorf("Check after cancel = %q, %v", got, err)
+	}
+}
+
+// tlsServer beantwortet Handshakes mit cert (inklusive Chain)
+func tlsServer(t *testing.T, cert tls.Certificate) int {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// TestScanHostRevocation: die Sperrprüfung setzt Revocation, ein Fehler beim Laden der
+// Sperrliste lässt den TLS-Scan nicht scheitern
+func TestScanHostRevocation(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	revokedCRL := newCRLServer(t, 200, ca.crl(t, time.Now().Add(time.Hour), 42))
+	expiredCRL := newCRLServer(t, 200, ca.crl(t, time.Now().Add(-time.Hour)))
+	failingCRL := newCRLServer(t, 500, nil)
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(hanging.Close)
+
+	tests := []struct {
+		name string
+		crl  string
+		want string
+	}{
+		{"revoked", revokedCRL.URL, RevocationRevoked},
+		{"expired crl", expiredCRL.URL, ""},
+		{"fetch error", failingCRL.URL, ""},
+		{"fetch timeout", hanging.URL, ""},
+		{"connection refused", "http://127.0.0.1:1", ""},
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := tlsServer(t, ca.leaf(t, 42, tt.crl+"/ca.crl"))
+			s := NewScanner(5*time.Second, log)
+			s.SetRevocationChecker(NewRevocationChecker(200 * time.Millisecond))
+
+			cert, err := s.ScanHost(context.Background(), "127.0.0.1", port)
+			if err != nil {
+				t.Fatalf("ScanHost failed because of the revocation check: %v", err)
+			}
+			if cert.Revocation != tt.want {
+				t.Errorf("Revocation = %q, want %q", cert.Revocation, tt.want)
+			}
+			if cert.SerialNumber == "" || cert.Fingerprint == "" {
+				t.Errorf("certificate data missing: %+v", cert)
+			}
+		})
+	}
+}
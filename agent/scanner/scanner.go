@@ -1,22 +1,29 @@
 package scanner
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/hex"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type Scanner struct {
-	timeout time.Duration
-	log     *logrus.Logger
+	timeout    atomic.Int64 // time.Duration, zur Laufzeit änderbar
+	log        *logrus.Logger
+	revocation *RevocationChecker // nil = keine Sperrprüfung
 }
 
 type CertificateData struct {
@@ -28,47 +35,111 @@ type CertificateData struct {
 	Issuer       string    `json:"issuer"`
 	NotBefore    time.Time `json:"not_before"`
 	NotAfter     time.Time `json:"not_after"`
-	KeyAlgorithm string    `json:"key_algorithm"`
+	KeyAlgorithm string    `json:"key_alg"`
 	KeySize      int       `json:"key_size,omitempty"`
-	SerialNumber string    `json:"serial_number"`
+	SerialNumber string    `json:"serial"`
 	SignatureAlg string    `json:"signature_algorithm"`
+	IsTrusted    bool      `json:"is_trusted"`     // Chain endet bei einer öffentlichen CA (System-Roots)
+	IsSelfSigned bool      `json:"is_self_signed"` // Aussteller = Subjekt und selbst signiert
+
+	LastScanRunID string `json:"last_scan_run_id,omitempty"`
+
+	// SNI, mit dem das Zertifikat abgerufen wurde ("" bei IP-Adressen, dort sendet TLS keine SNI)
+	SNI string `json:"-"`
+	// Ausgehandelte TLS-Version, z.B. "TLS 1.3"
+	TLSVersion string `json:"-"`
+	// Ergebnis der Sperrprüfung: RevocationGood, RevocationRevoked, "" = nicht geprüft
+	Revocation string `json:"-"`
 }
 
 func NewScanner(timeout time.Duration, log *logrus.Logger) *Scanner {
-	return &Scanner{
-		timeout: timeout,
-		log:     log,
+	s := &Scanner{
+		log: log,
 	}
+	s.SetTimeout(timeout)
+	return s
 }
 
-// ScanHost scannt einen einzelnen Host:Port nach TLS-Zertifikat
+// SetTimeout ändert den Timeout für folgende Scans
+func (s *Scanner) SetTimeout(timeout time.Duration) {
+	s.timeout.Store(int64(timeout))
+}
+
+// SetRevocationChecker aktiviert die Sperrprüfung für folgende Scans (vor dem ersten Scan aufrufen)
+func (s *Scanner) SetRevocationChecker(checker *RevocationChecker) {
+	s.revocation = checker
+}
+
+// ScanHost scannt einen einzelnen Host:Port nach TLS-Zertifikat.
+// Fehler sind vom Typ *ScanError und nach Ursache klassifiziert.
 func (s *Scanner) ScanHost(ctx context.Context, host string, port int) (*CertificateData, error) {
-	address := fmt.Sprintf("%s:%d", host, port)
+	connState, err := s.handshake(ctx, host, port, 0)
+	if err != nil {
+		return nil, err
+	}
+	certData := certificateData(host, connState)
+	if s.revocation != nil {
+		leaf := connState.PeerCertificates[0]
+		certData.Revocation, err = s.revocation.Check(ctx, leaf, issuerOf(leaf, connState.PeerCertificates))
+		if err != nil {
+			// Ohne Ergebnis gilt das Zertifikat nicht als gesperrt
+			s.log.WithError(err).WithField("host", net.JoinHostPort(host, strconv.Itoa(port))).Debug("Revocation check failed")
+		}
+	}
+	return certData, nil
+}
+
+// handshake baut eine TLS-Verbindung auf und liefert den Verbindungszustand.
+// version != 0 erzwingt genau diese TLS-Version.
+func (s *Scanner) handshake(ctx context.Context, host string, port int, version uint16) (tls.ConnectionState, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	timeout := time.Duration(s.timeout.Load())
+
+	// TCP-Verbindung (DNS-Auflösung, Connect) getrennt vom Handshake,
+	// damit "Host nicht erreichbar" von TLS-Problemen unterscheidbar ist
+	dialer := &net.Dialer{
+		Timeout: timeout,
+	}
+	rawConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return tls.ConnectionState{}, classifyError(PhaseConnect, err, false)
+	}
 
-	// TLS-Config mit InsecureSkipVerify (wir wollen nur Cert-Metadaten)
+	// TLS-Config mit InsecureSkipVerify (wir wollen nur Cert-Metadaten).
+	// Fordert der Server ein Client-Zertifikat an, wird ein leeres geschickt und das vermerkt.
+	certRequested := false
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: true,
 		ServerName:         host,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			certRequested = true
+			return &tls.Certificate{}, nil
+		},
 	}
-
-	// Dialer mit Timeout
-	dialer := &net.Dialer{
-		Timeout: s.timeout,
+	if version != 0 {
+		tlsConfig.MinVersion = version
+		tlsConfig.MaxVersion = version
 	}
 
-	// TLS-Connection öffnen
-	conn, err := tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
-	if err != nil {
-		return nil, fmt.Errorf("TLS handshake failed: %w", err)
-	}
+	conn := tls.Client(rawConn, tlsConfig)
 	defer conn.Close()
 
+	handshakeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		return tls.ConnectionState{}, classifyError(PhaseHandshake, err, certRequested)
+	}
+
 	// Connection State holen
 	connState := conn.ConnectionState()
 	if len(connState.PeerCertificates) == 0 {
-		return nil, fmt.Errorf("no certificates found")
+		return tls.ConnectionState{}, &ScanError{Class: ClassNoCertificate, Phase: PhaseHandshake, Err: fmt.Errorf("no certificates found")}
 	}
+	return connState, nil
+}
 
+// certificateData liest die Metadaten des End-Entity-Zertifikats
+func certificateData(host string, connState tls.ConnectionState) *CertificateData {
 	// End-Entity-Zertifikat (erstes in der Chain)
 	cert := connState.PeerCertificates[0]
 
@@ -84,9 +155,38 @@ func (s *Scanner) ScanHost(ctx context.Context, host string, port int) (*Certifi
 		KeySize:      getKeySize(cert),
 		SerialNumber: cert.SerialNumber.String(),
 		SignatureAlg: cert.SignatureAlgorithm.String(),
+		IsTrusted:    verifyChain(connState.PeerCertificates),
+		IsSelfSigned: isSelfSigned(cert),
+		TLSVersion:   tls.VersionName(connState.Version),
+	}
+	if net.ParseIP(host) == nil {
+		certData.SNI = host
 	}
 
-	return certData, nil
+	return certData
+}
+
+// verifyChain prüft, ob die ausgelieferte Chain bei einer vertrauenswürdigen Root endet
+// (Hostname und Verwendungszweck werden hier bewusst nicht geprüft)
+func verifyChain(chain []*x509.Certificate) bool {
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+// isSelfSigned erkennt selbst signierte Zertifikate
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		return false
+	}
+	// CheckSignatureFrom würde Leaf-Zertifikate ohne CA-Flag ablehnen
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
 }
 
 // calculateFingerprint berechnet SHA-256 Fingerprint
@@ -125,11 +225,13 @@ func extractSAN(cert *x509.Certificate) []string {
 // getKeySize ermittelt Key-Size (für RSA/ECDSA)
 func getKeySize(cert *x509.Certificate) int {
 	switch key := cert.PublicKey.(type) {
-	case *interface{ Size() int }:
-		return (*key).Size() * 8 // Bytes → Bits
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
 	default:
 		return 0
 	}
 }
-
-
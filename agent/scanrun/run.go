@@ -0,0 +1,175 @@
+package scanrun
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Trigger beschreibt wodurch ein Scan-Lauf ausgelöst wurde
+type Trigger string
+
+const (
+	TriggerSchedule Trigger = "schedule" // Intervall bzw. Start des Agents
+	TriggerManual   Trigger = "manual"   // "Jetzt scannen" in der UI
+	TriggerCommand  Trigger = "command"  // Aufruf über CLI oder Remote-Kommando
+)
+
+// Mode unterscheidet konfigurierte Targets und Netzwerk-Discovery
+type Mode string
+
+const (
+	ModeTargets   Mode = "targets"
+	ModeDiscovery Mode = "discovery"
+)
+
+// Status eines Scan-Laufs
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Outcome ist das Ergebnis eines einzelnen Endpoints (host:port) innerhalb eines Laufs
+type Outcome struct {
+	Host         string    `json:"host"`
+	Port         int       `json:"port"`
+	AssetID      string    `json:"asset_id,omitempty"`
+	Success      bool      `json:"-"`
+	Status       string    `json:"status"` // "success" oder "failed"
+	ErrorClass   string    `json:"error_class,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	ErrorPhase   string    `json:"error_phase,omitempty"` // "connect" oder "handshake"
+	TLSAlert     string    `json:"tls_alert,omitempty"`
+	TLSAlertCode *int      `json:"tls_alert_code,omitempty"`
+	Fingerprint  string    `json:"certificate_fingerprint,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	ScannedAt    time.Time `json:"scanned_at"`
+}
+
+// Summary ist der serialisierbare Stand eines Laufs (entspricht scan_runs)
+type Summary struct {
+	ID                 string     `json:"id"`
+	TenantID           string     `json:"tenant_id,omitempty"`
+	ConnectorID        string     `json:"connector_id,omitempty"`
+	Trigger            Trigger    `json:"trigger"`
+	Mode               Mode       `json:"mode"`
+	ConfigVersion      int64      `json:"config_version"`
+	Status             Status     `json:"status"`
+	StartedAt          time.Time  `json:"started_at"`
+	FinishedAt         *time.Time `json:"finished_at,omitempty"`
+	EndpointsTotal     int        `json:"endpoints_total"`
+	EndpointsSucceeded int        `json:"endpoints_succeeded"`
+	EndpointsFailed    int        `json:"endpoints_failed"`
+	CertificatesFound  int        `json:"certificates_found"`
+	HostsFound         int        `json:"hosts_found"`
+}
+
+// Run sammelt Statistiken und Endpoint-Ergebnisse eines Scan-Laufs (thread-safe)
+type Run struct {
+	mu       sync.Mutex
+	summary  Summary
+	outcomes []Outcome
+}
+
+// New startet einen neuen Lauf mit frisch erzeugter ID
+func New(trigger Trigger, mode Mode, configVersion int64) *Run {
+	return &Run{
+		summary: Summary{
+			ID:            NewID(),
+			Trigger:       trigger,
+			Mode:          mode,
+			ConfigVersion: configVersion,
+			Status:        StatusRunning,
+			StartedAt:     time.Now().UTC(),
+		},
+	}
+}
+
+// ID liefert die Run-ID
+func (r *Run) ID() string {
+	return r.summary.ID
+}
+
+// Mode liefert den Modus des Laufs
+func (r *Run) Mode() Mode {
+	return r.summary.Mode
+}
+
+// SetOwner setzt Tenant und Connector des Laufs
+func (r *Run) SetOwner(tenantID, connectorID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary.TenantID = tenantID
+	r.summary.ConnectorID = connectorID
+}
+
+// SetHostsFound setzt die Anzahl gefundener Hosts (Discovery)
+func (r *Run) SetHostsFound(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary.HostsFound = n
+}
+
+// Record speichert das Ergebnis eines Endpoints und aktualisiert die Zähler
+func (r *Run) Record(o Outcome) {
+	if o.ScannedAt.IsZero() {
+		o.ScannedAt = time.Now().UTC()
+	}
+	if o.Success {
+		o.Status = "success"
+	} else {
+		o.Status = "failed"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.outcomes = append(r.outcomes, o)
+	r.summary.EndpointsTotal++
+	if o.Success {
+		r.summary.EndpointsSucceeded++
+		if o.Fingerprint != "" {
+			r.summary.CertificatesFound++
+		}
+	} else {
+		r.summary.EndpointsFailed++
+	}
+}
+
+// Finish schließt den Lauf mit dem angegebenen Status ab
+func (r *Run) Finish(status Status) {
+	now := time.Now().UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.summary.Status = status
+	r.summary.FinishedAt = &now
+}
+
+// Summary liefert eine Kopie des aktuellen Stands
+func (r *Run) Summary() Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.summary
+}
+
+// Outcomes liefert eine Kopie aller Endpoint-Ergebnisse
+func (r *Run) Outcomes() []Outcome {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Outcome(nil), r.outcomes...)
+}
+
+// NewID erzeugt eine zufällige UUID (Version 4)
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
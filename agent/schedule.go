@@ -0,0 +1,142 @@
+package main
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/scheduler"
+)
+
+// discoveryTarget ist der Eintrag der Netzwerk-Discovery im Scheduler
+const discoveryTarget = "@discovery"
+
+// busyRetry ist die Wartezeit, wenn fällige Targets auf einen laufenden Scan warten
+const busyRetry = 10 * time.Second
+
+// configureSchedule überträgt Targets und Zeitpläne eines Snapshots auf den Scheduler
+func configureSchedule(sched *scheduler.Scheduler, snap *config.Snapshot, now time.Time) {
+	// Nur der Standard-Zeitplan ist adaptiv - eigene Gruppen gelten wie konfiguriert
+	def := scheduler.Schedule{
+		Name:     "default",
+		Interval: snap.ScanInterval,
+		Jitter:   snap.ScheduleJitter,
+		Adaptive: snap.AdaptiveSchedule,
+	}
+
+	groups := make([]scheduler.Group, 0, len(snap.Schedules))
+	for _, g := range snap.Schedules {
+		schedule := scheduler.Schedule{Name: g.Name, Interval: g.Interval, Jitter: g.Jitter}
+		if schedule.Jitter == 0 {
+			schedule.Jitter = snap.ScheduleJitter
+		}
+		if g.Cron != "" {
+			cron, err := scheduler.ParseCron(g.Cron)
+			if err != nil {
+				// Snapshot ist validiert - sollte nicht vorkommen
+				log.WithError(err).WithField("schedule", g.Name).Warn("Invalid cron expression - using default schedule")
+				continue
+			}
+			schedule.Cron = cron
+		}
+		groups = append(groups, scheduler.Group{Patterns: g.Targets, Schedule: schedule})
+	}
+
+	opts := scheduler.Options{
+		Splay:   snap.ScheduleSplay,
+		CatchUp: scheduler.CatchUp(snap.ScheduleCatchUp),
+	}
+	sched.Configure(scheduleTargets(snap), groups, def, opts, now)
+}
+
+// scheduleTargets liefert alle geplanten Einträge: konfigurierte Targets und ggf. die Discovery
+func scheduleTargets(snap *config.Snapshot) []string {
+	var targets []string
+	if snap.HasTargets() {
+		targets = append(targets, snap.ScanTargets...)
+	}
+	if snap.UsesDiscovery() {
+		targets = append(targets, discoveryTarget)
+	}
+	return targets
+}
+
+// seedSchedule übernimmt den letzten Scan pro Host aus dem Asset-Bestand, damit nach
+// einem Neustart nur überfällige Targets sofort gescannt werden
+func seedSchedule(sched *scheduler.Scheduler, records []lifecycle.Record) {
+	for _, rec := range records {
+		if rec.LastCheckedAt != nil {
+			sched.Seed(rec.Host, *rec.LastCheckedAt)
+		}
+	}
+}
+
+// adaptSchedule richtet das Intervall jedes Targets nach seinem am frühesten ablaufenden
+// Zertifikat. Targets ohne gelesenes Zertifikat fallen auf das reguläre Intervall zurück.
+func adaptSchedule(sched *scheduler.Scheduler, snap *config.Snapshot, certs map[string][]scheduler.CertTimes, now time.Time) {
+	if !snap.AdaptiveSchedule {
+		return
+	}
+	policy := scheduler.Policy{Floor: snap.AdaptiveMinInterval, Ceiling: snap.AdaptiveMaxInterval}
+
+	for target, list := range certs {
+		var interval time.Duration
+		var reason string
+		for _, c := range list {
+			if iv, r := policy.Interval(c, now); interval == 0 || iv < interval {
+				interval, reason = iv, r
+			}
+		}
+		sched.Adapt(target, interval, reason, now)
+		if interval > 0 {
+			log.WithFields(logrus.Fields{
+				"target":   target,
+				"interval": interval,
+				"reason":   reason,
+			}).Debug("Adaptive scan interval")
+		}
+	}
+}
+
+// splitDue trennt fällige Einträge in Targets und Discovery
+func splitDue(due []string) (targets []string, discovery bool) {
+	for _, target := range due {
+		if target == discoveryTarget {
+			discovery = true
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets, discovery
+}
+
+// logNextRuns meldet den nächsten geplanten Lauf
+func logNextRuns(sched *scheduler.Scheduler) {
+	entries := sched.Entries()
+	if len(entries) == 0 {
+		log.Warn("Nothing to scan - no targets and discovery disabled")
+		return
+	}
+	next := entries[0]
+	log.WithFields(logrus.Fields{
+		"next_target": next.Target,
+		"next_run":    next.NextRun.Format(time.RFC3339),
+		"schedule":    next.Schedule,
+		"targets":     len(entries),
+	}).Debug("Next scan scheduled")
+}
+
+// resetTimer setzt einen Timer sicher neu (auch wenn er bereits abgelaufen ist)
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	if d < 0 {
+		d = 0
+	}
+	t.Reset(d)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/scheduler"
+)
+
+// TestConfigureSchedule prüft die Übernahme von Gruppen, Cron-Ausdrücken, Jitter und
+// Discovery aus dem Snapshot
+func TestConfigureSchedule(t *testing.T) {
+	now := time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+	snap := &config.Snapshot{Settings: config.Settings{
+		ScanTargets:     []string{"a.example", "db.internal", "b.example"},
+		ScanInterval:    time.Hour,
+		DiscoveryMode:   "always",
+		ScheduleJitter:  time.Minute,
+		ScheduleCatchUp: "skip",
+		Schedules: []config.ScheduleGroup{
+			{Name: "nightly", Targets: []string{"*.internal"}, Cron: "0 2 * * *"},
+			{Name: "discovery", Targets: []string{"@discovery"}, Interval: 24 * time.Hour, Jitter: time.Second},
+		},
+	}}
+
+	sched := scheduler.New("connector")
+	sched.Seed("db.internal", now.Add(-time.Hour))
+	configureSchedule(sched, snap, now)
+
+	specs := make(map[string]string)
+	for _, e := range sched.Entries() {
+		specs[e.Target] = e.Schedule + " " + e.Spec
+		switch e.Target {
+		case "db.internal":
+			// Lauf vor einer Stunde, nächster um 02:00 plus Jitter des Snapshots
+			next := time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC)
+			if e.NextRun.Before(next) || !e.NextRun.Before(next.Add(time.Minute)) {
+				t.Errorf("db.internal next run %s", e.NextRun)
+			}
+		default:
+			if !e.NextRun.Equal(now) {
+				t.Errorf("%s next run %s, want now", e.Target, e.NextRun)
+			}
+		}
+	}
+	want := map[string]string{
+		"a.example":     "default every 1h0m0s",
+		"b.example":     "default every 1h0m0s",
+		"db.internal":   "nightly 0 2 * * *",
+		discoveryTarget: "discovery every 24h0m0s",
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("schedules = %v, want %v", specs, want)
+	}
+
+	targets, discovery := splitDue(sched.Due(now))
+	if !reflect.DeepEqual(targets, []string{"a.example", "b.example"}) || !discovery {
+		t.Errorf("splitDue = %v, %v", targets, discovery)
+	}
+}
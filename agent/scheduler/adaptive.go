@@ -0,0 +1,94 @@
+package scheduler
+
+import "time"
+
+// Gründe für ein adaptives Intervall
+const (
+	ReasonExpired         = "expired"          // Zertifikat abgelaufen - auf Erneuerung warten
+	ReasonExpiring        = "expiring"         // läuft in wenigen Tagen ab
+	ReasonRenewalDue      = "renewal_due"      // Erneuerung erwartet, neues Zertifikat noch nicht gesehen
+	ReasonRecentlyRotated = "recently_rotated" // kürzlich gewechselt - Deployment beobachten
+	ReasonStable          = "stable"
+)
+
+// urgentWindow: so kurz vor Ablauf wird immer mit dem Mindestintervall gescannt
+const urgentWindow = 3 * 24 * time.Hour
+
+// rotatedWindow: so lange nach einem Wechsel wird häufiger gescannt
+const rotatedWindow = 7 * 24 * time.Hour
+
+// maxRenewalWindow: spätestens so lange vor Ablauf wird eine Erneuerung erwartet
+const maxRenewalWindow = 30 * 24 * time.Hour
+
+// Policy leitet das Scan-Intervall eines Targets aus der Laufzeit seines Zertifikats ab
+type Policy struct {
+	Floor   time.Duration // Mindestintervall (ablaufende Zertifikate)
+	Ceiling time.Duration // Höchstintervall (stabile Zertifikate)
+}
+
+// CertTimes sind die für die Planung relevanten Zeitpunkte eines Zertifikats
+type CertTimes struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+	RotatedAt time.Time // letzter erkannter Wechsel auf dem Endpoint (Nullzeit = unbekannt)
+}
+
+// RenewalAt liefert den Zeitpunkt, ab dem eine Erneuerung zu erwarten ist: ein Drittel
+// der Laufzeit vor Ablauf (wie bei ACME üblich), höchstens 30 Tage vorher
+func (c CertTimes) RenewalAt() time.Time {
+	window := c.NotAfter.Sub(c.NotBefore) / 3
+	if window <= 0 || window > maxRenewalWindow {
+		window = maxRenewalWindow
+	}
+	return c.NotAfter.Add(-window)
+}
+
+// Interval liefert das Intervall bis zum nächsten Scan und den Grund dafür.
+// Stabile Zertifikate werden selten gescannt, der Scan fällt aber spätestens auf den
+// Beginn der Erneuerung - danach wird häufiger geprüft, bis das neue Zertifikat ausgeliefert wird.
+func (p Policy) Interval(c CertTimes, now time.Time) (time.Duration, string) {
+	left := c.NotAfter.Sub(now)
+	renewalAt := c.RenewalAt()
+
+	var interval time.Duration
+	reason := ReasonStable
+	switch {
+	case left <= 0:
+		return p.Floor, ReasonExpired
+	case left <= urgentWindow:
+		return p.Floor, ReasonExpiring
+	case !now.Before(renewalAt):
+		// Erneuerung fällig: engmaschig prüfen, bis das neue Zertifikat ausgeliefert wird
+		interval, reason = p.clamp(minDuration(left/60, p.Ceiling/8)), ReasonRenewalDue
+	default:
+		interval = p.clamp(left / 10)
+		// Zum erwarteten Erneuerungszeitpunkt nachsehen
+		if untilRenewal := renewalAt.Sub(now); untilRenewal < interval {
+			interval = p.clamp(untilRenewal)
+		}
+	}
+
+	if !c.RotatedAt.IsZero() && now.Sub(c.RotatedAt) < rotatedWindow {
+		if recent := p.clamp(p.Ceiling / 4); recent < interval {
+			interval, reason = recent, ReasonRecentlyRotated
+		}
+	}
+	return interval, reason
+}
+
+func (p Policy) clamp(d time.Duration) time.Duration {
+	if d < p.Floor {
+		return p.Floor
+	}
+	if p.Ceiling > 0 && d > p.Ceiling {
+		return p.Ceiling
+	}
+	return d
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,98 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+func TestPolicyInterval(t *testing.T) {
+	now := time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)
+	policy := Policy{Floor: 15 * time.Minute, Ceiling: day}
+	// cert liefert ein 90-Tage-Zertifikat (Erneuerung 30 Tage vor Ablauf), das in left abläuft
+	cert := func(left time.Duration) CertTimes {
+		return CertTimes{NotBefore: now.Add(left - 90*day), NotAfter: now.Add(left)}
+	}
+	rotated := func(c CertTimes, ago time.Duration) CertTimes {
+		c.RotatedAt = now.Add(-ago)
+		return c
+	}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		cert       CertTimes
+		want       time.Duration
+		wantReason string
+	}{
+		{"stable, clamped to ceiling", policy, cert(80 * day), day, ReasonStable},
+		{"stable, until renewal", policy, cert(30*day + 10*time.Hour), 10 * time.Hour, ReasonStable},
+		{"renewal imminent, clamped to floor", policy, cert(30*day + 5*time.Minute), 15 * time.Minute, ReasonStable},
+		{"renewal due", policy, cert(20 * day), 3 * time.Hour, ReasonRenewalDue},
+		{"renewal due, closer to expiry", policy, cert(4 * day), 96 * time.Minute, ReasonRenewalDue},
+		{"expiring", policy, cert(2 * day), 15 * time.Minute, ReasonExpiring},
+		{"expired", policy, cert(-time.Hour), 15 * time.Minute, ReasonExpired},
+		{"recently rotated", policy, rotated(cert(89*day), day), 6 * time.Hour, ReasonRecentlyRotated},
+		{"rotated long ago", policy, rotated(cert(80*day), 8*day), day, ReasonStable},
+		{"rotated, renewal due is shorter", policy, rotated(cert(20*day), day), 3 * time.Hour, ReasonRenewalDue},
+		{"no ceiling", Policy{Floor: time.Hour}, CertTimes{NotBefore: now.Add(-30 * day), NotAfter: now.Add(335 * day)}, 335 * day / 10, ReasonStable},
+		{"high floor", Policy{Floor: 2 * time.Hour, Ceiling: day}, cert(30*day + 10*time.Minute), 2 * time.Hour, ReasonStable},
+		{"ceiling below floor of recent", Policy{Floor: time.Hour, Ceiling: 2 * time.Hour}, rotated(cert(80*day), time.Hour), time.Hour, ReasonRecentlyRotated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.policy.Interval(tt.cert, now)
+			if got != tt.want || reason != tt.wantReason {
+				t.Errorf("Interval = %s (%s), want %s (%s)", got, reason, tt.want, tt.wantReason)
+			}
+			if got < tt.policy.Floor || tt.policy.Ceiling > 0 && got > tt.policy.Ceiling {
+				t.Errorf("Interval %s outside [%s, %s]", got, tt.policy.Floor, tt.policy.Ceiling)
+			}
+		})
+	}
+}
+
+// TestPolicyIntervalShortensNearExpiry: je näher der Ablauf, desto kürzer das Intervall
+func TestPolicyIntervalShortensNearExpiry(t *testing.T) {
+	policy := Policy{Floor: 10 * time.Minute, Ceiling: 12 * time.Hour}
+	c := CertTimes{NotBefore: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), NotAfter: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)}
+
+	var previous time.Duration
+	for _, left := range []time.Duration{80 * day, 25 * day, 10 * day, 2 * day, 0} {
+		got, reason := policy.Interval(c, c.NotAfter.Add(-left))
+		if got < policy.Floor || got > policy.Ceiling {
+			t.Errorf("%s left: interval %s outside [%s, %s]", left, got, policy.Floor, policy.Ceiling)
+		}
+		if previous > 0 && got > previous {
+			t.Errorf("%s left: interval %s (%s) longer than before (%s)", left, got, reason, previous)
+		}
+		previous = got
+	}
+	if previous != policy.Floor {
+		t.Errorf("interval at expiry = %s, want floor %s", previous, policy.Floor)
+	}
+}
+
+func TestRenewalAt(t *testing.T) {
+	notAfter := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		want     time.Duration // vor Ablauf
+	}{
+		{"90 days", 90 * day, 30 * day},
+		{"one year, capped", 365 * day, 30 * day},
+		{"short-lived", 9 * day, 3 * day},
+		{"unknown start", 0, 30 * day},
+	}
+	for _, tt := range tests {
+		c := CertTimes{NotAfter: notAfter}
+		if tt.lifetime > 0 {
+			c.NotBefore = notAfter.Add(-tt.lifetime)
+		}
+		if got := notAfter.Sub(c.RenewalAt()); got != tt.want {
+			t.Errorf("%s: renewal %s before expiry, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron ist ein geparster Cron-Ausdruck im Standardformat mit fünf Feldern
+// (Minute Stunde Tag Monat Wochentag) oder einem der Makros @hourly, @daily,
+// @weekly, @monthly, @yearly. Zeiten werden in der Zeitzone des Agents ausgewertet.
+type Cron struct {
+	expr    string
+	minute  uint64
+	hour    uint64
+	dom     uint64
+	month   uint64
+	dow     uint64
+	domStar bool // Tag nicht eingeschränkt
+	dowStar bool // Wochentag nicht eingeschränkt
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	fieldMinute = cronField{name: "minute", min: 0, max: 59}
+	fieldHour   = cronField{name: "hour", min: 0, max: 23}
+	fieldDom    = cronField{name: "day of month", min: 1, max: 31}
+	fieldMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 0 und 7 sind beide Sonntag
+	fieldDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parst einen Cron-Ausdruck. Unterstützt *, Listen (1,15), Bereiche (1-5),
+// Schritte (*/15, 0-30/10) sowie Monats- und Wochentagsnamen (jan, mon).
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	c := &Cron{expr: strings.TrimSpace(expr)}
+	var err error
+	if c.minute, err = fieldMinute.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if c.hour, err = fieldHour.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if c.dom, err = fieldDom.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if c.month, err = fieldMonth.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if c.dow, err = fieldDow.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	// Sonntag als 7 auf 0 abbilden
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+	return c, nil
+}
+
+// parse wandelt ein Feld in eine Bitmaske der erlaubten Werte
+func (f cronField) parse(s string) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: invalid step in %q", f.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: invalid range %q", f.name, rangePart)
+			}
+		default:
+			v, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !strings.Contains(part, "/") {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// value liest einen einzelnen Wert (Zahl oder Name)
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid value %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: %d out of range %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String liefert den ursprünglichen Ausdruck
+func (c *Cron) String() string {
+	return c.expr
+}
+
+// Next liefert den ersten Zeitpunkt nach after, zu dem der Ausdruck zutrifft.
+// Liefert die Nullzeit, wenn es innerhalb von fünf Jahren keinen gibt (z.B. 30. Februar).
+func (c *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches wertet Tag und Wochentag aus: sind beide eingeschränkt, reicht
+// einer von beiden (wie bei cron üblich)
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
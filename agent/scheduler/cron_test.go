@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * *", "expected 5 fields, got 4"},
+		{"* * * * * *", "expected 5 fields, got 6"},
+		{"60 * * * *", "minute: 60 out of range 0-59"},
+		{"* 24 * * *", "hour: 24 out of range 0-23"},
+		{"* * 0 * *", "day of month: 0 out of range 1-31"},
+		{"* * * 13 *", "month: 13 out of range 1-12"},
+		{"* * * * 8", "day of week: 8 out of range 0-7"},
+		{"30-10 * * * *", `minute: invalid range "30-10"`},
+		{"*/0 * * * *", `minute: invalid step in "*/0"`},
+		{"*/x * * * *", `minute: invalid step in "*/x"`},
+		{"x * * * *", `minute: invalid value "x"`},
+		{"* * * foo *", `month: invalid value "foo"`},
+		{"@every", "expected 5 fields, got 1"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ParseCron(%q) = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Freitag, 6. März 2026, 10:07:30
+	after := time.Date(2026, 3, 6, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"*/15 * * * *", after, time.Date(2026, 3, 6, 10, 15, 0, 0, time.UTC)},
+		{"0-30/10 * * * *", after, time.Date(2026, 3, 6, 10, 10, 0, 0, time.UTC)},
+		{"7 10 * * *", after, time.Date(2026, 3, 7, 10, 7, 0, 0, time.UTC)}, // gleiche Minute zählt nicht
+		{"0 2 * * *", after, time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC)},
+		{"@hourly", after, time.Date(2026, 3, 6, 11, 0, 0, 0, time.UTC)},
+		{"@daily", after, time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", after, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", after, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@YEARLY", after, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", after, time.Date(2026, 3, 9, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", after, time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC)}, // 7 = Sonntag
+		{"0 0 1,15 * *", after, time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", after, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)},
+		// Tag und Wochentag eingeschränkt: einer von beiden reicht
+		{"0 0 20 * mon", after, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", after, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", after, time.Time{}},
+		{"59 23 31 12 *", time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2027, 12, 31, 23, 59, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, tt.after, got, tt.want)
+		}
+	}
+}
+
+// TestCronNextLocation: Cron-Ausdrücke gelten in der Zeitzone des übergebenen Zeitpunkts
+func TestCronNextLocation(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	c, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := c.Next(time.Date(2026, 3, 6, 0, 30, 0, 0, time.UTC)) // 01:30 CET
+	if got.Location() != time.UTC || got.Hour() != 2 {
+		t.Errorf("Next in UTC = %s", got)
+	}
+	got = c.Next(time.Date(2026, 3, 6, 1, 30, 0, 0, loc))
+	if want := time.Date(2026, 3, 6, 2, 0, 0, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next in CET = %s, want %s", got, want)
+	}
+	if c.String() != "0 2 * * *" {
+		t.Errorf("String = %q", c.String())
+	}
+}
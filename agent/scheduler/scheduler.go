@@ -0,0 +1,360 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CatchUp legt fest, was nach einer Downtime mit verpassten Läufen passiert
+type CatchUp string
+
+const (
+	CatchUpOnce CatchUp = "once" // überfällige Targets einmal sofort scannen
+	CatchUpSkip CatchUp = "skip" // verpasste Läufe auslassen, nächsten regulären Termin abwarten
+)
+
+// Schedule ist ein Zeitplan: festes Intervall oder Cron-Ausdruck
+type Schedule struct {
+	Name     string
+	Interval time.Duration // nur wenn Cron nil ist
+	Cron     *Cron
+	Jitter   time.Duration // zufällige Verzögerung [0, Jitter) pro Lauf
+	Adaptive bool          // Intervall darf per Adapt pro Target ersetzt werden
+}
+
+// Spec beschreibt den Zeitplan lesbar ("every 1h0m0s" bzw. der Cron-Ausdruck)
+func (s Schedule) Spec() string {
+	if s.Cron != nil {
+		return s.Cron.String()
+	}
+	return "every " + s.Interval.String()
+}
+
+// next liefert den nächsten regulären Termin nach after (ohne Jitter)
+func (s Schedule) next(after time.Time) time.Time {
+	if s.Cron != nil {
+		return s.Cron.Next(after)
+	}
+	return after.Add(s.Interval)
+}
+
+func (s Schedule) key() string {
+	return fmt.Sprintf("%s|%s|%s|%t", s.Name, s.Spec(), s.Jitter, s.Adaptive)
+}
+
+// Group ordnet Targets einem eigenen Zeitplan zu. Patterns sind Hostnamen oder
+// Glob-Muster wie *.internal oder 10.0.1.*.
+type Group struct {
+	Patterns []string
+	Schedule Schedule
+}
+
+// matches prüft, ob ein Target zur Gruppe gehört
+func (g Group) matches(target string) bool {
+	for _, pattern := range g.Patterns {
+		if strings.EqualFold(pattern, target) {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(target)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Options gelten für alle Zeitpläne
+type Options struct {
+	Splay   time.Duration // verteilt erste und nachgeholte Läufe pro Agent und Target über [0, Splay)
+	CatchUp CatchUp
+}
+
+// Entry ist der Planungsstand eines Targets
+type Entry struct {
+	Target   string     `json:"target"`
+	Schedule string     `json:"schedule"`
+	Spec     string     `json:"spec"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+	NextRun  time.Time  `json:"next_run"`
+
+	// Nur bei adaptiven Zeitplänen mit bekanntem Zertifikat
+	AdaptiveInterval string `json:"adaptive_interval,omitempty"`
+	AdaptiveReason   string `json:"adaptive_reason,omitempty"`
+}
+
+// adaptation ist ein aus dem Zertifikat abgeleitetes Intervall
+type adaptation struct {
+	interval time.Duration
+	reason   string
+}
+
+type entry struct {
+	target   string
+	schedule Schedule
+	next     time.Time
+}
+
+// Scheduler plant Scans pro Target (thread-safe). Er startet selbst nichts: der
+// Aufrufer fragt mit Due fällige Targets ab und meldet gestartete Läufe mit MarkRun.
+type Scheduler struct {
+	mu       sync.Mutex
+	seed     string // macht den Splay pro Agent unterschiedlich (z.B. Connector-ID)
+	opts     Options
+	order    []string
+	entries  map[string]*entry
+	lastRuns map[string]time.Time // bleibt erhalten, auch wenn ein Target entfernt wird
+	adapted  map[string]adaptation
+	changed  chan struct{}
+}
+
+// New erstellt einen leeren Scheduler
+func New(seed string) *Scheduler {
+	return &Scheduler{
+		seed:     seed,
+		opts:     Options{CatchUp: CatchUpOnce},
+		entries:  make(map[string]*entry),
+		lastRuns: make(map[string]time.Time),
+		adapted:  make(map[string]adaptation),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// Seed übernimmt den Zeitpunkt des letzten Scans eines Targets (z.B. aus dem Backend),
+// damit nach einem Neustart nur überfällige Targets nachgeholt werden
+func (s *Scheduler) Seed(target string, lastRun time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lastRun.After(s.lastRuns[target]) {
+		s.lastRuns[target] = lastRun
+	}
+}
+
+// Configure setzt Targets und Zeitpläne neu. Ein Target nutzt die erste passende
+// Gruppe, sonst def. Targets mit unverändertem Zeitplan behalten ihren Termin,
+// bei geändertem Zeitplan wird vom letzten Lauf aus neu gerechnet.
+func (s *Scheduler) Configure(targets []string, groups []Group, def Schedule, opts Options, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if opts.CatchUp == "" {
+		opts.CatchUp = CatchUpOnce
+	}
+	s.opts = opts
+
+	entries := make(map[string]*entry, len(targets))
+	order := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if _, dup := entries[target]; dup {
+			continue
+		}
+		schedule := def
+		for _, g := range groups {
+			if g.matches(target) {
+				schedule = g.Schedule
+				break
+			}
+		}
+
+		e, ok := s.entries[target]
+		if ok && e.schedule.key() == schedule.key() {
+			entries[target] = e
+		} else {
+			e = &entry{target: target, schedule: schedule}
+			e.next = s.initial(e, now, ok)
+			entries[target] = e
+		}
+		order = append(order, target)
+	}
+
+	s.entries = entries
+	s.order = order
+}
+
+// initial berechnet den ersten Termin eines neuen oder geänderten Eintrags
+func (s *Scheduler) initial(e *entry, now time.Time, changed bool) time.Time {
+	last, ok := s.lastRuns[e.target]
+	if !ok {
+		// Noch nie gescannt
+		return now.Add(s.splay(e.target))
+	}
+
+	next := s.nextAfter(e, last)
+	if next.IsZero() || next.After(now) {
+		return s.withJitter(next, e.schedule)
+	}
+
+	// Termin verpasst (Downtime oder kürzerer Zeitplan)
+	if changed || s.opts.CatchUp == CatchUpOnce {
+		return now.Add(s.splay(e.target))
+	}
+	return s.withJitter(s.nextAfter(e, now), e.schedule)
+}
+
+// nextAfter liefert den nächsten Termin nach after - bei adaptiven Zeitplänen mit dem
+// Intervall aus Adapt, sonst nach dem Zeitplan
+func (s *Scheduler) nextAfter(e *entry, after time.Time) time.Time {
+	if e.schedule.Adaptive {
+		if a, ok := s.adapted[e.target]; ok {
+			return after.Add(a.interval)
+		}
+	}
+	return e.schedule.next(after)
+}
+
+// Adapt ersetzt das Intervall eines Targets mit adaptivem Zeitplan (interval 0 = wieder
+// das reguläre Intervall) und plant den nächsten Lauf vom letzten Scan aus neu
+func (s *Scheduler) Adapt(target string, interval time.Duration, reason string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if interval <= 0 {
+		delete(s.adapted, target)
+	} else {
+		s.adapted[target] = adaptation{interval: interval, reason: reason}
+	}
+
+	e, ok := s.entries[target]
+	if !ok || !e.schedule.Adaptive {
+		return
+	}
+	last, ok := s.lastRuns[target]
+	if !ok {
+		return
+	}
+	next := s.withJitter(s.nextAfter(e, last), e.schedule)
+	if next.Before(now) {
+		next = now
+	}
+	e.next = next
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Changed meldet, dass Adapt Termine verschoben hat - der Aufrufer sollte seinen
+// Timer neu auf NextWake stellen
+func (s *Scheduler) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Due liefert alle Targets, deren Termin erreicht ist (in Konfigurationsreihenfolge)
+func (s *Scheduler) Due(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []string
+	for _, target := range s.order {
+		e := s.entries[target]
+		if !e.next.IsZero() && !e.next.After(now) {
+			due = append(due, target)
+		}
+	}
+	return due
+}
+
+// MarkRun hält fest, dass die Targets zum Zeitpunkt at gescannt wurden, und plant den nächsten Lauf
+func (s *Scheduler) MarkRun(targets []string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, target := range targets {
+		s.lastRuns[target] = at
+		if e, ok := s.entries[target]; ok {
+			e.next = s.withJitter(s.nextAfter(e, at), e.schedule)
+		}
+	}
+}
+
+// NextWake liefert den frühesten geplanten Termin; false, wenn nichts geplant ist
+func (s *Scheduler) NextWake() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, e := range s.entries {
+		if e.next.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || e.next.Before(earliest) {
+			earliest = e.next
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// Overdue liefert die Targets, deren Termin schon so lange verstrichen ist, dass seit dem
+// letzten Scan mehr als factor Intervalle vergangen sind (Scans hängen oder laufen nicht)
+func (s *Scheduler) Overdue(now time.Time, factor int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var overdue []string
+	for _, target := range s.order {
+		e := s.entries[target]
+		if e.next.IsZero() {
+			continue
+		}
+		interval := s.nextAfter(e, e.next).Sub(e.next)
+		if interval > 0 && now.Sub(e.next) > time.Duration(factor-1)*interval {
+			overdue = append(overdue, target)
+		}
+	}
+	return overdue
+}
+
+// Entries liefert den Planungsstand aller Targets, nach nächstem Termin sortiert
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Entry, 0, len(s.entries))
+	for _, target := range s.order {
+		e := s.entries[target]
+		item := Entry{
+			Target:   target,
+			Schedule: e.schedule.Name,
+			Spec:     e.schedule.Spec(),
+			NextRun:  e.next,
+		}
+		if last, ok := s.lastRuns[target]; ok {
+			item.LastRun = &last
+		}
+		if a, ok := s.adapted[target]; ok && e.schedule.Adaptive {
+			item.AdaptiveInterval = a.interval.String()
+			item.AdaptiveReason = a.reason
+		}
+		list = append(list, item)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].NextRun.Before(list[j].NextRun)
+	})
+	return list
+}
+
+// splay liefert einen festen Versatz pro Agent und Target in [0, Splay) - so laufen
+// mehrere Agents nicht gleichzeitig gegen dieselben Targets
+func (s *Scheduler) splay(target string) time.Duration {
+	if s.opts.Splay <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(s.seed))
+	h.Write([]byte{0})
+	h.Write([]byte(target))
+	return time.Duration(h.Sum64() % uint64(s.opts.Splay))
+}
+
+// withJitter verschiebt einen Termin um eine zufällige Dauer in [0, Jitter)
+func (s *Scheduler) withJitter(t time.Time, schedule Schedule) time.Time {
+	if t.IsZero() || schedule.Jitter <= 0 {
+		return t
+	}
+	return t.Add(time.Duration(rand.Int63n(int64(schedule.Jitter))))
+}
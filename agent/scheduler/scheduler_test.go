@@ -0,0 +1,252 @@
+package scheduler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// clock ist die Testuhr; der Scheduler bekommt die Zeit immer übergeben
+type clock struct{ now time.Time }
+
+func newClock() *clock {
+	return &clock{now: time.Date(2026, 3, 6, 10, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) time.Time {
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+func hourly() Schedule {
+	return Schedule{Name: "default", Interval: time.Hour}
+}
+
+func nextRun(t *testing.T, s *Scheduler, target string) time.Time {
+	t.Helper()
+	for _, e := range s.Entries() {
+		if e.Target == target {
+			return e.NextRun
+		}
+	}
+	t.Fatalf("no entry for %s", target)
+	return time.Time{}
+}
+
+// TestSplayBounds: erste Läufe verteilen sich fest pro Agent und Target über [0, Splay)
+func TestSplayBounds(t *testing.T) {
+	clk := newClock()
+	splay := 10 * time.Minute
+	var targets []string
+	for i := 0; i < 200; i++ {
+		targets = append(targets, fmt.Sprintf("host-%d.example", i))
+	}
+
+	plan := func(seed string) []time.Time {
+		s := New(seed)
+		s.Configure(targets, nil, hourly(), Options{Splay: splay}, clk.Now())
+		runs := make([]time.Time, len(targets))
+		for i, target := range targets {
+			runs[i] = nextRun(t, s, target)
+		}
+		return runs
+	}
+
+	a := plan("connector-a")
+	distinct := make(map[time.Time]bool)
+	for i, run := range a {
+		if run.Before(clk.Now()) || !run.Before(clk.Now().Add(splay)) {
+			t.Errorf("%s: first run %s outside [now, now+%s)", targets[i], run, splay)
+		}
+		distinct[run] = true
+	}
+	if len(distinct) < len(targets)/2 {
+		t.Errorf("only %d distinct start times for %d targets", len(distinct), len(targets))
+	}
+	if !reflect.DeepEqual(a, plan("connector-a")) {
+		t.Error("splay differs for the same agent")
+	}
+	if reflect.DeepEqual(a, plan("connector-b")) {
+		t.Error("splay identical for different agents")
+	}
+
+	// Ohne Splay sind alle sofort fällig
+	s := New("connector-a")
+	s.Configure(targets, nil, hourly(), Options{}, clk.Now())
+	if due := s.Due(clk.Now()); len(due) != len(targets) || due[0] != targets[0] {
+		t.Errorf("due without splay = %d targets", len(due))
+	}
+}
+
+// TestJitterBounds: jeder Lauf wird um [0, Jitter) verschoben, bei Intervall und Cron
+func TestJitterBounds(t *testing.T) {
+	cron, err := ParseCron("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jitter := 5 * time.Minute
+	for _, schedule := range []Schedule{
+		{Name: "interval", Interval: time.Hour, Jitter: jitter},
+		{Name: "cron", Cron: cron, Jitter: jitter},
+	} {
+		t.Run(schedule.Name, func(t *testing.T) {
+			clk := newClock()
+			s := New("connector")
+			s.Configure([]string{"a.example"}, nil, schedule, Options{}, clk.Now())
+
+			lo, hi := jitter, time.Duration(0)
+			for i := 0; i < 500; i++ {
+				at := clk.Advance(time.Hour)
+				s.MarkRun([]string{"a.example"}, at)
+				delay := nextRun(t, s, "a.example").Sub(schedule.next(at))
+				if delay < 0 || delay >= jitter {
+					t.Fatalf("jitter %s outside [0, %s)", delay, jitter)
+				}
+				lo, hi = minDuration(lo, delay), maxDuration(hi, delay)
+			}
+			if hi-lo < jitter/2 {
+				t.Errorf("jitter spread only %s-%s", lo, hi)
+			}
+		})
+	}
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// TestCatchUp prüft den ersten Termin nach einem Neustart bzw. einer Downtime
+func TestCatchUp(t *testing.T) {
+	nightly, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := newClock() // 10:00
+	now := clk.Now()
+	tests := []struct {
+		name     string
+		schedule Schedule
+		catchUp  CatchUp
+		lastRun  time.Duration // vor now, 0 = nie gescannt
+		want     time.Time
+	}{
+		{"never scanned", hourly(), CatchUpSkip, 0, now},
+		{"not missed", hourly(), CatchUpOnce, 20 * time.Minute, now.Add(40 * time.Minute)},
+		{"missed once", hourly(), CatchUpOnce, 3 * time.Hour, now},
+		{"missed skip", hourly(), CatchUpSkip, 3 * time.Hour, now.Add(time.Hour)},
+		{"default is once", hourly(), "", 3 * time.Hour, now},
+		{"cron not missed", Schedule{Name: "nightly", Cron: nightly}, CatchUpSkip, 8 * time.Hour, time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC)},
+		{"cron missed once", Schedule{Name: "nightly", Cron: nightly}, CatchUpOnce, 48 * time.Hour, now},
+		{"cron missed skip", Schedule{Name: "nightly", Cron: nightly}, CatchUpSkip, 48 * time.Hour, time.Date(2026, 3, 7, 2, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("connector")
+			if tt.lastRun > 0 {
+				s.Seed("a.example", now.Add(-tt.lastRun))
+			}
+			s.Configure([]string{"a.example"}, nil, tt.schedule, Options{CatchUp: tt.catchUp}, now)
+			if got := nextRun(t, s, "a.example"); !got.Equal(tt.want) {
+				t.Errorf("next run = %s, want %s", got, tt.want)
+			}
+			due := len(s.Due(now)) == 1
+			if due != !tt.want.After(now) {
+				t.Errorf("due = %v at %s", due, now)
+			}
+		})
+	}
+}
+
+// TestCatchUpWithSplay: nachgeholte Läufe werden ebenfalls verteilt
+func TestCatchUpWithSplay(t *testing.T) {
+	clk := newClock()
+	s := New("connector")
+	s.Seed("a.example", clk.Now().Add(-5*time.Hour))
+	s.Configure([]string{"a.example"}, nil, hourly(), Options{Splay: time.Minute, CatchUp: CatchUpOnce}, clk.Now())
+	if next := nextRun(t, s, "a.example"); next.Before(clk.Now()) || !next.Before(clk.Now().Add(time.Minute)) {
+		t.Errorf("catch-up run %s outside splay window", next)
+	}
+}
+
+// TestReconfigure: unveränderte Zeitpläne behalten ihren Termin, geänderte werden vom
+// letzten Lauf aus neu gerechnet und bei verpasstem Termin sofort nachgeholt
+func TestReconfigure(t *testing.T) {
+	clk := newClock()
+	s := New("connector")
+	targets := []string{"a.example", "b.internal"}
+	s.Configure(targets, nil, Schedule{Name: "default", Interval: 6 * time.Hour}, Options{CatchUp: CatchUpSkip}, clk.Now())
+	s.MarkRun(s.Due(clk.Now()), clk.Now())
+
+	clk.Advance(2 * time.Hour)
+	groups := []Group{{Patterns: []string{"*.INTERNAL"}, Schedule: Schedule{Name: "internal", Interval: time.Hour}}}
+	s.Configure(targets, groups, Schedule{Name: "default", Interval: 6 * time.Hour}, Options{CatchUp: CatchUpSkip}, clk.Now())
+
+	if got, want := nextRun(t, s, "a.example"), clk.Now().Add(4*time.Hour); !got.Equal(want) {
+		t.Errorf("unchanged schedule: next run %s, want %s", got, want)
+	}
+	if got := nextRun(t, s, "b.internal"); !got.Equal(clk.Now()) {
+		t.Errorf("changed schedule: next run %s, want now", got)
+	}
+	if due := s.Due(clk.Now()); !reflect.DeepEqual(due, []string{"b.internal"}) {
+		t.Errorf("due = %v", due)
+	}
+	entries := s.Entries()
+	if len(entries) != 2 || entries[0].Target != "b.internal" || entries[0].Schedule != "internal" || entries[0].Spec != "every 1h0m0s" ||
+		entries[1].Spec != "every 6h0m0s" || entries[1].LastRun == nil {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+// TestDueAndMarkRun spielt einige Stunden mit der Testuhr durch
+func TestDueAndMarkRun(t *testing.T) {
+	clk := newClock()
+	s := New("connector")
+	fast := Group{Patterns: []string{"fast.example"}, Schedule: Schedule{Name: "fast", Interval: 15 * time.Minute}}
+	s.Configure([]string{"slow.example", "fast.example"}, []Group{fast}, hourly(), Options{}, clk.Now())
+
+	runs := make(map[string]int)
+	for i := 0; i < 12; i++ { // 3 Stunden in 15-Minuten-Schritten
+		due := s.Due(clk.Now())
+		for _, target := range due {
+			runs[target]++
+		}
+		s.MarkRun(due, clk.Now())
+		wake, ok := s.NextWake()
+		if !ok || !wake.After(clk.Now()) {
+			t.Fatalf("NextWake = %s, %v at %s", wake, ok, clk.Now())
+		}
+		clk.Advance(15 * time.Minute)
+	}
+	if runs["fast.example"] != 12 || runs["slow.example"] != 3 {
+		t.Errorf("runs = %v, want fast 12, slow 3", runs)
+	}
+}
+
+// TestOverdue: ein Target gilt als überfällig, wenn seit dem Termin mehr als factor-1
+// Intervalle vergangen sind
+func TestOverdue(t *testing.T) {
+	clk := newClock()
+	s := New("connector")
+	s.Configure([]string{"a.example"}, nil, hourly(), Options{}, clk.Now())
+	s.MarkRun([]string{"a.example"}, clk.Now())
+
+	for _, tt := range []struct {
+		after time.Duration
+		want  bool
+	}{
+		{time.Hour, false},
+		{3 * time.Hour, false},
+		{3*time.Hour + time.Second, true},
+	} {
+		got := len(s.Overdue(clk.Now().Add(tt.after), 3)) == 1
+		if got != tt.want {
+			t.Errorf("overdue after %s = %v, want %v", tt.after, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// shutdown fährt den Agent geordnet herunter, nachdem der Haupt-Context abgebrochen
+// wurde: auf den laufenden Scan warten, ausstehende Schreibzugriffe und Sink-Records
+// innerhalb der Frist senden, Connector mit Grund offline melden und den Health-Server
+// beenden
+func (a *agent) shutdown(reason string, healthServer *http.Server) {
+	started := time.Now()
+	log.WithFields(logrus.Fields{
+		"reason":  reason,
+		"timeout": a.cfg.ShutdownTimeout,
+		"pending": a.outbox.Len(),
+	}).Info("Shutting down gracefully...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Laufender Scan bricht über den Context ab und schreibt seinen Endstatus
+	if !a.waitScans(ctx) {
+		log.Warn("Shutdown deadline reached while waiting for the running scan")
+	}
+
+	// Letzten Scan-Zustand (z.B. "cancelled") und gepufferte Daten senden
+	a.progress.Flush(ctx)
+	if pending := a.outbox.Flush(ctx); pending > 0 {
+		log.WithField("pending", pending).Warn("Shutdown deadline reached - unsent backend writes dropped")
+	}
+	if pending := a.sinks.Close(ctx); pending > 0 {
+		log.WithField("pending", pending).Warn("Shutdown deadline reached - unsent sink records dropped")
+	}
+
+	if a.cfg.ConnectorID != "" {
+		log.Info("Marking connector as offline...")
+		if err := a.client.MarkConnectorOffline(ctx, reason); err != nil {
+			log.WithError(err).Warn("Failed to mark connector offline")
+		}
+	}
+
+	if err := healthServer.Shutdown(ctx); err != nil {
+		log.WithError(err).Warn("Health check server did not stop cleanly")
+	}
+
+	log.WithField("duration", time.Since(started)).Info("Shutdown complete")
+}
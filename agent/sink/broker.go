@@ -0,0 +1,158 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/rotation"
+)
+
+// MessageVersion ist die Version des Nachrichtenformats für NATS und Kafka. Sie steigt
+// nur bei inkompatiblen Änderungen; neue Felder kommen ohne neue Version hinzu.
+const MessageVersion = 1
+
+// Defaults für Subject (NATS) und Topic (Kafka), Go-Templates über den Record
+const (
+	defaultSubject = "zertifikat-waechter.{{.Agent.TenantID}}.{{.Type}}"
+	defaultTopic   = "zertifikat-waechter.{{.Agent.TenantID}}.{{.Type}}"
+)
+
+// Message ist eine Nachricht an NATS bzw. Kafka: der Record mit Formatversion und
+// Ereignisnamen (wie X-Webhook-Event)
+type Message struct {
+	Version int         `json:"version"`
+	ID      string      `json:"id"`
+	Kind    Kind        `json:"kind"`
+	Type    string      `json:"type"`
+	Event   string      `json:"event"`
+	Time    time.Time   `json:"time"`
+	Agent   Agent       `json:"agent"`
+	Data    interface{} `json:"data"`
+}
+
+// NewMessage verpackt einen Record
+func NewMessage(rec Record) Message {
+	return Message{
+		Version: MessageVersion,
+		ID:      rec.ID,
+		Kind:    rec.Kind,
+		Type:    rec.Type,
+		Event:   EventName(rec.Type),
+		Time:    rec.Time,
+		Agent:   rec.Agent,
+		Data:    rec.Data,
+	}
+}
+
+// broker sind NATS- und Kafka-Sink: prepare rendert Subject bzw. Topic und Schlüssel,
+// bevor ein Record in den Spool kommt
+type broker interface {
+	prepare(rec Record) (Record, error)
+}
+
+// messageHeaders sind die Header jeder Nachricht (NATS-Header bzw. Kafka-Record-Header)
+func messageHeaders(rec Record) [][2]string {
+	return [][2]string{
+		{"Content-Type", "application/json"},
+		{"Message-Id", rec.ID},
+		{"Message-Type", rec.Type},
+		{"Message-Version", strconv.Itoa(MessageVersion)},
+	}
+}
+
+// recordKey liefert den Endpoint (host:port) eines Records - Nachrichten eines Endpoints
+// landen in derselben Kafka-Partition und bleiben geordnet ("" = kein Endpoint)
+func recordKey(rec Record) string {
+	host, port := "", 0
+	switch data := rec.Data.(type) {
+	case CertificateEvent:
+		host, port = data.Host, data.Port
+	case map[string]interface{}:
+		if change, ok := data["change"].(rotation.Event); ok {
+			host, port = change.Host, change.Port
+		} else {
+			host, _ = data["host"].(string)
+			port, _ = data["port"].(int)
+		}
+	}
+	if host == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// destination rendert Subject bzw. Topic eines Records; clean ersetzt unzulässige Zeichen
+type destination struct {
+	tmpl  *template.Template
+	clean func(string) string
+}
+
+func newDestination(name, text string, clean func(string) string) (*destination, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &destination{tmpl: tmpl, clean: clean}, nil
+}
+
+func (d *destination) render(rec Record) (string, error) {
+	var buf bytes.Buffer
+	if err := d.tmpl.Execute(&buf, rec); err != nil {
+		return "", err
+	}
+	name := d.clean(strings.TrimSpace(buf.String()))
+	if name == "" {
+		return "", fmt.Errorf("template rendered an empty name")
+	}
+	return name, nil
+}
+
+// natsSubject macht aus einem gerenderten Subject ein gültiges NATS-Subject: keine
+// Leerzeichen und Wildcards, keine leeren Tokens
+func natsSubject(s string) string {
+	tokens := strings.Split(s, ".")
+	for i, token := range tokens {
+		token = strings.Map(func(r rune) rune {
+			if r <= ' ' || r == '*' || r == '>' || r == 0x7f {
+				return '_'
+			}
+			return r
+		}, token)
+		if token == "" {
+			token = "_"
+		}
+		tokens[i] = token
+	}
+	return strings.Join(tokens, ".")
+}
+
+// maxTopicLength ist die maximale Länge eines Kafka-Topics
+const maxTopicLength = 249
+
+// kafkaTopic macht aus einem gerenderten Topic ein gültiges Kafka-Topic ([a-zA-Z0-9._-])
+func kafkaTopic(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+	if s == "." || s == ".." {
+		s = "_"
+	}
+	if len(s) > maxTopicLength {
+		s = s[:maxTopicLength]
+	}
+	return s
+}
+
+// marshalMessage serialisiert die Nachricht eines Records
+func marshalMessage(rec Record) ([]byte, error) {
+	return json.Marshal(NewMessage(rec))
+}
@@ -0,0 +1,629 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/scanrun"
+)
+
+// defaultAttempts ist die Anzahl Versuche pro Record und Sink
+const defaultAttempts = 3
+
+// maxRetryDelay begrenzt die Wartezeit zwischen Versuchen (Spool: ohne max_attempts)
+const maxRetryDelay = 5 * time.Minute
+
+// writeTimeout begrenzt einen einzelnen Schreibversuch
+const writeTimeout = 10 * time.Second
+
+// maxDeliveries begrenzt das Zustellprotokoll im Speicher
+const maxDeliveries = 200
+
+// Stats sind die Zähler eines Sinks
+type Stats struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Sent         int64      `json:"sent"`
+	Failed       int64      `json:"failed"`  // nach allen Versuchen verworfen
+	Dropped      int64      `json:"dropped"` // Warteschlange bzw. Spool voll
+	DeadLettered int64      `json:"dead_lettered"`
+	QueueDepth   int        `json:"queue_depth"`
+	Spooled      int        `json:"spooled,omitempty"` // Broker-Sinks: Records im Spool
+	Capacity     int        `json:"capacity"`
+	LastError    string     `json:"last_error,omitempty"`
+	LastFailAt   *time.Time `json:"last_failure_at,omitempty"`
+}
+
+// Ergebnis eines Zustellversuchs (Delivery.Status)
+const (
+	DeliveryDelivered = "delivered"
+	DeliveryRetrying  = "retrying" // Versuch gescheitert, wird wiederholt
+	DeliveryFailed    = "failed"   // endgültig gescheitert
+)
+
+// Delivery ist ein Eintrag im Zustellprotokoll
+type Delivery struct {
+	Time         time.Time `json:"time"`
+	Sink         string    `json:"sink"`
+	RecordID     string    `json:"record_id"`
+	Type         string    `json:"type"`
+	Attempt      int       `json:"attempt"`
+	Status       string    `json:"status"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	DurationMs   int64     `json:"duration_ms"`
+	Error        string    `json:"error,omitempty"`
+	DeadLettered bool      `json:"dead_lettered,omitempty"`
+}
+
+// deadLetter ist ein Eintrag der Dead-Letter-Datei
+type deadLetter struct {
+	FailedAt time.Time `json:"failed_at"`
+	Sink     string    `json:"sink"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	Record   Record    `json:"record"`
+}
+
+// output ist ein Sink mit eigenem Filter, eigener Warteschlange und eigener Goroutine -
+// ein langsamer oder ausgefallener Sink hält die anderen nicht auf
+type output struct {
+	cfg   Config
+	sink  Sink
+	queue chan Record
+	done  chan struct{}
+
+	attempts    int
+	retryDelay  time.Duration
+	deadLetter  *os.File // nil = keine Dead-Letter-Datei
+	deliveryLog *os.File // nil = kein Zustellprotokoll
+
+	// Broker-Sinks: Records aus queue kommen in den Spool, wake weckt die Zustellung
+	spool       *spool
+	spoolMaxAge time.Duration
+	wake        chan struct{}
+
+	sent         atomic.Int64
+	failed       atomic.Int64
+	dropped      atomic.Int64
+	deadLettered atomic.Int64
+
+	mu         sync.Mutex
+	lastError  string
+	lastFailAt time.Time
+}
+
+// Dispatcher verteilt Records an alle Sinks, deren Filter passt (Fan-out)
+type Dispatcher struct {
+	agent   Agent
+	log     *logrus.Logger
+	outputs []*output
+	stop    chan struct{}
+	closed  atomic.Bool
+
+	mu         sync.Mutex
+	deliveries []Delivery // neueste zuletzt
+}
+
+// NewDispatcher erstellt einen Dispatcher ohne Sinks; agent kennzeichnet alle Records
+func NewDispatcher(agent Agent, log *logrus.Logger) *Dispatcher {
+	return &Dispatcher{agent: agent, log: log, stop: make(chan struct{})}
+}
+
+// Add meldet einen Sink an und öffnet Dead-Letter-Datei und Zustellprotokoll.
+// Muss vor Start aufgerufen werden.
+func (d *Dispatcher) Add(cfg Config, s Sink) error {
+	o := &output{
+		cfg:        cfg,
+		sink:       s,
+		attempts:   defaultAttempts,
+		retryDelay: time.Second,
+		done:       make(chan struct{}),
+	}
+	size := cfg.QueueSize
+	if size == 0 {
+		size = defaultQueueSize
+	}
+	o.queue = make(chan Record, size)
+	if cfg.MaxAttempts > 0 {
+		o.attempts = cfg.MaxAttempts
+	}
+	if cfg.RetryDelaySeconds > 0 {
+		o.retryDelay = time.Duration(cfg.RetryDelaySeconds) * time.Second
+	}
+
+	var err error
+	if cfg.SpoolFile != "" {
+		if _, ok := s.(broker); !ok {
+			return fmt.Errorf("sink type %s does not support spool_file", cfg.Type)
+		}
+		if o.spool, err = openSpool(cfg.SpoolFile, cfg.spoolMaxRecords()); err != nil {
+			return fmt.Errorf("open spool: %w", err)
+		}
+		// Ohne max_attempts bis spool_max_age_seconds
+		o.attempts = cfg.MaxAttempts
+		o.spoolMaxAge = cfg.spoolMaxAge()
+		o.wake = make(chan struct{}, 1)
+	}
+	if cfg.DeadLetter != "" {
+		if o.deadLetter, err = openAppend(cfg.DeadLetter); err != nil {
+			return fmt.Errorf("open dead letter file: %w", err)
+		}
+	}
+	if cfg.DeliveryLog != "" {
+		if o.deliveryLog, err = openAppend(cfg.DeliveryLog); err != nil {
+			return fmt.Errorf("open delivery log: %w", err)
+		}
+	}
+	d.outputs = append(d.outputs, o)
+	return nil
+}
+
+// openAppend öffnet eine JSONL-Datei zum Anhängen (Verzeichnis wird bei Bedarf angelegt)
+func openAppend(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+}
+
+// Len liefert die Anzahl angemeldeter Sinks
+func (d *Dispatcher) Len() int {
+	return len(d.outputs)
+}
+
+// ExpiringDays liefert die größte Ablauf-Schwelle aller Sinks, die certificate_expiring
+// bekommen - Zertifikate darunter werden gemeldet (0 = kein Sink interessiert sich dafür)
+func (d *Dispatcher) ExpiringDays() int {
+	days := 0
+	probe := Record{Kind: KindEvent, Type: TypeCertificateExpiring}
+	for _, o := range d.outputs {
+		if o.cfg.Match(probe) && o.cfg.expiringDays() > days {
+			days = o.cfg.expiringDays()
+		}
+	}
+	return days
+}
+
+// Start startet pro Sink eine Goroutine
+func (d *Dispatcher) Start() {
+	for _, o := range d.outputs {
+		go d.run(o)
+	}
+}
+
+// Emit verteilt einen Record. Blockiert nie - ist die Warteschlange eines Sinks voll,
+// wird der Record für diesen Sink verworfen; nach Close werden Records ignoriert. data
+// wird später serialisiert und darf danach nicht mehr verändert werden.
+func (d *Dispatcher) Emit(kind Kind, typ string, data interface{}) {
+	d.emit(Record{Kind: kind, Type: typ, Data: data})
+}
+
+// EmitLog verteilt eine Meldung des Agents
+func (d *Dispatcher) EmitLog(level, message string, metadata map[string]interface{}) {
+	d.emit(Record{Kind: KindLog, Type: TypeLog, Level: level, Data: map[string]interface{}{
+		"message":  message,
+		"metadata": metadata,
+	}})
+}
+
+func (d *Dispatcher) emit(rec Record) {
+	if len(d.outputs) == 0 || d.closed.Load() {
+		return
+	}
+	rec.ID = scanrun.NewID()
+	rec.Time = time.Now().UTC()
+	rec.Agent = d.agent
+
+	for _, o := range d.outputs {
+		rec := rec
+		if !o.cfg.Match(rec) {
+			if _, ok := o.sink.(deduper); !ok || !endsFindings(rec) {
+				continue
+			}
+			rec.forgetOnly = true
+		}
+		select {
+		case o.queue <- rec:
+		default:
+			if n := o.dropped.Add(1); n == 1 || n%100 == 0 {
+				d.log.WithFields(logrus.Fields{"sink": o.cfg.Name, "dropped": n}).Warn("Sink queue full - dropping records")
+			}
+		}
+	}
+}
+
+// spoolBatch begrenzt die Records, die in einer Transaktion in den Spool kommen
+const spoolBatch = 100
+
+// fillSpool übernimmt die Records aus der Warteschlange in den Spool, bis Close
+// aufgerufen wurde
+func (d *Dispatcher) fillSpool(o *output) {
+	for {
+		select {
+		case rec := <-o.queue:
+			batch := []Record{rec}
+		more:
+			for len(batch) < spoolBatch {
+				select {
+				case rec := <-o.queue:
+					batch = append(batch, rec)
+				default:
+					break more
+				}
+			}
+			d.spoolRecords(o, batch)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// spoolRecords bereitet Records auf und legt sie im Spool ab. Wer sich nicht aufbereiten
+// lässt, landet gleich in der Dead-Letter-Datei; ist der Spool voll, wird verworfen.
+func (d *Dispatcher) spoolRecords(o *output, recs []Record) {
+	values := make([][]byte, 0, len(recs))
+	for _, rec := range recs {
+		started := time.Now()
+		rec, err := o.sink.(broker).prepare(rec)
+		var value []byte
+		if err == nil {
+			value, err = encodeSpooled(rec)
+		}
+		if err != nil {
+			d.fail(o, rec, 1, started, Permanent(err))
+			continue
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	dropped, err := o.spool.put(values)
+	if err != nil {
+		dropped = len(values)
+		d.log.WithError(err).WithField("sink", o.cfg.Name).Error("Failed to write sink spool")
+	}
+	if dropped > 0 {
+		n := o.dropped.Add(int64(dropped))
+		if before := n - int64(dropped); before == 0 || before/100 != n/100 {
+			d.log.WithFields(logrus.Fields{"sink": o.cfg.Name, "dropped": n}).Warn("Sink spool full - dropping records")
+		}
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run schreibt die Records eines Sinks, bis Close aufgerufen wurde. Broker-Sinks füllen
+// den Spool und stellen aus ihm zu - in zwei Goroutinen, damit die Warteschlange auch
+// während langer Wiederholungen leer läuft.
+func (d *Dispatcher) run(o *output) {
+	defer close(o.done)
+	if o.spool != nil {
+		delivered := make(chan struct{})
+		go func() {
+			defer close(delivered)
+			d.runSpool(o)
+		}()
+		d.fillSpool(o)
+		<-delivered
+		return
+	}
+	for {
+		select {
+		case rec := <-o.queue:
+			d.handle(o, rec, o.attempts)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// runSpool stellt die Records im Spool der Reihe nach zu, bis Close aufgerufen wurde.
+// Ein Record verlässt den Spool erst, wenn er zugestellt ist oder in der Dead-Letter-Datei
+// steht; was beim Shutdown übrig ist, folgt nach dem Neustart.
+func (d *Dispatcher) runSpool(o *output) {
+	for {
+		seq, rec, err := o.spool.first()
+		switch {
+		case err != nil && seq != 0:
+			o.failed.Add(1)
+			d.log.WithError(err).WithField("sink", o.cfg.Name).Error("Dropping unreadable spool entry")
+		case err != nil:
+			d.log.WithError(err).WithField("sink", o.cfg.Name).Error("Failed to read sink spool")
+		case seq == 0:
+			select {
+			case <-o.wake:
+				continue
+			case <-d.stop:
+				return
+			}
+		case !d.deliver(o, rec, o.attempts):
+			return
+		}
+
+		if seq != 0 {
+			err = o.spool.remove(seq)
+			if err != nil {
+				d.log.WithError(err).WithField("sink", o.cfg.Name).Error("Failed to remove record from sink spool")
+			}
+		}
+		if err != nil {
+			select {
+			case <-time.After(o.retryDelay):
+			case <-d.stop:
+				return
+			}
+		}
+	}
+}
+
+// handle schreibt einen Record und pflegt die gemeldeten Befunde des Sinks (deduper)
+func (d *Dispatcher) handle(o *output, rec Record, attempts int) {
+	dedupe, ok := o.sink.(deduper)
+	if ok {
+		dedupe.forget(rec)
+	}
+	if !rec.forgetOnly {
+		d.deliver(o, rec, attempts)
+	}
+	if ok {
+		if err := dedupe.save(); err != nil {
+			d.log.WithError(err).WithField("sink", o.cfg.Name).Warn("Failed to save sink state")
+		}
+	}
+}
+
+// deliver schreibt einen Record mit bis zu attempts Versuchen (0 = ohne Grenze, nur mit
+// Spool); endgültig gescheiterte Records landen in der Dead-Letter-Datei. Records aus dem
+// Spool gelten nach spool_max_age_seconds als gescheitert. Liefert false, wenn der Shutdown
+// einen Record aus dem Spool unterbrochen hat - er bleibt dann im Spool.
+func (d *Dispatcher) deliver(o *output, rec Record, attempts int) bool {
+	var err error
+	var started time.Time
+	attempt := 1
+retry:
+	for ; ; attempt++ {
+		started = time.Now()
+		err = o.write(rec, attempt)
+		switch {
+		case errors.Is(err, ErrSkipped):
+			return true
+		case err == nil:
+			o.sent.Add(1)
+			d.record(o, rec, attempt, started, DeliveryDelivered, nil, false)
+			return true
+		case (attempts > 0 && attempt >= attempts) || !retryable(err):
+			break retry
+		case o.spool != nil && time.Since(rec.Time) > o.spoolMaxAge:
+			err = fmt.Errorf("spooled longer than %s: %w", o.spoolMaxAge, err)
+			break retry
+		}
+		d.record(o, rec, attempt, started, DeliveryRetrying, err, false)
+
+		delay := time.Duration(attempt) * o.retryDelay
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		select {
+		case <-d.stop:
+			// Beim Shutdown nicht mehr warten
+			if o.spool != nil {
+				return false
+			}
+			break retry
+		case <-time.After(delay):
+		}
+	}
+	d.fail(o, rec, attempt, started, err)
+	return true
+}
+
+// fail zählt einen endgültig gescheiterten Record und schreibt ihn in die Dead-Letter-Datei
+func (d *Dispatcher) fail(o *output, rec Record, attempt int, started time.Time, err error) {
+	o.failed.Add(1)
+	o.mu.Lock()
+	o.lastError = err.Error()
+	o.lastFailAt = time.Now()
+	o.mu.Unlock()
+
+	deadLettered := d.deadLetter(o, rec, attempt, err)
+	d.record(o, rec, attempt, started, DeliveryFailed, err, deadLettered)
+	d.log.WithError(err).WithFields(logrus.Fields{
+		"sink":          o.cfg.Name,
+		"type":          rec.Type,
+		"attempts":      attempt,
+		"dead_lettered": deadLettered,
+	}).Warn("Sink write failed")
+}
+
+// write führt einen Schreibversuch mit Timeout aus; ein Panic im Sink bleibt auf ihn beschränkt
+func (o *output) write(rec Record, attempt int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	return o.sink.Write(context.WithValue(ctx, attemptKey{}, attempt), rec)
+}
+
+// deadLetter hängt einen endgültig gescheiterten Record an die Dead-Letter-Datei an
+func (d *Dispatcher) deadLetter(o *output, rec Record, attempts int, cause error) bool {
+	if o.deadLetter == nil {
+		return false
+	}
+	line, err := json.Marshal(deadLetter{FailedAt: time.Now().UTC(), Sink: o.cfg.Name, Attempts: attempts, Error: cause.Error(), Record: rec})
+	if err == nil {
+		_, err = o.deadLetter.Write(append(line, '\n'))
+	}
+	if err != nil {
+		d.log.WithError(err).WithField("sink", o.cfg.Name).Warn("Failed to write dead letter")
+		return false
+	}
+	o.deadLettered.Add(1)
+	return true
+}
+
+// record hält einen Zustellversuch im Speicher und im Zustellprotokoll fest
+func (d *Dispatcher) record(o *output, rec Record, attempt int, started time.Time, status string, err error, deadLettered bool) {
+	entry := Delivery{
+		Time:         time.Now().UTC(),
+		Sink:         o.cfg.Name,
+		RecordID:     rec.ID,
+		Type:         rec.Type,
+		Attempt:      attempt,
+		Status:       status,
+		DurationMs:   time.Since(started).Milliseconds(),
+		DeadLettered: deadLettered,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			entry.StatusCode = statusErr.StatusCode
+		}
+	}
+
+	d.mu.Lock()
+	d.deliveries = append(d.deliveries, entry)
+	if len(d.deliveries) > maxDeliveries {
+		d.deliveries = d.deliveries[len(d.deliveries)-maxDeliveries:]
+	}
+	d.mu.Unlock()
+
+	if o.deliveryLog != nil {
+		if line, err := json.Marshal(entry); err == nil {
+			o.deliveryLog.Write(append(line, '\n'))
+		}
+	}
+}
+
+// Deliveries liefert die letzten Zustellversuche, neuester zuerst
+// (sinkName "" = alle Sinks, limit <= 0 = alle gespeicherten)
+func (d *Dispatcher) Deliveries(sinkName string, limit int) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := []Delivery{}
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		if sinkName != "" && d.deliveries[i].Sink != sinkName {
+			continue
+		}
+		list = append(list, d.deliveries[i])
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+	}
+	return list
+}
+
+// Close beendet die Goroutinen, schreibt verbliebene Records (je ein Versuch) bis
+// ctx abläuft und schließt die Sinks. Liefert die Anzahl nicht geschriebener Records;
+// Records im Spool zählen nicht dazu, sie werden nach dem Neustart zugestellt.
+func (d *Dispatcher) Close(ctx context.Context) int {
+	d.closed.Store(true)
+	close(d.stop)
+
+	pending := 0
+	for _, o := range d.outputs {
+		select {
+		case <-o.done:
+		case <-ctx.Done():
+			// Sink hängt noch in einem Schreibversuch - nicht parallel schreiben
+			pending += len(o.queue)
+			continue
+		}
+		if o.spool != nil {
+			d.closeSpool(o)
+		} else {
+		drain:
+			for {
+				select {
+				case rec := <-o.queue:
+					if ctx.Err() != nil {
+						pending++
+						continue
+					}
+					d.handle(o, rec, 1)
+				default:
+					break drain
+				}
+			}
+		}
+		if err := o.sink.Close(); err != nil {
+			d.log.WithError(err).WithField("sink", o.cfg.Name).Warn("Failed to close sink")
+		}
+		for _, f := range []*os.File{o.deadLetter, o.deliveryLog} {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}
+	return pending
+}
+
+// closeSpool sichert die noch wartenden Records eines Broker-Sinks im Spool, statt sie
+// zu senden, und schließt ihn
+func (d *Dispatcher) closeSpool(o *output) {
+	var recs []Record
+	for len(o.queue) > 0 {
+		recs = append(recs, <-o.queue)
+	}
+	d.spoolRecords(o, recs)
+	if n := o.spool.count(); n > 0 {
+		d.log.WithFields(logrus.Fields{"sink": o.cfg.Name, "spooled": n}).Info("Records remain in sink spool")
+	}
+	if err := o.spool.close(); err != nil {
+		d.log.WithError(err).WithField("sink", o.cfg.Name).Warn("Failed to close sink spool")
+	}
+}
+
+// Configs liefert die Einträge aller Sinks ohne Geheimnisse
+func (d *Dispatcher) Configs() []Config {
+	list := make([]Config, 0, len(d.outputs))
+	for _, o := range d.outputs {
+		list = append(list, o.cfg.Redacted())
+	}
+	return list
+}
+
+// Stats liefert die Zähler aller Sinks in Konfigurationsreihenfolge
+func (d *Dispatcher) Stats() []Stats {
+	list := make([]Stats, 0, len(d.outputs))
+	for _, o := range d.outputs {
+		o.mu.Lock()
+		stats := Stats{
+			Name:         o.cfg.Name,
+			Type:         o.cfg.Type,
+			Sent:         o.sent.Load(),
+			Failed:       o.failed.Load(),
+			Dropped:      o.dropped.Load(),
+			DeadLettered: o.deadLettered.Load(),
+			QueueDepth:   len(o.queue),
+			Capacity:     cap(o.queue),
+			LastError:    o.lastError,
+		}
+		if o.spool != nil {
+			stats.Spooled = o.spool.count()
+		}
+		if !o.lastFailAt.IsZero() {
+			at := o.lastFailAt.UTC()
+			stats.LastFailAt = &at
+		}
+		o.mu.Unlock()
+		list = append(list, stats)
+	}
+	return list
+}
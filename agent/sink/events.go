@@ -0,0 +1,88 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Severities der Zertifikats-Ereignisse (wie beim Webhook-Versand aus der Cloud)
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+// CertificateEvent sind die Daten der Zertifikats-Ereignisse (certificate_discovered,
+// certificate_expiring, certificate_validation_failed, certificate_weak_crypto, endpoint_unreachable)
+type CertificateEvent struct {
+	Host          string     `json:"host"`
+	Port          int        `json:"port"`
+	SNI           string     `json:"sni,omitempty"`
+	Severity      string     `json:"severity"`
+	Message       string     `json:"message"`
+	RunID         string     `json:"run_id,omitempty"`
+	AssetID       string     `json:"asset_id,omitempty"`
+	CertificateID string     `json:"certificate_id,omitempty"`
+	Fingerprint   string     `json:"fingerprint,omitempty"`
+	SubjectCN     string     `json:"subject_cn,omitempty"`
+	Issuer        string     `json:"issuer,omitempty"`
+	NotAfter      *time.Time `json:"not_after,omitempty"`
+	DaysLeft      int        `json:"days_left"`
+	Reasons       []string   `json:"reasons,omitempty"` // Prüfungen, die fehlgeschlagen sind (bzw. Befund-Codes)
+	ErrorClass    string     `json:"error_class,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// eventNames übersetzt Record-Typen in die Ereignisnamen der Webhooks (X-Webhook-Event)
+var eventNames = map[string]string{
+	TypeCertificateDiscovered: "certificate.discovered",
+	TypeCertificateChange:     "certificate.changed",
+	TypeCertificateExpiring:   "certificate.expiring",
+	TypeValidationFailed:      "certificate.validation_failed",
+	TypeWeakCrypto:            "certificate.weak_crypto",
+	TypeEndpointUnreachable:   "endpoint.unreachable",
+	TypeScanRunFinished:       "scan.completed",
+}
+
+// EventName liefert den Ereignisnamen eines Record-Typs (unbekannte Typen unverändert)
+func EventName(typ string) string {
+	if name, ok := eventNames[typ]; ok {
+		return name
+	}
+	return typ
+}
+
+// templateFuncs stehen in Payload-Templates zur Verfügung
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"event": EventName,
+	"upper": strings.ToUpper,
+}
+
+// payloadTemplate übersetzt template bzw. template_file (nil = Record als JSON)
+func (c *Config) payloadTemplate() (*template.Template, error) {
+	text := c.Template
+	if c.TemplateFile != "" {
+		data, err := os.ReadFile(c.TemplateFile)
+		if err != nil {
+			return nil, fmt.Errorf("read template: %w", err)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(c.Name).Option("missingkey=zero").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
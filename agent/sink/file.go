@@ -0,0 +1,47 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriterSink schreibt jeden Record als JSON-Zeile (JSONL) in einen Writer
+type WriterSink struct {
+	w     io.Writer
+	close func() error
+}
+
+// NewWriterSink schreibt in w (z.B. os.Stdout); Close schließt w nicht
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w, close: func() error { return nil }}
+}
+
+// NewFileSink hängt an die Datei path an (wird bei Bedarf angelegt).
+// Kompatibel mit logrotate im copytruncate-Modus.
+func NewFileSink(path string) (*WriterSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("create sink directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open sink file: %w", err)
+	}
+	return &WriterSink{w: f, close: f.Close}, nil
+}
+
+func (s *WriterSink) Write(ctx context.Context, rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+func (s *WriterSink) Close() error {
+	return s.close()
+}
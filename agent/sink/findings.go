@@ -0,0 +1,121 @@
+package sink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zertifikat-waechter/agent/lifecycle"
+)
+
+// findings merkt sich pro Endpoint und Befund-Art den zuletzt gemeldeten Stand
+// (Fingerprint, Severity, Gründe). Jeder Scan meldet bestehende Befunde erneut; gesendet
+// wird nur, wenn sich der Stand ändert - z.B. wenn ein ablaufendes Zertifikat die
+// kritische Schwelle unterschreitet. Zertifikatswechsel und ausgemusterte Endpoints
+// löschen die Einträge des Endpoints, die Größe ist damit durch die Endpoints begrenzt.
+type findings struct {
+	path  string                       // "" = nur im Speicher
+	state map[string]map[string]string // host:port -> Befund-Art -> Stand
+	dirty bool
+}
+
+// newFindings lädt den gespeicherten Stand aus path (falls gesetzt und vorhanden)
+func newFindings(path string) (*findings, error) {
+	f := &findings{path: path, state: make(map[string]map[string]string)}
+	if path == "" {
+		return f, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &f.state); err != nil {
+		return nil, fmt.Errorf("parse state file %s: %w", path, err)
+	}
+	if f.state == nil {
+		f.state = make(map[string]map[string]string)
+	}
+	return f, nil
+}
+
+// findingState fasst den Stand eines Befunds zusammen
+func findingState(fingerprint, severity string, reasons []string) string {
+	return fingerprint + "|" + severity + "|" + strings.Join(reasons, ",")
+}
+
+// reported meldet, ob der Befund mit genau diesem Stand schon gesendet wurde
+func (f *findings) reported(endpoint, finding, state string) bool {
+	return f.state[endpoint][finding] == state
+}
+
+// mark hält einen gesendeten Befund fest
+func (f *findings) mark(endpoint, finding, state string) {
+	if f.state[endpoint] == nil {
+		f.state[endpoint] = make(map[string]string)
+	}
+	if f.state[endpoint][finding] != state {
+		f.state[endpoint][finding] = state
+		f.dirty = true
+	}
+}
+
+// forget löscht die Befunde eines Endpoints nach einem Zertifikatswechsel bzw. wenn
+// der Endpoint ausgemustert wurde
+func (f *findings) forget(rec Record) {
+	if !endsFindings(rec) {
+		return
+	}
+	endpoint := recordKey(rec)
+	if _, ok := f.state[endpoint]; ok {
+		delete(f.state, endpoint)
+		f.dirty = true
+	}
+}
+
+// save schreibt den Stand atomar (nur bei Änderungen)
+func (f *findings) save() error {
+	if f.path == "" || !f.dirty {
+		return nil
+	}
+	data, err := json.Marshal(f.state)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(f.path), 0o750)
+	}
+	if err == nil {
+		tmp := f.path + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o640); err == nil {
+			err = os.Rename(tmp, f.path)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("save state file: %w", err)
+	}
+	f.dirty = false
+	return nil
+}
+
+// deduper ist ein Sink mit findings. Er bekommt Zertifikatswechsel und ausgemusterte
+// Endpoints auch dann, wenn sein Filter sie nicht durchlässt (dann nur forget, kein Write);
+// der Dispatcher speichert den Stand nach jedem Record.
+type deduper interface {
+	forget(rec Record)
+	save() error
+}
+
+// endsFindings meldet Records, nach denen die Befunde eines Endpoints nicht mehr gelten
+func endsFindings(rec Record) bool {
+	switch rec.Type {
+	case TypeCertificateChange:
+		return true
+	case TypeAssetTransition:
+		data, ok := rec.Data.(map[string]interface{})
+		return ok && fmt.Sprint(data["to"]) == string(lifecycle.StateRetired)
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+)
+
+// KafkaOptions sind die Einstellungen eines Kafka-Sinks
+type KafkaOptions struct {
+	Brokers  []string // Bootstrap-Broker host:port
+	Topic    *destination
+	Username string // SASL/PLAIN, leer = ohne Anmeldung
+	Password string
+	TLS      *tls.Config // nil = ohne TLS
+	Timeout  time.Duration
+}
+
+// KafkaSink veröffentlicht Records mit acks=all. Ein Record gilt erst als zugestellt, wenn
+// alle In-Sync-Replicas ihn haben; Schlüssel ist der Endpoint, damit Nachrichten eines
+// Endpoints in einer Partition geordnet bleiben.
+type KafkaSink struct {
+	opts   KafkaOptions
+	client *kgo.Client
+}
+
+// Fehler des Brokers, bei denen Wiederholen nicht hilft
+var kafkaPermanent = []error{
+	kerr.MessageTooLarge,
+	kerr.InvalidTopicException,
+	kerr.RecordListTooLarge,
+	kerr.TopicAuthorizationFailed,
+	kerr.InvalidRecord,
+}
+
+// NewKafkaSink erstellt einen Kafka-Sink; Verbindungen werden beim ersten Record aufgebaut
+func NewKafkaSink(opts KafkaOptions) *KafkaSink {
+	return &KafkaSink{opts: opts}
+}
+
+func (s *KafkaSink) Write(ctx context.Context, rec Record) error {
+	rec, err := s.prepare(rec)
+	if err != nil {
+		return err
+	}
+	body, err := marshalMessage(rec)
+	if err != nil {
+		return Permanent(err)
+	}
+	if s.client == nil {
+		if s.client, err = s.newClient(); err != nil {
+			return Permanent(fmt.Errorf("kafka client: %w", err))
+		}
+	}
+
+	record := &kgo.Record{Topic: rec.destination, Value: body}
+	if rec.key != "" {
+		record.Key = []byte(rec.key)
+	}
+	for _, h := range messageHeaders(rec) {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: h[0], Value: []byte(h[1])})
+	}
+	if err := s.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		for _, permanent := range kafkaPermanent {
+			if errors.Is(err, permanent) {
+				return Permanent(fmt.Errorf("produce to %s: %w", rec.destination, err))
+			}
+		}
+		return fmt.Errorf("produce to %s: %w", rec.destination, err)
+	}
+	return nil
+}
+
+// prepare rendert Topic und Schlüssel eines Records (nicht bei Records aus dem Spool)
+func (s *KafkaSink) prepare(rec Record) (Record, error) {
+	if rec.destination != "" {
+		return rec, nil
+	}
+	topic, err := s.opts.Topic.render(rec)
+	if err != nil {
+		return rec, Permanent(fmt.Errorf("render topic: %w", err))
+	}
+	rec.destination, rec.key = topic, recordKey(rec)
+	return rec, nil
+}
+
+// newClient erstellt den Producer. Die Partition wählt er wie die Java-Clients (murmur2
+// über den Schlüssel); ohne Idempotenz und Kompression, damit auch Event Hubs & Co. passen.
+func (s *KafkaSink) newClient() (*kgo.Client, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(s.opts.Brokers...),
+		kgo.ClientID("zertifikat-waechter-agent"),
+		kgo.DialTimeout(s.opts.Timeout),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.DisableIdempotentWrite(),
+		kgo.ProducerBatchCompression(kgo.NoCompression()),
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+		kgo.RecordDeliveryTimeout(s.opts.Timeout),
+		kgo.AllowAutoTopicCreation(),
+	}
+	if s.opts.TLS != nil {
+		// ServerName setzt der Client pro Broker
+		opts = append(opts, kgo.DialTLSConfig(s.opts.TLS.Clone()))
+	}
+	if s.opts.Username != "" {
+		opts = append(opts, kgo.SASL(plain.Auth{User: s.opts.Username, Pass: s.opts.Password}.AsMechanism()))
+	}
+	return kgo.NewClient(opts...)
+}
+
+func (s *KafkaSink) Close() error {
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const testTopic = "zertifikat-waechter.tenant-1.certificate_expiring"
+
+// TestKafkaProduce: Topic, Schlüssel, Header und Nachrichtenformat, Partition nach Endpoint
+func TestKafkaProduce(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, testTopic))
+	if err != nil {
+		t.Fatalf("kfake: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+
+	dir := t.TempDir()
+	cfg := Config{Name: "kafka", Type: "kafka", Servers: cluster.ListenAddrs(),
+		SpoolFile: filepath.Join(dir, "kafka.spool"), DeadLetter: filepath.Join(dir, "kafka-dead.jsonl")}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, id := range []string{"rec-1", "rec-2"} {
+		if err := s.Write(ctx, brokerRecord(id)); err != nil {
+			t.Fatalf("Write %s: %v", id, err)
+		}
+	}
+
+	consumer, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics(testTopic))
+	if err != nil {
+		t.Fatalf("consumer: %v", err)
+	}
+	defer consumer.Close()
+	var records []*kgo.Record
+	for len(records) < 2 && ctx.Err() == nil {
+		consumer.PollFetches(ctx).EachRecord(func(r *kgo.Record) { records = append(records, r) })
+	}
+	if len(records) != 2 {
+		t.Fatalf("consumed %d records, want 2", len(records))
+	}
+
+	if records[0].Partition != records[1].Partition {
+		t.Errorf("records of one endpoint in partitions %d and %d", records[0].Partition, records[1].Partition)
+	}
+	r := records[0]
+	if string(r.Key) != "api.internal:443" {
+		t.Errorf("key = %q", r.Key)
+	}
+	headers := map[string]string{}
+	for _, h := range r.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers["Message-Version"] != "1" || headers["Message-Type"] != TypeCertificateExpiring || headers["Content-Type"] != "application/json" {
+		t.Errorf("headers = %v", headers)
+	}
+	var body struct {
+		Version int    `json:"version"`
+		ID      string `json:"id"`
+	}
+	if err := json.Unmarshal(r.Value, &body); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if body.Version != MessageVersion || body.ID != headers["Message-Id"] {
+		t.Errorf("message = %+v, Message-Id %s", body, headers["Message-Id"])
+	}
+}
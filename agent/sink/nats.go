@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSOptions sind die Einstellungen eines NATS-Sinks
+type NATSOptions struct {
+	Servers  []string // host:port
+	Subject  *destination
+	Username string
+	Password string      // ohne Username: Token
+	TLS      *tls.Config // nil = ohne TLS (außer der Server verlangt es)
+	Timeout  time.Duration
+}
+
+// NATSSink veröffentlicht Records über NATS JetStream. Jede Nachricht wird erst nach der
+// Bestätigung (PubAck) des Streams als zugestellt gezählt; Wiederholungen tragen dieselbe
+// Nats-Msg-Id, JetStream verwirft sie innerhalb seines Duplikat-Fensters.
+type NATSSink struct {
+	opts NATSOptions
+
+	conn *nats.Conn
+	js   jetstream.JetStream
+}
+
+// NewNATSSink erstellt einen NATS-Sink; die Verbindung wird beim ersten Record aufgebaut
+func NewNATSSink(opts NATSOptions) *NATSSink {
+	return &NATSSink{opts: opts}
+}
+
+func (s *NATSSink) Write(ctx context.Context, rec Record) error {
+	rec, err := s.prepare(rec)
+	if err != nil {
+		return err
+	}
+	body, err := marshalMessage(rec)
+	if err != nil {
+		return Permanent(err)
+	}
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+
+	msg := nats.NewMsg(rec.destination)
+	msg.Data = body
+	for _, h := range messageHeaders(rec) {
+		msg.Header.Set(h[0], h[1])
+	}
+	_, err = s.js.PublishMsg(ctx, msg, jetstream.WithMsgID(rec.ID))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, jetstream.ErrNoStreamResponse), errors.Is(err, nats.ErrNoResponders):
+		// Der Stream kann noch angelegt werden - wiederholen
+		return fmt.Errorf("no JetStream stream for subject %s", rec.destination)
+	case errors.Is(err, nats.ErrMaxPayload), errors.Is(err, nats.ErrBadSubject):
+		return Permanent(fmt.Errorf("publish to nats: %w", err))
+	}
+	return fmt.Errorf("publish to nats: %w", err)
+}
+
+// prepare rendert das Subject eines Records (nicht bei Records aus dem Spool)
+func (s *NATSSink) prepare(rec Record) (Record, error) {
+	if rec.destination != "" {
+		return rec, nil
+	}
+	subject, err := s.opts.Subject.render(rec)
+	if err != nil {
+		return rec, Permanent(fmt.Errorf("render subject: %w", err))
+	}
+	rec.destination = subject
+	return rec, nil
+}
+
+// connect verbindet sich mit einem der Server; danach verbindet der Client selbst neu
+func (s *NATSSink) connect() error {
+	servers := make([]string, len(s.opts.Servers))
+	for i, addr := range s.opts.Servers {
+		servers[i] = "nats://" + addr
+	}
+	opts := []nats.Option{
+		nats.Name("zertifikat-waechter-agent"),
+		nats.Timeout(s.opts.Timeout),
+		nats.MaxReconnects(-1),
+	}
+	if s.opts.TLS != nil {
+		// ServerName setzt der Client pro Server
+		opts = append(opts, nats.Secure(s.opts.TLS.Clone()))
+	}
+	switch {
+	case s.opts.Username != "":
+		opts = append(opts, nats.UserInfo(s.opts.Username, s.opts.Password))
+	case s.opts.Password != "":
+		opts = append(opts, nats.Token(s.opts.Password))
+	}
+
+	conn, err := nats.Connect(strings.Join(servers, ","), opts...)
+	if err != nil {
+		return fmt.Errorf("connect to nats: %w", err)
+	}
+	if !conn.HeadersSupported() {
+		conn.Close()
+		return fmt.Errorf("connect to nats: server does not support headers (NATS 2.2 or newer required)")
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connect to nats: %w", err)
+	}
+	s.conn, s.js = conn, js
+	return nil
+}
+
+func (s *NATSSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	s.conn.Close()
+	s.conn, s.js = nil, nil
+	return nil
+}
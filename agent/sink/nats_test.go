@@ -0,0 +1,337 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/sirupsen/logrus"
+)
+
+// runJetStream startet einen eingebetteten NATS-Server mit JetStream und verbindet einen
+// Client, der Streams anlegt und liest
+func runJetStream(t *testing.T) (string, jetstream.JetStream) {
+	t.Helper()
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	srv := natsserver.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	js, err := jetstream.New(conn)
+	if err != nil {
+		t.Fatalf("jetstream: %v", err)
+	}
+	return srv.Addr().String(), js
+}
+
+func createStream(t *testing.T, js jetstream.JetStream) jetstream.Stream {
+	t.Helper()
+	stream, err := js.CreateStream(context.Background(), jetstream.StreamConfig{Name: "CERTS", Subjects: []string{"zertifikat-waechter.>"}})
+	if err != nil {
+		t.Fatalf("create stream: %v", err)
+	}
+	return stream
+}
+
+func natsConfig(addr, dir string) Config {
+	return Config{Name: "bus", Type: "nats", Servers: []string{addr},
+		SpoolFile: filepath.Join(dir, "bus.spool"), DeadLetter: filepath.Join(dir, "bus-dead.jsonl")}
+}
+
+func newTestNATS(t *testing.T, addr string) Sink {
+	t.Helper()
+	cfg := natsConfig(addr, t.TempDir())
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func brokerRecord(id string) Record {
+	rec := expiring("aa", 20)
+	rec.ID = id
+	rec.Agent = Agent{TenantID: "tenant-1", Name: "agent-test"}
+	return rec
+}
+
+// TestNATSPublish: Subject, Header und Nachrichtenformat im Stream
+func TestNATSPublish(t *testing.T) {
+	addr, js := runJetStream(t)
+	stream := createStream(t, js)
+	s := newTestNATS(t, addr)
+
+	if err := s.Write(context.Background(), brokerRecord("rec-1")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	msg, err := stream.GetMsg(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetMsg: %v", err)
+	}
+	if msg.Subject != "zertifikat-waechter.tenant-1.certificate_expiring" {
+		t.Errorf("subject = %s", msg.Subject)
+	}
+	for name, want := range map[string]string{
+		"Nats-Msg-Id": "rec-1", "Message-Id": "rec-1", "Message-Type": TypeCertificateExpiring,
+		"Message-Version": "1", "Content-Type": "application/json",
+	} {
+		if got := msg.Header.Get(name); got != want {
+			t.Errorf("header %s = %q, want %q", name, got, want)
+		}
+	}
+	var body struct {
+		Version int                    `json:"version"`
+		ID      string                 `json:"id"`
+		Event   string                 `json:"event"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Data, &body); err != nil {
+		t.Fatalf("decode message: %v", err)
+	}
+	if body.Version != MessageVersion || body.ID != "rec-1" || body.Event != EventName(TypeCertificateExpiring) || body.Data["host"] != "api.internal" {
+		t.Errorf("message = %+v", body)
+	}
+}
+
+// TestNATSDedupe: eine Wiederholung mit derselben ID landet nur einmal im Stream
+func TestNATSDedupe(t *testing.T) {
+	addr, js := runJetStream(t)
+	stream := createStream(t, js)
+	s := newTestNATS(t, addr)
+
+	for i := 0; i < 2; i++ {
+		if err := s.Write(context.Background(), brokerRecord("rec-1")); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	s.Write(context.Background(), brokerRecord("rec-2"))
+	info, err := stream.Info(context.Background())
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Errorf("stream has %d messages, want 2", info.State.Msgs)
+	}
+}
+
+// TestNATSNoStream: ohne Stream für das Subject (503 no responders) wird wiederholt
+func TestNATSNoStream(t *testing.T) {
+	addr, _ := runJetStream(t)
+	s := newTestNATS(t, addr)
+
+	err := s.Write(context.Background(), brokerRecord("rec-1"))
+	if err == nil || !strings.Contains(err.Error(), "no JetStream stream for subject zertifikat-waechter.tenant-1.certificate_expiring") {
+		t.Fatalf("Write without stream: %v", err)
+	}
+	if !retryable(err) {
+		t.Errorf("missing stream is not retried")
+	}
+}
+
+// startSpooled startet einen Dispatcher mit einem NATS-Sink über den Spool
+func startSpooled(t *testing.T, cfg Config) *Dispatcher {
+	t.Helper()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	d := NewDispatcher(Agent{TenantID: "tenant-1"}, log)
+	if err := d.Add(cfg, s); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	d.Start()
+	return d
+}
+
+func closeDispatcher(d *Dispatcher) int {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return d.Close(ctx)
+}
+
+// waitStats wartet, bis ok für die Zähler des ersten Sinks gilt
+func waitStats(t *testing.T, d *Dispatcher, ok func(Stats) bool) Stats {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !ok(d.Stats()[0]) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return d.Stats()[0]
+}
+
+// deadLetters liest die Dead-Letter-Datei
+func deadLetters(t *testing.T, path string) []deadLetter {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open dead letters: %v", err)
+	}
+	defer f.Close()
+	var list []deadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decode dead letter: %v", err)
+		}
+		list = append(list, entry)
+	}
+	return list
+}
+
+// TestNATSSpool: Records ohne Stream bleiben über einen Neustart im Spool und werden
+// zugestellt, sobald es den Stream gibt
+func TestNATSSpool(t *testing.T) {
+	addr, js := runJetStream(t)
+	dir := t.TempDir()
+
+	d := startSpooled(t, natsConfig(addr, dir))
+	for _, days := range []int{20, 10} {
+		d.Emit(KindEvent, TypeCertificateExpiring, expiring("aa", days).Data)
+	}
+	// Ohne Stream wird wiederholt - nichts landet in der Dead-Letter-Datei
+	waitStats(t, d, func(Stats) bool { return len(d.Deliveries("bus", 0)) > 0 })
+	if got := d.Stats()[0]; got.Spooled != 2 || got.Failed != 0 {
+		t.Errorf("stats before restart = %+v", got)
+	}
+	if pending := closeDispatcher(d); pending != 0 {
+		t.Errorf("Close reported %d lost records", pending)
+	}
+	// Nach Close werden Records ignoriert
+	d.Emit(KindEvent, TypeCertificateExpiring, expiring("aa", 5).Data)
+	if got := d.Stats()[0]; got.Dropped != 0 || got.QueueDepth != 0 {
+		t.Errorf("emit after Close: %+v", got)
+	}
+
+	stream := createStream(t, js)
+	d = startSpooled(t, natsConfig(addr, dir))
+	waitStats(t, d, func(s Stats) bool { return s.Spooled == 0 })
+	closeDispatcher(d)
+
+	info, err := stream.Info(context.Background())
+	if err != nil {
+		t.Fatalf("stream info: %v", err)
+	}
+	if info.State.Msgs != 2 {
+		t.Fatalf("stream has %d messages, want 2", info.State.Msgs)
+	}
+	// Reihenfolge bleibt erhalten
+	for seq, days := range map[uint64]float64{1: 20, 2: 10} {
+		msg, err := stream.GetMsg(context.Background(), seq)
+		if err != nil {
+			t.Fatalf("GetMsg: %v", err)
+		}
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		json.Unmarshal(msg.Data, &body)
+		if body.Data["days_left"] != days {
+			t.Errorf("message %d days_left = %v, want %v", seq, body.Data["days_left"], days)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "bus-dead.jsonl")); err == nil && len(deadLetters(t, filepath.Join(dir, "bus-dead.jsonl"))) > 0 {
+		t.Errorf("records were dead-lettered")
+	}
+}
+
+// TestNATSSpoolLimits: ohne Stream kommen Records nach max_attempts bzw.
+// spool_max_age_seconds in die Dead-Letter-Datei und halten die folgenden nicht auf;
+// ein voller Spool verwirft neue Records
+func TestNATSSpoolLimits(t *testing.T) {
+	addr, js := runJetStream(t)
+
+	t.Run("max_attempts", func(t *testing.T) {
+		cfg := natsConfig(addr, t.TempDir())
+		cfg.MaxAttempts, cfg.RetryDelaySeconds = 2, 1
+		d := startSpooled(t, cfg)
+		defer closeDispatcher(d)
+		d.Emit(KindEvent, TypeCertificateExpiring, expiring("aa", 20).Data)
+
+		got := waitStats(t, d, func(s Stats) bool { return s.DeadLettered == 1 })
+		if got.DeadLettered != 1 || got.Failed != 1 || got.Spooled != 0 {
+			t.Fatalf("stats = %+v", got)
+		}
+		dead := deadLetters(t, cfg.DeadLetter)
+		if len(dead) != 1 || dead[0].Attempts != 2 || !strings.Contains(dead[0].Error, "no JetStream stream for subject") ||
+			dead[0].Record.Type != TypeCertificateExpiring {
+			t.Errorf("dead letters = %+v", dead)
+		}
+	})
+
+	t.Run("spool_max_age_seconds", func(t *testing.T) {
+		cfg := natsConfig(addr, t.TempDir())
+		cfg.SpoolMaxAgeSeconds, cfg.RetryDelaySeconds = 1, 1
+		cfg.Subject = "missing.{{.Type}}"
+		d := startSpooled(t, cfg)
+		defer closeDispatcher(d)
+		d.Emit(KindEvent, TypeCertificateExpiring, expiring("aa", 20).Data)
+
+		got := waitStats(t, d, func(s Stats) bool { return s.DeadLettered == 1 })
+		if got.DeadLettered != 1 || got.Spooled != 0 {
+			t.Fatalf("stats = %+v", got)
+		}
+		if dead := deadLetters(t, cfg.DeadLetter); len(dead) != 1 || !strings.Contains(dead[0].Error, "spooled longer than 1s") {
+			t.Errorf("dead letters = %+v", dead)
+		}
+	})
+
+	t.Run("spool_max_records", func(t *testing.T) {
+		cfg := natsConfig(addr, t.TempDir())
+		cfg.SpoolMaxRecords = 2
+		cfg.Subject = "missing.{{.Type}}"
+		d := startSpooled(t, cfg)
+		defer closeDispatcher(d)
+		for i := 0; i < 5; i++ {
+			d.Emit(KindEvent, TypeCertificateExpiring, expiring("aa", 20).Data)
+		}
+		got := waitStats(t, d, func(s Stats) bool { return s.Spooled+int(s.Dropped) == 5 })
+		if got.Spooled != 2 || got.Dropped != 3 {
+			t.Errorf("stats = %+v", got)
+		}
+	})
+
+	// Ein Stream für die übrigen Subjects: Zustellung nach dem gescheiterten Record
+	t.Run("after dead letter", func(t *testing.T) {
+		stream := createStream(t, js)
+		cfg := natsConfig(addr, t.TempDir())
+		cfg.MaxAttempts, cfg.RetryDelaySeconds = 1, 1
+		cfg.Subject = "{{if eq .Type \"certificate_expiring\"}}missing{{else}}zertifikat-waechter{{end}}.{{.Type}}"
+		d := startSpooled(t, cfg)
+		defer closeDispatcher(d)
+		d.Emit(KindEvent, TypeCertificateExpiring, expiring("aa", 20).Data)
+		d.Emit(KindEvent, TypeCertificateChange, map[string]interface{}{"host": "api.internal", "port": 443})
+
+		got := waitStats(t, d, func(s Stats) bool { return s.Sent == 1 && s.DeadLettered == 1 })
+		if got.Sent != 1 || got.DeadLettered != 1 {
+			t.Fatalf("stats = %+v", got)
+		}
+		if info, _ := stream.Info(context.Background()); info.State.Msgs != 1 {
+			t.Errorf("stream has %d messages, want 1", info.State.Msgs)
+		}
+	})
+}
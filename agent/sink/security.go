@@ -0,0 +1,222 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/rotation"
+)
+
+// Signature-IDs der sicherheitsrelevanten Ereignisse in CEF und LEEF. Die Werte sind
+// Teil der Schnittstelle zum SIEM und dürfen sich nicht ändern.
+const (
+	SignatureServiceDiscovered = "100" // neuer TLS-Dienst (erstes Zertifikat auf einem Endpoint)
+	SignatureUnexpectedChange  = "200" // verdächtiger Zertifikatswechsel
+	SignatureSelfSigned        = "300" // selbstsigniertes Zertifikat
+	SignatureWeakCrypto        = "400" // schwacher Schlüssel, schwache Signatur, veraltetes Protokoll
+	SignatureRevoked           = "500" // vom Aussteller gesperrtes Zertifikat
+)
+
+// Hersteller und Produkt im CEF- bzw. LEEF-Header
+const (
+	securityVendor  = "Zertifikat-Waechter"
+	securityProduct = "Agent"
+)
+
+// SecurityEvent ist ein sicherheitsrelevantes Ereignis für SIEM-Formate
+type SecurityEvent struct {
+	Signature string
+	Name      string
+	Severity  int // 0-10 (CEF) bzw. 1-10 (LEEF)
+	Record    Record
+
+	Host, SNI           string
+	Port                int
+	Message             string
+	Fingerprint         string
+	PreviousFingerprint string
+	Subject, Issuer     string
+	Reasons             []string
+	NotAfter            *time.Time
+	DaysLeft            int
+	hasDaysLeft         bool
+}
+
+// SecurityEvents liefert die sicherheitsrelevanten Ereignisse eines Records (meist keins)
+func SecurityEvents(rec Record) []SecurityEvent {
+	switch data := rec.Data.(type) {
+	case CertificateEvent:
+		base := SecurityEvent{
+			Record:      rec,
+			Host:        data.Host,
+			Port:        data.Port,
+			SNI:         data.SNI,
+			Message:     data.Message,
+			Fingerprint: data.Fingerprint,
+			Subject:     data.SubjectCN,
+			Issuer:      data.Issuer,
+			Reasons:     data.Reasons,
+			NotAfter:    data.NotAfter,
+			DaysLeft:    data.DaysLeft,
+			hasDaysLeft: data.NotAfter != nil,
+		}
+		var events []SecurityEvent
+		add := func(signature, name string, severity int) {
+			ev := base
+			ev.Signature, ev.Name, ev.Severity = signature, name, severity
+			events = append(events, ev)
+		}
+		switch rec.Type {
+		case TypeCertificateDiscovered:
+			add(SignatureServiceDiscovered, "New TLS service discovered", 3)
+		case TypeWeakCrypto:
+			add(SignatureWeakCrypto, "Weak cryptography", 6)
+		case TypeValidationFailed:
+			if containsString(data.Reasons, "self_signed") {
+				add(SignatureSelfSigned, "Self-signed certificate", 5)
+			}
+			if containsString(data.Reasons, "revoked") {
+				add(SignatureRevoked, "Certificate revoked", 9)
+			}
+		}
+		return events
+
+	case map[string]interface{}:
+		change, ok := data["change"].(rotation.Event)
+		if rec.Type != TypeCertificateChange || !ok || !change.Suspicious {
+			return nil
+		}
+		return []SecurityEvent{{
+			Signature:           SignatureUnexpectedChange,
+			Name:                "Unexpected certificate change",
+			Severity:            7,
+			Record:              rec,
+			Host:                change.Host,
+			Port:                change.Port,
+			SNI:                 change.SNI,
+			Message:             fmt.Sprintf("Suspicious certificate change on %s: %s", net.JoinHostPort(change.Host, strconv.Itoa(change.Port)), strings.Join(change.SuspiciousReasons, ", ")),
+			Fingerprint:         change.NewFingerprint,
+			PreviousFingerprint: change.OldFingerprint,
+			Reasons:             change.SuspiciousReasons,
+		}}
+	}
+	return nil
+}
+
+// field ist ein Feld der Erweiterung (CEF-Schlüssel, LEEF-Schlüssel, Wert)
+type field struct {
+	cef, leef, value string
+}
+
+// fields liefert die Felder des Ereignisses in fester Reihenfolge (leere Werte entfallen)
+func (e SecurityEvent) fields() []field {
+	rec := e.Record
+	var list []field
+	add := func(cef, leef, value string) {
+		if value != "" {
+			list = append(list, field{cef, leef, value})
+		}
+	}
+	custom := func(n int, label, leef, value string) {
+		if value != "" {
+			list = append(list, field{"cs" + strconv.Itoa(n) + "Label", "", label})
+			list = append(list, field{"cs" + strconv.Itoa(n), leef, value})
+		}
+	}
+
+	add("rt", "", strconv.FormatInt(rec.Time.UnixMilli(), 10))
+	add("", "devTime", rec.Time.UTC().Format(leefTimeFormat))
+	add("", "devTimeFormat", leefTimeJava)
+	add("", "sev", strconv.Itoa(max(e.Severity, 1)))
+	add("cat", "cat", rec.Type)
+	add("externalId", "externalId", rec.ID)
+	add("msg", "msg", e.Message)
+	if net.ParseIP(e.Host) != nil {
+		add("dst", "dst", e.Host)
+	} else {
+		add("dhost", "dstName", e.Host)
+	}
+	if e.Port > 0 {
+		add("dpt", "dstPort", strconv.Itoa(e.Port))
+	}
+	add("dvchost", "agentName", rec.Agent.Name)
+	add("deviceExternalId", "connectorId", rec.Agent.ConnectorID)
+	custom(1, "fingerprint", "fingerprint", e.Fingerprint)
+	custom(2, "subject", "subject", e.Subject)
+	custom(3, "issuer", "issuer", e.Issuer)
+	custom(4, "reasons", "reasons", strings.Join(e.Reasons, ","))
+	custom(5, "previousFingerprint", "previousFingerprint", e.PreviousFingerprint)
+	custom(6, "sni", "sni", e.SNI)
+	if e.NotAfter != nil {
+		add("deviceCustomDate1Label", "", "notAfter")
+		add("deviceCustomDate1", "", strconv.FormatInt(e.NotAfter.UnixMilli(), 10))
+		add("", "notAfter", e.NotAfter.UTC().Format(time.RFC3339))
+	}
+	if e.hasDaysLeft {
+		add("cn1Label", "", "daysLeft")
+		add("cn1", "daysLeft", strconv.Itoa(e.DaysLeft))
+	}
+	return list
+}
+
+// Zeitformat der LEEF-Ereignisse (devTime) in Go- und Java-Schreibweise (devTimeFormat)
+const (
+	leefTimeFormat = "2006-01-02T15:04:05.000-0700"
+	leefTimeJava   = "yyyy-MM-dd'T'HH:mm:ss.SSSZ"
+)
+
+// CEF liefert das Ereignis im ArcSight Common Event Format (Version 0)
+func (e SecurityEvent) CEF() string {
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, h := range []string{securityVendor, securityProduct, e.Record.Agent.Version, e.Signature, e.Name, strconv.Itoa(e.Severity)} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(h))
+	}
+	b.WriteByte('|')
+	first := true
+	for _, f := range e.fields() {
+		if f.cef == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		b.WriteString(f.cef)
+		b.WriteByte('=')
+		b.WriteString(cefValueEscaper.Replace(f.value))
+	}
+	return b.String()
+}
+
+// LEEF liefert das Ereignis im IBM QRadar Log Event Extended Format (Version 2.0,
+// Attribute durch Tabulator getrennt)
+func (e SecurityEvent) LEEF() string {
+	var b strings.Builder
+	b.WriteString("LEEF:2.0")
+	for _, h := range []string{securityVendor, securityProduct, e.Record.Agent.Version, e.Signature} {
+		b.WriteByte('|')
+		b.WriteString(leefHeaderEscaper.Replace(h))
+	}
+	b.WriteString("|x09|")
+	var attrs []string
+	for _, f := range e.fields() {
+		if f.leef == "" {
+			continue
+		}
+		attrs = append(attrs, f.leef+"="+leefValueEscaper.Replace(f.value))
+	}
+	b.WriteString(strings.Join(attrs, "\t"))
+	return b.String()
+}
+
+var (
+	cefHeaderEscaper  = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefValueEscaper   = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\r", `\n`, "\n", `\n`)
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\t", " ", "\r", " ", "\n", " ")
+	leefValueEscaper  = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
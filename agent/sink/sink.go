@@ -0,0 +1,649 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Kind ist die Art eines Ergebnisses
+type Kind string
+
+const (
+	KindCertificate Kind = "certificate" // Zertifikat auf einem Endpoint gesehen
+	KindAsset       Kind = "asset"       // Asset angelegt bzw. aktualisiert
+	KindDiscovery   Kind = "discovery"   // Host bei der Netzwerk-Discovery gefunden
+	KindLog         Kind = "log"         // Meldung des Agents
+	KindEvent       Kind = "event"       // Zustandswechsel, Zertifikatswechsel, Lauf beendet
+)
+
+var kinds = map[Kind]bool{KindCertificate: true, KindAsset: true, KindDiscovery: true, KindLog: true, KindEvent: true}
+
+// Typen der Records (Record.Type)
+const (
+	TypeCertificateObserved = "certificate_observed"
+	TypeAssetUpdated        = "asset_updated"
+	TypeHostDiscovered      = "host_discovered"
+	TypeLog                 = "log"
+	TypeAssetTransition     = "asset_transition"
+	TypeCertificateChange   = "certificate_change"
+	TypeScanRunFinished     = "scan_run_finished"
+
+	// Zertifikats-Ereignisse (Data ist ein CertificateEvent)
+	TypeCertificateDiscovered = "certificate_discovered"        // erstes Zertifikat auf einem Endpoint
+	TypeCertificateExpiring   = "certificate_expiring"          // läuft innerhalb von ExpiringDays ab
+	TypeValidationFailed      = "certificate_validation_failed" // abgelaufen, nicht vertrauenswürdig, Hostname passt nicht
+	TypeEndpointUnreachable   = "endpoint_unreachable"          // Asset wechselt nach unreachable
+	TypeWeakCrypto            = "certificate_weak_crypto"       // schwacher Schlüssel, schwache Signatur, veraltetes Protokoll
+)
+
+// levelRank ordnet die Log-Level für MinLevel
+var levelRank = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+// Agent kennzeichnet die Quelle eines Records
+type Agent struct {
+	ConnectorID string `json:"connector_id"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+}
+
+// Record ist ein Ergebnis, das an alle passenden Sinks geht
+type Record struct {
+	ID    string      `json:"id"` // eindeutig pro Record, für alle Sinks gleich
+	Kind  Kind        `json:"kind"`
+	Type  string      `json:"type"`
+	Level string      `json:"level,omitempty"` // nur bei Logs
+	Time  time.Time   `json:"time"`
+	Agent Agent       `json:"agent"`
+	Data  interface{} `json:"data"`
+
+	forgetOnly bool // nur für die Deduplizierung des Sinks, nicht schreiben (deduper)
+
+	// Subject bzw. Topic und Schlüssel, bereits aufbereitet (broker, Spool)
+	destination string
+	key         string
+}
+
+// Sink nimmt Records entgegen. Write wird pro Sink nur aus einer Goroutine aufgerufen.
+type Sink interface {
+	Write(ctx context.Context, rec Record) error
+	Close() error
+}
+
+// Filter wählt aus, welche Records ein Sink bekommt (leere Listen = alle)
+type Filter struct {
+	Kinds    []Kind   `json:"kinds,omitempty"`
+	Types    []string `json:"types,omitempty"`
+	MinLevel string   `json:"min_level,omitempty"` // Logs unterhalb dieses Levels werden nicht gesendet
+
+	// certificate_expiring nur, wenn das Zertifikat innerhalb so vieler Tage abläuft (Default 30)
+	ExpiringDays int `json:"expiring_days,omitempty"`
+}
+
+// Match meldet, ob der Record den Filter passiert
+func (f Filter) Match(rec Record) bool {
+	if len(f.Kinds) > 0 && !containsKind(f.Kinds, rec.Kind) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsString(f.Types, rec.Type) {
+		return false
+	}
+	if f.MinLevel != "" && rec.Kind == KindLog && levelRank[rec.Level] < levelRank[f.MinLevel] {
+		return false
+	}
+	if ev, ok := rec.Data.(CertificateEvent); ok && rec.Type == TypeCertificateExpiring && ev.DaysLeft > f.expiringDays() {
+		return false
+	}
+	return true
+}
+
+// defaultExpiringDays gilt, wenn ein Sink keine expiring_days angibt
+const defaultExpiringDays = 30
+
+func (f Filter) expiringDays() int {
+	if f.ExpiringDays > 0 {
+		return f.ExpiringDays
+	}
+	return defaultExpiringDays
+}
+
+// Config beschreibt einen Sink in der Sink-Datei (SINKS_FILE)
+type Config struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // stdout, file, webhook, syslog, nats, kafka
+	Filter
+	QueueSize int `json:"queue_size,omitempty"` // Default 1000
+
+	// Zustellung: Versuche pro Record, Wartezeit vor dem n-ten Wiederholen (n * retry_delay),
+	// Dead-Letter-Datei für endgültig gescheiterte Records, Zustellprotokoll (JSONL)
+	MaxAttempts       int    `json:"max_attempts,omitempty"`        // Default 3
+	RetryDelaySeconds int    `json:"retry_delay_seconds,omitempty"` // Default 1
+	DeadLetter        string `json:"dead_letter,omitempty"`
+	DeliveryLog       string `json:"delivery_log,omitempty"`
+
+	// webhook, syslog (cef, leef): bereits gemeldete Befunde, überlebt Neustarts
+	StateFile string `json:"state_file,omitempty"`
+
+	// nats, kafka: Records bis zur Bestätigung des Brokers (bbolt), überlebt Neustarts.
+	// Ältere Records kommen in die Dead-Letter-Datei, ist der Spool voll, werden neue verworfen.
+	SpoolFile          string `json:"spool_file,omitempty"`
+	SpoolMaxRecords    int    `json:"spool_max_records,omitempty"`     // Default 100000
+	SpoolMaxAgeSeconds int    `json:"spool_max_age_seconds,omitempty"` // Default 86400
+
+	// file
+	Path string `json:"path,omitempty"`
+
+	// webhook
+	URL            string            `json:"url,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"` // Default 10
+	Secret         string            `json:"secret,omitempty"`          // HMAC-SHA256-Signatur (X-Webhook-Signature)
+	SecretEnv      string            `json:"secret_env,omitempty"`      // Secret aus dieser Umgebungsvariable
+	Template       string            `json:"template,omitempty"`        // Payload als Go-Template über den Record
+	TemplateFile   string            `json:"template_file,omitempty"`
+	ContentType    string            `json:"content_type,omitempty"` // Default application/json
+
+	// syslog (RFC 5424); timeout_seconds gilt für Verbindungsaufbau und Schreiben
+	Network  string `json:"network,omitempty"`   // udp (Default), tcp, tls
+	Address  string `json:"address,omitempty"`   // host:port (Default-Port 514, bei tls 6514)
+	Format   string `json:"format,omitempty"`    // rfc5424 (Default), cef, leef
+	Facility string `json:"facility,omitempty"`  // Default local0
+	AppName  string `json:"app_name,omitempty"`  // Default zertifikat-waechter
+	Framing  string `json:"framing,omitempty"`   // tcp/tls: octet-counting (Default), lf
+	CAFile   string `json:"ca_file,omitempty"`   // tls: CA des Servers statt der System-Roots
+	CertFile string `json:"cert_file,omitempty"` // tls: Client-Zertifikat (mit key_file)
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// nats (JetStream), kafka; ca_file, cert_file und key_file gelten auch hier
+	Servers     []string `json:"servers,omitempty"`      // nats: nats://host:4222 (tls:// = TLS), kafka: host:9092
+	Subject     string   `json:"subject,omitempty"`      // nats: Go-Template über den Record
+	Topic       string   `json:"topic,omitempty"`        // kafka: Go-Template über den Record
+	Username    string   `json:"username,omitempty"`     // kafka: SASL/PLAIN
+	Password    string   `json:"password,omitempty"`     // nats ohne username: Token
+	PasswordEnv string   `json:"password_env,omitempty"` // Passwort aus dieser Umgebungsvariable
+	TLS         bool     `json:"tls,omitempty"`
+}
+
+// defaultQueueSize ist die Kapazität der Warteschlange pro Sink
+const defaultQueueSize = 1000
+
+// Grenzen des Spools eines Broker-Sinks
+const (
+	defaultSpoolMaxRecords = 100000
+	defaultSpoolMaxAge     = 24 * time.Hour
+)
+
+func (c Config) spoolMaxRecords() int {
+	if c.SpoolMaxRecords > 0 {
+		return c.SpoolMaxRecords
+	}
+	return defaultSpoolMaxRecords
+}
+
+func (c Config) spoolMaxAge() time.Duration {
+	if c.SpoolMaxAgeSeconds > 0 {
+		return time.Duration(c.SpoolMaxAgeSeconds) * time.Second
+	}
+	return defaultSpoolMaxAge
+}
+
+// fileConfig ist der Aufbau der Sink-Datei
+type fileConfig struct {
+	Sinks []Config `json:"sinks"`
+}
+
+// LoadConfig liest und prüft die Sink-Datei
+func LoadConfig(path string) ([]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read sinks file: %w", err)
+	}
+
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return nil, fmt.Errorf("parse sinks file: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range fc.Sinks {
+		c := &fc.Sinks[i]
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid sinks file: sink %d: %w", i+1, err)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("invalid sinks file: duplicate sink name %q", c.Name)
+		}
+		names[c.Name] = true
+	}
+	return fc.Sinks, nil
+}
+
+// Validate prüft einen Sink-Eintrag und normalisiert Level und Typ
+func (c *Config) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	c.Type = strings.ToLower(c.Type)
+	c.MinLevel = strings.ToLower(c.MinLevel)
+
+	for _, k := range c.Kinds {
+		if !kinds[k] {
+			return fmt.Errorf("%s: unknown kind %q", c.Name, k)
+		}
+	}
+	if _, ok := levelRank[c.MinLevel]; c.MinLevel != "" && !ok {
+		return fmt.Errorf("%s: invalid min_level %q (debug, info, warn, error)", c.Name, c.MinLevel)
+	}
+	if c.QueueSize < 0 || c.TimeoutSeconds < 0 || c.MaxAttempts < 0 || c.RetryDelaySeconds < 0 || c.ExpiringDays < 0 ||
+		c.SpoolMaxRecords < 0 || c.SpoolMaxAgeSeconds < 0 {
+		return fmt.Errorf("%s: queue_size, timeout_seconds, max_attempts, retry_delay_seconds, expiring_days, spool_max_records and spool_max_age_seconds must not be negative", c.Name)
+	}
+
+	switch c.Type {
+	case "stdout":
+	case "file":
+		if c.Path == "" {
+			return fmt.Errorf("%s: path is required for file sinks", c.Name)
+		}
+	case "webhook":
+		if !strings.HasPrefix(c.URL, "https://") && !strings.HasPrefix(c.URL, "http://") {
+			return fmt.Errorf("%s: url must be an http(s) URL", c.Name)
+		}
+		if c.Secret != "" && c.SecretEnv != "" {
+			return fmt.Errorf("%s: use either secret or secret_env", c.Name)
+		}
+		if c.SecretEnv != "" && os.Getenv(c.SecretEnv) == "" {
+			return fmt.Errorf("%s: secret_env %s is not set", c.Name, c.SecretEnv)
+		}
+		if c.Template != "" && c.TemplateFile != "" {
+			return fmt.Errorf("%s: use either template or template_file", c.Name)
+		}
+		if _, err := c.payloadTemplate(); err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+	case "syslog":
+		if err := c.validateSyslog(); err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+	case "nats", "kafka":
+		if err := c.validateBroker(); err != nil {
+			return fmt.Errorf("%s: %w", c.Name, err)
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %q (stdout, file, webhook, syslog, nats, kafka)", c.Name, c.Type)
+	}
+	if c.StateFile != "" && c.Type != "webhook" && !(c.Type == "syslog" && c.Format != SyslogRFC5424) {
+		return fmt.Errorf("%s: state_file is only supported by webhook sinks and syslog sinks with format cef or leef", c.Name)
+	}
+	if (c.SpoolFile != "" || c.SpoolMaxRecords != 0 || c.SpoolMaxAgeSeconds != 0) && c.Type != "nats" && c.Type != "kafka" {
+		return fmt.Errorf("%s: spool_file, spool_max_records and spool_max_age_seconds are only supported by nats and kafka sinks", c.Name)
+	}
+	return nil
+}
+
+// validateBroker prüft einen NATS- bzw. Kafka-Sink. Ohne kinds und types bekommt er
+// Zertifikatsbeobachtungen und Ereignisse.
+func (c *Config) validateBroker() error {
+	if len(c.Servers) == 0 {
+		return fmt.Errorf("servers are required for %s sinks", c.Type)
+	}
+	// Zustellung über den Spool: Versuche ohne max_attempts bis spool_max_age_seconds
+	if c.SpoolFile == "" || c.DeadLetter == "" {
+		return fmt.Errorf("spool_file and dead_letter are required for %s sinks", c.Type)
+	}
+	if _, err := c.brokerAddrs(); err != nil {
+		return err
+	}
+	if c.Type == "nats" {
+		if c.Topic != "" {
+			return fmt.Errorf("nats sinks use subject (not topic)")
+		}
+		if c.Username != "" && c.Password == "" && c.PasswordEnv == "" {
+			return fmt.Errorf("username requires password")
+		}
+		if c.Subject == "" {
+			c.Subject = defaultSubject
+		}
+		if _, err := newDestination(c.Name, c.Subject, natsSubject); err != nil {
+			return fmt.Errorf("parse subject: %w", err)
+		}
+	} else {
+		if c.Subject != "" {
+			return fmt.Errorf("kafka sinks use topic (not subject)")
+		}
+		if c.Username == "" && (c.Password != "" || c.PasswordEnv != "") {
+			return fmt.Errorf("password requires username (SASL/PLAIN)")
+		}
+		if c.Topic == "" {
+			c.Topic = defaultTopic
+		}
+		if _, err := newDestination(c.Name, c.Topic, kafkaTopic); err != nil {
+			return fmt.Errorf("parse topic: %w", err)
+		}
+	}
+	if c.Password != "" && c.PasswordEnv != "" {
+		return fmt.Errorf("use either password or password_env")
+	}
+	if c.PasswordEnv != "" && os.Getenv(c.PasswordEnv) == "" {
+		return fmt.Errorf("password_env %s is not set", c.PasswordEnv)
+	}
+	if (c.CAFile != "" || c.CertFile != "" || c.KeyFile != "") && !c.TLS {
+		return fmt.Errorf("ca_file, cert_file and key_file require tls")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	if c.TLS {
+		if _, err := clientTLSConfig("", c.CAFile, c.CertFile, c.KeyFile); err != nil {
+			return err
+		}
+	}
+	if len(c.Kinds) == 0 && len(c.Types) == 0 {
+		c.Kinds = []Kind{KindCertificate, KindEvent}
+	}
+	return nil
+}
+
+// brokerAddrs liefert die Server eines NATS- bzw. Kafka-Sinks als host:port
+// (Default-Port 4222 bzw. 9092); tls:// schaltet bei NATS TLS ein
+func (c *Config) brokerAddrs() ([]string, error) {
+	port := "9092"
+	if c.Type == "nats" {
+		port = "4222"
+	}
+	addrs := make([]string, 0, len(c.Servers))
+	for _, server := range c.Servers {
+		addr := server
+		if scheme, rest, ok := strings.Cut(server, "://"); ok {
+			if c.Type != "nats" {
+				return nil, fmt.Errorf("invalid server %q (host:port)", server)
+			}
+			switch scheme {
+			case "nats":
+			case "tls":
+				c.TLS = true
+			default:
+				return nil, fmt.Errorf("invalid server %q (nats://host:port, tls://host:port)", server)
+			}
+			addr = rest
+		}
+		if strings.ContainsAny(addr, "@/") {
+			return nil, fmt.Errorf("invalid server %q (credentials belong in username and password)", server)
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func (c *Config) validateSyslog() error {
+	c.Network = strings.ToLower(c.Network)
+	c.Format = strings.ToLower(c.Format)
+	c.Facility = strings.ToLower(c.Facility)
+	c.Framing = strings.ToLower(c.Framing)
+
+	switch c.Network {
+	case "":
+		c.Network = "udp"
+	case "udp", "tcp", "tls":
+	default:
+		return fmt.Errorf("invalid network %q (udp, tcp, tls)", c.Network)
+	}
+	if c.Address == "" {
+		return fmt.Errorf("address is required for syslog sinks")
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		port := "514"
+		if c.Network == "tls" {
+			port = "6514"
+		}
+		c.Address = net.JoinHostPort(strings.Trim(c.Address, "[]"), port)
+	}
+	switch c.Format {
+	case "":
+		c.Format = SyslogRFC5424
+	case SyslogRFC5424, SyslogCEF, SyslogLEEF:
+	default:
+		return fmt.Errorf("invalid format %q (rfc5424, cef, leef)", c.Format)
+	}
+	if c.Facility == "" {
+		c.Facility = "local0"
+	}
+	if _, ok := syslogFacilities[c.Facility]; !ok {
+		return fmt.Errorf("invalid facility %q (e.g. daemon, security, local0-local7)", c.Facility)
+	}
+	if c.AppName == "" {
+		c.AppName = "zertifikat-waechter"
+	}
+	switch c.Framing {
+	case "":
+		c.Framing = "octet-counting"
+	case "octet-counting", "lf":
+	default:
+		return fmt.Errorf("invalid framing %q (octet-counting, lf)", c.Framing)
+	}
+	if c.Network != "tls" && (c.CAFile != "" || c.CertFile != "" || c.KeyFile != "") {
+		return fmt.Errorf("ca_file, cert_file and key_file require network tls")
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("cert_file and key_file must be set together")
+	}
+	if c.Network == "tls" {
+		if _, err := c.syslogTLS(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syslogTLS erstellt die TLS-Config eines Syslog-Sinks
+func (c *Config) syslogTLS() (*tls.Config, error) {
+	host, _, _ := net.SplitHostPort(c.Address)
+	return clientTLSConfig(host, c.CAFile, c.CertFile, c.KeyFile)
+}
+
+// Redacted liefert den Eintrag ohne Geheimnisse: Secret, Header-Werte und
+// Query/Userinfo der URL werden ersetzt
+func (c Config) Redacted() Config {
+	if c.Secret != "" {
+		c.Secret = redacted
+	}
+	if c.Password != "" {
+		c.Password = redacted
+	}
+	if len(c.Headers) > 0 {
+		headers := make(map[string]string, len(c.Headers))
+		for k := range c.Headers {
+			headers[k] = redacted
+		}
+		c.Headers = headers
+	}
+	if u, err := url.Parse(c.URL); err == nil && c.URL != "" {
+		if u.User != nil {
+			u.User = url.User("redacted")
+		}
+		if u.RawQuery != "" {
+			u.RawQuery = redacted
+		}
+		c.URL = u.String()
+	}
+	return c
+}
+
+// redacted ersetzt geheime Werte in Ausgaben
+const redacted = "[redacted]"
+
+// New erstellt den Sink zu einem geprüften Eintrag
+func New(c Config) (Sink, error) {
+	switch c.Type {
+	case "stdout":
+		return NewWriterSink(os.Stdout), nil
+	case "file":
+		return NewFileSink(c.Path)
+	case "webhook":
+		timeout := 10 * time.Second
+		if c.TimeoutSeconds > 0 {
+			timeout = time.Duration(c.TimeoutSeconds) * time.Second
+		}
+		tmpl, err := c.payloadTemplate()
+		if err != nil {
+			return nil, err
+		}
+		secret := c.Secret
+		if c.SecretEnv != "" {
+			secret = os.Getenv(c.SecretEnv)
+		}
+		return NewWebhookSink(c.URL, WebhookOptions{
+			Headers:     c.Headers,
+			Timeout:     timeout,
+			Secret:      secret,
+			Template:    tmpl,
+			ContentType: c.ContentType,
+			StateFile:   c.StateFile,
+		})
+	case "syslog":
+		opts := SyslogOptions{
+			Network:  c.Network,
+			Address:  c.Address,
+			Format:   c.Format,
+			Facility: syslogFacilities[c.Facility],
+			AppName:  c.AppName,
+			Framing:  c.Framing,
+			Timeout:  10 * time.Second,
+
+			StateFile: c.StateFile,
+		}
+		if c.TimeoutSeconds > 0 {
+			opts.Timeout = time.Duration(c.TimeoutSeconds) * time.Second
+		}
+		if c.Network == "tls" {
+			tlsConfig, err := c.syslogTLS()
+			if err != nil {
+				return nil, err
+			}
+			opts.TLS = tlsConfig
+		}
+		return NewSyslogSink(opts)
+	case "nats", "kafka":
+		return c.newBroker()
+	}
+	return nil, fmt.Errorf("unknown sink type %q", c.Type)
+}
+
+// newBroker erstellt einen NATS- bzw. Kafka-Sink
+func (c Config) newBroker() (Sink, error) {
+	addrs, err := c.brokerAddrs()
+	if err != nil {
+		return nil, err
+	}
+	timeout := 10 * time.Second
+	if c.TimeoutSeconds > 0 {
+		timeout = time.Duration(c.TimeoutSeconds) * time.Second
+	}
+	password := c.Password
+	if c.PasswordEnv != "" {
+		password = os.Getenv(c.PasswordEnv)
+	}
+	var tlsConfig *tls.Config
+	if c.TLS {
+		// ServerName setzt der Sink pro Server
+		if tlsConfig, err = clientTLSConfig("", c.CAFile, c.CertFile, c.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Type == "nats" {
+		subject, err := newDestination(c.Name, c.Subject, natsSubject)
+		if err != nil {
+			return nil, err
+		}
+		return NewNATSSink(NATSOptions{
+			Servers:  addrs,
+			Subject:  subject,
+			Username: c.Username,
+			Password: password,
+			TLS:      tlsConfig,
+			Timeout:  timeout,
+		}), nil
+	}
+	topic, err := newDestination(c.Name, c.Topic, kafkaTopic)
+	if err != nil {
+		return nil, err
+	}
+	return NewKafkaSink(KafkaOptions{
+		Brokers:  addrs,
+		Topic:    topic,
+		Username: c.Username,
+		Password: password,
+		TLS:      tlsConfig,
+		Timeout:  timeout,
+	}), nil
+}
+
+func containsKind(list []Kind, k Kind) bool {
+	for _, v := range list {
+		if v == k {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrSkipped meldet ein Sink, der einen Record bewusst nicht schreibt (z.B. bereits gemeldet)
+var ErrSkipped = errors.New("record skipped")
+
+// permanentError wird nicht wiederholt
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent kennzeichnet einen Fehler, den ein erneuter Versuch nicht behebt
+func Permanent(err error) error {
+	return &permanentError{err}
+}
+
+// retryable meldet, ob sich ein erneuter Versuch lohnt
+func retryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Retryable()
+	}
+	return true
+}
+
+type attemptKey struct{}
+
+// AttemptFromContext liefert die Nummer des laufenden Zustellversuchs (ab 1, 0 = unbekannt)
+func AttemptFromContext(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
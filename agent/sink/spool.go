@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var spoolBucket = []byte("records")
+
+// spool hält die Records eines Broker-Sinks auf der Platte, bis der Broker sie bestätigt
+// hat oder sie in der Dead-Letter-Datei stehen - ein Neustart verliert nichts
+type spool struct {
+	db  *bolt.DB
+	max int          // höchstens so viele Records
+	n   atomic.Int64 // Records im Spool
+}
+
+// spooled ist ein Eintrag im Spool: der aufbereitete Record, Data als JSON
+type spooled struct {
+	Record      Record          `json:"record"`
+	Data        json.RawMessage `json:"data"`
+	Destination string          `json:"destination"`
+	Key         string          `json:"key,omitempty"`
+}
+
+func openSpool(path string, max int) (*spool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o640, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{db: db, max: max}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(spoolBucket)
+		if err == nil {
+			s.n.Store(int64(b.Stats().KeyN))
+		}
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// encodeSpooled serialisiert einen aufbereiteten Record für put
+func encodeSpooled(rec Record) ([]byte, error) {
+	data, err := json.Marshal(rec.Data)
+	if err != nil {
+		return nil, err
+	}
+	entry := spooled{Record: rec, Data: data, Destination: rec.destination, Key: rec.key}
+	entry.Record.Data = nil
+	return json.Marshal(entry)
+}
+
+// put hängt Records in einer Transaktion an; ist der Spool voll, werden die übrigen
+// verworfen (Anzahl in dropped)
+func (s *spool) put(values [][]byte) (dropped int, err error) {
+	added := 0
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(spoolBucket)
+		for _, value := range values {
+			if int(s.n.Load())+added >= s.max {
+				dropped++
+				continue
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := b.Put(binary.BigEndian.AppendUint64(nil, seq), value); err != nil {
+				return err
+			}
+			added++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.n.Add(int64(added))
+	return dropped, nil
+}
+
+// first liefert den ältesten Record (seq 0 = Spool leer). Ist der Eintrag nicht lesbar,
+// kommt seq mit dem Fehler zurück, damit er entfernt werden kann.
+func (s *spool) first() (seq uint64, rec Record, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(spoolBucket).Cursor().First()
+		if k == nil {
+			return nil
+		}
+		seq = binary.BigEndian.Uint64(k)
+		var entry spooled
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return fmt.Errorf("decode spool entry %d: %w", seq, err)
+		}
+		rec = entry.Record
+		rec.Data = entry.Data
+		rec.destination, rec.key = entry.Destination, entry.Key
+		return nil
+	})
+	return seq, rec, err
+}
+
+func (s *spool) remove(seq uint64) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(spoolBucket).Delete(binary.BigEndian.AppendUint64(nil, seq))
+	})
+	if err == nil {
+		s.n.Add(-1)
+	}
+	return err
+}
+
+// count liefert die Anzahl Records im Spool
+func (s *spool) count() int {
+	return int(s.n.Load())
+}
+
+func (s *spool) close() error {
+	return s.db.Close()
+}
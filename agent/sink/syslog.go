@@ -0,0 +1,295 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/rotation"
+)
+
+// Formate der Syslog-Nachrichten
+const (
+	SyslogRFC5424 = "rfc5424" // jeder Record als JSON im MSG-Teil
+	SyslogCEF     = "cef"     // nur sicherheitsrelevante Ereignisse, ArcSight CEF
+	SyslogLEEF    = "leef"    // nur sicherheitsrelevante Ereignisse, IBM QRadar LEEF 2.0
+)
+
+// syslogFacilities sind die Facilities nach RFC 5424
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog-Severities (RFC 5424)
+const (
+	syslogCritical = 2
+	syslogError    = 3
+	syslogWarning  = 4
+	syslogNotice   = 5
+	syslogInfo     = 6
+	syslogDebug    = 7
+)
+
+// maxDatagram begrenzt Nachrichten über UDP (größere werden gekürzt)
+const maxDatagram = 8192
+
+// SyslogOptions sind die Einstellungen eines Syslog-Sinks
+type SyslogOptions struct {
+	Network  string // udp, tcp, tls
+	Address  string // host:port
+	Format   string // rfc5424, cef, leef
+	Facility int
+	AppName  string
+	Hostname string
+	Framing  string      // tcp/tls: octet-counting (RFC 6587) oder lf
+	TLS      *tls.Config // nur bei tls
+	Timeout  time.Duration
+
+	StateFile string // gemeldete Befunde (überlebt Neustarts), leer = nur im Speicher
+}
+
+// SyslogSink sendet Records als RFC-5424-Nachrichten per UDP, TCP oder TLS (RFC 5425).
+// Bei CEF und LEEF gehen nur sicherheitsrelevante Ereignisse hinaus (SecurityEvents).
+type SyslogSink struct {
+	opts SyslogOptions
+	conn net.Conn
+
+	// Bereits gemeldete Befunde (CEF/LEEF) - jeder Scan meldet sie erneut
+	*findings
+}
+
+// NewSyslogSink erstellt einen Syslog-Sink und lädt die gemeldeten Befunde; die
+// Verbindung wird beim ersten Record aufgebaut
+func NewSyslogSink(opts SyslogOptions) (*SyslogSink, error) {
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	f, err := newFindings(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogSink{opts: opts, findings: f}, nil
+}
+
+// reportedFinding ist ein gesendeter Befund (CEF/LEEF)
+type reportedFinding struct {
+	endpoint, signature, state string
+}
+
+func (s *SyslogSink) Write(ctx context.Context, rec Record) error {
+	messages, reported, err := s.messages(rec)
+	if err != nil {
+		return Permanent(err)
+	}
+	if len(messages) == 0 {
+		return ErrSkipped
+	}
+
+	for _, msg := range messages {
+		if err := s.send(ctx, msg); err != nil {
+			return err
+		}
+	}
+	for _, f := range reported {
+		s.mark(f.endpoint, f.signature, f.state)
+	}
+	return nil
+}
+
+// messages baut die Syslog-Nachrichten eines Records und die darin gemeldeten Befunde
+func (s *SyslogSink) messages(rec Record) ([][]byte, []reportedFinding, error) {
+	if s.opts.Format == SyslogRFC5424 {
+		body, err := json.Marshal(rec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return [][]byte{s.header(rec, recordSeverity(rec), rec.Type, body)}, nil, nil
+	}
+
+	var messages [][]byte
+	var reported []reportedFinding
+	for _, ev := range SecurityEvents(rec) {
+		// Befunde einmal pro Zertifikat, Endpoint und Severity bzw. Gründe
+		if repeated(rec.Type) {
+			f := reportedFinding{
+				endpoint:  net.JoinHostPort(ev.Host, strconv.Itoa(ev.Port)),
+				signature: ev.Signature,
+				state:     findingState(ev.Fingerprint, strconv.Itoa(ev.Severity), ev.Reasons),
+			}
+			if s.reported(f.endpoint, f.signature, f.state) {
+				continue
+			}
+			reported = append(reported, f)
+		}
+		line := ev.CEF()
+		if s.opts.Format == SyslogLEEF {
+			line = ev.LEEF()
+		}
+		messages = append(messages, s.header(rec, securitySeverity(ev.Severity), strings.ToUpper(s.opts.Format), []byte(line)))
+	}
+	return messages, reported, nil
+}
+
+// header setzt den RFC-5424-Header vor msg
+func (s *SyslogSink) header(rec Record, severity int, msgID string, msg []byte) []byte {
+	pri := s.opts.Facility*8 + severity
+	sd := "-"
+	if rec.Agent.Version != "" {
+		sd = `[origin software="zertifikat-waechter" swVersion="` + sdEscaper.Replace(rec.Agent.Version) + `"]`
+	}
+	head := fmt.Sprintf("<%d>1 %s %s %s %d %s %s ", pri, rec.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(s.opts.Hostname, 255), headerField(s.opts.AppName, 48), os.Getpid(), headerField(msgID, 32), sd)
+	return append([]byte(head), msg...)
+}
+
+// send stellt eine Nachricht zu; nach einem Fehler wird beim nächsten Versuch neu verbunden
+func (s *SyslogSink) send(ctx context.Context, msg []byte) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("connect to syslog %s: %w", s.opts.Address, err)
+		}
+		s.conn = conn
+	}
+
+	var frame []byte
+	switch {
+	case s.opts.Network == "udp":
+		if len(msg) > maxDatagram {
+			msg = msg[:maxDatagram]
+		}
+		frame = msg
+	case s.opts.Framing == "lf":
+		frame = append(msg, '\n')
+	default:
+		frame = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	deadline := time.Now().Add(s.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	s.conn.SetWriteDeadline(deadline)
+	if _, err := s.conn.Write(frame); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return fmt.Errorf("write to syslog %s: %w", s.opts.Address, err)
+	}
+	return nil
+}
+
+func (s *SyslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.opts.Timeout}
+	if s.opts.Network == "tls" {
+		return (&tls.Dialer{NetDialer: dialer, Config: s.opts.TLS}).DialContext(ctx, "tcp", s.opts.Address)
+	}
+	return dialer.DialContext(ctx, s.opts.Network, s.opts.Address)
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// recordSeverity leitet die Syslog-Severity aus Level bzw. Severity des Records ab
+func recordSeverity(rec Record) int {
+	switch rec.Level {
+	case "debug":
+		return syslogDebug
+	case "warn":
+		return syslogWarning
+	case "error":
+		return syslogError
+	}
+	severity := ""
+	switch data := rec.Data.(type) {
+	case CertificateEvent:
+		severity = data.Severity
+	case map[string]interface{}:
+		if change, ok := data["change"].(rotation.Event); ok && change.Suspicious {
+			severity = SeverityWarning
+		}
+	}
+	switch severity {
+	case SeverityCritical:
+		return syslogCritical
+	case SeverityError:
+		return syslogError
+	case SeverityWarning:
+		return syslogWarning
+	}
+	return syslogInfo
+}
+
+// securitySeverity ordnet die CEF-/LEEF-Severity (0-10) der Syslog-Severity zu
+func securitySeverity(severity int) int {
+	switch {
+	case severity >= 9:
+		return syslogCritical
+	case severity >= 7:
+		return syslogError
+	case severity >= 5:
+		return syslogWarning
+	case severity >= 3:
+		return syslogNotice
+	}
+	return syslogInfo
+}
+
+// headerField macht einen Wert zum gültigen Header-Feld (druckbares ASCII ohne
+// Leerzeichen, gekürzt; leer = "-")
+func headerField(value string, limit int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+		if b.Len() == limit {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// sdEscaper maskiert Werte in Structured Data (RFC 5424, Abschnitt 6.3.3)
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// clientTLSConfig erstellt die TLS-Config (eigene CA, Client-Zertifikat) für host
+func clientTLSConfig(host, caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("ca_file %s contains no PEM certificates", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
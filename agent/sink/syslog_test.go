@@ -0,0 +1,369 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/rotation"
+)
+
+var update = flag.Bool("update", false, "Golden-Dateien in testdata neu schreiben")
+
+// syslogServer nimmt Syslog-Nachrichten über TCP bzw. TLS entgegen und zerlegt sie nach
+// framing (octet-counting oder lf)
+func syslogServer(t *testing.T, tlsConfig *tls.Config, framing string) (addr string, messages <-chan string) {
+	t.Helper()
+	var ln net.Listener
+	var err error
+	if tlsConfig != nil {
+		ln, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		ln, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan string, 100)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var msg string
+			if framing == "lf" {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				msg = strings.TrimSuffix(line, "\n")
+			} else {
+				prefix, err := r.ReadString(' ')
+				if err != nil {
+					return
+				}
+				n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+				if err != nil {
+					t.Errorf("invalid octet count %q", prefix)
+					return
+				}
+				buf := make([]byte, n)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					t.Errorf("frame shorter than %d octets: %v", n, err)
+					return
+				}
+				msg = string(buf)
+			}
+			ch <- msg
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+// receive wartet auf n Nachrichten
+func receive(t *testing.T, messages <-chan string, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case msg := <-messages:
+			got = append(got, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of %d syslog messages", len(got), n)
+		}
+	}
+	return got
+}
+
+// newTestSyslog prüft cfg und erstellt den Sink mit festem Hostnamen
+func newTestSyslog(t *testing.T, cfg Config) *SyslogSink {
+	t.Helper()
+	cfg.Name, cfg.Type = "siem", "syslog"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	syslog := s.(*SyslogSink)
+	syslog.opts.Hostname = "agent-host"
+	return syslog
+}
+
+// selfSignedCert erstellt ein selbstsigniertes Server-Zertifikat für 127.0.0.1 und
+// schreibt es als CA-Datei
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "syslog test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+// securityRecords sind je ein Record pro sicherheitsrelevantem Ereignis, ein Record mit
+// Sonderzeichen in Header und Werten und ein Log-Record ohne Sicherheitsbezug
+func securityRecords() []Record {
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2026, 5, 30, 12, 0, 0, 0, time.UTC)
+	agent := Agent{ConnectorID: "conn-1", Name: "agent-1", Version: "1.4.0"}
+	cert := func(id, typ string, ev CertificateEvent) Record {
+		ev.Port = 443
+		ev.NotAfter = &notAfter
+		ev.DaysLeft = 90
+		return Record{ID: id, Kind: KindEvent, Type: typ, Time: at, Agent: agent, Data: ev}
+	}
+
+	escaped := cert("rec-escape", TypeWeakCrypto, CertificateEvent{
+		Host: "legacy.example.com", Severity: SeverityWarning, Message: "a=b \\ c|d\nzweite Zeile\tmit Tab",
+		Fingerprint: "ee:ee", SubjectCN: "CN=x|y", Issuer: `Example\CA`, Reasons: []string{"weak_key"},
+	})
+	escaped.Agent.Version = `2.0|rc\1=x`
+
+	return []Record{
+		cert("rec-discovered", TypeCertificateDiscovered, CertificateEvent{
+			Host: "api.example.com", SNI: "api.example.com", Severity: SeverityInfo, Message: "New TLS service",
+			Fingerprint: "aa:aa", SubjectCN: "api.example.com", Issuer: "Example CA",
+		}),
+		{ID: "rec-change", Kind: KindEvent, Type: TypeCertificateChange, Time: at, Agent: agent, Data: map[string]interface{}{
+			"change": rotation.Event{
+				Host: "api.example.com", Port: 443, SNI: "api.example.com", OldFingerprint: "aa:aa", NewFingerprint: "bb:bb",
+				Suspicious: true, SuspiciousReasons: []string{"issuer_changed", "key_changed"}, DetectedAt: at,
+			},
+		}},
+		cert("rec-self-signed", TypeValidationFailed, CertificateEvent{
+			Host: "10.0.0.5", Severity: SeverityError, Message: "Certificate not trusted",
+			Fingerprint: "cc:cc", SubjectCN: "printer.local", Issuer: "printer.local", Reasons: []string{"self_signed"},
+		}),
+		cert("rec-weak", TypeWeakCrypto, CertificateEvent{
+			Host: "old.example.com", Severity: SeverityWarning, Message: "Weak cryptography",
+			Fingerprint: "dd:dd", SubjectCN: "old.example.com", Issuer: "Example CA", Reasons: []string{"weak_key", "weak_signature"},
+		}),
+		cert("rec-revoked", TypeValidationFailed, CertificateEvent{
+			Host: "shop.example.com", Severity: SeverityCritical, Message: "Certificate revoked",
+			Fingerprint: "ff:ff", SubjectCN: "shop.example.com", Issuer: "Example CA", Reasons: []string{"revoked"},
+		}),
+		escaped,
+		{ID: "rec-log", Kind: KindLog, Type: TypeLog, Level: "warn", Time: at, Agent: agent, Data: map[string]interface{}{"message": "Scan failed"}},
+	}
+}
+
+// TestSyslogGolden vergleicht CEF, LEEF und RFC 5424 mit den Golden-Dateien in testdata
+// (neu schreiben mit go test ./sink -run TestSyslogGolden -update)
+func TestSyslogGolden(t *testing.T) {
+	pid := " " + strconv.Itoa(os.Getpid()) + " "
+	for _, tt := range []struct {
+		format string
+		want   int // Nachrichten; CEF und LEEF nur sicherheitsrelevante Ereignisse
+	}{
+		{SyslogCEF, 6},
+		{SyslogLEEF, 6},
+		{SyslogRFC5424, 7},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			addr, messages := syslogServer(t, nil, "octet-counting")
+			s := newTestSyslog(t, Config{Network: "tcp", Address: addr, Format: tt.format, Facility: "local4"})
+			for _, rec := range securityRecords() {
+				if err := s.Write(context.Background(), rec); err != nil && err != ErrSkipped {
+					t.Fatalf("Write %s: %v", rec.ID, err)
+				}
+			}
+
+			got := receive(t, messages, tt.want)
+			for i, msg := range got {
+				// Die PID im Header hängt vom Testlauf ab
+				got[i] = strings.Replace(msg, pid, " PID ", 1)
+			}
+			golden := filepath.Join("testdata", "syslog_"+tt.format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(strings.Join(got, "\n")+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			want := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			if len(got) != len(want) {
+				t.Fatalf("got %d messages, golden file has %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("message %d:\n got: %q\nwant: %q", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestSecurityEventEscaping prüft die Maskierung von |, =, \ und Zeilenumbrüchen in
+// Header und Erweiterung
+func TestSecurityEventEscaping(t *testing.T) {
+	records := securityRecords()
+	events := SecurityEvents(records[5])
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+
+	cef := ev.CEF()
+	for _, want := range []string{
+		`CEF:0|Zertifikat-Waechter|Agent|2.0\|rc\\1=x|400|Weak cryptography|6|`,
+		`msg=a\=b \\ c|d\nzweite Zeile` + "\t" + `mit Tab`,
+		`cs2=CN\=x|y`,
+		`cs3=Example\\CA`,
+	} {
+		if !strings.Contains(cef, want) {
+			t.Errorf("CEF %q does not contain %q", cef, want)
+		}
+	}
+
+	leef := ev.LEEF()
+	for _, want := range []string{
+		`LEEF:2.0|Zertifikat-Waechter|Agent|2.0\|rc\1=x|400|x09|`,
+		"\tmsg=a=b \\ c|d zweite Zeile mit Tab\t",
+		"\tsubject=CN=x|y\t",
+	} {
+		if !strings.Contains(leef, want) {
+			t.Errorf("LEEF %q does not contain %q", leef, want)
+		}
+	}
+	if strings.ContainsAny(cef, "\r\n") || strings.ContainsAny(leef, "\r\n") {
+		t.Error("line break in CEF or LEEF event")
+	}
+}
+
+// TestSyslogPriority prüft PRI = Facility * 8 + Severity
+func TestSyslogPriority(t *testing.T) {
+	records := securityRecords()
+	tests := []struct {
+		facility string
+		format   string
+		rec      Record
+		want     string
+	}{
+		{"local0", SyslogRFC5424, records[0], "<134>1 "},   // 16*8 + info
+		{"local0", SyslogRFC5424, records[4], "<130>1 "},   // 16*8 + critical
+		{"local0", SyslogRFC5424, records[6], "<132>1 "},   // 16*8 + warning (Log-Level)
+		{"local0", SyslogRFC5424, records[1], "<132>1 "},   // verdächtiger Wechsel: warning
+		{"security", SyslogCEF, records[0], "<109>1 "},     // 13*8 + notice (CEF 3)
+		{"security", SyslogCEF, records[1], "<107>1 "},     // 13*8 + error (CEF 7)
+		{"authpriv", SyslogCEF, records[4], "<82>1 "},      // 10*8 + critical (CEF 9)
+		{"kern", SyslogLEEF, records[3], "<4>1 "},          // 0*8 + warning (LEEF 6)
+		{"local7", SyslogLEEF, records[2], "<188>1 "},      // 23*8 + warning (LEEF 5)
+		{"daemon", SyslogRFC5424, records[3], "<28>1 "},    // 3*8 + warning
+		{"user", SyslogRFC5424, records[2], "<11>1 "},      // 1*8 + error
+		{"local4", SyslogRFC5424, records[0], "<166>1 "},   // 20*8 + info
+		{"LOCAL4", SyslogCEF, records[4], "<162>1 "},       // Groß-/Kleinschreibung egal
+		{"mail", SyslogLEEF, records[0], "<21>1 "},         // 2*8 + notice (LEEF 3)
+		{"local1", SyslogLEEF, records[5], "<140>1 "},      // 17*8 + warning (LEEF 6)
+		{"security", SyslogRFC5424, records[5], "<108>1 "}, // 13*8 + warning
+	}
+	for _, tt := range tests {
+		t.Run(tt.facility+"/"+tt.format+"/"+tt.rec.ID, func(t *testing.T) {
+			s := newTestSyslog(t, Config{Network: "udp", Address: "127.0.0.1:514", Format: tt.format, Facility: tt.facility})
+			messages, _, err := s.messages(tt.rec)
+			if err != nil || len(messages) != 1 {
+				t.Fatalf("messages = %d (%v)", len(messages), err)
+			}
+			if got := string(messages[0]); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("message %q does not start with %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSyslogFraming prüft octet-counting (RFC 6587) über TLS mit eigener CA und lf über TCP;
+// die Länge zählt Bytes, nicht Zeichen
+func TestSyslogFraming(t *testing.T) {
+	cert, caFile := selfSignedCert(t)
+	rec := Record{ID: "rec-umlaut", Kind: KindLog, Type: TypeLog, Level: "info", Time: time.Now(),
+		Data: map[string]interface{}{"message": "Prüfung läuft\nzweite Zeile"}}
+
+	tests := []struct {
+		name    string
+		network string
+		framing string
+		tls     *tls.Config
+	}{
+		{"tls octet-counting", "tls", "", &tls.Config{Certificates: []tls.Certificate{cert}}},
+		{"tcp octet-counting", "tcp", "octet-counting", nil},
+		{"tcp lf", "tcp", "lf", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, messages := syslogServer(t, tt.tls, tt.framing)
+			cfg := Config{Network: tt.network, Address: addr, Framing: tt.framing}
+			if tt.tls != nil {
+				cfg.CAFile = caFile
+			}
+			s := newTestSyslog(t, cfg)
+			for i := 0; i < 3; i++ {
+				if err := s.Write(context.Background(), rec); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+			}
+			for _, msg := range receive(t, messages, 3) {
+				if !strings.HasPrefix(msg, "<134>1 ") || !strings.HasSuffix(msg, `"message":"Prüfung läuft\nzweite Zeile"}}`) {
+					t.Errorf("message = %q", msg)
+				}
+			}
+		})
+	}
+}
+
+// TestSyslogTLSUnknownCA: ohne passende CA schlägt der Verbindungsaufbau fehl, der Record
+// wird wiederholt
+func TestSyslogTLSUnknownCA(t *testing.T) {
+	cert, _ := selfSignedCert(t)
+	_, otherCA := selfSignedCert(t)
+	addr, _ := syslogServer(t, &tls.Config{Certificates: []tls.Certificate{cert}}, "")
+	s := newTestSyslog(t, Config{Network: "tls", Address: addr, CAFile: otherCA})
+	err := s.Write(context.Background(), securityRecords()[6])
+	if err == nil || !retryable(err) || !strings.Contains(err.Error(), "connect to syslog") {
+		t.Errorf("Write = %v, want retryable connect error", err)
+	}
+}
@@ -0,0 +1,160 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// StatusError ist eine Antwort des Empfängers mit HTTP-Status >= 300
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook error: %d - %s", e.StatusCode, e.Body)
+}
+
+// Retryable: 4xx-Antworten (außer 408 und 429) ändern sich durch Wiederholen nicht
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// WebhookOptions sind die Einstellungen eines Webhook-Sinks
+type WebhookOptions struct {
+	Headers     map[string]string
+	Timeout     time.Duration
+	Secret      string             // leer = unsigniert
+	Template    *template.Template // nil = Record als JSON
+	ContentType string             // Default application/json
+	StateFile   string             // gemeldete Befunde (überlebt Neustarts), leer = nur im Speicher
+}
+
+// WebhookSink sendet jeden Record per HTTP POST, optional mit eigenem Payload-Template
+// und HMAC-SHA256-Signatur (kompatibel zum Webhook-Versand der Cloud)
+type WebhookSink struct {
+	url  string
+	opts WebhookOptions
+
+	client *http.Client
+
+	// Bereits gemeldete Ablauf-/Prüfungs-Ereignisse - jeder Scan meldet sie erneut
+	*findings
+}
+
+// NewWebhookSink erstellt einen Webhook-Sink und lädt die gemeldeten Befunde
+func NewWebhookSink(endpoint string, opts WebhookOptions) (*WebhookSink, error) {
+	if opts.ContentType == "" {
+		opts.ContentType = "application/json"
+	}
+	f, err := newFindings(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	return &WebhookSink{
+		url:      endpoint,
+		opts:     opts,
+		client:   &http.Client{Timeout: opts.Timeout},
+		findings: f,
+	}, nil
+}
+
+func (s *WebhookSink) Write(ctx context.Context, rec Record) error {
+	// Ablauf, fehlgeschlagene Prüfung und schwache Kryptografie nur einmal pro Zertifikat,
+	// Endpoint und Severity bzw. Gründe
+	endpoint, state := "", ""
+	if ev, ok := rec.Data.(CertificateEvent); ok && repeated(rec.Type) {
+		endpoint, state = recordKey(rec), findingState(ev.Fingerprint, ev.Severity, ev.Reasons)
+		if s.reported(endpoint, rec.Type, state) {
+			return ErrSkipped
+		}
+	}
+
+	body, err := s.payload(rec)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("create request failed: %w", err))
+	}
+	req.Header.Set("Content-Type", s.opts.ContentType)
+	req.Header.Set("User-Agent", "zertifikat-waechter-agent")
+	req.Header.Set("X-Webhook-Event", EventName(rec.Type))
+	req.Header.Set("X-Webhook-Delivery", rec.ID)
+	if attempt := AttemptFromContext(ctx); attempt > 0 {
+		req.Header.Set("X-Webhook-Attempt", strconv.Itoa(attempt))
+	}
+	if s.opts.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+Sign(s.opts.Secret, body))
+		req.Header.Set("X-Webhook-Signature-Timestamp", rec.Time.Format(time.RFC3339Nano))
+	}
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// Ohne URL - Query-Parameter können Tokens enthalten
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	if endpoint != "" {
+		s.mark(endpoint, rec.Type, state)
+	}
+	return nil
+}
+
+// payload rendert das Template bzw. serialisiert den Record
+func (s *WebhookSink) payload(rec Record) ([]byte, error) {
+	if s.opts.Template == nil {
+		return json.Marshal(rec)
+	}
+	var buf bytes.Buffer
+	if err := s.opts.Template.Execute(&buf, rec); err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// Sign liefert die HMAC-SHA256-Signatur des Bodys (hex), wie sie im Header
+// X-Webhook-Signature nach "sha256=" steht
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// repeated meldet Ereignis-Typen, die jeder Scan erneut meldet, solange der Befund besteht
+func repeated(typ string) bool {
+	return typ == TypeCertificateExpiring || typ == TypeValidationFailed || typ == TypeWeakCrypto
+}
@@ -0,0 +1,196 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/rotation"
+)
+
+// webhookReceiver nimmt Webhooks entgegen und merkt sich die Ereignisse
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	events []string // X-Webhook-Event
+	bodies []Record
+}
+
+func newWebhookReceiver(t *testing.T) *webhookReceiver {
+	t.Helper()
+	r := &webhookReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var rec Record
+		if err := json.NewDecoder(req.Body).Decode(&rec); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		r.mu.Lock()
+		r.events = append(r.events, req.Header.Get("X-Webhook-Event"))
+		r.bodies = append(r.bodies, rec)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func expiring(fingerprint string, days int) Record {
+	severity := SeverityWarning
+	if days <= 7 {
+		severity = SeverityCritical
+	}
+	return Record{ID: "rec-" + fingerprint, Kind: KindEvent, Type: TypeCertificateExpiring, Time: time.Now(), Data: CertificateEvent{
+		Host: "api.internal", Port: 443, Severity: severity, Fingerprint: fingerprint, DaysLeft: days,
+	}}
+}
+
+func newTestWebhook(t *testing.T, url, stateFile string) *WebhookSink {
+	t.Helper()
+	s, err := NewWebhookSink(url, WebhookOptions{Timeout: time.Second, StateFile: stateFile})
+	if err != nil {
+		t.Fatalf("NewWebhookSink: %v", err)
+	}
+	return s
+}
+
+// TestWebhookDedupeSeverity: derselbe Befund wird einmal gesendet, das Unterschreiten
+// der kritischen Schwelle erneut
+func TestWebhookDedupeSeverity(t *testing.T) {
+	recv := newWebhookReceiver(t)
+	s := newTestWebhook(t, recv.URL, "")
+	ctx := context.Background()
+
+	if err := s.Write(ctx, expiring("aa", 20)); err != nil {
+		t.Fatalf("first write: %v", err)
+	}
+	if err := s.Write(ctx, expiring("aa", 19)); err != ErrSkipped {
+		t.Errorf("repeated warning: got %v, want ErrSkipped", err)
+	}
+	if err := s.Write(ctx, expiring("aa", 7)); err != nil {
+		t.Errorf("critical threshold: %v", err)
+	}
+	if err := s.Write(ctx, expiring("aa", 6)); err != ErrSkipped {
+		t.Errorf("repeated critical: got %v, want ErrSkipped", err)
+	}
+	if got := recv.count(); got != 2 {
+		t.Errorf("sent %d webhooks, want 2", got)
+	}
+	if ev := recv.bodies[1].Data.(map[string]interface{}); ev["severity"] != SeverityCritical {
+		t.Errorf("second webhook severity = %v, want critical", ev["severity"])
+	}
+}
+
+// TestWebhookDedupeValidationReasons: neue Gründe einer fehlgeschlagenen Prüfung werden gemeldet
+func TestWebhookDedupeValidationReasons(t *testing.T) {
+	recv := newWebhookReceiver(t)
+	s := newTestWebhook(t, recv.URL, "")
+	failed := func(reasons ...string) Record {
+		return Record{Kind: KindEvent, Type: TypeValidationFailed, Data: CertificateEvent{
+			Host: "api.internal", Port: 443, Severity: SeverityError, Fingerprint: "aa", Reasons: reasons,
+		}}
+	}
+	for _, rec := range []Record{failed("untrusted"), failed("untrusted"), failed("untrusted", "hostname_mismatch")} {
+		s.Write(context.Background(), rec)
+	}
+	if got := recv.count(); got != 2 {
+		t.Errorf("sent %d webhooks, want 2", got)
+	}
+}
+
+// TestWebhookStateFile: gemeldete Befunde überleben einen Neustart
+func TestWebhookStateFile(t *testing.T) {
+	recv := newWebhookReceiver(t)
+	stateFile := filepath.Join(t.TempDir(), "state", "webhook.json")
+
+	s := newTestWebhook(t, recv.URL, stateFile)
+	if err := s.Write(context.Background(), expiring("aa", 20)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := s.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	restarted := newTestWebhook(t, recv.URL, stateFile)
+	if err := restarted.Write(context.Background(), expiring("aa", 20)); err != ErrSkipped {
+		t.Errorf("after restart: got %v, want ErrSkipped", err)
+	}
+	if got := recv.count(); got != 1 {
+		t.Errorf("sent %d webhooks, want 1", got)
+	}
+}
+
+// TestWebhookForget: Zertifikatswechsel und ausgemusterte Endpoints löschen die Befunde,
+// auch wenn der Filter des Sinks diese Records nicht durchlässt
+func TestWebhookForget(t *testing.T) {
+	recv := newWebhookReceiver(t)
+	stateFile := filepath.Join(t.TempDir(), "webhook.json")
+	s := newTestWebhook(t, recv.URL, stateFile)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	d := NewDispatcher(Agent{Name: "test"}, log)
+	cfg := Config{Name: "hook", Type: "webhook", Filter: Filter{Types: []string{TypeCertificateExpiring}}}
+	if err := d.Add(cfg, s); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	d.Start()
+
+	wait := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for recv.count() < n && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := recv.count(); got != n {
+			t.Fatalf("sent %d webhooks, want %d", got, n)
+		}
+	}
+	expiringEvent := func() interface{} { return expiring("aa", 20).Data }
+
+	d.Emit(KindEvent, TypeCertificateExpiring, expiringEvent())
+	wait(1)
+
+	// Wechsel auf ein neues Zertifikat und zurück: der alte Befund gilt wieder als neu
+	d.Emit(KindEvent, TypeCertificateChange, map[string]interface{}{
+		"change": rotation.Event{Host: "api.internal", Port: 443, OldFingerprint: "aa", NewFingerprint: "bb"},
+	})
+	d.Emit(KindEvent, TypeCertificateExpiring, expiringEvent())
+	wait(2)
+
+	d.Emit(KindEvent, TypeAssetTransition, map[string]interface{}{
+		"host": "api.internal", "port": 443, "from": lifecycle.StateUnreachable, "to": lifecycle.StateRetired,
+	})
+	d.Emit(KindEvent, TypeCertificateExpiring, expiringEvent())
+	wait(3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d.Close(ctx)
+
+	for _, ev := range recv.events {
+		if ev != EventName(TypeCertificateExpiring) {
+			t.Errorf("filtered record %s was sent", ev)
+		}
+	}
+	f, err := newFindings(stateFile)
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if len(f.state) != 1 {
+		t.Errorf("state has %d endpoints, want 1", len(f.state))
+	}
+}
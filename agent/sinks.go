@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zertifikat-waechter/agent/config"
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/notify"
+	"github.com/zertifikat-waechter/agent/rotation"
+	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/sink"
+)
+
+// notifierSink ist der Name, unter dem der Notifier am Dispatcher angemeldet wird
+const notifierSink = "notifier"
+
+// newSinks erstellt den Dispatcher mit den Sinks aus SINKS_FILE (ohne Datei: keine Sinks)
+// und meldet den Notifier aus NOTIFY_FILE als weiteren Sink an (ohne Datei: nil).
+// Muss nach der Anmeldung aufgerufen werden, da die Records die Connector-ID tragen.
+func newSinks(cfg *config.Config) (*sink.Dispatcher, *notify.Notifier, error) {
+	dispatcher := sink.NewDispatcher(sink.Agent{
+		ConnectorID: cfg.ConnectorID,
+		TenantID:    cfg.TenantID,
+		Name:        cfg.ConnectorName,
+		Version:     currentBuildInfo().Version,
+	}, log)
+
+	if cfg.SinksFile != "" {
+		configs, err := sink.LoadConfig(cfg.SinksFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range configs {
+			if c.Name == notifierSink && cfg.NotifyFile != "" {
+				return nil, nil, fmt.Errorf("sink name %q is reserved for notifications", notifierSink)
+			}
+			s, err := sink.New(c)
+			if err != nil {
+				return nil, nil, fmt.Errorf("sink %s: %w", c.Name, err)
+			}
+			if err := dispatcher.Add(c, s); err != nil {
+				return nil, nil, fmt.Errorf("sink %s: %w", c.Name, err)
+			}
+		}
+	}
+
+	if cfg.NotifyFile == "" {
+		return dispatcher, nil, nil
+	}
+	notifyCfg, err := notify.Load(cfg.NotifyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	notifier, err := notify.New(notifyCfg, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := dispatcher.Add(notifier.SinkConfig(notifierSink), notifier); err != nil {
+		return nil, nil, err
+	}
+	return dispatcher, notifier, nil
+}
+
+// Gründe für certificate_validation_failed
+const (
+	problemExpired          = "expired"
+	problemNotYetValid      = "not_yet_valid"
+	problemSelfSigned       = "self_signed"
+	problemUntrusted        = "untrusted"
+	problemHostnameMismatch = "hostname_mismatch"
+	problemRevoked          = "revoked"
+)
+
+// criticalDays: Ablauf innerhalb so vieler Tage ist kritisch
+const criticalDays = 7
+
+// emitCertificateEvents meldet die Zertifikats-Ereignisse eines erfolgreichen Scans an die Sinks.
+// known gibt an, ob für den Endpoint vorher schon ein Zertifikat bekannt war.
+func (a *agent) emitCertificateEvents(runID, certID, assetID string, host string, port int, cert *scanner.CertificateData, known bool) {
+	if a.sinks.Len() == 0 {
+		return
+	}
+	now := time.Now()
+	days := daysLeft(cert.NotAfter)
+	event := func(severity, message string) sink.CertificateEvent {
+		notAfter := cert.NotAfter.UTC()
+		return sink.CertificateEvent{
+			Host:          host,
+			Port:          port,
+			SNI:           cert.SNI,
+			Severity:      severity,
+			Message:       message,
+			RunID:         runID,
+			AssetID:       assetID,
+			CertificateID: certID,
+			Fingerprint:   cert.Fingerprint,
+			SubjectCN:     cert.SubjectCN,
+			Issuer:        cert.Issuer,
+			NotAfter:      &notAfter,
+			DaysLeft:      days,
+		}
+	}
+
+	if !known {
+		a.sinks.Emit(sink.KindEvent, sink.TypeCertificateDiscovered, event(sink.SeverityInfo,
+			fmt.Sprintf("Neues Zertifikat auf %s:%d: %s (gültig bis %s)", host, port, certName(cert), cert.NotAfter.Format("2006-01-02"))))
+	}
+
+	if limit := a.sinks.ExpiringDays(); limit > 0 && days <= limit {
+		severity := sink.SeverityWarning
+		if days <= criticalDays {
+			severity = sink.SeverityCritical
+		}
+		message := fmt.Sprintf("Zertifikat auf %s:%d läuft in %d Tagen ab (%s)", host, port, days, certName(cert))
+		if days < 0 {
+			message = fmt.Sprintf("Zertifikat auf %s:%d ist seit %d Tagen abgelaufen (%s)", host, port, -days, certName(cert))
+		}
+		a.sinks.Emit(sink.KindEvent, sink.TypeCertificateExpiring, event(severity, message))
+	}
+
+	if problems := validationProblems(cert, now); len(problems) > 0 {
+		ev := event(sink.SeverityError, fmt.Sprintf("Zertifikatsprüfung für %s:%d fehlgeschlagen: %s", host, port, strings.Join(problems, ", ")))
+		ev.Reasons = problems
+		a.sinks.Emit(sink.KindEvent, sink.TypeValidationFailed, ev)
+	}
+
+	if findings := scanner.WeakCrypto(cert); len(findings) > 0 {
+		codes := make([]string, 0, len(findings))
+		messages := make([]string, 0, len(findings))
+		for _, f := range findings {
+			codes = append(codes, f.Code)
+			messages = append(messages, f.Message)
+		}
+		ev := event(sink.SeverityWarning, fmt.Sprintf("Schwache Kryptografie auf %s:%d: %s", host, port, strings.Join(messages, "; ")))
+		ev.Reasons = codes
+		a.sinks.Emit(sink.KindEvent, sink.TypeWeakCrypto, ev)
+	}
+}
+
+// certName liefert einen lesbaren Namen: CN, sonst erster SAN, sonst Fingerprint
+func certName(cert *scanner.CertificateData) string {
+	switch {
+	case cert.SubjectCN != "":
+		return cert.SubjectCN
+	case len(cert.SAN) > 0:
+		return cert.SAN[0]
+	case len(cert.Fingerprint) > 16:
+		return cert.Fingerprint[:16]
+	}
+	return cert.Fingerprint
+}
+
+// validationProblems prüft Laufzeit, Vertrauenskette, Sperrung und Hostname eines Zertifikats
+func validationProblems(cert *scanner.CertificateData, now time.Time) []string {
+	var problems []string
+	switch {
+	case now.After(cert.NotAfter):
+		problems = append(problems, problemExpired)
+	case now.Before(cert.NotBefore):
+		problems = append(problems, problemNotYetValid)
+	}
+	switch {
+	case cert.IsSelfSigned:
+		problems = append(problems, problemSelfSigned)
+	case !cert.IsTrusted:
+		problems = append(problems, problemUntrusted)
+	}
+	if cert.Revocation == scanner.RevocationRevoked {
+		problems = append(problems, problemRevoked)
+	}
+	// Bei IP-Adressen gibt es keine SNI und damit keinen Hostnamen zum Prüfen
+	if cert.SNI != "" && !rotation.Covers(cert.SAN, cert.SNI) {
+		problems = append(problems, problemHostnameMismatch)
+	}
+	return problems
+}
+
+// emitUnreachable meldet einen Endpoint, der nach unreachable gewechselt ist
+func (a *agent) emitUnreachable(runID string, transition lifecycle.Transition) {
+	a.sinks.Emit(sink.KindEvent, sink.TypeEndpointUnreachable, sink.CertificateEvent{
+		Host:       transition.Host,
+		Port:       transition.Port,
+		Severity:   sink.SeverityError,
+		Message:    fmt.Sprintf("Endpoint %s:%d ist nicht erreichbar (%s, %d Fehlversuche)", transition.Host, transition.Port, transition.Reason, transition.ConsecutiveFailures),
+		RunID:      runID,
+		AssetID:    transition.AssetID,
+		ErrorClass: transition.Reason,
+	})
+}
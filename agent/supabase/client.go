@@ -7,15 +7,20 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/zertifikat-waechter/agent/lifecycle"
+	"github.com/zertifikat-waechter/agent/rotation"
 	"github.com/zertifikat-waechter/agent/scanner"
+	"github.com/zertifikat-waechter/agent/scanrun"
 )
 
 type Client struct {
 	BaseURL     string
 	APIKey      string
 	client      *http.Client
+	transport   *countingTransport
 	TenantID    string
 	ConnectorID string
 }
@@ -35,15 +40,72 @@ type AssetData struct {
 	Port        int    `json:"port"`
 	Proto       string `json:"proto"`
 	Status      string `json:"status"`
+
+	// Lebenszyklus (siehe lifecycle.Record); null löscht einen früheren Wert
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccessAt       *time.Time `json:"last_success_at"`
+	LastFailureAt       *time.Time `json:"last_failure_at"`
+	LastCheckedAt       *time.Time `json:"last_checked_at"`
+	LastErrorClass      *string    `json:"last_error_class"`
+	LastError           *string    `json:"last_error"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+}
+
+// newAssetData baut den Asset-Datensatz aus dem Lebenszyklus-Zustand
+func (c *Client) newAssetData(rec lifecycle.Record) AssetData {
+	asset := AssetData{
+		TenantID:            c.TenantID,
+		ConnectorID:         c.ConnectorID,
+		Host:                rec.Host,
+		Port:                rec.Port,
+		Proto:               "tls",
+		Status:              string(rec.State),
+		ConsecutiveFailures: rec.ConsecutiveFailures,
+		LastSuccessAt:       rec.LastSuccessAt,
+		LastFailureAt:       rec.LastFailureAt,
+		LastCheckedAt:       rec.LastCheckedAt,
+	}
+	if rec.LastErrorClass != "" {
+		asset.LastErrorClass = &rec.LastErrorClass
+		asset.LastError = &rec.LastError
+	}
+	return asset
+}
+
+// Record liefert den Lebenszyklus-Zustand eines Asset-Datensatzes
+func (a AssetData) Record() lifecycle.Record {
+	rec := lifecycle.Record{
+		AssetID:             a.ID,
+		Host:                a.Host,
+		Port:                a.Port,
+		State:               lifecycle.State(a.Status),
+		ConsecutiveFailures: a.ConsecutiveFailures,
+		LastSuccessAt:       a.LastSuccessAt,
+		LastFailureAt:       a.LastFailureAt,
+		LastCheckedAt:       a.LastCheckedAt,
+	}
+	if a.CreatedAt != nil {
+		rec.FirstSeenAt = *a.CreatedAt
+	}
+	if a.LastErrorClass != nil {
+		rec.LastErrorClass = *a.LastErrorClass
+	}
+	if a.LastError != nil {
+		rec.LastError = *a.LastError
+	}
+	return rec
 }
 
 func NewClient(baseURL, apiKey string) *Client {
+	transport := newCountingTransport(http.DefaultTransport)
 	return &Client{
 		BaseURL: baseURL,
 		APIKey:  apiKey,
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout:   10 * time.Second,
+			Transport: transport,
 		},
+		transport: transport,
 	}
 }
 
@@ -112,18 +174,11 @@ func (c *Client) ValidateAndRegisterWithToken(ctx context.Context, token string)
 	return connector, nil
 }
 
-// UpsertAsset erstellt oder aktualisiert einen Asset-Eintrag
-func (c *Client) UpsertAsset(ctx context.Context, host string, port int) (string, error) {
-	url := fmt.Sprintf("%s/rest/v1/assets", c.BaseURL)
+// UpsertAsset erstellt oder aktualisiert einen Asset-Eintrag mit seinem Lebenszyklus-Zustand
+func (c *Client) UpsertAsset(ctx context.Context, rec lifecycle.Record) (string, error) {
+	url := fmt.Sprintf("%s/rest/v1/assets?on_conflict=tenant_id,host,port", c.BaseURL)
 
-	asset := AssetData{
-		TenantID:    c.TenantID,
-		ConnectorID: c.ConnectorID,
-		Host:        host,
-		Port:        port,
-		Proto:       "tls",
-		Status:      "active",
-	}
+	asset := c.newAssetData(rec)
 
 	data, err := json.Marshal(asset)
 	if err != nil {
@@ -163,42 +218,259 @@ func (c *Client) UpsertAsset(ctx context.Context, host string, port int) (string
 	return assets[0].ID, nil
 }
 
-// UpsertCertificate sendet Zertifikat-Daten an Supabase
-func (c *Client) UpsertCertificate(ctx context.Context, cert *scanner.CertificateData) error {
-	// Setze TenantID und AssetID falls noch nicht gesetzt
+// UpdateAssetStatus aktualisiert den Status eines bestehenden Assets, ohne es anzulegen.
+// Liefert die Asset-ID oder "" wenn es das Asset noch nicht gibt.
+func (c *Client) UpdateAssetStatus(ctx context.Context, rec lifecycle.Record) (string, error) {
+	endpoint := fmt.Sprintf("%s/rest/v1/assets?tenant_id=eq.%s&host=eq.%s&port=eq.%d",
+		c.BaseURL, url.QueryEscape(c.TenantID), url.QueryEscape(rec.Host), rec.Port)
+
+	asset := c.newAssetData(rec)
+	patch := map[string]interface{}{
+		"status":               asset.Status,
+		"consecutive_failures": asset.ConsecutiveFailures,
+		"last_success_at":      asset.LastSuccessAt,
+		"last_failure_at":      asset.LastFailureAt,
+		"last_checked_at":      asset.LastCheckedAt,
+		"last_error_class":     asset.LastErrorClass,
+		"last_error":           asset.LastError,
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return "", fmt.Errorf("marshal failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PATCH", endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return "", fmt.Errorf("create request failed: %w", err)
+	}
+
+	req.Header.Set("apikey", c.APIKey)
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "return=representation")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var assets []AssetData
+	if err := json.NewDecoder(resp.Body).Decode(&assets); err != nil {
+		return "", fmt.Errorf("decode failed: %w", err)
+	}
+	if len(assets) == 0 {
+		return "", nil
+	}
+	return assets[0].ID, nil
+}
+
+// SetAssetStatus setzt nur den Status eines Assets
+func (c *Client) SetAssetStatus(ctx context.Context, assetID, status string) error {
+	return c.send(ctx, "PATCH", "assets?id=eq."+assetID, map[string]interface{}{"status": status}, "")
+}
+
+// ListAssets lädt alle Assets dieses Connectors (z.B. um den Lebenszyklus nach einem Neustart fortzusetzen)
+func (c *Client) ListAssets(ctx context.Context) ([]AssetData, error) {
+	endpoint := fmt.Sprintf("%s/rest/v1/assets?connector_id=eq.%s&select=id,tenant_id,host,port,proto,status,consecutive_failures,last_success_at,last_failure_at,last_checked_at,last_error_class,last_error,created_at",
+		c.BaseURL, url.QueryEscape(c.ConnectorID))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+
+	req.Header.Set("apikey", c.APIKey)
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var assets []AssetData
+	if err := json.NewDecoder(resp.Body).Decode(&assets); err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	return assets, nil
+}
+
+// InsertAssetTransition protokolliert einen Zustandswechsel eines Assets
+func (c *Client) InsertAssetTransition(ctx context.Context, runID string, tr lifecycle.Transition) error {
+	row := map[string]interface{}{
+		"tenant_id":            c.TenantID,
+		"asset_id":             tr.AssetID,
+		"host":                 tr.Host,
+		"port":                 tr.Port,
+		"from_status":          tr.From,
+		"to_status":            tr.To,
+		"reason":               tr.Reason,
+		"consecutive_failures": tr.ConsecutiveFailures,
+		"last_success_at":      tr.LastSuccessAt,
+		"transitioned_at":      tr.At.UTC().Format(time.RFC3339),
+	}
+	if runID != "" {
+		row["run_id"] = runID
+	}
+	return c.send(ctx, "POST", "asset_transitions", row, "")
+}
+
+// CertificateObservation ist ein Fundort eines Zertifikats: welcher Endpoint es
+// mit welcher SNI ausliefert. first_seen_at setzt die Datenbank beim ersten Insert.
+type CertificateObservation struct {
+	TenantID      string    `json:"tenant_id"`
+	CertificateID string    `json:"certificate_id"`
+	AssetID       string    `json:"asset_id,omitempty"`
+	Host          string    `json:"host"`
+	Port          int       `json:"port"`
+	SNI           string    `json:"sni"`
+	LastSeenAt    time.Time `json:"last_seen_at"`
+	LastScanRunID string    `json:"last_scan_run_id,omitempty"`
+}
+
+// UpsertCertificateObservation meldet, dass ein Zertifikat auf einem Endpoint gesehen wurde.
+// Ein Zertifikat kann beliebig viele Fundorte haben (z.B. Wildcard auf 30 Servern).
+func (c *Client) UpsertCertificateObservation(ctx context.Context, obs CertificateObservation) error {
+	if obs.TenantID == "" {
+		obs.TenantID = c.TenantID
+	}
+	return c.send(ctx, "POST", "certificate_observations?on_conflict=certificate_id,host,port,sni", obs, "resolution=merge-duplicates")
+}
+
+// EndpointCertificate ist ein Fundort mit dem dort ausgelieferten Zertifikat
+type EndpointCertificate struct {
+	Host          string                  `json:"host"`
+	Port          int                     `json:"port"`
+	SNI           string                  `json:"sni"`
+	CertificateID string                  `json:"certificate_id"`
+	LastSeenAt    time.Time               `json:"last_seen_at"`
+	Certificate   scanner.CertificateData `json:"certificate"`
+}
+
+// ListEndpointCertificates lädt alle Fundorte des Tenants, älteste zuerst -
+// je Endpoint ist damit der letzte Eintrag das aktuelle Zertifikat
+func (c *Client) ListEndpointCertificates(ctx context.Context) ([]EndpointCertificate, error) {
+	endpoint := fmt.Sprintf("%s/rest/v1/certificate_observations?tenant_id=eq.%s&select=host,port,sni,certificate_id,last_seen_at,certificate:certificates(*)&order=last_seen_at.asc",
+		c.BaseURL, url.QueryEscape(c.TenantID))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+
+	req.Header.Set("apikey", c.APIKey)
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var result []EndpointCertificate
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	return result, nil
+}
+
+// InsertCertificateChange speichert einen erkannten Zertifikatswechsel mit Diff
+func (c *Client) InsertCertificateChange(ctx context.Context, runID, assetID string, event rotation.Event) error {
+	row := map[string]interface{}{
+		"tenant_id":          c.TenantID,
+		"host":               event.Host,
+		"port":               event.Port,
+		"sni":                event.SNI,
+		"old_fingerprint":    event.OldFingerprint,
+		"new_fingerprint":    event.NewFingerprint,
+		"changes":            event.Changes,
+		"suspicious":         event.Suspicious,
+		"suspicious_reasons": event.SuspiciousReasons,
+		"detected_at":        event.DetectedAt.Format(time.RFC3339),
+	}
+	if event.OldCertificateID != "" {
+		row["old_certificate_id"] = event.OldCertificateID
+	}
+	if event.NewCertificateID != "" {
+		row["new_certificate_id"] = event.NewCertificateID
+	}
+	if assetID != "" {
+		row["asset_id"] = assetID
+	}
+	if runID != "" {
+		row["run_id"] = runID
+	}
+	return c.send(ctx, "POST", "certificate_changes", row, "")
+}
+
+// UpsertCertificate sendet Zertifikat-Daten an Supabase und liefert die Zertifikat-ID
+func (c *Client) UpsertCertificate(ctx context.Context, cert *scanner.CertificateData) (string, error) {
+	// Setze TenantID falls noch nicht gesetzt
 	if cert.TenantID == "" {
 		cert.TenantID = c.TenantID
 	}
 
-	url := fmt.Sprintf("%s/rest/v1/certificates", c.BaseURL)
+	url := fmt.Sprintf("%s/rest/v1/certificates?on_conflict=fingerprint", c.BaseURL)
 
-	data, err := json.Marshal(cert)
+	// asset_id nicht mitsenden: bei merge-duplicates würde es das Asset des zuletzt
+	// gescannten Fundorts übernehmen - Fundorte stehen in certificate_observations
+	row := *cert
+	row.AssetID = ""
+	data, err := json.Marshal(&row)
 	if err != nil {
-		return fmt.Errorf("marshal failed: %w", err)
+		return "", fmt.Errorf("marshal failed: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
 	if err != nil {
-		return fmt.Errorf("create request failed: %w", err)
+		return "", fmt.Errorf("create request failed: %w", err)
 	}
 
 	req.Header.Set("apikey", c.APIKey)
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Prefer", "resolution=merge-duplicates")
+	req.Header.Set("Prefer", "return=representation,resolution=merge-duplicates")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("request failed: %w", err)
+		return "", fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
 	}
 
-	return nil
+	var certs []struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&certs); err != nil {
+		return "", fmt.Errorf("decode failed: %w", err)
+	}
+
+	if len(certs) == 0 {
+		return "", fmt.Errorf("no certificate returned")
+	}
+
+	return certs[0].ID, nil
 }
 
 // UpdateConnectorHeartbeat aktualisiert last_seen des Connectors
@@ -210,8 +482,9 @@ func (c *Client) UpdateConnectorHeartbeat(ctx context.Context) error {
 	url := fmt.Sprintf("%s/rest/v1/connectors?id=eq.%s", c.BaseURL, c.ConnectorID)
 
 	payload := map[string]interface{}{
-		"last_seen": time.Now().UTC().Format(time.RFC3339),
-		"status":    "active",
+		"last_seen":      time.Now().UTC().Format(time.RFC3339),
+		"status":         "active",
+		"offline_reason": nil,
 	}
 
 	data, err := json.Marshal(payload)
@@ -242,9 +515,29 @@ func (c *Client) UpdateConnectorHeartbeat(ctx context.Context) error {
 	return nil
 }
 
+// MarkConnectorOffline meldet den Connector beim Beenden als offline, mit Grund
+func (c *Client) MarkConnectorOffline(ctx context.Context, reason string) error {
+	if c.ConnectorID == "" {
+		return fmt.Errorf("connector not registered")
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	return c.send(ctx, "PATCH", "connectors?id=eq."+c.ConnectorID, map[string]interface{}{
+		"status":         "inactive",
+		"last_seen":      now,
+		"offline_at":     now,
+		"offline_reason": reason,
+	}, "")
+}
+
+// ConnectorConfig ist die vom User gepflegte Config plus der vom Agent quittierte Scan-Trigger
+type ConnectorConfig struct {
+	Config     map[string]interface{} `json:"config"`
+	TriggerAck int64                  `json:"scan_trigger_ack"`
+}
+
 // GetConnectorConfig holt aktuelle Config vom Backend
-func (c *Client) GetConnectorConfig(ctx context.Context, connectorID string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/rest/v1/connectors?id=eq.%s&select=config", c.BaseURL, connectorID)
+func (c *Client) GetConnectorConfig(ctx context.Context, connectorID string) (*ConnectorConfig, error) {
+	url := fmt.Sprintf("%s/rest/v1/connectors?id=eq.%s&select=config,scan_trigger_ack", c.BaseURL, connectorID)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -264,10 +557,7 @@ func (c *Client) GetConnectorConfig(ctx context.Context, connectorID string) (ma
 		return nil, fmt.Errorf("supabase error: %d", resp.StatusCode)
 	}
 
-	var results []struct {
-		Config map[string]interface{} `json:"config"`
-	}
-
+	var results []ConnectorConfig
 	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
 		return nil, fmt.Errorf("decode failed: %w", err)
 	}
@@ -276,33 +566,103 @@ func (c *Client) GetConnectorConfig(ctx context.Context, connectorID string) (ma
 		return nil, nil
 	}
 
-	return results[0].Config, nil
+	return &results[0], nil
 }
 
-// ClearScanTrigger löscht Scan-Trigger aus Config
-func (c *Client) ClearScanTrigger(ctx context.Context, connectorID string) error {
-	// Hole aktuelle Config
-	config, err := c.GetConnectorConfig(ctx, connectorID)
-	if err != nil {
-		return err
+// AcknowledgeScanTrigger quittiert einen Scan-Trigger, ohne connectors.config anzufassen
+func (c *Client) AcknowledgeScanTrigger(ctx context.Context, trigger int64) error {
+	return c.rpc(ctx, "acknowledge_scan_trigger", map[string]interface{}{
+		"p_connector_id": c.ConnectorID,
+		"p_trigger":      trigger,
+	}, nil)
+}
+
+// ReportScanState schreibt den Scan-Zustand in connectors.scan_state.
+// Das Backend verwirft Updates, deren Sequenznummer nicht größer als die zuletzt gespeicherte ist.
+func (c *Client) ReportScanState(ctx context.Context, seq int64, state interface{}) error {
+	if c.ConnectorID == "" {
+		return nil
 	}
+	return c.rpc(ctx, "report_scan_state", map[string]interface{}{
+		"p_connector_id": c.ConnectorID,
+		"p_seq":          seq,
+		"p_state":        state,
+	}, nil)
+}
 
-	// Entferne trigger_scan
-	delete(config, "trigger_scan")
+// CheckData ist ein Eintrag der checks-Tabelle (Ergebnis einer Zertifikatsprüfung)
+type CheckData struct {
+	CertificateID string                 `json:"certificate_id"`
+	ScanRunID     string                 `json:"scan_run_id,omitempty"`
+	AssetID       string                 `json:"asset_id,omitempty"`
+	Status        string                 `json:"status"` // success, warning, error, expired
+	Details       map[string]interface{} `json:"details,omitempty"`
+	RanAt         time.Time              `json:"ran_at"`
+}
 
-	// Update Config
-	url := fmt.Sprintf("%s/rest/v1/connectors?id=eq.%s", c.BaseURL, connectorID)
+// CreateScanRun legt einen neuen Scan-Lauf an
+func (c *Client) CreateScanRun(ctx context.Context, run scanrun.Summary) error {
+	return c.send(ctx, "POST", "scan_runs", run, "")
+}
 
-	payload := map[string]interface{}{
-		"config": config,
+// FinishScanRun schreibt Endstatus und Statistiken eines Scan-Laufs
+func (c *Client) FinishScanRun(ctx context.Context, run scanrun.Summary) error {
+	return c.send(ctx, "PATCH", "scan_runs?id=eq."+run.ID, run, "")
+}
+
+// InsertScanOutcomes speichert die Endpoint-Ergebnisse eines Laufs (Bulk-Insert)
+func (c *Client) InsertScanOutcomes(ctx context.Context, runID string, outcomes []scanrun.Outcome) error {
+	if len(outcomes) == 0 {
+		return nil
 	}
 
+	rows := make([]map[string]interface{}, 0, len(outcomes))
+	for _, o := range outcomes {
+		row := map[string]interface{}{
+			"run_id":      runID,
+			"tenant_id":   c.TenantID,
+			"host":        o.Host,
+			"port":        o.Port,
+			"status":      o.Status,
+			"duration_ms": o.DurationMs,
+			"scanned_at":  o.ScannedAt.Format(time.RFC3339),
+		}
+		if o.AssetID != "" {
+			row["asset_id"] = o.AssetID
+		}
+		if o.ErrorClass != "" {
+			row["error_class"] = o.ErrorClass
+			row["error_message"] = o.ErrorMessage
+			row["error_phase"] = o.ErrorPhase
+		}
+		if o.TLSAlertCode != nil {
+			row["tls_alert_code"] = *o.TLSAlertCode
+			row["tls_alert"] = o.TLSAlert
+		}
+		if o.Fingerprint != "" {
+			row["certificate_fingerprint"] = o.Fingerprint
+		}
+		rows = append(rows, row)
+	}
+
+	return c.send(ctx, "POST", "scan_run_endpoints", rows, "")
+}
+
+// InsertCheck speichert das Prüfergebnis eines Zertifikats
+func (c *Client) InsertCheck(ctx context.Context, check *CheckData) error {
+	return c.send(ctx, "POST", "checks", check, "")
+}
+
+// send schickt payload als JSON an eine Tabelle (path inkl. Query-Parametern)
+func (c *Client) send(ctx context.Context, method, path string, payload interface{}, prefer string) error {
+	url := fmt.Sprintf("%s/rest/v1/%s", c.BaseURL, path)
+
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("marshal failed: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "PATCH", url, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("create request failed: %w", err)
 	}
@@ -310,6 +670,9 @@ func (c *Client) ClearScanTrigger(ctx context.Context, connectorID string) error
 	req.Header.Set("apikey", c.APIKey)
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	req.Header.Set("Content-Type", "application/json")
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -317,6 +680,49 @@ func (c *Client) ClearScanTrigger(ctx context.Context, connectorID string) error
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// rpc ruft eine Postgres-Funktion über PostgREST auf und dekodiert optional das Ergebnis
+func (c *Client) rpc(ctx context.Context, function string, params map[string]interface{}, result interface{}) error {
+	url := fmt.Sprintf("%s/rest/v1/rpc/%s", c.BaseURL, function)
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+
+	req.Header.Set("apikey", c.APIKey)
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("decode failed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -438,35 +844,25 @@ func (c *Client) SendLog(ctx context.Context, connectorName, level, message stri
 	return nil
 }
 
-// UpdateScanProgress aktualisiert Scan-Fortschritt
-func (c *Client) UpdateScanProgress(ctx context.Context, current, total int, status string) error {
+// ReportConfigStatus meldet dem Backend welche Config-Version angewendet bzw. abgelehnt wurde
+func (c *Client) ReportConfigStatus(ctx context.Context, version int64, revision, status string, errors []string) error {
 	if c.ConnectorID == "" {
-		return nil
-	}
-
-	// Hole aktuelle Config
-	config, err := c.GetConnectorConfig(ctx, c.ConnectorID)
-	if err != nil {
-		return err
+		return fmt.Errorf("connector not registered")
 	}
 
-	if config == nil {
-		config = make(map[string]interface{})
-	}
+	url := fmt.Sprintf("%s/rest/v1/connectors?id=eq.%s", c.BaseURL, c.ConnectorID)
 
-	// Update Progress
-	config["scanning"] = current < total
-	config["scan_progress"] = map[string]interface{}{
-		"current": current,
-		"total":   total,
-		"status":  status,
+	if errors == nil {
+		errors = []string{}
 	}
 
-	// Update in DB
-	url := fmt.Sprintf("%s/rest/v1/connectors?id=eq.%s", c.BaseURL, c.ConnectorID)
-
+	// Nur die Status-Spalten schreiben - connectors.config gehört dem User
 	payload := map[string]interface{}{
-		"config": config,
+		"config_version":    version,
+		"config_revision":   revision,
+		"config_status":     status,
+		"config_errors":     errors,
+		"config_applied_at": time.Now().UTC().Format(time.RFC3339),
 	}
 
 	data, err := json.Marshal(payload)
@@ -489,7 +885,10 @@ func (c *Client) UpdateScanProgress(ctx context.Context, current, total int, sta
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("supabase error: %d - %s", resp.StatusCode, string(body))
+	}
+
 	return nil
 }
-
-
@@ -0,0 +1,42 @@
+package supabase
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zertifikat-waechter/agent/scanner"
+)
+
+func TestUpsertCertificateWithoutAsset(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/certificates" || r.URL.Query().Get("on_conflict") != "fingerprint" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.Write([]byte(`[{"id":"cert-1"}]`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "key")
+	c.TenantID = "tenant-1"
+	cert := &scanner.CertificateData{AssetID: "asset-1", Fingerprint: "ab:cd"}
+	id, err := c.UpsertCertificate(context.Background(), cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "cert-1" {
+		t.Errorf("id = %q, want cert-1", id)
+	}
+	if _, ok := body["asset_id"]; ok {
+		t.Errorf("asset_id sent in certificate upsert: %v", body["asset_id"])
+	}
+	if body["tenant_id"] != "tenant-1" || body["fingerprint"] != "ab:cd" {
+		t.Errorf("body = %v", body)
+	}
+}
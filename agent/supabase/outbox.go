@@ -0,0 +1,158 @@
+package supabase
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// outboxAttempts ist die Anzahl Sendeversuche pro Eintrag im laufenden Betrieb
+const outboxAttempts = 3
+
+// outboxTimeout begrenzt einen einzelnen Sendeversuch
+const outboxTimeout = 10 * time.Second
+
+type outboxItem struct {
+	name     string
+	send     func(ctx context.Context) error
+	attempts int
+}
+
+// Outbox puffert Schreibzugriffe, auf die ein Scan nicht warten muss (Logs, Endpoint-
+// Ergebnisse, Events), und sendet sie im Hintergrund. Beim Shutdown leert Flush die
+// Outbox innerhalb einer Frist, damit nichts verloren geht.
+// Die Einträge senden mit eigenem Timeout - ein abgebrochener Scan-Context bricht
+// bereits eingereihte Schreibzugriffe nicht ab.
+type Outbox struct {
+	log     *logrus.Logger
+	queue   chan *outboxItem
+	stopped chan struct{} // wird geschlossen, wenn Run beendet ist
+	dropped atomic.Int64
+	failed  atomic.Int64
+
+	lastDrop atomic.Int64 // Unix-Nanosekunden des letzten verworfenen Eintrags
+}
+
+// NewOutbox erstellt eine Outbox mit fester Kapazität; Run muss als Goroutine gestartet werden
+func NewOutbox(capacity int, log *logrus.Logger) *Outbox {
+	return &Outbox{
+		log:     log,
+		queue:   make(chan *outboxItem, capacity),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Enqueue reiht einen Schreibzugriff ein. Blockiert nie - ist die Outbox voll,
+// wird der Eintrag verworfen und false geliefert.
+func (o *Outbox) Enqueue(name string, send func(ctx context.Context) error) bool {
+	return o.enqueue(&outboxItem{name: name, send: send})
+}
+
+func (o *Outbox) enqueue(item *outboxItem) bool {
+	select {
+	case o.queue <- item:
+		return true
+	default:
+		o.dropped.Add(1)
+		o.lastDrop.Store(time.Now().UnixNano())
+		o.log.WithField("write", item.name).Warn("Outbox full - dropping backend write")
+		return false
+	}
+}
+
+// Len liefert die Anzahl ausstehender Einträge
+func (o *Outbox) Len() int {
+	return len(o.queue)
+}
+
+// Cap liefert die Kapazität der Outbox
+func (o *Outbox) Cap() int {
+	return cap(o.queue)
+}
+
+// Dropped liefert die Anzahl wegen voller Outbox verworfener Einträge
+func (o *Outbox) Dropped() int64 {
+	return o.dropped.Load()
+}
+
+// LastDrop liefert den Zeitpunkt des zuletzt verworfenen Eintrags (Nullzeit = nie)
+func (o *Outbox) LastDrop() time.Time {
+	if ns := o.lastDrop.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// Failed liefert die Anzahl endgültig fehlgeschlagener Einträge
+func (o *Outbox) Failed() int64 {
+	return o.failed.Load()
+}
+
+// Run sendet Einträge bis ctx beendet wird. Fehlgeschlagene Einträge werden
+// bis zu outboxAttempts Mal erneut eingereiht.
+func (o *Outbox) Run(ctx context.Context) {
+	defer close(o.stopped)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-o.queue:
+			o.deliver(ctx, item)
+		}
+	}
+}
+
+// deliver sendet einen Eintrag und reiht ihn bei Fehlern erneut ein
+func (o *Outbox) deliver(ctx context.Context, item *outboxItem) {
+	err := o.process(context.Background(), item)
+	if err == nil {
+		return
+	}
+	if item.attempts < outboxAttempts {
+		// kurze Pause, damit ein kurzer Backend-Ausfall nicht alle Versuche verbraucht
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(item.attempts) * time.Second):
+		}
+		o.enqueue(item)
+		return
+	}
+	o.failed.Add(1)
+	o.log.WithError(err).WithField("write", item.name).Warn("Backend write failed permanently")
+}
+
+// Flush sendet alle ausstehenden Einträge (je ein Versuch), bis die Outbox leer ist
+// oder ctx abläuft. Der Context von Run muss vorher beendet sein - Flush wartet,
+// bis Run den gerade bearbeiteten Eintrag abgeschlossen hat.
+// Liefert die Anzahl nicht gesendeter Einträge.
+func (o *Outbox) Flush(ctx context.Context) int {
+	select {
+	case <-o.stopped:
+	case <-ctx.Done():
+		return o.Len()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return o.Len()
+		case item := <-o.queue:
+			if err := o.process(ctx, item); err != nil {
+				o.failed.Add(1)
+				o.log.WithError(err).WithField("write", item.name).Warn("Backend write failed during flush")
+			}
+		default:
+			return 0
+		}
+	}
+}
+
+// process führt einen Sendeversuch mit eigenem Timeout aus
+func (o *Outbox) process(parent context.Context, item *outboxItem) error {
+	item.attempts++
+	ctx, cancel := context.WithTimeout(parent, outboxTimeout)
+	defer cancel()
+	return item.send(ctx)
+}
@@ -0,0 +1,161 @@
+package supabase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ScanState ist der Scan-Zustand, den die UI in connectors.scan_state anzeigt
+type ScanState struct {
+	Scanning  bool       `json:"scanning"`
+	Mode      string     `json:"mode,omitempty"` // "targets" oder "discovery"
+	Current   int        `json:"current"`
+	Total     int        `json:"total"`
+	Status    string     `json:"status"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// StateWriter nimmt den Scan-Zustand auf (Backend bzw. lokale Datenbank)
+type StateWriter interface {
+	ReportScanState(ctx context.Context, seq int64, state interface{}) error
+}
+
+// ProgressReporter meldet den Scan-Fortschritt gedrosselt an das Backend.
+// Update blockiert nie - gesendet wird im Hintergrund höchstens alle minInterval,
+// und zwar immer der neueste Stand. Jede Meldung trägt eine monoton steigende
+// Sequenznummer, damit verspätete Requests ältere Stände nicht zurückschreiben.
+type ProgressReporter struct {
+	writer      StateWriter
+	minInterval time.Duration
+	log         *logrus.Logger
+
+	mu      sync.Mutex
+	state   ScanState
+	dirty   bool
+	lastSeq int64
+
+	wake chan struct{}
+}
+
+// NewProgressReporter erstellt einen Reporter; Run muss als Goroutine gestartet werden
+func NewProgressReporter(writer StateWriter, minInterval time.Duration, log *logrus.Logger) *ProgressReporter {
+	return &ProgressReporter{
+		writer:      writer,
+		minInterval: minInterval,
+		log:         log,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Start markiert den Beginn eines Scans und meldet ihn sofort
+func (p *ProgressReporter) Start(ctx context.Context, mode string, total int) {
+	now := time.Now().UTC()
+
+	p.mu.Lock()
+	p.state = ScanState{
+		Scanning:  true,
+		Mode:      mode,
+		Total:     total,
+		Status:    "started",
+		StartedAt: &now,
+	}
+	p.dirty = true
+	p.mu.Unlock()
+
+	p.flush(ctx)
+}
+
+// Update aktualisiert den Fortschritt (gedrosselt, nicht blockierend)
+func (p *ProgressReporter) Update(current, total int, status string) {
+	p.mu.Lock()
+	p.state.Current = current
+	p.state.Total = total
+	p.state.Status = status
+	p.dirty = true
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Finish markiert das Ende eines Scans und meldet es sofort
+func (p *ProgressReporter) Finish(ctx context.Context, status string) {
+	p.mu.Lock()
+	p.state.Scanning = false
+	p.state.Status = status
+	p.dirty = true
+	p.mu.Unlock()
+
+	p.flush(ctx)
+}
+
+// State liefert den aktuellen Scan-Zustand
+func (p *ProgressReporter) State() ScanState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+// Run sendet gepufferte Updates bis ctx beendet wird
+func (p *ProgressReporter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		}
+
+		p.flush(ctx)
+
+		// Drosselung: nächstes Update frühestens nach minInterval
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.minInterval):
+		}
+	}
+}
+
+// Flush sendet einen noch nicht gemeldeten Stand sofort (z.B. beim Shutdown)
+func (p *ProgressReporter) Flush(ctx context.Context) {
+	p.flush(ctx)
+}
+
+// flush sendet den aktuellen Stand, falls er sich seit dem letzten Senden geändert hat.
+// Schlägt das Senden fehl, bleibt der Stand für den nächsten Versuch markiert.
+func (p *ProgressReporter) flush(ctx context.Context) {
+	p.mu.Lock()
+	if !p.dirty {
+		p.mu.Unlock()
+		return
+	}
+	p.dirty = false
+	p.state.UpdatedAt = time.Now().UTC()
+	state := p.state
+	seq := p.nextSeq()
+	p.mu.Unlock()
+
+	if err := p.writer.ReportScanState(ctx, seq, state); err != nil {
+		p.log.WithError(err).WithField("seq", seq).Debug("Failed to report scan state")
+		p.mu.Lock()
+		p.dirty = true
+		p.mu.Unlock()
+	}
+}
+
+// nextSeq liefert die nächste Sequenznummer. Basis ist die Uhrzeit in Mikrosekunden,
+// damit die Folge auch über Neustarts des Agents hinweg monoton bleibt.
+func (p *ProgressReporter) nextSeq() int64 {
+	seq := time.Now().UnixMicro()
+	if seq <= p.lastSeq {
+		seq = p.lastSeq + 1
+	}
+	p.lastSeq = seq
+	return seq
+}
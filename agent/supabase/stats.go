@@ -0,0 +1,111 @@
+package supabase
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestStats zählt Backend-Requests einer Ressource (Tabelle bzw. RPC)
+type RequestStats struct {
+	Resource string
+	Requests int64
+	Errors   int64 // Transportfehler und HTTP-Status >= 400
+}
+
+// BackendHealth beschreibt die Erreichbarkeit des Backends anhand der letzten Requests
+type BackendHealth struct {
+	LastSuccessAt     time.Time
+	LastErrorAt       time.Time
+	LastError         string
+	ConsecutiveErrors int
+}
+
+// countingTransport zählt Requests und Fehler pro Ressource
+type countingTransport struct {
+	next http.RoundTripper
+
+	mu     sync.Mutex
+	stats  map[string]*RequestStats
+	health BackendHealth
+}
+
+func newCountingTransport(next http.RoundTripper) *countingTransport {
+	return &countingTransport{next: next, stats: make(map[string]*RequestStats)}
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+
+	resource := resourceName(req.URL.Path)
+	t.mu.Lock()
+	stats, ok := t.stats[resource]
+	if !ok {
+		stats = &RequestStats{Resource: resource}
+		t.stats[resource] = stats
+	}
+	stats.Requests++
+	switch {
+	case err != nil:
+		stats.Errors++
+		t.failed(err.Error())
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		stats.Errors++
+		t.failed(fmt.Sprintf("%s %s: HTTP %d", req.Method, resource, resp.StatusCode))
+	case resp.StatusCode >= 400:
+		// Backend erreichbar, einzelner Request abgelehnt (z.B. Constraint) - kein Verbindungsproblem
+		stats.Errors++
+		t.health.LastSuccessAt = time.Now()
+		t.health.ConsecutiveErrors = 0
+	default:
+		t.health.LastSuccessAt = time.Now()
+		t.health.ConsecutiveErrors = 0
+	}
+	t.mu.Unlock()
+
+	return resp, err
+}
+
+// failed hält einen Verbindungs- oder Serverfehler fest (t.mu gehalten)
+func (t *countingTransport) failed(msg string) {
+	t.health.LastErrorAt = time.Now()
+	t.health.LastError = msg
+	t.health.ConsecutiveErrors++
+}
+
+// snapshot liefert die Zähler sortiert nach Ressource
+func (t *countingTransport) snapshot() []RequestStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := make([]RequestStats, 0, len(t.stats))
+	for _, stats := range t.stats {
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Resource < list[j].Resource })
+	return list
+}
+
+// resourceName macht aus "/rest/v1/assets" "assets" und aus "/rest/v1/rpc/fn" "rpc/fn"
+func resourceName(path string) string {
+	name := strings.TrimPrefix(path, "/rest/v1/")
+	if name == path {
+		return "other"
+	}
+	return name
+}
+
+// RequestStats liefert die Request- und Fehlerzähler pro Ressource seit dem Start
+func (c *Client) RequestStats() []RequestStats {
+	return c.transport.snapshot()
+}
+
+// Health liefert den Verbindungszustand zum Backend
+func (c *Client) Health() BackendHealth {
+	c.transport.mu.Lock()
+	defer c.transport.mu.Unlock()
+	return c.transport.health
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build-Informationen, werden beim Build gesetzt:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo beschreibt das laufende Binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // mit uncommitteten Änderungen gebaut
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuildInfo liefert die Build-Informationen; fehlende ldflags werden aus den
+// VCS-Daten ergänzt, die go build selbst einbettet
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" && len(setting.Value) >= 12 {
+				info.Commit = setting.Value[:12]
+			} else if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
@@ -25,6 +25,15 @@ interface Asset {
   }
 }
 
+const assetStatusVariant: Record<string, 'success' | 'warning' | 'error' | 'neutral'> = {
+  active: 'success',
+  degraded: 'warning',
+  unreachable: 'error',
+  error: 'error',
+  retired: 'neutral',
+  inactive: 'neutral',
+}
+
 export default function Assets() {
   const { user } = useAuth()
   const [loading, setLoading] = useState(true)
@@ -208,7 +217,7 @@ export default function Assets() {
                         </td>
                         <td className="px-6 py-4">
                           <Badge
-                            variant={asset.status === 'active' ? 'success' : 'neutral'}
+                            variant={assetStatusVariant[asset.status] ?? 'neutral'}
                             size="sm"
                           >
                             {asset.status}
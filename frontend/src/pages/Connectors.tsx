@@ -15,12 +15,7 @@ interface Connector {
   config?: {
     scan_targets?: string[]
     scan_ports?: number[]
-    scanning?: boolean
-    scan_progress?: {
-      current: number
-      total: number
-      status: string
-    }
+    trigger_scan?: number
     last_scan?: {
       total: number
       success: number
@@ -28,6 +23,15 @@ interface Connector {
       timestamp: string
     }
   } | null
+  // Vom Agent gemeldeter Scan-Zustand (getrennt von der User-Config)
+  scan_state?: {
+    scanning?: boolean
+    mode?: string
+    current: number
+    total: number
+    status: string
+    updated_at?: string
+  } | null
   created_at: string
 }
 
@@ -726,7 +730,7 @@ docker run -d \`
                               <span className="text-green-700 font-medium">Verbunden</span>
                               <span className="text-[#94A3B8]">•</span>
                               <span>{formatLastSeen(connector.last_seen)}</span>
-                              {connector.config?.scanning && (
+                              {connector.scan_state?.scanning && (
                                 <>
                                   <span className="text-[#94A3B8]">•</span>
                                   <span className="text-blue-600 font-medium animate-pulse">🔍 Scannt...</span>
@@ -744,7 +748,7 @@ docker run -d \`
                         </div>
 
                         {/* Scan Progress - nur anzeigen wenn aktiv am Scannen */}
-                        {connector.config?.scanning && connector.config?.scan_progress && connector.config.scan_progress.total > 0 && (
+                        {connector.scan_state?.scanning && connector.scan_state.total > 0 && (
                           <div className="bg-gradient-to-r from-blue-50 to-indigo-50 rounded-lg p-3 border border-blue-200">
                             <div className="flex items-center justify-between text-xs mb-2">
                               <span className="text-[#0F172A] font-semibold flex items-center gap-1">
@@ -752,17 +756,17 @@ docker run -d \`
                                 Scanning läuft...
                               </span>
                               <span className="text-blue-600 font-bold">
-                                {Math.round((connector.config.scan_progress.current / connector.config.scan_progress.total) * 100)}%
+                                {Math.round((connector.scan_state.current / connector.scan_state.total) * 100)}%
                               </span>
                             </div>
                             <div className="w-full bg-white rounded-full h-2 shadow-inner">
                               <div
                                 className="bg-gradient-to-r from-blue-500 to-indigo-500 h-2 rounded-full transition-all duration-500 shadow-sm"
-                                style={{ width: `${(connector.config.scan_progress.current / connector.config.scan_progress.total) * 100}%` }}
+                                style={{ width: `${(connector.scan_state.current / connector.scan_state.total) * 100}%` }}
                               ></div>
                             </div>
                             <div className="text-xs text-[#64748B] mt-1">
-                              {connector.config.scan_progress.current} / {connector.config.scan_progress.total} Hosts
+                              {connector.scan_state.current} / {connector.scan_state.total} Hosts
                             </div>
                           </div>
                         )}
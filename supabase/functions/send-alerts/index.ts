@@ -39,7 +39,7 @@ serve(async (req) => {
       .from('alerts')
       .select(`
         *,
-        certificate:certificates(*)
+        certificate:certificates(*, asset:assets(status))
       `)
       .is('acknowledged_at', null)
       .order('first_triggered_at', { ascending: true })
@@ -63,6 +63,9 @@ serve(async (req) => {
       const tenantId = alert.certificate?.tenant_id
       if (!tenantId) continue
 
+      // Abgebaute Endpoints (retired) nicht mehr alarmieren
+      if (alert.certificate?.asset?.status === 'retired') continue
+
       if (!alertsByTenant.has(tenantId)) {
         alertsByTenant.set(tenantId, [])
       }
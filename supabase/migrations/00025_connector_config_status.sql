@@ -0,0 +1,16 @@
+-- Connector Config Status
+-- Agent meldet zurück, welche Config-Version er angewendet (oder abgelehnt) hat.
+-- Die Spalten liegen bewusst NEBEN connectors.config, damit der Agent die
+-- vom User gepflegte Config nie überschreibt.
+
+ALTER TABLE connectors
+  ADD COLUMN IF NOT EXISTS config_version BIGINT,
+  ADD COLUMN IF NOT EXISTS config_revision TEXT,
+  ADD COLUMN IF NOT EXISTS config_status TEXT CHECK (config_status IN ('applied', 'rejected')),
+  ADD COLUMN IF NOT EXISTS config_errors JSONB DEFAULT '[]',
+  ADD COLUMN IF NOT EXISTS config_applied_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN connectors.config_version IS 'Lokale Config-Version des Agents (monoton steigend)';
+COMMENT ON COLUMN connectors.config_revision IS 'Hash der Backend-Config, auf die sich config_status bezieht';
+COMMENT ON COLUMN connectors.config_status IS 'applied = Config aktiv, rejected = Config ungültig (siehe config_errors)';
+COMMENT ON COLUMN connectors.config_errors IS 'Validierungsfehler der zuletzt abgelehnten Config';
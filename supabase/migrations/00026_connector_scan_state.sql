@@ -0,0 +1,50 @@
+-- Connector Scan State
+-- Scan-Fortschritt und Trigger-Quittung liegen in eigenen Spalten.
+-- Vorher hat der Agent connectors.config gelesen, verändert und zurückgeschrieben
+-- und dabei parallele Änderungen aus der UI überschrieben.
+
+ALTER TABLE connectors
+  ADD COLUMN IF NOT EXISTS scan_state JSONB DEFAULT '{}',
+  ADD COLUMN IF NOT EXISTS scan_state_seq BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS scan_trigger_ack BIGINT;
+
+-- Funktion: Scan-Zustand melden (nur neuere Sequenznummern gewinnen)
+CREATE OR REPLACE FUNCTION report_scan_state(
+    p_connector_id UUID,
+    p_seq BIGINT,
+    p_state JSONB
+)
+RETURNS BOOLEAN AS $$
+BEGIN
+    UPDATE connectors
+    SET
+        scan_state = p_state,
+        scan_state_seq = p_seq
+    WHERE id = p_connector_id
+    AND scan_state_seq < p_seq;
+
+    RETURN FOUND;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER;
+
+-- Funktion: Scan-Trigger quittieren (config.trigger_scan bleibt unverändert)
+CREATE OR REPLACE FUNCTION acknowledge_scan_trigger(
+    p_connector_id UUID,
+    p_trigger BIGINT
+)
+RETURNS VOID AS $$
+BEGIN
+    UPDATE connectors
+    SET scan_trigger_ack = GREATEST(COALESCE(scan_trigger_ack, 0), p_trigger)
+    WHERE id = p_connector_id;
+END;
+$$ LANGUAGE plpgsql SECURITY DEFINER;
+
+GRANT EXECUTE ON FUNCTION report_scan_state TO anon, authenticated;
+GRANT EXECUTE ON FUNCTION acknowledge_scan_trigger TO anon, authenticated;
+
+COMMENT ON COLUMN connectors.scan_state IS 'Aktueller Scan-Zustand des Agents (scanning, current, total, status)';
+COMMENT ON COLUMN connectors.scan_state_seq IS 'Sequenznummer des letzten scan_state Updates (monoton steigend)';
+COMMENT ON COLUMN connectors.scan_trigger_ack IS 'Zuletzt vom Agent ausgeführter config.trigger_scan Wert';
+COMMENT ON FUNCTION report_scan_state IS 'Agent meldet Scan-Fortschritt. Ältere Sequenznummern werden verworfen.';
+COMMENT ON FUNCTION acknowledge_scan_trigger IS 'Agent quittiert einen Scan-Trigger aus der UI.';
@@ -0,0 +1,111 @@
+-- Scan Runs
+-- Jeder Scan-Lauf des Agents (konfigurierte Targets oder Netzwerk-Discovery)
+-- wird als eigener Datensatz mit Statistiken und Ergebnis pro Endpoint gespeichert.
+
+-- ============================================================================
+-- 1. Scan-Läufe
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS scan_runs (
+    id UUID PRIMARY KEY, -- wird vom Agent erzeugt
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE NOT NULL,
+    connector_id UUID REFERENCES connectors(id) ON DELETE CASCADE NOT NULL,
+    trigger TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual', 'command')),
+    mode TEXT NOT NULL CHECK (mode IN ('targets', 'discovery')),
+    config_version BIGINT,
+    status TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed', 'cancelled')),
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    endpoints_total INTEGER NOT NULL DEFAULT 0,
+    endpoints_succeeded INTEGER NOT NULL DEFAULT 0,
+    endpoints_failed INTEGER NOT NULL DEFAULT 0,
+    certificates_found INTEGER NOT NULL DEFAULT 0,
+    hosts_found INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_runs_tenant_id ON scan_runs(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_scan_runs_connector_started ON scan_runs(connector_id, started_at DESC);
+
+CREATE TRIGGER update_scan_runs_updated_at BEFORE UPDATE ON scan_runs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- ============================================================================
+-- 2. Ergebnis pro Endpoint
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS scan_run_endpoints (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    run_id UUID REFERENCES scan_runs(id) ON DELETE CASCADE NOT NULL,
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE NOT NULL,
+    asset_id UUID REFERENCES assets(id) ON DELETE SET NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('success', 'failed')),
+    error_class TEXT,
+    error_message TEXT,
+    certificate_fingerprint TEXT,
+    duration_ms INTEGER,
+    scanned_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_run_endpoints_run_id ON scan_run_endpoints(run_id);
+CREATE INDEX IF NOT EXISTS idx_scan_run_endpoints_asset_id ON scan_run_endpoints(asset_id);
+
+-- ============================================================================
+-- 3. Zertifikate und Checks verweisen auf den Lauf
+-- ============================================================================
+ALTER TABLE certificates
+  ADD COLUMN IF NOT EXISTS last_scan_run_id UUID REFERENCES scan_runs(id) ON DELETE SET NULL;
+
+ALTER TABLE checks
+  ADD COLUMN IF NOT EXISTS scan_run_id UUID REFERENCES scan_runs(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS asset_id UUID REFERENCES assets(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_checks_scan_run_id ON checks(scan_run_id);
+
+-- ============================================================================
+-- 4. Assets eindeutig pro Tenant + Host + Port
+-- ============================================================================
+-- Bisher hat jeder Scan ein neues Asset angelegt. Duplikate zusammenführen:
+-- Referenzen auf das älteste Asset umbiegen, dann Duplikate löschen.
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY tenant_id, host, port ORDER BY created_at, id) AS keep_id
+    FROM assets
+)
+UPDATE certificates c SET asset_id = r.keep_id
+FROM ranked r
+WHERE c.asset_id = r.id AND r.id <> r.keep_id;
+
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY tenant_id, host, port ORDER BY created_at, id) AS keep_id
+    FROM assets
+)
+UPDATE ssl_checks s SET asset_id = r.keep_id
+FROM ranked r
+WHERE s.asset_id = r.id AND r.id <> r.keep_id;
+
+WITH ranked AS (
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY tenant_id, host, port ORDER BY created_at, id) AS keep_id
+    FROM assets
+)
+DELETE FROM assets a
+USING ranked r
+WHERE a.id = r.id AND r.id <> r.keep_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_assets_tenant_host_port ON assets(tenant_id, host, port);
+
+-- ============================================================================
+-- 5. Zugriff für den Agent (wie discovery_results / agent_logs)
+-- ============================================================================
+ALTER TABLE scan_runs DISABLE ROW LEVEL SECURITY;
+ALTER TABLE scan_run_endpoints DISABLE ROW LEVEL SECURITY;
+
+GRANT ALL ON scan_runs TO anon, authenticated;
+GRANT ALL ON scan_run_endpoints TO anon, authenticated;
+GRANT INSERT ON checks TO anon;
+
+COMMENT ON TABLE scan_runs IS 'Scan-Läufe der Agents mit Trigger, Config-Version und Statistiken';
+COMMENT ON TABLE scan_run_endpoints IS 'Ergebnis pro Endpoint (host:port) eines Scan-Laufs';
+COMMENT ON COLUMN scan_runs.trigger IS 'schedule = Intervall/Start, manual = UI, command = CLI/Remote-Kommando';
+COMMENT ON COLUMN scan_run_endpoints.error_class IS 'Fehlerklasse bei status = failed';
+COMMENT ON COLUMN certificates.last_scan_run_id IS 'Scan-Lauf, in dem das Zertifikat zuletzt gesehen wurde';
@@ -0,0 +1,34 @@
+-- Klassifizierte Scan-Fehler
+-- Der Agent ordnet jeden Fehler einer Ursache zu (DNS, Port geschlossen, Timeout,
+-- TLS-Alert, kein TLS, TLS-Version, Client-Zertifikat nötig, SNI abgelehnt, ...).
+-- Damit lässt sich "Host down" von "Zertifikatsproblem" unterscheiden.
+
+-- ============================================================================
+-- 1. Endpoint-Ergebnisse: Phase und TLS-Alert
+-- ============================================================================
+ALTER TABLE scan_run_endpoints
+  ADD COLUMN IF NOT EXISTS error_phase TEXT CHECK (error_phase IN ('connect', 'handshake')),
+  ADD COLUMN IF NOT EXISTS tls_alert_code INTEGER,
+  ADD COLUMN IF NOT EXISTS tls_alert TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_scan_run_endpoints_error_class ON scan_run_endpoints(error_class) WHERE error_class IS NOT NULL;
+
+COMMENT ON COLUMN scan_run_endpoints.error_class IS 'dns, connection_refused, host_unreachable, timeout, connection_reset, tls_alert, not_tls, protocol_version, client_cert_required, sni_rejected, handshake_failed, no_certificate, backend_error, unknown';
+COMMENT ON COLUMN scan_run_endpoints.error_phase IS 'connect = Endpoint nicht erreichbar, handshake = TLS-Problem';
+COMMENT ON COLUMN scan_run_endpoints.tls_alert_code IS 'TLS-Alert-Code laut RFC 8446 (z.B. 112 = unrecognized_name)';
+
+-- ============================================================================
+-- 2. Assets: Status aus dem letzten Scan
+-- ============================================================================
+ALTER TABLE assets DROP CONSTRAINT IF EXISTS assets_status_check;
+ALTER TABLE assets ADD CONSTRAINT assets_status_check
+  CHECK (status IN ('active', 'inactive', 'error', 'unreachable'));
+
+ALTER TABLE assets
+  ADD COLUMN IF NOT EXISTS last_error_class TEXT,
+  ADD COLUMN IF NOT EXISTS last_error TEXT,
+  ADD COLUMN IF NOT EXISTS last_checked_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN assets.status IS 'active = Zertifikat gelesen, unreachable = Host/Port nicht erreichbar, error = TLS-Problem, inactive = deaktiviert';
+COMMENT ON COLUMN assets.last_error_class IS 'Fehlerklasse des letzten Scans (NULL wenn erfolgreich)';
+COMMENT ON COLUMN assets.last_error IS 'Ursprüngliche Fehlermeldung des letzten Scans';
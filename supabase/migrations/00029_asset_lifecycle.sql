@@ -0,0 +1,49 @@
+-- Asset-Lebenszyklus
+-- Der Agent zählt Fehlschläge in Folge und den letzten Erfolg pro Asset und stuft
+-- Endpoints schrittweise herab: active → degraded → unreachable → retired.
+-- Retired Assets lösen keine Ablauf-Alerts mehr aus.
+
+-- ============================================================================
+-- 1. Assets: Zustand und Zähler
+-- ============================================================================
+ALTER TABLE assets DROP CONSTRAINT IF EXISTS assets_status_check;
+ALTER TABLE assets ADD CONSTRAINT assets_status_check
+  CHECK (status IN ('active', 'degraded', 'unreachable', 'error', 'retired', 'inactive'));
+
+ALTER TABLE assets
+  ADD COLUMN IF NOT EXISTS consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS last_failure_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_assets_connector_status ON assets(connector_id, status);
+
+COMMENT ON COLUMN assets.status IS 'active = Zertifikat gelesen, degraded = einzelne Fehlschläge, unreachable = wiederholt nicht erreichbar, error = TLS-Problem, retired = lange ohne Erfolg (abgebaut), inactive = deaktiviert';
+COMMENT ON COLUMN assets.consecutive_failures IS 'Fehlgeschlagene Scans in Folge (0 nach Erfolg)';
+COMMENT ON COLUMN assets.last_success_at IS 'Zeitpunkt des letzten erfolgreichen Scans';
+
+-- ============================================================================
+-- 2. Zustandswechsel
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS asset_transitions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE NOT NULL,
+    asset_id UUID REFERENCES assets(id) ON DELETE CASCADE NOT NULL,
+    run_id UUID REFERENCES scan_runs(id) ON DELETE SET NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    from_status TEXT NOT NULL,
+    to_status TEXT NOT NULL,
+    reason TEXT,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    last_success_at TIMESTAMPTZ,
+    transitioned_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_asset_transitions_asset ON asset_transitions(asset_id, transitioned_at DESC);
+CREATE INDEX IF NOT EXISTS idx_asset_transitions_tenant ON asset_transitions(tenant_id, transitioned_at DESC);
+
+ALTER TABLE asset_transitions DISABLE ROW LEVEL SECURITY;
+GRANT ALL ON asset_transitions TO anon, authenticated;
+
+COMMENT ON TABLE asset_transitions IS 'Zustandswechsel von Assets (active/degraded/unreachable/error/retired), gemeldet vom Agent';
+COMMENT ON COLUMN asset_transitions.reason IS 'Fehlerklasse, "recovered" (wieder erfolgreich) oder "stale" (retired wegen Zeitablauf)';
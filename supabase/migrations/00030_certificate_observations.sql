@@ -0,0 +1,78 @@
+-- Zertifikats-Fundorte (n:m zwischen Zertifikaten und Endpoints)
+-- certificates.fingerprint ist eindeutig und hat nur ein asset_id - ein Wildcard-Zertifikat
+-- auf 30 Servern wanderte bisher mit jedem Scan zum zuletzt gescannten Asset.
+-- Jetzt meldet der Agent jeden Fundort (Zertifikat, Endpoint, SNI, erstmals/zuletzt gesehen).
+
+-- ============================================================================
+-- 1. Fundorte
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS certificate_observations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE NOT NULL,
+    certificate_id UUID REFERENCES certificates(id) ON DELETE CASCADE NOT NULL,
+    asset_id UUID REFERENCES assets(id) ON DELETE SET NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    sni TEXT NOT NULL DEFAULT '', -- leer bei IP-Adressen (keine SNI)
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_scan_run_id UUID REFERENCES scan_runs(id) ON DELETE SET NULL,
+    UNIQUE (certificate_id, host, port, sni)
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_observations_tenant ON certificate_observations(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_certificate_observations_asset ON certificate_observations(asset_id);
+CREATE INDEX IF NOT EXISTS idx_certificate_observations_endpoint ON certificate_observations(tenant_id, host, port);
+
+-- Agent schreibt mit dem anon-Key (wie scan_runs / discovery_results)
+ALTER TABLE certificate_observations DISABLE ROW LEVEL SECURITY;
+GRANT ALL ON certificate_observations TO anon, authenticated;
+
+-- ============================================================================
+-- 2. Bestehende Zuordnungen übernehmen
+-- ============================================================================
+INSERT INTO certificate_observations (tenant_id, certificate_id, asset_id, host, port, sni, first_seen_at, last_seen_at)
+SELECT c.tenant_id, c.id, a.id, a.host, a.port,
+       CASE WHEN a.host ~ '^[0-9.]+$' OR a.host LIKE '%:%' THEN '' ELSE a.host END,
+       c.created_at, COALESCE(c.updated_at, c.created_at)
+FROM certificates c
+JOIN assets a ON a.id = c.asset_id
+ON CONFLICT (certificate_id, host, port, sni) DO NOTHING;
+
+-- ============================================================================
+-- 3. Übersicht: wo ist ein Zertifikat überall ausgerollt?
+-- ============================================================================
+CREATE OR REPLACE VIEW certificate_deployments AS
+SELECT
+    c.id AS certificate_id,
+    c.tenant_id,
+    c.fingerprint,
+    c.subject_cn,
+    c.not_after,
+    COUNT(o.id) AS endpoint_count,
+    MIN(o.first_seen_at) AS first_seen_at,
+    MAX(o.last_seen_at) AS last_seen_at,
+    COALESCE(
+        jsonb_agg(
+            jsonb_build_object(
+                'host', o.host,
+                'port', o.port,
+                'sni', o.sni,
+                'asset_id', o.asset_id,
+                'first_seen_at', o.first_seen_at,
+                'last_seen_at', o.last_seen_at
+            ) ORDER BY o.host, o.port
+        ) FILTER (WHERE o.id IS NOT NULL),
+        '[]'::jsonb
+    ) AS endpoints
+FROM certificates c
+LEFT JOIN certificate_observations o ON o.certificate_id = c.id
+GROUP BY c.id;
+
+ALTER VIEW certificate_deployments SET (security_invoker = true);
+
+GRANT SELECT ON certificate_deployments TO authenticated;
+
+COMMENT ON TABLE certificate_observations IS 'Fundorte von Zertifikaten: Endpoint (host:port) + SNI mit erstmals/zuletzt gesehen';
+COMMENT ON VIEW certificate_deployments IS 'Zertifikate mit allen Endpoints, auf denen sie ausgeliefert werden (Planung von Wildcard-Erneuerungen)';
+COMMENT ON COLUMN certificates.asset_id IS 'Veraltet, wird vom Agent nicht mehr geschrieben - Fundorte und Assets stehen in certificate_observations';
@@ -0,0 +1,48 @@
+-- Zertifikatswechsel mit Diff
+-- Der Agent merkt sich pro Endpoint das zuletzt gesehene Zertifikat und meldet jeden
+-- Wechsel mit den geänderten Feldern. Verdächtige Wechsel (z.B. öffentliche CA →
+-- selbst signiert) werden markiert - Hinweis auf TLS-Interception oder ein
+-- missglücktes Deployment.
+
+-- ============================================================================
+-- 1. Zertifikate: Felder, die der Agent meldet
+-- ============================================================================
+ALTER TABLE certificates
+  ADD COLUMN IF NOT EXISTS signature_algorithm TEXT;
+
+COMMENT ON COLUMN certificates.is_trusted IS 'Chain endet bei einer öffentlichen CA (System-Roots des Agents)';
+COMMENT ON COLUMN certificates.is_self_signed IS 'Aussteller = Subjekt und selbst signiert';
+
+-- ============================================================================
+-- 2. Wechsel
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS certificate_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID REFERENCES tenants(id) ON DELETE CASCADE NOT NULL,
+    asset_id UUID REFERENCES assets(id) ON DELETE SET NULL,
+    run_id UUID REFERENCES scan_runs(id) ON DELETE SET NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    sni TEXT NOT NULL DEFAULT '',
+    old_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL,
+    new_certificate_id UUID REFERENCES certificates(id) ON DELETE SET NULL,
+    old_fingerprint TEXT NOT NULL,
+    new_fingerprint TEXT NOT NULL,
+    changes JSONB NOT NULL DEFAULT '[]',
+    suspicious BOOLEAN NOT NULL DEFAULT false,
+    suspicious_reasons TEXT[] NOT NULL DEFAULT '{}',
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    acknowledged_by UUID REFERENCES auth.users(id) ON DELETE SET NULL,
+    acknowledged_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificate_changes_tenant ON certificate_changes(tenant_id, detected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_certificate_changes_asset ON certificate_changes(asset_id, detected_at DESC);
+CREATE INDEX IF NOT EXISTS idx_certificate_changes_suspicious ON certificate_changes(tenant_id) WHERE suspicious AND acknowledged_at IS NULL;
+
+ALTER TABLE certificate_changes DISABLE ROW LEVEL SECURITY;
+GRANT ALL ON certificate_changes TO anon, authenticated;
+
+COMMENT ON TABLE certificate_changes IS 'Vom Agent erkannte Zertifikatswechsel pro Endpoint mit Feld-Diff';
+COMMENT ON COLUMN certificate_changes.changes IS 'Liste {field, old, new} bzw. {field: "san", added, removed} bzw. {field: "not_after", shift_days}';
+COMMENT ON COLUMN certificate_changes.suspicious_reasons IS 'public_ca_to_self_signed, trusted_to_untrusted, key_size_decreased, validity_rollback, hostname_not_covered, issuer_changed_early, key_changed_early';
@@ -0,0 +1,10 @@
+-- Offline-Meldung beim Beenden des Agents
+-- Bei SIGTERM (z.B. Rolling Update in Kubernetes) meldet sich der Agent mit Grund ab,
+-- statt minutenlang weiter als "active" zu erscheinen.
+
+ALTER TABLE connectors
+  ADD COLUMN IF NOT EXISTS offline_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS offline_reason TEXT;
+
+COMMENT ON COLUMN connectors.offline_at IS 'Zeitpunkt der letzten Abmeldung des Agents';
+COMMENT ON COLUMN connectors.offline_reason IS 'Grund der Abmeldung (z.B. "agent stopped (terminated)"); NULL solange der Agent läuft';
@@ -0,0 +1,9 @@
+-- Connector Config Status: unsupported
+-- Der Agent lehnt Configs mit einer neueren schema_version ab, als er kennt.
+-- Das ist kein Fehler in der Config, sondern ein zu alter Agent - eigener Status.
+
+ALTER TABLE connectors DROP CONSTRAINT IF EXISTS connectors_config_status_check;
+ALTER TABLE connectors
+  ADD CONSTRAINT connectors_config_status_check CHECK (config_status IN ('applied', 'rejected', 'unsupported'));
+
+COMMENT ON COLUMN connectors.config_status IS 'applied = Config aktiv, rejected = Config ungültig (siehe config_errors), unsupported = schema_version neuer als der Agent';